@@ -18,9 +18,14 @@ import (
 	"strings"
 
 	"github.com/appbaseio/reactivesearch-api/middleware"
+	"github.com/appbaseio/reactivesearch-api/middleware/classify"
 	"github.com/appbaseio/reactivesearch-api/middleware/logger"
+	"github.com/appbaseio/reactivesearch-api/middleware/ratelimiter"
+	"github.com/appbaseio/reactivesearch-api/middleware/validate"
 	"github.com/appbaseio/reactivesearch-api/plugins"
 	"github.com/appbaseio/reactivesearch-api/util"
+	"github.com/appbaseio/reactivesearch-api/util/audit"
+	"github.com/appbaseio/reactivesearch-api/util/iplookup"
 	"github.com/denisbrodbeck/machineid"
 	"github.com/gorilla/mux"
 	"github.com/pkg/profile"
@@ -163,8 +168,52 @@ func main() {
 		log.Infoln(logTag, ": reading env file", envFile, ". This may happen if the environments are declared directly : ", err)
 	}
 
+	if err := util.ValidateConfig(os.Getenv); err != nil {
+		log.Fatal(err)
+	}
+
 	router := mux.NewRouter().StrictSlash(true)
 
+	if header := os.Getenv(util.GatewayHeaderEnvName); header != "" {
+		value := os.Getenv(util.GatewayHeaderValueEnvName)
+		if value == "" {
+			log.Fatal(util.GatewayHeaderValueEnvName + " must be set along with " + util.GatewayHeaderEnvName)
+		}
+		router.Use(util.GatewayHeaderMiddleware(header, value))
+	}
+
+	if err := iplookup.InitTrustedProxies(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := util.InitHTTPClient(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := util.InitWriteRetries(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := audit.InitSyslog(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := validate.InitCategoryAccessMode(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := validate.InitIndexExistence(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := ratelimiter.InitDailyQuota(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := ratelimiter.InitIndexRateLimits(); err != nil {
+		log.Fatal(err)
+	}
+
 	if PlanRefreshInterval == "" {
 		PlanRefreshInterval = "1"
 	} else {
@@ -268,6 +317,8 @@ func main() {
 	util.NewClient()
 	util.SetDefaultIndexTemplate()
 	util.SetSystemIndexTemplate()
+	// evict the alias mappings of the deleted indices
+	classify.StartIndexAliasCacheSweep()
 	// map of specific plugins
 	sequencedPlugins := []string{"cache.so", "searchrelevancy.so", "rules.so", "functions.so", "analytics.so", "suggestions.so", "applycache.so"}
 	sequencedPluginsByPath := make(map[string]string)
@@ -352,7 +403,28 @@ func main() {
 		AllowedHeaders: []string{"*"},
 		ExposedHeaders: []string{"*"},
 	})
-	handler := c.Handler(router)
+	allowedMethods, err := util.ParseAllowedMethods(os.Getenv(util.AllowedMethodsEnvName))
+	if err != nil {
+		log.Fatal("invalid value for "+util.AllowedMethodsEnvName+": ", err)
+	}
+	maxQueryString, err := util.ParseMaxQueryString(os.Getenv(util.MaxQueryStringEnvName))
+	if err != nil {
+		log.Fatal("invalid value for "+util.MaxQueryStringEnvName+": ", err)
+	}
+	maxInFlight, err := util.ParseMaxInFlight(os.Getenv(util.MaxInFlightEnvName))
+	if err != nil {
+		log.Fatal("invalid value for "+util.MaxInFlightEnvName+": ", err)
+	}
+	responseHeaders, err := util.ParseResponseHeaders(os.Getenv(util.ResponseHeadersEnvName))
+	if err != nil {
+		log.Fatal("invalid value for "+util.ResponseHeadersEnvName+": ", err)
+	}
+	handler := util.QueryStringLimitMiddleware(maxQueryString)(c.Handler(router))
+	handler = util.AllowedMethodsMiddleware(allowedMethods...)(handler)
+	handler = util.InFlightLimitMiddleware(maxInFlight)(handler)
+	if len(responseHeaders) > 0 {
+		handler = util.ResponseHeadersMiddleware(responseHeaders, os.Getenv(util.ResponseHeadersOverrideEnvName) == "true")(handler)
+	}
 	handler = logger.Log(handler)
 
 	// Listen and serve ...
@@ -1,56 +1,196 @@
 package classify
 
+import (
+	"context"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/appbaseio/reactivesearch-api/util"
+	"github.com/robfig/cron"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	logTag                  = "[classify]"
+	envAliasCacheMaxAge     = "ALIAS_CACHE_MAX_AGE"
+	defaultAliasCacheMaxAge = 24 * time.Hour
+	aliasCacheSweepTimeout  = time.Minute
+)
+
 // IndexAliasCache cache to store index -> alias map
 var IndexAliasCache = make(map[string]string)
 
 // AliasIndexCache cache to store alias -> index map
 var AliasIndexCache = make(map[string]string)
 
-// GetIndexAliasCache get whole cache
+var (
+	// indexLastUsed stores the time in unix nanoseconds at which an index was last set or
+	// looked up in the caches, the lookups touch it atomically under the read lock
+	indexLastUsed = make(map[string]*int64)
+	cacheMu       sync.RWMutex
+)
+
+// setLastUsed records the index as used now, cacheMu must be held for writing.
+func setLastUsed(index string) {
+	now := time.Now().UnixNano()
+	indexLastUsed[index] = &now
+}
+
+// touchLastUsed records the index as used now, cacheMu must be held at least for reading.
+func touchLastUsed(index string) {
+	if lastUsed, ok := indexLastUsed[index]; ok {
+		atomic.StoreInt64(lastUsed, time.Now().UnixNano())
+	}
+}
+
+// GetIndexAliasCache get a copy of the whole cache, safe to range over while the
+// cache is updated
 func GetIndexAliasCache() map[string]string {
-	return IndexAliasCache
+	cacheMu.RLock()
+	defer cacheMu.RUnlock()
+	return copyCache(IndexAliasCache)
 }
 
 // GetIndexAlias get alias for specific index
 func GetIndexAlias(index string) string {
+	cacheMu.RLock()
+	defer cacheMu.RUnlock()
 	alias, ok := IndexAliasCache[index]
 
 	if !ok {
 		return ""
 	}
+	touchLastUsed(index)
 	return alias
 }
 
 // SetIndexAlias set alias for specific index
 func SetIndexAlias(index, alias string) {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
 	IndexAliasCache[index] = alias
+	setLastUsed(index)
 }
 
 // GetAliasIndex get index for specific alias
 func GetAliasIndex(alias string) string {
+	cacheMu.RLock()
+	defer cacheMu.RUnlock()
 	index, ok := AliasIndexCache[alias]
 	if !ok {
 		return ""
 	}
+	touchLastUsed(index)
 	return index
 }
 
 // SetAliasIndex set index for specific alias
 func SetAliasIndex(alias, index string) {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
 	AliasIndexCache[alias] = index
+	setLastUsed(index)
 }
 
 // SetAliasIndexCache set the whole cache
 func SetAliasIndexCache(data map[string]string) {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
 	AliasIndexCache = data
+	for _, index := range data {
+		setLastUsed(index)
+	}
 }
 
-// GetAliasIndexCache get the whole cache
+// GetAliasIndexCache get a copy of the whole cache
 func GetAliasIndexCache() map[string]string {
-	return AliasIndexCache
+	cacheMu.RLock()
+	defer cacheMu.RUnlock()
+	return copyCache(AliasIndexCache)
+}
+
+func copyCache(cache map[string]string) map[string]string {
+	c := make(map[string]string, len(cache))
+	for k, v := range cache {
+		c[k] = v
+	}
+	return c
 }
 
 // RemoveFromIndexAliasCache get the whole cache
 func RemoveFromIndexAliasCache(indexName string) {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
 	delete(IndexAliasCache, indexName)
+	delete(indexLastUsed, indexName)
+}
+
+// SweepIndexAliasCache evicts the mappings of the indices that haven't been used for
+// longer than maxAge and no longer exist. Indices for which the existence check fails
+// are retained until the next sweep, the ones that still exist are considered used as
+// of the sweep. It returns the evicted indices.
+func SweepIndexAliasCache(maxAge time.Duration, exists func(index string) (bool, error)) []string {
+	// collect the stale indices first to avoid holding the lock during the existence checks
+	cacheMu.RLock()
+	var stale []string
+	for index, lastUsed := range indexLastUsed {
+		if time.Since(time.Unix(0, atomic.LoadInt64(lastUsed))) > maxAge {
+			stale = append(stale, index)
+		}
+	}
+	cacheMu.RUnlock()
+
+	var evicted []string
+	for _, index := range stale {
+		ok, err := exists(index)
+		if err != nil {
+			log.Errorln(logTag, ": unable to check if index", index, "exists:", err)
+			continue
+		}
+		if ok {
+			cacheMu.RLock()
+			touchLastUsed(index)
+			cacheMu.RUnlock()
+			continue
+		}
+		cacheMu.Lock()
+		delete(IndexAliasCache, index)
+		for alias, aliasIndex := range AliasIndexCache {
+			if aliasIndex == index {
+				delete(AliasIndexCache, alias)
+			}
+		}
+		delete(indexLastUsed, index)
+		cacheMu.Unlock()
+		evicted = append(evicted, index)
+	}
+	return evicted
+}
+
+// StartIndexAliasCacheSweep periodically evicts the mappings of the deleted indices from
+// the caches. The maximum age of unused mappings can be configured with ALIAS_CACHE_MAX_AGE.
+func StartIndexAliasCacheSweep() {
+	maxAge := defaultAliasCacheMaxAge
+	if value := os.Getenv(envAliasCacheMaxAge); value != "" {
+		duration, err := time.ParseDuration(value)
+		if err != nil || duration <= 0 {
+			log.Warnln(logTag, ": invalid value for", envAliasCacheMaxAge, ", using the default:", value)
+		} else {
+			maxAge = duration
+		}
+	}
+	cronjob := cron.New()
+	cronjob.AddFunc("@every "+maxAge.String(), func() {
+		evicted := SweepIndexAliasCache(maxAge, func(index string) (bool, error) {
+			ctx, cancel := context.WithTimeout(context.Background(), aliasCacheSweepTimeout)
+			defer cancel()
+			return util.GetClient7().IndexExists(index).Do(ctx)
+		})
+		if len(evicted) > 0 {
+			log.Println(logTag, ": evicted alias mappings for deleted indices", evicted)
+		}
+	})
+	cronjob.Start()
 }
@@ -0,0 +1,78 @@
+package classify
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSweepIndexAliasCache(t *testing.T) {
+	Convey("Sweeping the alias cache", t, func() {
+		SetIndexAlias("books-000001", "books")
+		SetAliasIndex("books", "books-000001")
+		SetIndexAlias("movies-000001", "movies")
+		SetAliasIndex("movies", "movies-000001")
+		SetIndexAlias("songs-000001", "songs")
+		Reset(func() {
+			for _, index := range []string{"books-000001", "movies-000001", "songs-000001"} {
+				RemoveFromIndexAliasCache(index)
+				delete(indexLastUsed, index)
+			}
+			delete(AliasIndexCache, "books")
+			delete(AliasIndexCache, "movies")
+		})
+
+		// books-000001 was deleted, the rest are still present
+		exists := func(index string) (bool, error) {
+			if index == "songs-000001" {
+				return false, errors.New("connection refused")
+			}
+			return index != "books-000001", nil
+		}
+
+		Convey("should retain the recently used mappings", func() {
+			evicted := SweepIndexAliasCache(time.Hour, exists)
+			So(evicted, ShouldBeEmpty)
+			So(GetIndexAlias("books-000001"), ShouldEqual, "books")
+		})
+
+		Convey("should evict the stale mappings of the deleted indices", func() {
+			time.Sleep(10 * time.Millisecond)
+			GetIndexAlias("movies-000001")
+			evicted := SweepIndexAliasCache(5*time.Millisecond, exists)
+			So(evicted, ShouldResemble, []string{"books-000001"})
+			So(GetIndexAlias("books-000001"), ShouldBeEmpty)
+			So(GetAliasIndex("books"), ShouldBeEmpty)
+			So(GetIndexAlias("movies-000001"), ShouldEqual, "movies")
+			So(GetAliasIndex("movies"), ShouldEqual, "movies-000001")
+			So(GetIndexAlias("songs-000001"), ShouldEqual, "songs")
+		})
+
+		Convey("should not check again the indices found to still exist", func() {
+			time.Sleep(10 * time.Millisecond)
+			SweepIndexAliasCache(5*time.Millisecond, exists)
+			var checked []string
+			SweepIndexAliasCache(5*time.Millisecond, func(index string) (bool, error) {
+				checked = append(checked, index)
+				return true, nil
+			})
+			// songs-000001 couldn't be checked, so it's still stale
+			So(checked, ShouldResemble, []string{"songs-000001"})
+		})
+
+		Convey("should forget the removed indices", func() {
+			RemoveFromIndexAliasCache("songs-000001")
+			So(indexLastUsed, ShouldNotContainKey, "songs-000001")
+		})
+
+		Convey("should hand out a copy of the cache unaffected by the sweep", func() {
+			cache := GetIndexAliasCache()
+			time.Sleep(10 * time.Millisecond)
+			SweepIndexAliasCache(5*time.Millisecond, exists)
+			So(cache, ShouldContainKey, "books-000001")
+			So(GetIndexAliasCache(), ShouldNotContainKey, "books-000001")
+		})
+	})
+}
@@ -0,0 +1,144 @@
+package coalesce
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/appbaseio/reactivesearch-api/middleware"
+	"github.com/appbaseio/reactivesearch-api/model/op"
+	"github.com/appbaseio/reactivesearch-api/model/queuetime"
+	"github.com/appbaseio/reactivesearch-api/util"
+)
+
+const (
+	logTag            = "[coalesce]"
+	envCoalesceReads  = "COALESCE_READ_REQUESTS"
+	headerCoalesced   = "X-Coalesced-Request"
+	coalescedResponse = "true"
+)
+
+var (
+	instance *Coalescer
+	once     sync.Once
+)
+
+// call is an in-flight request whose response is shared with the identical requests.
+type call struct {
+	done     chan struct{}
+	response *httptest.ResponseRecorder
+}
+
+// Coalescer coalesces the concurrent identical read requests into a single upstream
+// request. Creating direct instances of Coalescer should be avoided, coalesce.Instance
+// returns the singleton instance of the Coalescer.
+type Coalescer struct {
+	mu      sync.Mutex
+	enabled bool
+	calls   map[string]*call
+}
+
+// Instance returns the singleton instance of the coalescer.
+func Instance() *Coalescer {
+	once.Do(func() {
+		instance = &Coalescer{
+			enabled: os.Getenv(envCoalesceReads) == "true",
+			calls:   make(map[string]*call),
+		}
+	})
+	return instance
+}
+
+// Coalesce returns a middleware that coalesces the concurrent identical read requests,
+// sharing the response of the first one with the rest. It is enabled by setting
+// COALESCE_READ_REQUESTS to true.
+func Coalesce() middleware.Middleware {
+	return Instance().coalesce
+}
+
+func (c *Coalescer) coalesce(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		reqOp, err := op.FromContext(req.Context())
+		if err != nil {
+			log.Errorln(logTag, ":", err)
+			util.WriteBackError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		// only the idempotent reads can share a response
+		if !c.enabled || *reqOp != op.Read || (req.Method != http.MethodGet && req.Method != http.MethodPost) {
+			h(w, req)
+			return
+		}
+
+		key, err := requestKey(req)
+		if err != nil {
+			log.Errorln(logTag, ":", err)
+			util.WriteBackError(w, "unable to read request body", http.StatusBadRequest)
+			return
+		}
+
+		c.mu.Lock()
+		if inflight, ok := c.calls[key]; ok {
+			c.mu.Unlock()
+			start := time.Now()
+			<-inflight.done
+			queuetime.Add(req.Context(), time.Since(start))
+			w.Header().Set(headerCoalesced, coalescedResponse)
+			writeResponse(w, inflight.response)
+			return
+		}
+		inflight := &call{done: make(chan struct{})}
+		c.calls[key] = inflight
+		c.mu.Unlock()
+
+		defer func() {
+			c.mu.Lock()
+			delete(c.calls, key)
+			c.mu.Unlock()
+			// the waiters of a panicking request get a 500 response
+			if inflight.response == nil {
+				inflight.response = httptest.NewRecorder()
+				util.WriteBackError(inflight.response, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			}
+			close(inflight.done)
+		}()
+		respRecorder := httptest.NewRecorder()
+		h(respRecorder, req)
+		inflight.response = respRecorder
+		writeResponse(w, respRecorder)
+	}
+}
+
+// requestKey hashes everything that can affect the response of a request, including
+// the credentials since the responses are filtered per credential.
+func requestKey(req *http.Request) (string, error) {
+	hash := sha256.New()
+	hash.Write([]byte(req.Method + " " + req.URL.RequestURI() + "\n"))
+	hash.Write([]byte(req.Header.Get("Authorization") + "\n"))
+	if req.Body != nil {
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return "", err
+		}
+		req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		hash.Write(body)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func writeResponse(w http.ResponseWriter, response *httptest.ResponseRecorder) {
+	for k, v := range response.Header() {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(response.Code)
+	w.Write(response.Body.Bytes())
+}
@@ -0,0 +1,70 @@
+package coalesce
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/appbaseio/reactivesearch-api/model/op"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func newTestRequest(reqOp op.Operation, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/books/_search", strings.NewReader(body))
+	return req.WithContext(op.NewContext(req.Context(), &reqOp))
+}
+
+func TestCoalesce(t *testing.T) {
+	Convey("Coalesce identical requests", t, func() {
+		c := &Coalescer{enabled: true, calls: make(map[string]*call)}
+		var upstreamCalls int32
+		release := make(chan struct{})
+		handler := c.coalesce(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&upstreamCalls, 1)
+			<-release
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"hits":{"total":1}}`))
+		})
+
+		serveConcurrently := func(n int, reqOp op.Operation, body func(i int) string) []*httptest.ResponseRecorder {
+			responses := make([]*httptest.ResponseRecorder, n)
+			var wg sync.WaitGroup
+			for i := 0; i < n; i++ {
+				wg.Add(1)
+				responses[i] = httptest.NewRecorder()
+				go func(i int) {
+					defer wg.Done()
+					handler(responses[i], newTestRequest(reqOp, body(i)))
+				}(i)
+			}
+			// give the requests time to pile up behind the first one
+			time.Sleep(50 * time.Millisecond)
+			close(release)
+			wg.Wait()
+			return responses
+		}
+
+		Convey("Concurrent identical reads result in a single upstream call", func() {
+			responses := serveConcurrently(10, op.Read, func(int) string { return `{"query":{"match_all":{}}}` })
+			So(atomic.LoadInt32(&upstreamCalls), ShouldEqual, 1)
+			for _, w := range responses {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Body.String(), ShouldEqual, `{"hits":{"total":1}}`)
+				So(w.Header().Get("Content-Type"), ShouldEqual, "application/json")
+			}
+		})
+		Convey("Different reads aren't coalesced", func() {
+			serveConcurrently(3, op.Read, func(i int) string { return strings.Repeat(" ", i) + `{}` })
+			So(atomic.LoadInt32(&upstreamCalls), ShouldEqual, 3)
+		})
+		Convey("Writes aren't coalesced", func() {
+			serveConcurrently(3, op.Write, func(int) string { return `{"title":"dune"}` })
+			So(atomic.LoadInt32(&upstreamCalls), ShouldEqual, 3)
+		})
+	})
+}
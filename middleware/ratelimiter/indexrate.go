@@ -0,0 +1,126 @@
+package ratelimiter
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/appbaseio/reactivesearch-api/middleware"
+	"github.com/appbaseio/reactivesearch-api/model/index"
+	"github.com/appbaseio/reactivesearch-api/util"
+)
+
+const envIndexRateLimits = "INDEX_RATE_LIMITS"
+
+// indexRateLimit is the number of requests per second an index matching the pattern can receive.
+type indexRateLimit struct {
+	pattern string
+	limit   int64
+}
+
+// parseIndexRateLimits parses a comma separated list of index pattern to requests per
+// second, e.g. "books:100,logs-*:20". An index is limited by the first pattern it matches.
+func parseIndexRateLimits(value string) ([]indexRateLimit, error) {
+	var limits []indexRateLimit
+	for _, token := range strings.Split(value, ",") {
+		token = strings.TrimSpace(token)
+		if token == "" {
+			continue
+		}
+		i := strings.LastIndex(token, ":")
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid index rate limit: %s", token)
+		}
+		pattern := strings.TrimSpace(token[:i])
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid index pattern: %s", pattern)
+		}
+		limit, err := strconv.ParseInt(strings.TrimSpace(token[i+1:]), 10, 64)
+		if err != nil || limit <= 0 {
+			return nil, fmt.Errorf("invalid index rate limit: %s", token)
+		}
+		limits = append(limits, indexRateLimit{pattern: pattern, limit: limit})
+	}
+	return limits, nil
+}
+
+// indexRateLimitFor returns the requests per second the index can receive, zero if unlimited.
+func (rl *Ratelimiter) indexRateLimitFor(indexName string) int64 {
+	for _, l := range rl.indexRateLimits {
+		if matched, _ := path.Match(l.pattern, indexName); matched {
+			return l.limit
+		}
+	}
+	return 0
+}
+
+// InitIndexRateLimits reads the rate limits of the indices from INDEX_RATE_LIMITS.
+func InitIndexRateLimits() error {
+	value := os.Getenv(envIndexRateLimits)
+	if value == "" {
+		return nil
+	}
+	limits, err := parseIndexRateLimits(value)
+	if err != nil {
+		return fmt.Errorf("invalid value for %s: %v", envIndexRateLimits, err)
+	}
+	rl := Instance()
+	rl.Lock()
+	defer rl.Unlock()
+	rl.indexRateLimits = limits
+	return nil
+}
+
+// IndexRate middleware limits the requests per second each index can receive, across
+// all the credentials, as per INDEX_RATE_LIMITS. The requests to an index over its limit
+// are rejected with a 429, while the other indices are unaffected.
+func IndexRate() middleware.Middleware {
+	return Instance().limitIndexRate
+}
+
+func (rl *Ratelimiter) limitIndexRate(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if len(rl.indexRateLimits) == 0 {
+			h(w, req)
+			return
+		}
+		reqIndices, err := index.FromContext(req.Context())
+		if err != nil {
+			h(w, req)
+			return
+		}
+
+		// a request is either counted against all of its indices or none of them, the
+		// concurrent requests mustn't be let through by the same remaining capacity
+		rl.indexRateMu.Lock()
+		for _, indexName := range reqIndices {
+			limit := rl.indexRateLimitFor(indexName)
+			if limit == 0 {
+				continue
+			}
+			if rem, _ := rl.peekLimit(indexRateKey(indexName), limit, time.Second); rem <= 0 {
+				rl.indexRateMu.Unlock()
+				w.Header().Set("Retry-After", "1")
+				msg := fmt.Sprintf("rate limit of %d requests per second of index %s exceeded", limit, indexName)
+				util.WriteBackMessage(w, msg, http.StatusTooManyRequests)
+				return
+			}
+		}
+		for _, indexName := range reqIndices {
+			if limit := rl.indexRateLimitFor(indexName); limit != 0 {
+				rl.limit(indexRateKey(indexName), limit, time.Second)
+			}
+		}
+		rl.indexRateMu.Unlock()
+
+		h(w, req)
+	}
+}
+
+func indexRateKey(indexName string) string {
+	return "index_rate:" + indexName
+}
@@ -0,0 +1,85 @@
+package ratelimiter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/appbaseio/reactivesearch-api/model/index"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/ulule/limiter"
+)
+
+func TestIndexRate(t *testing.T) {
+	Convey("Per-index rate limits", t, func() {
+		limits, err := parseIndexRateLimits("books:2, logs-*:1")
+		So(err, ShouldBeNil)
+		rl := &Ratelimiter{
+			limiters:        make(map[string]*limiter.Limiter),
+			indexRateLimits: limits,
+		}
+		serve := func(indices ...string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodPost, "/_search", nil)
+			ctx := index.NewContext(req.Context(), indices)
+			w := httptest.NewRecorder()
+			rl.limitIndexRate(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})(w, req.WithContext(ctx))
+			return w
+		}
+
+		Convey("Requests to a saturated index are throttled", func() {
+			So(serve("books").Code, ShouldEqual, http.StatusOK)
+			So(serve("books").Code, ShouldEqual, http.StatusOK)
+			w := serve("books")
+			So(w.Code, ShouldEqual, http.StatusTooManyRequests)
+			So(w.Header().Get("Retry-After"), ShouldEqual, "1")
+
+			Convey("Requests to another index pass", func() {
+				So(serve("movies").Code, ShouldEqual, http.StatusOK)
+				So(serve("logs-1").Code, ShouldEqual, http.StatusOK)
+			})
+			Convey("A request to several indices is throttled by the saturated one", func() {
+				So(serve("logs-1", "books").Code, ShouldEqual, http.StatusTooManyRequests)
+				// the rejected request wasn't counted against the other index
+				So(serve("logs-1").Code, ShouldEqual, http.StatusOK)
+			})
+		})
+		Convey("Concurrent requests don't exceed the limit", func() {
+			var wg sync.WaitGroup
+			var served int32
+			for i := 0; i < 20; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if serve("books").Code == http.StatusOK {
+						atomic.AddInt32(&served, 1)
+					}
+				}()
+			}
+			wg.Wait()
+			So(served, ShouldEqual, 2)
+		})
+		Convey("The indices matching a pattern are limited separately", func() {
+			So(serve("logs-1").Code, ShouldEqual, http.StatusOK)
+			So(serve("logs-2").Code, ShouldEqual, http.StatusOK)
+			So(serve("logs-1").Code, ShouldEqual, http.StatusTooManyRequests)
+		})
+		Convey("Invalid limits are rejected", func() {
+			for _, value := range []string{"books", "books:0", "books:x", "[:1"} {
+				_, err := parseIndexRateLimits(value)
+				So(err, ShouldNotBeNil)
+			}
+		})
+		Convey("Invalid limits fail the init", func() {
+			os.Setenv(envIndexRateLimits, "books:x")
+			Reset(func() { os.Unsetenv(envIndexRateLimits) })
+			err := InitIndexRateLimits()
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "invalid value for INDEX_RATE_LIMITS")
+		})
+	})
+}
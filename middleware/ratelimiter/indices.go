@@ -0,0 +1,143 @@
+package ratelimiter
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/appbaseio/reactivesearch-api/middleware"
+	"github.com/appbaseio/reactivesearch-api/model/credential"
+	"github.com/appbaseio/reactivesearch-api/model/index"
+	"github.com/appbaseio/reactivesearch-api/model/op"
+	"github.com/appbaseio/reactivesearch-api/model/permission"
+	"github.com/appbaseio/reactivesearch-api/model/user"
+	"github.com/appbaseio/reactivesearch-api/util"
+)
+
+const (
+	envIndexCreationLimit      = "INDEX_CREATION_LIMIT"
+	envIndexCreationWindow     = "INDEX_CREATION_WINDOW"
+	defaultIndexCreationWindow = time.Hour
+)
+
+// IndexCreations middleware limits the number of indices a credential can create within
+// a window. Write requests to the indices that don't exist yet are counted as creations,
+// since elasticsearch creates them automatically. Requests beyond INDEX_CREATION_LIMIT
+// per INDEX_CREATION_WINDOW are rejected, the limit is disabled if it isn't set.
+func IndexCreations() middleware.Middleware {
+	rl := Instance()
+	rl.Lock()
+	defer rl.Unlock()
+	if value := os.Getenv(envIndexCreationLimit); value != "" {
+		limit, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || limit < 0 {
+			log.Errorln(logTag, ": invalid value for", envIndexCreationLimit, ":", value)
+		} else {
+			rl.indexCreationLimit = limit
+		}
+	}
+	rl.indexCreationWindow = defaultIndexCreationWindow
+	if value := os.Getenv(envIndexCreationWindow); value != "" {
+		window, err := time.ParseDuration(value)
+		if err != nil || window <= 0 {
+			log.Errorln(logTag, ": invalid value for", envIndexCreationWindow, ":", value)
+		} else {
+			rl.indexCreationWindow = window
+		}
+	}
+	if rl.indexExists == nil {
+		rl.indexExists = func(ctx context.Context, name string) (bool, error) {
+			return util.GetClient7().IndexExists(name).Do(ctx)
+		}
+	}
+	return rl.limitIndexCreations
+}
+
+func (rl *Ratelimiter) limitIndexCreations(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+
+		reqOp, err := op.FromContext(ctx)
+		if err != nil {
+			log.Errorln(logTag, ":", err)
+			util.WriteBackError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if rl.indexCreationLimit == 0 || *reqOp != op.Write {
+			h(w, req)
+			return
+		}
+
+		username, err := credentialUsername(ctx)
+		if err != nil {
+			log.Errorln(logTag, ":", err)
+			util.WriteBackError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		reqIndices, err := index.FromContext(ctx)
+		if err != nil {
+			log.Errorln(logTag, ":", err)
+			util.WriteBackError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		key := fmt.Sprintf("%s:index_creations", username)
+		for _, name := range reqIndices {
+			// patterns can't create an index
+			if strings.Contains(name, "*") || strings.HasPrefix(name, "_") {
+				continue
+			}
+			exists, err := rl.indexExists(ctx, name)
+			if err != nil {
+				// let elasticsearch deal with the request if the index can't be checked
+				log.Errorln(logTag, ": unable to check if index", name, "exists:", err)
+				continue
+			}
+			if exists {
+				continue
+			}
+			if rl.limitExceededByIndexCreations(key) {
+				msg := fmt.Sprintf("index creation limit of %d per %s exceeded", rl.indexCreationLimit, rl.indexCreationWindow)
+				util.WriteBackMessage(w, msg, http.StatusTooManyRequests)
+				return
+			}
+		}
+
+		h(w, req)
+	}
+}
+
+func (rl *Ratelimiter) limitExceededByIndexCreations(key string) bool {
+	rem, _ := rl.peekLimit(key, rl.indexCreationLimit, rl.indexCreationWindow)
+	if rem <= 0 {
+		return true
+	}
+	rl.limit(key, rl.indexCreationLimit, rl.indexCreationWindow)
+	return false
+}
+
+// credentialUsername returns the username of the user or permission making the request.
+func credentialUsername(ctx context.Context) (string, error) {
+	reqCredential, err := credential.FromContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	if reqCredential == credential.Permission {
+		reqPermission, err := permission.FromContext(ctx)
+		if err != nil {
+			return "", err
+		}
+		return reqPermission.Username, nil
+	}
+	reqUser, err := user.FromContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	return reqUser.Username, nil
+}
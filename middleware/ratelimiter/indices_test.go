@@ -0,0 +1,61 @@
+package ratelimiter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/appbaseio/reactivesearch-api/model/credential"
+	"github.com/appbaseio/reactivesearch-api/model/index"
+	"github.com/appbaseio/reactivesearch-api/model/op"
+	"github.com/appbaseio/reactivesearch-api/model/permission"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/ulule/limiter"
+)
+
+func TestIndexCreations(t *testing.T) {
+	Convey("Index creations", t, func() {
+		rl := &Ratelimiter{
+			limiters:            make(map[string]*limiter.Limiter),
+			indexCreationLimit:  2,
+			indexCreationWindow: time.Hour,
+			indexExists: func(ctx context.Context, name string) (bool, error) {
+				return name == "books", nil
+			},
+		}
+		p, err := permission.New("admin")
+		So(err, ShouldBeNil)
+
+		serve := func(reqOp op.Operation, indices ...string) int {
+			req := httptest.NewRequest(http.MethodPost, "/"+indices[0]+"/_doc", nil)
+			ctx := credential.NewContext(req.Context(), credential.Permission)
+			ctx = permission.NewContext(ctx, p)
+			ctx = op.NewContext(ctx, &reqOp)
+			ctx = index.NewContext(ctx, indices)
+			w := httptest.NewRecorder()
+			rl.limitIndexCreations(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})(w, req.WithContext(ctx))
+			return w.Code
+		}
+
+		Convey("Creations under the limit pass", func() {
+			So(serve(op.Write, "logs-1"), ShouldEqual, http.StatusOK)
+			So(serve(op.Write, "logs-2"), ShouldEqual, http.StatusOK)
+		})
+		Convey("Creations over the limit are rejected", func() {
+			So(serve(op.Write, "logs-1"), ShouldEqual, http.StatusOK)
+			So(serve(op.Write, "logs-2"), ShouldEqual, http.StatusOK)
+			So(serve(op.Write, "logs-3"), ShouldEqual, http.StatusTooManyRequests)
+
+			Convey("Writes to the existing indices aren't limited", func() {
+				So(serve(op.Write, "books"), ShouldEqual, http.StatusOK)
+			})
+			Convey("Reads aren't limited", func() {
+				So(serve(op.Read, "logs-4"), ShouldEqual, http.StatusOK)
+			})
+		})
+	})
+}
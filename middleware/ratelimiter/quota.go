@@ -0,0 +1,134 @@
+package ratelimiter
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/appbaseio/reactivesearch-api/middleware"
+	"github.com/appbaseio/reactivesearch-api/model/credential"
+	"github.com/appbaseio/reactivesearch-api/model/permission"
+	"github.com/appbaseio/reactivesearch-api/util"
+)
+
+const (
+	envDailyQuotaTimezone = "DAILY_QUOTA_TIMEZONE"
+	// headerDailyQuotaRemaining is the number of the requests left for the day
+	headerDailyQuotaRemaining = "X-Daily-Quota-Remaining"
+)
+
+// quotaCounter counts the requests of each credential during the current calendar day
+// of the configured timezone, the counts are reset at its midnight.
+type quotaCounter struct {
+	mu       sync.Mutex
+	location *time.Location
+	day      string
+	counts   map[string]int64
+	now      func() time.Time
+}
+
+func newQuotaCounter(location *time.Location) *quotaCounter {
+	return &quotaCounter{
+		location: location,
+		counts:   make(map[string]int64),
+		now:      time.Now,
+	}
+}
+
+// take counts a request of the username against the quota, it returns the requests
+// left for the day and false if the quota is already exhausted.
+func (q *quotaCounter) take(username string, quota int64) (int64, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if day := q.now().In(q.location).Format("2006-01-02"); day != q.day {
+		q.day = day
+		q.counts = make(map[string]int64)
+	}
+	if q.counts[username] >= quota {
+		return 0, false
+	}
+	q.counts[username]++
+	return quota - q.counts[username], true
+}
+
+// untilReset returns the time left until the counts are reset.
+func (q *quotaCounter) untilReset() time.Duration {
+	now := q.now().In(q.location)
+	year, month, day := now.Date()
+	return time.Date(year, month, day+1, 0, 0, 0, 0, q.location).Sub(now)
+}
+
+// quotaLocation is the timezone at whose midnight the daily quotas are reset.
+var quotaLocation = time.UTC
+
+// InitDailyQuota reads the timezone of the daily quotas from DAILY_QUOTA_TIMEZONE.
+func InitDailyQuota() error {
+	location, err := parseQuotaTimezone(os.Getenv(envDailyQuotaTimezone))
+	if err != nil {
+		return err
+	}
+	quotaLocation = location
+	return nil
+}
+
+func parseQuotaTimezone(value string) (*time.Location, error) {
+	if value == "" {
+		return time.UTC, nil
+	}
+	location, err := time.LoadLocation(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value for %s: %s", envDailyQuotaTimezone, value)
+	}
+	return location, nil
+}
+
+// DailyQuota middleware limits the number of requests a permission can make per calendar
+// day to its daily_quota, the requests beyond it are rejected with a 429 until the
+// midnight of DAILY_QUOTA_TIMEZONE, UTC by default. The requests left for the day are
+// set in the X-Daily-Quota-Remaining header. The counts are kept in memory.
+func DailyQuota() middleware.Middleware {
+	rl := Instance()
+	rl.Lock()
+	defer rl.Unlock()
+	if rl.quotas == nil {
+		rl.quotas = newQuotaCounter(quotaLocation)
+	}
+	return rl.limitDailyQuota
+}
+
+func (rl *Ratelimiter) limitDailyQuota(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		if reqCredential, err := credential.FromContext(ctx); err != nil || reqCredential != credential.Permission {
+			h(w, req)
+			return
+		}
+		reqPermission, err := permission.FromContext(ctx)
+		if err != nil {
+			log.Errorln(logTag, ":", err)
+			util.WriteBackError(w, "An error occurred while validating daily quota", http.StatusInternalServerError)
+			return
+		}
+		if reqPermission.DailyQuota == nil || *reqPermission.DailyQuota == 0 {
+			h(w, req)
+			return
+		}
+
+		remaining, ok := rl.quotas.take(reqPermission.Username, *reqPermission.DailyQuota)
+		w.Header().Set(headerDailyQuotaRemaining, strconv.FormatInt(remaining, 10))
+		if !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(rl.quotas.untilReset().Seconds()))))
+			msg := fmt.Sprintf("daily quota of %d requests exhausted", *reqPermission.DailyQuota)
+			util.WriteBackMessage(w, msg, http.StatusTooManyRequests)
+			return
+		}
+
+		h(w, req)
+	}
+}
@@ -0,0 +1,79 @@
+package ratelimiter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/appbaseio/reactivesearch-api/model/credential"
+	"github.com/appbaseio/reactivesearch-api/model/permission"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDailyQuota(t *testing.T) {
+	Convey("Daily quota", t, func() {
+		location := time.FixedZone("UTC+5", 5*60*60)
+		// 23:00 of the quota timezone
+		now := time.Date(2021, 6, 30, 18, 0, 0, 0, time.UTC)
+		rl := &Ratelimiter{quotas: newQuotaCounter(location)}
+		rl.quotas.now = func() time.Time { return now }
+		handler := rl.limitDailyQuota(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})
+		quota := int64(2)
+		serve := func(p *permission.Permission) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodPost, "/books/_search", nil)
+			ctx := credential.NewContext(req.Context(), credential.Permission)
+			ctx = permission.NewContext(ctx, p)
+			w := httptest.NewRecorder()
+			handler(w, req.WithContext(ctx))
+			return w
+		}
+		limited := &permission.Permission{Username: "foo", DailyQuota: &quota}
+
+		Convey("Requests up to the quota pass", func() {
+			w := serve(limited)
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(w.Header().Get(headerDailyQuotaRemaining), ShouldEqual, "1")
+			w = serve(limited)
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(w.Header().Get(headerDailyQuotaRemaining), ShouldEqual, "0")
+
+			Convey("The next one is rejected until the midnight of the timezone", func() {
+				w := serve(limited)
+				So(w.Code, ShouldEqual, http.StatusTooManyRequests)
+				So(w.Header().Get(headerDailyQuotaRemaining), ShouldEqual, "0")
+				So(w.Header().Get("Retry-After"), ShouldEqual, "3600")
+			})
+			Convey("Other credentials aren't limited", func() {
+				So(serve(&permission.Permission{Username: "bar", DailyQuota: &quota}).Code, ShouldEqual, http.StatusOK)
+			})
+			Convey("The counter resets at the day boundary", func() {
+				now = now.Add(time.Hour)
+				w := serve(limited)
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Header().Get(headerDailyQuotaRemaining), ShouldEqual, "1")
+			})
+		})
+		Convey("Permissions without a quota aren't counted", func() {
+			for i := 0; i < 5; i++ {
+				w := serve(&permission.Permission{Username: "baz"})
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Header().Get(headerDailyQuotaRemaining), ShouldBeEmpty)
+			}
+		})
+	})
+
+	Convey("The daily quota timezone", t, func() {
+		location, err := parseQuotaTimezone("")
+		So(err, ShouldBeNil)
+		So(location, ShouldEqual, time.UTC)
+		location, err = parseQuotaTimezone("America/New_York")
+		So(err, ShouldBeNil)
+		So(location.String(), ShouldEqual, "America/New_York")
+		_, err = parseQuotaTimezone("Mars/Olympus")
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "invalid value for DAILY_QUOTA_TIMEZONE")
+	})
+}
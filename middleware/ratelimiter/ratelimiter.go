@@ -38,6 +38,19 @@ var (
 type Ratelimiter struct {
 	sync.Mutex
 	limiters map[string]*limiter.Limiter
+	// maximum number of indices a credential can create per indexCreationWindow
+	indexCreationLimit  int64
+	indexCreationWindow time.Duration
+	indexExists         func(ctx context.Context, name string) (bool, error)
+	// maximum number of scrolls a credential can keep open at once
+	maxOpenScrolls int
+	scrolls        *scrollTracker
+	// requests of the permissions during the current day, counted against their daily quota
+	quotas *quotaCounter
+	// requests per second the indices matching each pattern can receive
+	indexRateLimits []indexRateLimit
+	// indexRateMu makes checking and counting a request against its indices atomic
+	indexRateMu sync.Mutex
 }
 
 // Instance returns the singleton instance of ratelimiter.
@@ -0,0 +1,272 @@
+package ratelimiter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/appbaseio/reactivesearch-api/middleware"
+	"github.com/appbaseio/reactivesearch-api/util"
+)
+
+const (
+	envMaxOpenScrolls = "MAX_OPEN_SCROLLS"
+	// defaultScrollKeepAlive is the keep alive of the scrolls whose keep alive can't be parsed
+	defaultScrollKeepAlive = 5 * time.Minute
+	// maxScrollIDBytes bounds the response bytes buffered to read the scroll id from,
+	// elasticsearch writes it first
+	maxScrollIDBytes = 64 * 1024
+)
+
+// scrollTracker records the scroll ids opened by each credential along with the time
+// they expire at, so that the scrolls cleared or expired no longer count as open.
+type scrollTracker struct {
+	mu      sync.Mutex
+	scrolls map[string]map[string]time.Time
+	now     func() time.Time
+}
+
+func newScrollTracker() *scrollTracker {
+	return &scrollTracker{
+		scrolls: make(map[string]map[string]time.Time),
+		now:     time.Now,
+	}
+}
+
+// open returns the number of the open scrolls of the username, the expired ones are pruned.
+func (t *scrollTracker) open(username string) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now()
+	for id, expiresAt := range t.scrolls[username] {
+		if !now.Before(expiresAt) {
+			delete(t.scrolls[username], id)
+		}
+	}
+	return len(t.scrolls[username])
+}
+
+// keep records the scroll id of the username, or extends it, for the keep alive.
+func (t *scrollTracker) keep(username, id string, keepAlive time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.scrolls[username] == nil {
+		t.scrolls[username] = make(map[string]time.Time)
+	}
+	t.scrolls[username][id] = t.now().Add(keepAlive)
+}
+
+// clear removes the scroll ids of the username, or all of them for `_all`.
+func (t *scrollTracker) clear(username string, ids ...string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, id := range ids {
+		if id == "_all" {
+			delete(t.scrolls, username)
+			return
+		}
+		delete(t.scrolls[username], id)
+	}
+}
+
+// Scrolls middleware limits the number of scrolls a credential can keep open at once to
+// MAX_OPEN_SCROLLS, the search requests opening a scroll beyond it are rejected with a 429.
+// The scroll ids of the responses are recorded until the scrolls expire or are cleared,
+// the limit is disabled if it isn't set.
+func Scrolls() middleware.Middleware {
+	rl := Instance()
+	rl.Lock()
+	defer rl.Unlock()
+	if value := os.Getenv(envMaxOpenScrolls); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			log.Errorln(logTag, ": invalid value for", envMaxOpenScrolls, ":", value)
+		} else {
+			rl.maxOpenScrolls = limit
+		}
+	}
+	if rl.scrolls == nil {
+		rl.scrolls = newScrollTracker()
+	}
+	return rl.limitScrolls
+}
+
+func (rl *Ratelimiter) limitScrolls(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if rl.maxOpenScrolls == 0 {
+			h(w, req)
+			return
+		}
+		isScroll := strings.HasSuffix(strings.TrimSuffix(req.URL.Path, "/"), "/_search/scroll") ||
+			strings.Contains(req.URL.Path, "/_search/scroll/")
+		keepAlive := req.URL.Query().Get("scroll")
+		if !isScroll && (keepAlive == "" || !strings.HasSuffix(strings.TrimSuffix(req.URL.Path, "/"), "_search")) {
+			h(w, req)
+			return
+		}
+
+		username, err := credentialUsername(req.Context())
+		if err != nil {
+			log.Errorln(logTag, ":", err)
+			util.WriteBackError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if isScroll {
+			rl.trackScroll(w, req, h, username)
+			return
+		}
+
+		if open := rl.scrolls.open(username); open >= rl.maxOpenScrolls {
+			msg := fmt.Sprintf("limit of %d open scrolls exceeded, clear the unused scrolls with DELETE /_search/scroll", rl.maxOpenScrolls)
+			util.WriteBackMessage(w, msg, http.StatusTooManyRequests)
+			return
+		}
+		cw := newScrollIDWriter(w)
+		h(cw, req)
+		if id := cw.scrollID(); id != "" {
+			rl.scrolls.keep(username, id, parseKeepAlive(keepAlive))
+		}
+	}
+}
+
+// trackScroll serves the requests to continue or clear a scroll and updates the
+// recorded scrolls of the username accordingly.
+func (rl *Ratelimiter) trackScroll(w http.ResponseWriter, req *http.Request, h http.HandlerFunc, username string) {
+	var body struct {
+		Scroll   string      `json:"scroll"`
+		ScrollID interface{} `json:"scroll_id"`
+	}
+	if req.Body != nil {
+		raw, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			log.Errorln(logTag, ":", err)
+			util.WriteBackError(w, "unable to read request body", http.StatusBadRequest)
+			return
+		}
+		req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(raw))
+		// elasticsearch rejects the malformed bodies
+		json.Unmarshal(raw, &body)
+	}
+	var ids []string
+	switch v := body.ScrollID.(type) {
+	case string:
+		ids = append(ids, strings.Split(v, ",")...)
+	case []interface{}:
+		for _, id := range v {
+			if s, ok := id.(string); ok {
+				ids = append(ids, s)
+			}
+		}
+	}
+	if id := req.URL.Query().Get("scroll_id"); id != "" {
+		ids = append(ids, strings.Split(id, ",")...)
+	}
+	if i := strings.Index(req.URL.Path, "/_search/scroll/"); i >= 0 {
+		ids = append(ids, strings.Split(req.URL.Path[i+len("/_search/scroll/"):], ",")...)
+	}
+
+	if req.Method == http.MethodDelete {
+		h(w, req)
+		rl.scrolls.clear(username, ids...)
+		return
+	}
+
+	keepAlive := req.URL.Query().Get("scroll")
+	if keepAlive == "" {
+		keepAlive = body.Scroll
+	}
+	cw := newScrollIDWriter(w)
+	h(cw, req)
+	if keepAlive == "" {
+		return
+	}
+	// the continued scroll may be given a new id
+	if id := cw.scrollID(); id != "" && cw.status < http.StatusBadRequest {
+		for _, prev := range ids {
+			if prev != id {
+				rl.scrolls.clear(username, prev)
+			}
+		}
+		rl.scrolls.keep(username, id, parseKeepAlive(keepAlive))
+	}
+}
+
+// parseKeepAlive parses an elasticsearch time unit, e.g. `30s` or `1d`.
+func parseKeepAlive(value string) time.Duration {
+	if strings.HasSuffix(value, "d") {
+		if days, err := strconv.Atoi(strings.TrimSuffix(value, "d")); err == nil && days > 0 {
+			return time.Duration(days) * 24 * time.Hour
+		}
+	}
+	if keepAlive, err := time.ParseDuration(value); err == nil && keepAlive > 0 {
+		return keepAlive
+	}
+	return defaultScrollKeepAlive
+}
+
+// scrollIDWriter buffers the beginning of a response to read its scroll id from.
+type scrollIDWriter struct {
+	http.ResponseWriter
+	status int
+	buf    bytes.Buffer
+}
+
+func newScrollIDWriter(w http.ResponseWriter) *scrollIDWriter {
+	return &scrollIDWriter{ResponseWriter: w, status: http.StatusOK}
+}
+
+func (w *scrollIDWriter) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *scrollIDWriter) Write(b []byte) (int, error) {
+	if rem := maxScrollIDBytes - w.buf.Len(); rem > 0 {
+		if len(b) < rem {
+			rem = len(b)
+		}
+		w.buf.Write(b[:rem])
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// scrollID returns the scroll id of a successful response.
+func (w *scrollIDWriter) scrollID() string {
+	if w.status >= http.StatusBadRequest {
+		return ""
+	}
+	dec := json.NewDecoder(bytes.NewReader(w.buf.Bytes()))
+	// the response may be truncated, the scroll id precedes the hits
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return ""
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return ""
+		}
+		if key == "_scroll_id" {
+			var id string
+			if err := dec.Decode(&id); err != nil {
+				return ""
+			}
+			return id
+		}
+		var skip json.RawMessage
+		if err := dec.Decode(&skip); err != nil {
+			return ""
+		}
+	}
+	return ""
+}
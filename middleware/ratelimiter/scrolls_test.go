@@ -0,0 +1,75 @@
+package ratelimiter
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/appbaseio/reactivesearch-api/model/credential"
+	"github.com/appbaseio/reactivesearch-api/model/permission"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestScrolls(t *testing.T) {
+	Convey("Open scrolls", t, func() {
+		now := time.Now()
+		rl := &Ratelimiter{maxOpenScrolls: 2, scrolls: newScrollTracker()}
+		rl.scrolls.now = func() time.Time { return now }
+		var opened int
+		handler := rl.limitScrolls(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodDelete {
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+			opened++
+			fmt.Fprintf(w, `{"_scroll_id":"scroll-%d","took":1,"hits":{"hits":[]}}`, opened)
+		})
+		serve := func(username, method, target, body string) int {
+			req := httptest.NewRequest(method, target, strings.NewReader(body))
+			ctx := credential.NewContext(req.Context(), credential.Permission)
+			ctx = permission.NewContext(ctx, &permission.Permission{Username: username})
+			w := httptest.NewRecorder()
+			handler(w, req.WithContext(ctx))
+			return w.Code
+		}
+
+		Convey("Scroll creations are counted per credential", func() {
+			So(serve("foo", http.MethodPost, "/books/_search?scroll=1m", `{}`), ShouldEqual, http.StatusOK)
+			So(rl.scrolls.open("foo"), ShouldEqual, 1)
+			So(rl.scrolls.open("bar"), ShouldEqual, 0)
+		})
+		Convey("Searches without a scroll aren't counted", func() {
+			So(serve("foo", http.MethodPost, "/books/_search", `{}`), ShouldEqual, http.StatusOK)
+			So(rl.scrolls.open("foo"), ShouldEqual, 0)
+		})
+		Convey("Scroll creations over the limit are rejected", func() {
+			So(serve("foo", http.MethodPost, "/books/_search?scroll=1m", `{}`), ShouldEqual, http.StatusOK)
+			So(serve("foo", http.MethodPost, "/books/_search?scroll=1m", `{}`), ShouldEqual, http.StatusOK)
+			So(serve("foo", http.MethodPost, "/books/_search?scroll=1m", `{}`), ShouldEqual, http.StatusTooManyRequests)
+
+			Convey("Other credentials aren't limited", func() {
+				So(serve("bar", http.MethodPost, "/books/_search?scroll=1m", `{}`), ShouldEqual, http.StatusOK)
+			})
+			Convey("Continuing a scroll doesn't open a new one", func() {
+				So(serve("foo", http.MethodPost, "/_search/scroll", `{"scroll":"1m","scroll_id":"scroll-1"}`), ShouldEqual, http.StatusOK)
+				So(rl.scrolls.open("foo"), ShouldEqual, 2)
+			})
+			Convey("Clearing a scroll frees it", func() {
+				So(serve("foo", http.MethodDelete, "/_search/scroll", `{"scroll_id":["scroll-1"]}`), ShouldEqual, http.StatusOK)
+				So(serve("foo", http.MethodPost, "/books/_search?scroll=1m", `{}`), ShouldEqual, http.StatusOK)
+			})
+			Convey("Expired scrolls are freed", func() {
+				now = now.Add(time.Minute)
+				So(serve("foo", http.MethodPost, "/books/_search?scroll=1m", `{}`), ShouldEqual, http.StatusOK)
+			})
+		})
+		Convey("Keep alive is parsed in elasticsearch time units", func() {
+			So(parseKeepAlive("30s"), ShouldEqual, 30*time.Second)
+			So(parseKeepAlive("2d"), ShouldEqual, 48*time.Hour)
+			So(parseKeepAlive("forever"), ShouldEqual, defaultScrollKeepAlive)
+		})
+	})
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/appbaseio/reactivesearch-api/middleware"
 	"github.com/appbaseio/reactivesearch-api/model/acl"
 	"github.com/appbaseio/reactivesearch-api/model/credential"
+	"github.com/appbaseio/reactivesearch-api/model/decision"
 	"github.com/appbaseio/reactivesearch-api/model/permission"
 	"github.com/appbaseio/reactivesearch-api/model/user"
 	"github.com/appbaseio/reactivesearch-api/util"
@@ -17,7 +18,7 @@ import (
 
 // ACL returns a middleware that validates the request acl against the credential acls.
 func ACL() middleware.Middleware {
-	return validateACL
+	return timed(validateACL)
 }
 
 func validateACL(h http.HandlerFunc) http.HandlerFunc {
@@ -54,10 +55,12 @@ func validateACL(h http.HandlerFunc) http.HandlerFunc {
 
 		if !ok {
 			msg := fmt.Sprintf(`credentials cannot access "%s" acl`, reqACL.String())
+			decision.Record(ctx, "acl", false, msg)
 			w.Header().Set("www-authenticate", "Basic realm=\"Authentication Required\"")
 			util.WriteBackError(w, msg, http.StatusUnauthorized)
 			return
 		}
+		decision.Record(ctx, "acl", true, "")
 
 		h(w, req)
 	}
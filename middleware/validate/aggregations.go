@@ -0,0 +1,126 @@
+package validate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/appbaseio/reactivesearch-api/middleware"
+	"github.com/appbaseio/reactivesearch-api/model/credential"
+	"github.com/appbaseio/reactivesearch-api/model/permission"
+	"github.com/appbaseio/reactivesearch-api/util"
+)
+
+// aggregationSizeKeys are the aggregation parameters that bound the number of buckets.
+var aggregationSizeKeys = []string{"size", "shard_size"}
+
+// Aggregations returns a middleware that rejects the requests using aggregations
+// that aren't allowed by the permission's aggregation limits.
+func Aggregations() middleware.Middleware {
+	return timed(aggregations)
+}
+
+func aggregations(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+
+		reqCredential, err := credential.FromContext(ctx)
+		if err != nil {
+			log.Errorln(logTag, ":", err)
+			util.WriteBackError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if reqCredential == credential.Permission && req.Body != nil {
+			reqPermission, err := permission.FromContext(ctx)
+			if err != nil {
+				log.Errorln(logTag, ":", err)
+				util.WriteBackError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			if reqPermission.Aggregations != nil {
+				body, err := ioutil.ReadAll(req.Body)
+				if err != nil {
+					log.Errorln(logTag, ":", err)
+					util.WriteBackError(w, "unable to read request body", http.StatusBadRequest)
+					return
+				}
+				req.Body.Close()
+				req.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+				if err := checkAggregations(reqPermission.Aggregations, body); err != nil {
+					util.WriteBackError(w, err.Error(), http.StatusForbidden)
+					return
+				}
+			}
+		}
+
+		h(w, req)
+	}
+}
+
+// checkAggregations validates the aggregations of a JSON or NDJSON body against the limits.
+// Bodies that can't be parsed are left for elasticsearch to reject.
+func checkAggregations(limits *permission.AggregationLimits, body []byte) error {
+	var err error
+	eachDocument(body, func(doc map[string]interface{}) bool {
+		err = checkAggregationsAt(limits, doc, 1)
+		return err == nil
+	})
+	return err
+}
+
+func checkAggregationsAt(limits *permission.AggregationLimits, parent map[string]interface{}, depth int) error {
+	for _, key := range []string{"aggs", "aggregations"} {
+		aggs, ok := parent[key].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for name, value := range aggs {
+			agg, ok := value.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if limits.MaxDepth > 0 && depth > limits.MaxDepth {
+				return fmt.Errorf(`aggregation "%s" exceeds the maximum aggregation depth of %d`, name, limits.MaxDepth)
+			}
+			for aggType, params := range agg {
+				if aggType == "aggs" || aggType == "aggregations" || aggType == "meta" {
+					continue
+				}
+				if len(limits.Types) > 0 && !util.Contains(limits.Types, aggType) {
+					return fmt.Errorf(`aggregation "%s" of type "%s" isn't allowed`, name, aggType)
+				}
+				if err := checkAggregationSize(limits, name, params); err != nil {
+					return err
+				}
+			}
+			if err := checkAggregationsAt(limits, agg, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func checkAggregationSize(limits *permission.AggregationLimits, name string, params interface{}) error {
+	p, ok := params.(map[string]interface{})
+	if limits.MaxSize == 0 || !ok {
+		return nil
+	}
+	for _, key := range aggregationSizeKeys {
+		size, ok := p[key].(json.Number)
+		if !ok {
+			continue
+		}
+		if n, err := size.Int64(); err != nil || n > int64(limits.MaxSize) {
+			return fmt.Errorf(`aggregation "%s" exceeds the maximum aggregation size of %d`, name, limits.MaxSize)
+		}
+	}
+	return nil
+}
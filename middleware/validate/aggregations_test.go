@@ -0,0 +1,69 @@
+package validate
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/appbaseio/reactivesearch-api/model/acl"
+	"github.com/appbaseio/reactivesearch-api/model/credential"
+	"github.com/appbaseio/reactivesearch-api/model/permission"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// servePermission serves a request of the permission through the middleware, responding
+// with a 200 if it reaches the handler.
+func servePermission(m func(http.HandlerFunc) http.HandlerFunc, p *permission.Permission, a acl.ACL, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	ctx := credential.NewContext(req.Context(), credential.Permission)
+	ctx = permission.NewContext(ctx, p)
+	ctx = acl.NewContext(ctx, &a)
+	w := httptest.NewRecorder()
+	m(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})(w, req.WithContext(ctx))
+	return w
+}
+
+func serveAggregations(p *permission.Permission, body string) *httptest.ResponseRecorder {
+	return servePermission(aggregations, p, acl.Search, "/books/_search", body)
+}
+
+func TestAggregations(t *testing.T) {
+	Convey("Aggregations", t, func() {
+		restricted, err := permission.New("admin", permission.SetAggregations(&permission.AggregationLimits{
+			Types:    []string{"terms", "avg"},
+			MaxDepth: 2,
+			MaxSize:  100,
+		}))
+		So(err, ShouldBeNil)
+
+		Convey("An allowed aggregation is proxied", func() {
+			body := `{"aggs":{"genres":{"terms":{"field":"genre","size":10},"aggs":{"rating":{"avg":{"field":"rating"}}}}}}`
+			So(serveAggregations(restricted, body).Code, ShouldEqual, http.StatusOK)
+		})
+		Convey("A disallowed aggregation type is rejected", func() {
+			w := serveAggregations(restricted, `{"aggregations":{"authors":{"cardinality":{"field":"author"}}}}`)
+			So(w.Code, ShouldEqual, http.StatusForbidden)
+			So(w.Body.String(), ShouldContainSubstring, `aggregation \"authors\" of type \"cardinality\"`)
+		})
+		Convey("An aggregation exceeding the maximum size is rejected", func() {
+			w := serveAggregations(restricted, `{"aggs":{"genres":{"terms":{"field":"genre","size":10000}}}}`)
+			So(w.Code, ShouldEqual, http.StatusForbidden)
+			So(w.Body.String(), ShouldContainSubstring, "genres")
+		})
+		Convey("An aggregation exceeding the maximum depth is rejected", func() {
+			body := "{}\n" + `{"aggs":{"a":{"terms":{"field":"a"},"aggs":{"b":{"terms":{"field":"b"},"aggs":{"c":{"avg":{"field":"c"}}}}}}}}` + "\n"
+			w := serveAggregations(restricted, body)
+			So(w.Code, ShouldEqual, http.StatusForbidden)
+			So(w.Body.String(), ShouldContainSubstring, `aggregation \"c\" exceeds the maximum aggregation depth`)
+		})
+		Convey("Aggregations aren't restricted without limits", func() {
+			unrestricted, err := permission.New("admin")
+			So(err, ShouldBeNil)
+			w := serveAggregations(unrestricted, `{"aggs":{"authors":{"cardinality":{"field":"author"}}}}`)
+			So(w.Code, ShouldEqual, http.StatusOK)
+		})
+	})
+}
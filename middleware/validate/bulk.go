@@ -0,0 +1,115 @@
+package validate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/appbaseio/reactivesearch-api/middleware"
+	"github.com/appbaseio/reactivesearch-api/model/acl"
+	"github.com/appbaseio/reactivesearch-api/model/credential"
+	"github.com/appbaseio/reactivesearch-api/model/permission"
+	"github.com/appbaseio/reactivesearch-api/util"
+)
+
+// BulkSize returns a middleware that rejects the bulk requests with more actions
+// than allowed by the permission's max_bulk_actions.
+func BulkSize() middleware.Middleware {
+	return timed(bulkSize)
+}
+
+func bulkSize(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+
+		reqCredential, err := credential.FromContext(ctx)
+		if err != nil {
+			log.Errorln(logTag, ":", err)
+			util.WriteBackError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		reqACL, err := acl.FromContext(ctx)
+		if err != nil {
+			log.Errorln(logTag, ":", err)
+			util.WriteBackError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if reqCredential == credential.Permission && *reqACL == acl.Bulk && req.Body != nil {
+			reqPermission, err := permission.FromContext(ctx)
+			if err != nil {
+				log.Errorln(logTag, ":", err)
+				util.WriteBackError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			if limit := reqPermission.MaxBulkActions; limit != nil && *limit > 0 {
+				body, err := ioutil.ReadAll(req.Body)
+				if err != nil {
+					log.Errorln(logTag, ":", err)
+					util.WriteBackError(w, "unable to read request body", http.StatusBadRequest)
+					return
+				}
+				req.Body.Close()
+				req.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+				if actions := countBulkActions(body); actions > *limit {
+					msg := fmt.Sprintf("bulk request has %d actions, the permission allows at most %d", actions, *limit)
+					util.WriteBackError(w, msg, http.StatusRequestEntityTooLarge)
+					return
+				}
+			}
+		}
+
+		h(w, req)
+	}
+}
+
+// bulkAction is an action line of a bulk body, e.g. {"update": {"_index": "books"}},
+// along with its source line, if any.
+type bulkAction struct {
+	// name is the type of the action, i.e. index, create, update or delete
+	name string
+	// index is the _index of the action, empty for the index of the path
+	index  string
+	source []byte
+}
+
+// parseBulkActions returns the actions of an NDJSON bulk body. Every action except
+// delete is followed by a source line.
+func parseBulkActions(body []byte) []bulkAction {
+	var actions []bulkAction
+	expectSource := false
+	for _, line := range bytes.Split(body, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		if expectSource {
+			expectSource = false
+			actions[len(actions)-1].source = line
+			continue
+		}
+		// malformed bodies are left for elasticsearch to reject
+		var action map[string]struct {
+			Index string `json:"_index"`
+		}
+		json.Unmarshal(line, &action)
+		var a bulkAction
+		for name, meta := range action {
+			a.name, a.index = name, meta.Index
+		}
+		actions = append(actions, a)
+		expectSource = a.name != "delete"
+	}
+	return actions
+}
+
+// countBulkActions counts the action lines of an NDJSON bulk body.
+func countBulkActions(body []byte) int {
+	return len(parseBulkActions(body))
+}
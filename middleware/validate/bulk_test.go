@@ -0,0 +1,58 @@
+package validate
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/appbaseio/reactivesearch-api/model/acl"
+	"github.com/appbaseio/reactivesearch-api/model/credential"
+	"github.com/appbaseio/reactivesearch-api/model/permission"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const testBulkBody = `{"index":{"_index":"books","_id":"1"}}
+{"title":"a"}
+{"delete":{"_index":"books","_id":"2"}}
+{"update":{"_index":"books","_id":"3"}}
+{"doc":{"title":"c"}}
+`
+
+func serveBulk(p *permission.Permission, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/_bulk", strings.NewReader(body))
+	reqACL := acl.Bulk
+	ctx := credential.NewContext(req.Context(), credential.Permission)
+	ctx = acl.NewContext(ctx, &reqACL)
+	ctx = permission.NewContext(ctx, p)
+	w := httptest.NewRecorder()
+	bulkSize(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})(w, req.WithContext(ctx))
+	return w
+}
+
+func TestBulkSize(t *testing.T) {
+	Convey("Maximum bulk actions", t, func() {
+		limit := func(n int) *permission.Permission {
+			return &permission.Permission{MaxBulkActions: &n}
+		}
+
+		Convey("Counts the actions of a bulk body", func() {
+			So(countBulkActions([]byte(testBulkBody)), ShouldEqual, 3)
+			So(countBulkActions(nil), ShouldEqual, 0)
+		})
+		Convey("Bulk under the limit passes", func() {
+			So(serveBulk(limit(3), testBulkBody).Code, ShouldEqual, http.StatusOK)
+		})
+		Convey("Bulk over the limit is rejected", func() {
+			w := serveBulk(limit(2), testBulkBody)
+			So(w.Code, ShouldEqual, http.StatusRequestEntityTooLarge)
+			So(w.Body.String(), ShouldContainSubstring, "3 actions")
+		})
+		Convey("Unset limit is unlimited", func() {
+			So(serveBulk(&permission.Permission{}, testBulkBody).Code, ShouldEqual, http.StatusOK)
+			So(serveBulk(limit(0), testBulkBody).Code, ShouldEqual, http.StatusOK)
+		})
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"os"
 	"strings"
 
 	log "github.com/sirupsen/logrus"
@@ -11,14 +12,53 @@ import (
 	"github.com/appbaseio/reactivesearch-api/middleware"
 	"github.com/appbaseio/reactivesearch-api/model/category"
 	"github.com/appbaseio/reactivesearch-api/model/credential"
+	"github.com/appbaseio/reactivesearch-api/model/decision"
 	"github.com/appbaseio/reactivesearch-api/model/permission"
 	"github.com/appbaseio/reactivesearch-api/model/user"
 	"github.com/appbaseio/reactivesearch-api/util"
 )
 
+const (
+	// envCategoryAccessMode is either "allow", the default, or "deny" in which only the
+	// categories the credentials are explicitly granted can be accessed
+	envCategoryAccessMode = "CATEGORY_ACCESS_MODE"
+	categoryAccessAllow   = "allow"
+	categoryAccessDeny    = "deny"
+)
+
+// categoryDefaultDeny is set by InitCategoryAccessMode.
+var categoryDefaultDeny bool
+
+// InitCategoryAccessMode reads the category access mode from CATEGORY_ACCESS_MODE.
+func InitCategoryAccessMode() error {
+	deny, err := parseCategoryAccessMode(os.Getenv(envCategoryAccessMode))
+	if err != nil {
+		return err
+	}
+	categoryDefaultDeny = deny
+	return nil
+}
+
+func parseCategoryAccessMode(mode string) (bool, error) {
+	switch mode {
+	case "", categoryAccessAllow:
+		return false, nil
+	case categoryAccessDeny:
+		return true, nil
+	default:
+		return false, fmt.Errorf("invalid value for %s: %s", envCategoryAccessMode, mode)
+	}
+}
+
+// CategoryDefaultDeny returns true if the credentials can only access the categories
+// they're explicitly granted, admins included, as per CATEGORY_ACCESS_MODE.
+func CategoryDefaultDeny() bool {
+	return categoryDefaultDeny
+}
+
 // Category returns a middleware that validates the request category against credential categories.
 func Category() middleware.Middleware {
-	return validateCategory
+	return timed(validateCategory)
 }
 
 func validateCategory(h http.HandlerFunc) http.HandlerFunc {
@@ -56,12 +96,21 @@ func validateCategory(h http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
+		msg := fmt.Sprintf(`credential can't access "%s" category`, reqCategory.String())
+		// the category the request fell back to isn't the one that was granted, the
+		// request may belong to a category the credential was never granted
+		if ok && categoryDefaultDeny && category.IsFallback(ctx) {
+			ok = false
+			msg = "the request category couldn't be determined, it can't be accessed in the default deny mode"
+		}
+
 		if !ok {
-			msg := fmt.Sprintf(`credential can't access "%s" category`, reqCategory.String())
+			decision.Record(ctx, "category", false, msg)
 			w.Header().Set("www-authenticate", "Basic realm=\"Authentication Required\"")
 			util.WriteBackError(w, msg, http.StatusUnauthorized)
 			return
 		}
+		decision.Record(ctx, "category", true, "")
 
 		h(w, req)
 	}
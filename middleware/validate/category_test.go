@@ -0,0 +1,71 @@
+package validate
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/appbaseio/reactivesearch-api/model/category"
+	"github.com/appbaseio/reactivesearch-api/model/credential"
+	"github.com/appbaseio/reactivesearch-api/model/permission"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func serveCategory(c category.Category, fallback bool, p *permission.Permission) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/books/_search", nil)
+	ctx := category.NewContext(req.Context(), &c)
+	ctx = category.NewFallbackContext(ctx, fallback)
+	ctx = credential.NewContext(ctx, credential.Permission)
+	ctx = permission.NewContext(ctx, p)
+	w := httptest.NewRecorder()
+	validateCategory(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})(w, req.WithContext(ctx))
+	return w
+}
+
+func TestCategory(t *testing.T) {
+	p := &permission.Permission{Categories: []category.Category{category.Search, category.Misc}}
+
+	Convey("In the default allow mode", t, func() {
+		Convey("A granted category passes", func() {
+			w := serveCategory(category.Search, false, p)
+			So(w.Code, ShouldEqual, http.StatusOK)
+		})
+		Convey("A category the request fell back to passes if granted", func() {
+			w := serveCategory(category.Misc, true, p)
+			So(w.Code, ShouldEqual, http.StatusOK)
+		})
+	})
+
+	Convey("In the default deny mode", t, func() {
+		categoryDefaultDeny = true
+		Reset(func() { categoryDefaultDeny = false })
+
+		Convey("A granted category passes", func() {
+			w := serveCategory(category.Search, false, p)
+			So(w.Code, ShouldEqual, http.StatusOK)
+		})
+		Convey("An ungranted category is rejected", func() {
+			w := serveCategory(category.Docs, false, p)
+			So(w.Code, ShouldEqual, http.StatusUnauthorized)
+			So(w.Body.String(), ShouldContainSubstring, `can't access \"docs\" category`)
+		})
+		Convey("A category the request fell back to is rejected even if granted", func() {
+			w := serveCategory(category.Misc, true, p)
+			So(w.Code, ShouldEqual, http.StatusUnauthorized)
+		})
+	})
+
+	Convey("The category access mode", t, func() {
+		deny, err := parseCategoryAccessMode("deny")
+		So(err, ShouldBeNil)
+		So(deny, ShouldBeTrue)
+		deny, err = parseCategoryAccessMode("")
+		So(err, ShouldBeNil)
+		So(deny, ShouldBeFalse)
+		_, err = parseCategoryAccessMode("block")
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "invalid value for CATEGORY_ACCESS_MODE")
+	})
+}
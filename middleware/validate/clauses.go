@@ -0,0 +1,231 @@
+package validate
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/appbaseio/reactivesearch-api/middleware"
+	"github.com/appbaseio/reactivesearch-api/model/acl"
+	"github.com/appbaseio/reactivesearch-api/model/credential"
+	"github.com/appbaseio/reactivesearch-api/model/permission"
+	"github.com/appbaseio/reactivesearch-api/util"
+)
+
+// QueryClauses returns a middleware that rejects the search requests using the query
+// clauses denied to the permission.
+func QueryClauses() middleware.Middleware {
+	return timed(queryClauses)
+}
+
+func queryClauses(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+
+		reqCredential, err := credential.FromContext(ctx)
+		if err != nil {
+			log.Errorln(logTag, ":", err)
+			util.WriteBackError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		reqACL, err := acl.FromContext(ctx)
+		if err != nil || reqCredential != credential.Permission ||
+			(*reqACL != acl.Search && *reqACL != acl.Msearch && *reqACL != acl.Count) {
+			h(w, req)
+			return
+		}
+
+		reqPermission, err := permission.FromContext(ctx)
+		if err != nil {
+			log.Errorln(logTag, ":", err)
+			util.WriteBackError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if len(reqPermission.DeniedQueryClauses) == 0 {
+			h(w, req)
+			return
+		}
+		// the query string syntax of a URI search can express any of the clauses
+		if req.URL.Query().Get("q") != "" {
+			util.WriteBackError(w, `permission isn't allowed to use "q" URI searches as some query clauses are denied to it`, http.StatusForbidden)
+			return
+		}
+		if req.Body == nil {
+			h(w, req)
+			return
+		}
+
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			log.Errorln(logTag, ":", err)
+			util.WriteBackError(w, "unable to read request body", http.StatusBadRequest)
+			return
+		}
+		req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		if clause, ok := findDeniedClause(reqPermission, body); ok {
+			util.WriteBackError(w, `permission isn't allowed to use "`+clause+`" queries`, http.StatusForbidden)
+			return
+		}
+
+		h(w, req)
+	}
+}
+
+// compoundClauses are the clauses holding other clauses, along with their keys holding them.
+var compoundClauses = map[string][]string{
+	"bool":               {"must", "filter", "should", "must_not"},
+	"boosting":           {"positive", "negative"},
+	"constant_score":     {"filter"},
+	"dis_max":            {"queries"},
+	"function_score":     {"query"},
+	"script_score":       {"query"},
+	"nested":             {"query"},
+	"has_child":          {"query"},
+	"has_parent":         {"query"},
+	"pinned":             {"organic"},
+	"field_masking_span": {"query"},
+	"span_near":          {"clauses"},
+	"span_or":            {"clauses"},
+	"span_not":           {"include", "exclude"},
+	"span_first":         {"match"},
+	"span_multi":         {"match"},
+	"span_containing":    {"big", "little"},
+	"span_within":        {"big", "little"},
+}
+
+// queryStringClauses parse a query string, whose syntax can express any of the clauses,
+// so they're denied along with any clause as the "q" URI searches are.
+var queryStringClauses = map[string]bool{
+	"query_string":        true,
+	"simple_query_string": true,
+}
+
+// findDeniedClause looks for the denied query clauses in the queries of a JSON or NDJSON
+// body, i.e. its query, post_filter, rescore queries and the filters of its aggregations.
+// Bodies that can't be parsed are left for elasticsearch to reject.
+func findDeniedClause(p *permission.Permission, body []byte) (string, bool) {
+	var clause string
+	var found bool
+	eachDocument(body, func(doc map[string]interface{}) bool {
+		clause, found = findDeniedBodyClause(p, doc)
+		return !found
+	})
+	return clause, found
+}
+
+func findDeniedBodyClause(p *permission.Permission, doc map[string]interface{}) (string, bool) {
+	queries := []interface{}{doc["query"], doc["post_filter"]}
+	for _, rescore := range asList(doc["rescore"]) {
+		if r, ok := rescore.(map[string]interface{}); ok {
+			if query, ok := r["query"].(map[string]interface{}); ok {
+				queries = append(queries, query["rescore_query"])
+			}
+		}
+	}
+	for _, query := range queries {
+		if clause, ok := findDeniedQueryClause(p, query); ok {
+			return clause, true
+		}
+	}
+	for _, key := range []string{"aggs", "aggregations"} {
+		if clause, ok := findDeniedAggClause(p, doc[key]); ok {
+			return clause, true
+		}
+	}
+	return "", false
+}
+
+// findDeniedQueryClause looks for the denied clauses in a query, whose keys are clauses.
+func findDeniedQueryClause(p *permission.Permission, query interface{}) (string, bool) {
+	for _, q := range asList(query) {
+		clauses, ok := q.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for clause, value := range clauses {
+			if p.IsQueryClauseDenied(clause) || queryStringClauses[clause] {
+				return clause, true
+			}
+			body, ok := value.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			for _, key := range compoundClauses[clause] {
+				if found, ok := findDeniedQueryClause(p, body[key]); ok {
+					return found, true
+				}
+			}
+			if clause == "function_score" {
+				for _, function := range asList(body["functions"]) {
+					if f, ok := function.(map[string]interface{}); ok {
+						if found, ok := findDeniedQueryClause(p, f["filter"]); ok {
+							return found, true
+						}
+					}
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+// findDeniedAggClause looks for the denied clauses in the filters of the aggregations.
+func findDeniedAggClause(p *permission.Permission, aggs interface{}) (string, bool) {
+	named, ok := aggs.(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+	for _, agg := range named {
+		definition, ok := agg.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for aggType, value := range definition {
+			var queries []interface{}
+			switch aggType {
+			case "aggs", "aggregations":
+				if found, ok := findDeniedAggClause(p, value); ok {
+					return found, true
+				}
+			case "filter":
+				queries = append(queries, value)
+			case "filters", "adjacency_matrix":
+				if body, ok := value.(map[string]interface{}); ok {
+					switch filters := body["filters"].(type) {
+					case map[string]interface{}:
+						for _, filter := range filters {
+							queries = append(queries, filter)
+						}
+					case []interface{}:
+						queries = append(queries, filters...)
+					}
+				}
+			case "significant_terms", "significant_text":
+				if body, ok := value.(map[string]interface{}); ok {
+					queries = append(queries, body["background_filter"])
+				}
+			}
+			for _, query := range queries {
+				if found, ok := findDeniedQueryClause(p, query); ok {
+					return found, true
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+// asList returns the value as a list, whether it's a single value or an array of them.
+func asList(value interface{}) []interface{} {
+	if list, ok := value.([]interface{}); ok {
+		return list
+	}
+	if value == nil {
+		return nil
+	}
+	return []interface{}{value}
+}
@@ -0,0 +1,96 @@
+package validate
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/appbaseio/reactivesearch-api/model/acl"
+	"github.com/appbaseio/reactivesearch-api/model/credential"
+	"github.com/appbaseio/reactivesearch-api/model/permission"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func serveQueryClauses(p *permission.Permission, a acl.ACL, target, body string) *httptest.ResponseRecorder {
+	return servePermission(queryClauses, p, a, target, body)
+}
+
+func TestQueryClauses(t *testing.T) {
+	Convey("QueryClauses", t, func() {
+		restricted, err := permission.New("admin", permission.SetDeniedQueryClauses([]string{"regexp", "wildcard", "query_string"}))
+		So(err, ShouldBeNil)
+
+		Convey("An allowed clause is proxied", func() {
+			body := `{"query":{"bool":{"must":[{"match":{"title":"dune"}}]}}}`
+			So(serveQueryClauses(restricted, acl.Search, "/books/_search", body).Code, ShouldEqual, http.StatusOK)
+		})
+		Convey("A denied clause is rejected", func() {
+			body := `{"query":{"bool":{"should":[{"match":{"title":"dune"}},{"regexp":{"title":"d.*e"}}]}}}`
+			w := serveQueryClauses(restricted, acl.Search, "/books/_search", body)
+			So(w.Code, ShouldEqual, http.StatusForbidden)
+			So(w.Body.String(), ShouldContainSubstring, `use \"regexp\" queries`)
+		})
+		Convey("A denied clause of a msearch body is rejected", func() {
+			body := "{}\n" + `{"query":{"match_all":{}}}` + "\n{}\n" + `{"query":{"query_string":{"query":"d*"}}}` + "\n"
+			So(serveQueryClauses(restricted, acl.Msearch, "/_msearch", body).Code, ShouldEqual, http.StatusForbidden)
+		})
+		Convey("A field named after a denied clause isn't rejected", func() {
+			body := `{"query":{"match":{"wildcard":"dune"}}}`
+			So(serveQueryClauses(restricted, acl.Search, "/books/_search", body).Code, ShouldEqual, http.StatusOK)
+		})
+		Convey("A denied clause nested in a compound query is rejected", func() {
+			for _, body := range []string{
+				`{"query":{"nested":{"path":"editions","query":{"bool":{"filter":{"wildcard":{"isbn":"97*"}}}}}}}`,
+				`{"query":{"function_score":{"query":{"match_all":{}},"functions":[{"filter":{"regexp":{"title":"d.*"}},"weight":2}]}}}`,
+				`{"post_filter":{"constant_score":{"filter":{"regexp":{"title":"d.*"}}}}}`,
+			} {
+				So(serveQueryClauses(restricted, acl.Search, "/books/_search", body).Code, ShouldEqual, http.StatusForbidden)
+			}
+		})
+		Convey("A denied clause of an aggregation filter is rejected", func() {
+			body := `{"aggs":{"by_genre":{"terms":{"field":"genre"},"aggs":{"dune":{"filter":{"wildcard":{"title":"d*"}}}}}}}`
+			So(serveQueryClauses(restricted, acl.Search, "/books/_search", body).Code, ShouldEqual, http.StatusForbidden)
+			body = `{"aggs":{"titles":{"filters":{"filters":{"d":{"regexp":{"title":"d.*"}}}}}}}`
+			So(serveQueryClauses(restricted, acl.Search, "/books/_search", body).Code, ShouldEqual, http.StatusForbidden)
+		})
+		Convey("Fields and aggregations named after a denied clause aren't rejected", func() {
+			body := `{"query":{"term":{"regexp":{"value":"d.*"}}},"aggs":{"wildcard":{"terms":{"field":"wildcard"}}}}`
+			So(serveQueryClauses(restricted, acl.Search, "/books/_search", body).Code, ShouldEqual, http.StatusOK)
+			body = `{"query":{"match_all":{}},"_source":{"regexp":{"title":"d.*"}}}`
+			So(serveQueryClauses(restricted, acl.Search, "/books/_search", body).Code, ShouldEqual, http.StatusOK)
+		})
+		Convey("A URI search is rejected when clauses are denied", func() {
+			So(serveQueryClauses(restricted, acl.Search, "/books/_search?q=title:/d.*e/", "").Code, ShouldEqual, http.StatusForbidden)
+			req := httptest.NewRequest(http.MethodGet, "/books/_search?q=dune", nil)
+			ctx := credential.NewContext(req.Context(), credential.Permission)
+			ctx = permission.NewContext(ctx, restricted)
+			reqACL := acl.Search
+			ctx = acl.NewContext(ctx, &reqACL)
+			req = req.WithContext(ctx)
+			req.Body = nil
+			w := httptest.NewRecorder()
+			queryClauses(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})(w, req)
+			So(w.Code, ShouldEqual, http.StatusForbidden)
+		})
+		Convey("The query string clauses are rejected when clauses are denied", func() {
+			regexp, err := permission.New("admin", permission.SetDeniedQueryClauses([]string{"regexp"}))
+			So(err, ShouldBeNil)
+			for _, body := range []string{
+				`{"query":{"query_string":{"query":"title:/d.*e/"}}}`,
+				`{"query":{"bool":{"filter":{"simple_query_string":{"query":"d*"}}}}}`,
+			} {
+				So(serveQueryClauses(regexp, acl.Search, "/books/_search", body).Code, ShouldEqual, http.StatusForbidden)
+			}
+		})
+		Convey("The clauses aren't restricted without a denylist", func() {
+			unrestricted, err := permission.New("admin")
+			So(err, ShouldBeNil)
+			body := `{"query":{"regexp":{"title":"d.*e"}}}`
+			So(serveQueryClauses(unrestricted, acl.Search, "/books/_search", body).Code, ShouldEqual, http.StatusOK)
+			body = `{"query":{"query_string":{"query":"dune"}}}`
+			So(serveQueryClauses(unrestricted, acl.Search, "/books/_search", body).Code, ShouldEqual, http.StatusOK)
+		})
+	})
+}
@@ -0,0 +1,281 @@
+package validate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/appbaseio/reactivesearch-api/middleware"
+	"github.com/appbaseio/reactivesearch-api/model/acl"
+	"github.com/appbaseio/reactivesearch-api/model/decision"
+	"github.com/appbaseio/reactivesearch-api/model/index"
+	"github.com/appbaseio/reactivesearch-api/model/op"
+	"github.com/appbaseio/reactivesearch-api/util"
+)
+
+// envWriteDenylist is a comma separated list of the index names and glob patterns, e.g.
+// ".security*,.users", that can't be written to or deleted from by any credential.
+const envWriteDenylist = "WRITE_DENYLIST_INDICES"
+
+// denylistAliasesRefresh is how often the aliases of the denylisted indices are looked up.
+const denylistAliasesRefresh = time.Minute
+
+var (
+	writeDenylist     []string
+	loadWriteDenylist sync.Once
+	// denylistAliases are the aliases pointing at the denylisted indices, whose writes
+	// end up in them
+	denylistAliases = &aliasDenylist{lookup: catAliases}
+)
+
+// aliasDenylist holds the aliases of the denylisted indices, which are looked up in the
+// background every denylistAliasesRefresh so that the requests don't wait on the lookup.
+type aliasDenylist struct {
+	mu      sync.RWMutex
+	aliases map[string]bool
+	// lookup returns the indices of each alias
+	lookup func() (map[string][]string, error)
+}
+
+// refresh looks up the aliases of the denylisted indices. The aliases last looked up
+// are kept if the lookup fails.
+func (d *aliasDenylist) refresh() {
+	aliasIndices, err := d.lookup()
+	if err != nil {
+		log.Errorln(logTag, ": unable to look up the aliases of the denylisted indices:", err)
+		return
+	}
+	aliases := make(map[string]bool)
+	for name, indices := range aliasIndices {
+		for _, index := range indices {
+			if denylisted(index) {
+				aliases[name] = true
+			}
+		}
+	}
+	d.mu.Lock()
+	d.aliases = aliases
+	d.mu.Unlock()
+}
+
+// watch refreshes the aliases every denylistAliasesRefresh.
+func (d *aliasDenylist) watch() {
+	for range time.Tick(denylistAliasesRefresh) {
+		d.refresh()
+	}
+}
+
+// contains reports whether the alias points at a denylisted index.
+func (d *aliasDenylist) contains(alias string) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.aliases[alias]
+}
+
+// overlaps reports whether the index pattern may expand onto an alias of a denylisted index.
+func (d *aliasDenylist) overlaps(pattern string) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	for alias := range d.aliases {
+		if util.PatternsOverlap(pattern, alias) {
+			return true
+		}
+	}
+	return false
+}
+
+func catAliases() (map[string][]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	rows, err := util.GetClient7().CatAliases().Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	aliasIndices := make(map[string][]string)
+	for _, row := range rows {
+		aliasIndices[row.Alias] = append(aliasIndices[row.Alias], row.Index)
+	}
+	return aliasIndices, nil
+}
+
+// WriteDenylist returns a middleware that rejects the write and delete operations on
+// the denylisted indices, regardless of the permissions of the credential.
+func WriteDenylist() middleware.Middleware {
+	loadWriteDenylist.Do(func() {
+		writeDenylist = parseWriteDenylist(os.Getenv(envWriteDenylist))
+		if len(writeDenylist) > 0 {
+			denylistAliases.refresh()
+			go denylistAliases.watch()
+		}
+	})
+	return timed(denyWrites)
+}
+
+func parseWriteDenylist(value string) []string {
+	var patterns []string
+	for _, pattern := range strings.Split(value, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			log.Errorln(logTag, ": invalid index pattern in", envWriteDenylist, ":", pattern)
+			continue
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns
+}
+
+func denyWrites(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if len(writeDenylist) == 0 {
+			h(w, req)
+			return
+		}
+		ctx := req.Context()
+
+		reqOp, err := op.FromContext(ctx)
+		if err != nil {
+			log.Errorln(logTag, ":", err)
+			util.WriteBackError(w, "an error occurred while validating request op", http.StatusInternalServerError)
+			return
+		}
+		if *reqOp != op.Write && *reqOp != op.Delete {
+			h(w, req)
+			return
+		}
+
+		reqIndices, err := index.FromContext(ctx)
+		if err != nil {
+			log.Errorln(logTag, ":", err)
+			util.WriteBackError(w, "an error occurred while validating indices", http.StatusInternalServerError)
+			return
+		}
+		// the bulk actions, the reindex and the alias actions can target indices other
+		// than the ones in the path
+		if reqACL, err := acl.FromContext(ctx); err == nil && req.Body != nil &&
+			(*reqACL == acl.Bulk || *reqACL == acl.Reindex || *reqACL == acl.Aliases) {
+			body, err := ioutil.ReadAll(req.Body)
+			if err != nil {
+				log.Errorln(logTag, ":", err)
+				util.WriteBackError(w, "unable to read request body", http.StatusBadRequest)
+				return
+			}
+			req.Body.Close()
+			req.Body = ioutil.NopCloser(bytes.NewReader(body))
+			switch *reqACL {
+			case acl.Bulk:
+				reqIndices = append(reqIndices, bulkIndices(body)...)
+			case acl.Reindex:
+				reqIndices = append(reqIndices, reindexDestIndex(body)...)
+			case acl.Aliases:
+				reqIndices = append(reqIndices, aliasActionIndices(body)...)
+			}
+		}
+
+		for _, name := range reqIndices {
+			if denylisted(name) || denylistAliases.contains(name) || mayExpandOntoDenylist(name) {
+				msg := fmt.Sprintf("index %s is protected from writes", name)
+				decision.Record(ctx, "denylist", false, msg)
+				util.WriteBackError(w, msg, http.StatusForbidden)
+				return
+			}
+		}
+
+		h(w, req)
+	}
+}
+
+func denylisted(name string) bool {
+	for _, pattern := range writeDenylist {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// isIndexPattern reports whether the index name is a pattern elasticsearch expands, i.e.
+// `_all` or a wildcard expression.
+func isIndexPattern(name string) bool {
+	return name == "_all" || strings.Contains(name, "*")
+}
+
+// mayExpandOntoDenylist reports whether the index pattern may expand onto a denylisted
+// index or an alias of one, e.g. `.sec*` onto `.security*`.
+func mayExpandOntoDenylist(name string) bool {
+	if !isIndexPattern(name) || strings.HasPrefix(name, "-") {
+		return false
+	}
+	if name == "_all" {
+		return true
+	}
+	for _, pattern := range writeDenylist {
+		if util.PatternsOverlap(name, pattern) {
+			return true
+		}
+	}
+	return denylistAliases.overlaps(name)
+}
+
+// bulkIndices returns the indices targeted by the actions of an NDJSON bulk body.
+func bulkIndices(body []byte) []string {
+	var indices []string
+	for _, action := range parseBulkActions(body) {
+		if action.index != "" {
+			indices = append(indices, action.index)
+		}
+	}
+	return indices
+}
+
+// reindexDestIndex returns the destination index of a reindex body.
+func reindexDestIndex(body []byte) []string {
+	var reindex struct {
+		Dest struct {
+			Index string `json:"index"`
+		} `json:"dest"`
+	}
+	// malformed bodies are left for elasticsearch to reject
+	json.Unmarshal(body, &reindex)
+	if reindex.Dest.Index == "" {
+		return nil
+	}
+	return []string{reindex.Dest.Index}
+}
+
+// aliasActionIndices returns the indices and the aliases changed by the actions of an
+// aliases body, e.g. {"actions": [{"add": {"index": "books", "alias": "library"}}]}.
+func aliasActionIndices(body []byte) []string {
+	var aliases struct {
+		Actions []map[string]struct {
+			Index   string   `json:"index"`
+			Indices []string `json:"indices"`
+			Alias   string   `json:"alias"`
+			Aliases []string `json:"aliases"`
+		} `json:"actions"`
+	}
+	json.Unmarshal(body, &aliases)
+	var indices []string
+	for _, action := range aliases.Actions {
+		for _, a := range action {
+			for _, name := range append(append([]string{a.Index, a.Alias}, a.Indices...), a.Aliases...) {
+				if name != "" {
+					indices = append(indices, name)
+				}
+			}
+		}
+	}
+	return indices
+}
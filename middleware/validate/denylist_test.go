@@ -0,0 +1,108 @@
+package validate
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/appbaseio/reactivesearch-api/model/acl"
+	"github.com/appbaseio/reactivesearch-api/model/credential"
+	"github.com/appbaseio/reactivesearch-api/model/index"
+	"github.com/appbaseio/reactivesearch-api/model/op"
+	"github.com/appbaseio/reactivesearch-api/model/user"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func serveDenylist(reqOp op.Operation, reqACL acl.ACL, indices []string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/_bulk", strings.NewReader(body))
+	isAdmin := true
+	ctx := credential.NewContext(req.Context(), credential.User)
+	ctx = user.NewContext(ctx, &user.User{Username: "admin", IsAdmin: &isAdmin})
+	ctx = op.NewContext(ctx, &reqOp)
+	ctx = acl.NewContext(ctx, &reqACL)
+	ctx = index.NewContext(ctx, indices)
+	w := httptest.NewRecorder()
+	denyWrites(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})(w, req.WithContext(ctx))
+	return w
+}
+
+func TestWriteDenylist(t *testing.T) {
+	Convey("Write denylist", t, func() {
+		writeDenylist = parseWriteDenylist(".security*, .users")
+		lookups := 0
+		defaultAliases := denylistAliases
+		denylistAliases = &aliasDenylist{lookup: func() (map[string][]string, error) {
+			lookups++
+			return map[string][]string{"security": {".security-7"}, "library": {"books"}}, nil
+		}}
+		denylistAliases.refresh()
+		defer func() {
+			writeDenylist = nil
+			denylistAliases = defaultAliases
+		}()
+
+		Convey("Writes to a denylisted index are rejected even for an admin", func() {
+			w := serveDenylist(op.Write, acl.Index, []string{".security-7"}, "")
+			So(w.Code, ShouldEqual, http.StatusForbidden)
+			So(w.Body.String(), ShouldContainSubstring, ".security-7")
+			So(serveDenylist(op.Delete, acl.Delete, []string{".users"}, "").Code, ShouldEqual, http.StatusForbidden)
+		})
+		Convey("Bulk actions on a denylisted index are rejected", func() {
+			body := `{"index":{"_index":"books"}}
+{"title":"a"}
+{"delete":{"_index":".users","_id":"foo"}}
+`
+			So(serveDenylist(op.Write, acl.Bulk, nil, body).Code, ShouldEqual, http.StatusForbidden)
+		})
+		Convey("Writes through an alias of a denylisted index are rejected", func() {
+			So(serveDenylist(op.Write, acl.Index, []string{"security"}, "").Code, ShouldEqual, http.StatusForbidden)
+			So(serveDenylist(op.Write, acl.Index, []string{"library"}, "").Code, ShouldEqual, http.StatusOK)
+			// the aliases are looked up in the background rather than on the requests
+			So(lookups, ShouldEqual, 1)
+		})
+		Convey("Reindexing into a denylisted index is rejected", func() {
+			body := `{"source":{"index":"books"},"dest":{"index":".users"}}`
+			So(serveDenylist(op.Write, acl.Reindex, nil, body).Code, ShouldEqual, http.StatusForbidden)
+			body = `{"source":{"index":".users"},"dest":{"index":"users-copy"}}`
+			So(serveDenylist(op.Write, acl.Reindex, nil, body).Code, ShouldEqual, http.StatusOK)
+		})
+		Convey("Alias actions on a denylisted index are rejected", func() {
+			body := `{"actions":[{"add":{"index":".security-7","alias":"writable"}}]}`
+			So(serveDenylist(op.Write, acl.Aliases, nil, body).Code, ShouldEqual, http.StatusForbidden)
+			body = `{"actions":[{"remove_index":{"indices":["books",".users"]}}]}`
+			So(serveDenylist(op.Write, acl.Aliases, nil, body).Code, ShouldEqual, http.StatusForbidden)
+			body = `{"actions":[{"add":{"index":"books","alias":"library"}}]}`
+			So(serveDenylist(op.Write, acl.Aliases, nil, body).Code, ShouldEqual, http.StatusOK)
+		})
+		Convey("The aliases of the denylisted indices are kept if the lookup fails", func() {
+			denylistAliases.lookup = func() (map[string][]string, error) {
+				return nil, errors.New("connection refused")
+			}
+			denylistAliases.refresh()
+			So(serveDenylist(op.Write, acl.Index, []string{"security"}, "").Code, ShouldEqual, http.StatusForbidden)
+		})
+		Convey("Writes to the patterns that may expand onto a denylisted index are rejected", func() {
+			for _, indices := range [][]string{{"*"}, {"_all"}, {".sec*"}, {"*-7"}, {".u*rs"}, {"secu*"}, {"books", "*"}} {
+				So(serveDenylist(op.Delete, acl.DeleteByQuery, indices, "").Code, ShouldEqual, http.StatusForbidden)
+			}
+			body := `{"delete":{"_index":".s*","_id":"foo"}}` + "\n"
+			So(serveDenylist(op.Write, acl.Bulk, nil, body).Code, ShouldEqual, http.StatusForbidden)
+		})
+		Convey("Writes to the patterns that can't expand onto a denylisted index pass", func() {
+			for _, indices := range [][]string{{"books*"}, {"logs-*"}, {".users-*"}, {"lib*"}} {
+				So(serveDenylist(op.Delete, acl.DeleteByQuery, indices, "").Code, ShouldEqual, http.StatusOK)
+			}
+		})
+		Convey("Writes to the other indices pass", func() {
+			So(serveDenylist(op.Write, acl.Index, []string{"books"}, "").Code, ShouldEqual, http.StatusOK)
+			So(serveDenylist(op.Write, acl.Bulk, nil, testBulkBody).Code, ShouldEqual, http.StatusOK)
+		})
+		Convey("Reads of a denylisted index pass", func() {
+			So(serveDenylist(op.Read, acl.Search, []string{".users"}, "").Code, ShouldEqual, http.StatusOK)
+		})
+	})
+}
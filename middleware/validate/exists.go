@@ -0,0 +1,203 @@
+package validate
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/appbaseio/reactivesearch-api/middleware"
+	"github.com/appbaseio/reactivesearch-api/model/acl"
+	"github.com/appbaseio/reactivesearch-api/model/decision"
+	"github.com/appbaseio/reactivesearch-api/model/index"
+	"github.com/appbaseio/reactivesearch-api/model/op"
+	"github.com/appbaseio/reactivesearch-api/util"
+)
+
+const (
+	envIndexPrecheck         = "INDEX_EXISTENCE_PRECHECK"
+	envIndexPrecheckCacheTTL = "INDEX_EXISTENCE_CACHE_TTL"
+	defaultIndexPrecheckTTL  = 10 * time.Second
+	indexPrecheckTimeout     = 5 * time.Second
+	// indexPrecheckMaxEntries bounds the number of the indices whose existence is cached
+	indexPrecheckMaxEntries = 10000
+)
+
+// precheckedACLs are the document writes whose target index is checked, the index
+// creations and the other index level writes are left to elasticsearch.
+var precheckedACLs = []acl.ACL{acl.Index, acl.Create, acl.Update, acl.Bulk}
+
+// indexPrecheck is set by InitIndexExistence if the precheck is enabled.
+var indexPrecheck *indexExistence
+
+// indexExistence caches the existing indices for a short time to spare elasticsearch a
+// check per write. The missing ones aren't cached, as they may be created at any time.
+type indexExistence struct {
+	ttl    time.Duration
+	exists func(name string) (bool, error)
+	mu     sync.Mutex
+	// checked holds when the existing indices were checked
+	checked map[string]time.Time
+}
+
+func newIndexExistence(ttl time.Duration, exists func(name string) (bool, error)) *indexExistence {
+	return &indexExistence{ttl: ttl, exists: exists, checked: make(map[string]time.Time)}
+}
+
+// check returns whether the index exists, from the cache if it was found within the ttl.
+func (e *indexExistence) check(name string) (bool, error) {
+	e.mu.Lock()
+	checkedAt, ok := e.checked[name]
+	e.mu.Unlock()
+	if ok && time.Since(checkedAt) < e.ttl {
+		return true, nil
+	}
+	exists, err := e.exists(name)
+	if err != nil {
+		return false, err
+	}
+	if exists {
+		e.mu.Lock()
+		e.store(name, time.Now())
+		e.mu.Unlock()
+	}
+	return exists, nil
+}
+
+// store caches the existing index, the expired entries are swept once the cache
+// is full, and nothing is cached while it's still full.
+func (e *indexExistence) store(name string, now time.Time) {
+	if len(e.checked) >= indexPrecheckMaxEntries {
+		for cached, checkedAt := range e.checked {
+			if now.Sub(checkedAt) >= e.ttl {
+				delete(e.checked, cached)
+			}
+		}
+		if len(e.checked) >= indexPrecheckMaxEntries {
+			return
+		}
+	}
+	e.checked[name] = now
+}
+
+// InitIndexExistence enables the index existence precheck as per INDEX_EXISTENCE_PRECHECK,
+// with the existing indices cached for INDEX_EXISTENCE_CACHE_TTL.
+func InitIndexExistence() error {
+	if os.Getenv(envIndexPrecheck) != "true" {
+		return nil
+	}
+	ttl := defaultIndexPrecheckTTL
+	if value := os.Getenv(envIndexPrecheckCacheTTL); value != "" {
+		duration, err := time.ParseDuration(value)
+		if err != nil || duration < 0 {
+			return fmt.Errorf("invalid value for %s: %s", envIndexPrecheckCacheTTL, value)
+		}
+		ttl = duration
+	}
+	indexPrecheck = newIndexExistence(ttl, func(name string) (bool, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), indexPrecheckTimeout)
+		defer cancel()
+		return util.GetClient7().IndexExists(name).Do(ctx)
+	})
+	return nil
+}
+
+// IndexExistence returns a middleware that rejects the document writes to the indices
+// that don't exist with a 404, if enabled, instead of passing elasticsearch's error
+// through when it doesn't create the indices automatically.
+func IndexExistence() middleware.Middleware {
+	return timed(precheckIndices)
+}
+
+func precheckIndices(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if indexPrecheck == nil {
+			h(w, req)
+			return
+		}
+		ctx := req.Context()
+
+		reqOp, err := op.FromContext(ctx)
+		if err != nil {
+			log.Errorln(logTag, ":", err)
+			util.WriteBackError(w, "an error occurred while validating request op", http.StatusInternalServerError)
+			return
+		}
+		reqACL, err := acl.FromContext(ctx)
+		if err != nil {
+			log.Errorln(logTag, ":", err)
+			util.WriteBackError(w, "an error occurred while validating request acl", http.StatusInternalServerError)
+			return
+		}
+		if *reqOp != op.Write || !acl.Contains(precheckedACLs, *reqACL) {
+			h(w, req)
+			return
+		}
+
+		reqIndices, err := index.FromContext(ctx)
+		if err != nil {
+			log.Errorln(logTag, ":", err)
+			util.WriteBackError(w, "an error occurred while validating indices", http.StatusInternalServerError)
+			return
+		}
+		// the actions of a bulk request may target other indices than the one of the path
+		if *reqACL == acl.Bulk && req.Body != nil {
+			body, err := ioutil.ReadAll(req.Body)
+			if err != nil {
+				log.Errorln(logTag, ":", err)
+				util.WriteBackError(w, "unable to read request body", http.StatusBadRequest)
+				return
+			}
+			req.Body.Close()
+			req.Body = ioutil.NopCloser(bytes.NewReader(body))
+			reqIndices = bulkTargetIndices(reqIndices, body)
+		}
+		for _, name := range reqIndices {
+			// the patterns and the date math names can't be checked as is
+			if name == "" || strings.ContainsAny(name, "*,<") {
+				continue
+			}
+			exists, err := indexPrecheck.check(name)
+			if err != nil {
+				// elasticsearch answers the write itself if the check fails
+				log.Errorln(logTag, ": unable to check if index", name, "exists:", err)
+				continue
+			}
+			if !exists {
+				msg := fmt.Sprintf("index %s doesn't exist, create it before writing to it", name)
+				decision.Record(ctx, "index_existence", false, msg)
+				util.WriteBackError(w, msg, http.StatusNotFound)
+				return
+			}
+		}
+
+		h(w, req)
+	}
+}
+
+// bulkTargetIndices returns the indices the actions of a bulk body write to, i.e. their
+// _index or else the index of the path.
+func bulkTargetIndices(pathIndices []string, body []byte) []string {
+	var indices []string
+	seen := make(map[string]bool)
+	for _, action := range parseBulkActions(body) {
+		targets := pathIndices
+		if action.index != "" {
+			targets = []string{action.index}
+		}
+		for _, name := range targets {
+			if !seen[name] {
+				seen[name] = true
+				indices = append(indices, name)
+			}
+		}
+	}
+	return indices
+}
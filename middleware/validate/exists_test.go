@@ -0,0 +1,108 @@
+package validate
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/appbaseio/reactivesearch-api/model/acl"
+	"github.com/appbaseio/reactivesearch-api/model/index"
+	"github.com/appbaseio/reactivesearch-api/model/op"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func servePrecheck(reqOp op.Operation, reqACL acl.ACL, indices []string) *httptest.ResponseRecorder {
+	return servePrecheckBody(reqOp, reqACL, indices, "")
+}
+
+func servePrecheckBody(reqOp op.Operation, reqACL acl.ACL, indices []string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	ctx := op.NewContext(req.Context(), &reqOp)
+	ctx = acl.NewContext(ctx, &reqACL)
+	ctx = index.NewContext(ctx, indices)
+	w := httptest.NewRecorder()
+	precheckIndices(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})(w, req.WithContext(ctx))
+	return w
+}
+
+func TestIndexExistence(t *testing.T) {
+	Convey("Index existence precheck", t, func() {
+		checks := 0
+		indexPrecheck = newIndexExistence(time.Minute, func(name string) (bool, error) {
+			checks++
+			if name == "broken" {
+				return false, errors.New("connection refused")
+			}
+			return name == "books", nil
+		})
+		defer func() { indexPrecheck = nil }()
+
+		Convey("Writes to an existing index pass", func() {
+			So(servePrecheck(op.Write, acl.Index, []string{"books"}).Code, ShouldEqual, http.StatusOK)
+		})
+		Convey("Writes to a missing index are rejected with a 404", func() {
+			w := servePrecheck(op.Write, acl.Index, []string{"boks"})
+			So(w.Code, ShouldEqual, http.StatusNotFound)
+			So(w.Body.String(), ShouldContainSubstring, "index boks doesn't exist")
+		})
+		Convey("The existing indices are cached", func() {
+			servePrecheck(op.Write, acl.Index, []string{"books"})
+			servePrecheck(op.Write, acl.Update, []string{"books"})
+			So(checks, ShouldEqual, 1)
+		})
+		Convey("The missing indices aren't cached", func() {
+			servePrecheck(op.Write, acl.Index, []string{"boks"})
+			servePrecheck(op.Write, acl.Update, []string{"boks"})
+			So(checks, ShouldEqual, 2)
+		})
+		Convey("The cache is bounded", func() {
+			for i := 0; i < indexPrecheckMaxEntries; i++ {
+				indexPrecheck.checked[fmt.Sprintf("index-%d", i)] = time.Now()
+			}
+			servePrecheck(op.Write, acl.Index, []string{"books"})
+			So(len(indexPrecheck.checked), ShouldEqual, indexPrecheckMaxEntries)
+			So(indexPrecheck.checked, ShouldNotContainKey, "books")
+
+			Convey("The expired entries are swept once it's full", func() {
+				indexPrecheck.checked["index-0"] = time.Now().Add(-time.Hour)
+				servePrecheck(op.Write, acl.Index, []string{"books"})
+				So(indexPrecheck.checked, ShouldContainKey, "books")
+				So(indexPrecheck.checked, ShouldNotContainKey, "index-0")
+			})
+		})
+		Convey("The bulk actions are checked against their own index", func() {
+			body := `{"index":{"_index":"books"}}` + "\n" + `{"title":"Dune"}` + "\n"
+			So(servePrecheckBody(op.Write, acl.Bulk, []string{"boks"}, body).Code, ShouldEqual, http.StatusOK)
+			body += `{"delete":{"_id":"1"}}` + "\n"
+			w := servePrecheckBody(op.Write, acl.Bulk, []string{"boks"}, body)
+			So(w.Code, ShouldEqual, http.StatusNotFound)
+			So(w.Body.String(), ShouldContainSubstring, "index boks doesn't exist")
+		})
+		Convey("The index creations, reads and patterns aren't checked", func() {
+			So(servePrecheck(op.Write, acl.Indices, []string{"boks"}).Code, ShouldEqual, http.StatusOK)
+			So(servePrecheck(op.Read, acl.Search, []string{"boks"}).Code, ShouldEqual, http.StatusOK)
+			So(servePrecheck(op.Write, acl.Bulk, []string{"logs-*"}).Code, ShouldEqual, http.StatusOK)
+			So(checks, ShouldEqual, 0)
+		})
+		Convey("A failed check lets elasticsearch answer the write", func() {
+			So(servePrecheck(op.Write, acl.Index, []string{"broken"}).Code, ShouldEqual, http.StatusOK)
+		})
+	})
+
+	Convey("An invalid cache ttl fails the init", t, func() {
+		os.Setenv(envIndexPrecheck, "true")
+		os.Setenv(envIndexPrecheckCacheTTL, "soon")
+		defer os.Unsetenv(envIndexPrecheck)
+		defer os.Unsetenv(envIndexPrecheckCacheTTL)
+		err := InitIndexExistence()
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "invalid value for INDEX_EXISTENCE_CACHE_TTL")
+	})
+}
@@ -14,7 +14,7 @@ import (
 
 // PermissionExpiry returns a middleware that checks whether a permission is expired or not.
 func PermissionExpiry() middleware.Middleware {
-	return validateExpiry
+	return timed(validateExpiry)
 }
 
 func validateExpiry(h http.HandlerFunc) http.HandlerFunc {
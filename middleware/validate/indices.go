@@ -9,6 +9,7 @@ import (
 
 	"github.com/appbaseio/reactivesearch-api/middleware"
 	"github.com/appbaseio/reactivesearch-api/model/credential"
+	"github.com/appbaseio/reactivesearch-api/model/decision"
 	"github.com/appbaseio/reactivesearch-api/model/index"
 	"github.com/appbaseio/reactivesearch-api/model/permission"
 	"github.com/appbaseio/reactivesearch-api/model/user"
@@ -17,7 +18,7 @@ import (
 
 // Indices returns a middleware that validates the request indices against the credential indices.
 func Indices() middleware.Middleware {
-	return indices
+	return timed(indices)
 }
 
 func indices(h http.HandlerFunc) http.HandlerFunc {
@@ -48,8 +49,10 @@ func indices(h http.HandlerFunc) http.HandlerFunc {
 				return
 			}
 			if !ok {
+				msg := "credentials cannot access cluster level routes"
+				decision.Record(ctx, "indices", false, msg)
 				w.Header().Set("www-authenticate", "Basic realm=\"Authentication Required\"")
-				util.WriteBackError(w, "credentials cannot access cluster level routes", http.StatusUnauthorized)
+				util.WriteBackError(w, msg, http.StatusUnauthorized)
 				return
 			}
 		} else {
@@ -62,11 +65,13 @@ func indices(h http.HandlerFunc) http.HandlerFunc {
 			}
 			if !ok {
 				msg := fmt.Sprintf("credentials cannot access %v index/indices", reqIndices)
+				decision.Record(ctx, "indices", false, msg)
 				w.Header().Set("www-authenticate", "Basic realm=\"Authentication Required\"")
 				util.WriteBackError(w, msg, http.StatusUnauthorized)
 				return
 			}
 		}
+		decision.Record(ctx, "indices", true, "")
 
 		h(w, req)
 	}
@@ -0,0 +1,151 @@
+package validate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/appbaseio/reactivesearch-api/middleware"
+	"github.com/appbaseio/reactivesearch-api/model/category"
+	"github.com/appbaseio/reactivesearch-api/util"
+)
+
+// envValidateJSONCategories is a comma separated list of the categories, e.g. "search,docs",
+// whose request bodies must be valid JSON, or NDJSON for the bulk and msearch endpoints.
+const envValidateJSONCategories = "VALIDATE_JSON_CATEGORIES"
+
+var (
+	jsonCategories     map[category.Category]bool
+	loadJSONCategories sync.Once
+)
+
+// JSON returns a middleware that rejects the requests of the configured categories
+// whose body isn't well-formed JSON, pointing out the location of the parse error.
+func JSON() middleware.Middleware {
+	loadJSONCategories.Do(func() {
+		jsonCategories = make(map[category.Category]bool)
+		for _, name := range strings.Split(os.Getenv(envValidateJSONCategories), ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			var c category.Category
+			if err := json.Unmarshal([]byte(`"`+name+`"`), &c); err != nil {
+				log.Errorln(logTag, ": invalid category in", envValidateJSONCategories, ":", name)
+				continue
+			}
+			jsonCategories[c] = true
+		}
+	})
+	return timed(validateJSON)
+}
+
+func validateJSON(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		reqCategory, err := category.FromContext(req.Context())
+		if err != nil {
+			log.Errorln(logTag, ":", err)
+			util.WriteBackError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !jsonCategories[*reqCategory] || req.Body == nil {
+			h(w, req)
+			return
+		}
+
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			log.Errorln(logTag, ":", err)
+			util.WriteBackError(w, "unable to read request body", http.StatusBadRequest)
+			return
+		}
+		req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		endpoint := schemaEndpoint(req.URL.Path)
+		if endpoint == "_bulk" || endpoint == "_msearch" {
+			err = checkNDJSON(body)
+		} else {
+			err = checkJSON(body)
+		}
+		if err != nil {
+			util.WriteBackError(w, "malformed request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		h(w, req)
+	}
+}
+
+// checkJSON returns the location and cause of the error if the body isn't a single
+// JSON document. An empty body is valid.
+func checkJSON(body []byte) error {
+	if offset, err := jsonError(body); err != nil {
+		line, column := jsonLocation(body, offset)
+		return fmt.Errorf("line %d, column %d: %v", line, column, err)
+	}
+	return nil
+}
+
+// checkNDJSON checks that every non-empty line of the body is a JSON document.
+func checkNDJSON(body []byte) error {
+	for i, line := range bytes.Split(body, []byte("\n")) {
+		if offset, err := jsonError(line); err != nil {
+			return fmt.Errorf("line %d, column %d: %v", i+1, offset, err)
+		}
+	}
+	return nil
+}
+
+// jsonError returns the parse error of a JSON document along with its byte offset.
+func jsonError(doc []byte) (int64, error) {
+	if len(bytes.TrimSpace(doc)) == 0 {
+		return 0, nil
+	}
+	var raw json.RawMessage
+	err := json.Unmarshal(doc, &raw)
+	if err == nil {
+		return 0, nil
+	}
+	if syntaxErr, ok := err.(*json.SyntaxError); ok {
+		return syntaxErr.Offset, err
+	}
+	// truncated documents fail at the end
+	return int64(len(doc)), err
+}
+
+// jsonLocation converts a byte offset of the body into a line and column.
+func jsonLocation(body []byte, offset int64) (int, int) {
+	if offset > int64(len(body)) {
+		offset = int64(len(body))
+	}
+	prefix := body[:offset]
+	line := bytes.Count(prefix, []byte("\n")) + 1
+	column := len(prefix) - bytes.LastIndexByte(prefix, '\n') - 1
+	return line, column
+}
+
+// eachDocument calls fn with each of the documents of a JSON or NDJSON body, with their
+// numbers decoded as json.Number, until fn returns false. The reading stops at the first
+// document that can't be parsed, which is left for elasticsearch to reject.
+func eachDocument(body []byte, fn func(doc map[string]interface{}) bool) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	for {
+		var doc map[string]interface{}
+		if err := decoder.Decode(&doc); err != nil {
+			// io.EOF once all the documents have been read
+			return
+		}
+		if !fn(doc) {
+			return
+		}
+	}
+}
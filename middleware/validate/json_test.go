@@ -0,0 +1,61 @@
+package validate
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/appbaseio/reactivesearch-api/model/category"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func serveJSON(c category.Category, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req = req.WithContext(category.NewContext(req.Context(), &c))
+	w := httptest.NewRecorder()
+	validateJSON(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})(w, req)
+	return w
+}
+
+func TestJSON(t *testing.T) {
+	jsonCategories = map[category.Category]bool{category.Search: true, category.Docs: true}
+	defer func() { jsonCategories = nil }()
+
+	Convey("A valid JSON body is proxied", t, func() {
+		w := serveJSON(category.Search, "/books/_search", `{"query": {"match_all": {}}}`)
+		So(w.Code, ShouldEqual, http.StatusOK)
+	})
+
+	Convey("An invalid JSON body is rejected with the error location", t, func() {
+		w := serveJSON(category.Search, "/books/_search", "{\n  \"query\": {\"match_all\": {}},,\n}")
+		So(w.Code, ShouldEqual, http.StatusBadRequest)
+		So(w.Body.String(), ShouldContainSubstring, "line 2, column 30")
+	})
+
+	Convey("A truncated JSON body is rejected", t, func() {
+		w := serveJSON(category.Search, "/books/_search", `{"query": {"match_all": {}`)
+		So(w.Code, ShouldEqual, http.StatusBadRequest)
+		So(w.Body.String(), ShouldContainSubstring, "line 1, column 26")
+	})
+
+	Convey("A valid NDJSON bulk body is proxied", t, func() {
+		body := `{"index":{"_index":"books"}}` + "\n" + `{"title":"dune"}` + "\n"
+		w := serveJSON(category.Docs, "/_bulk", body)
+		So(w.Code, ShouldEqual, http.StatusOK)
+	})
+
+	Convey("An invalid NDJSON bulk body is rejected with the offending line", t, func() {
+		body := `{"index":{"_index":"books"}}` + "\n" + `{"title":dune}` + "\n"
+		w := serveJSON(category.Docs, "/_bulk", body)
+		So(w.Code, ShouldEqual, http.StatusBadRequest)
+		So(w.Body.String(), ShouldContainSubstring, "line 2, column 10")
+	})
+
+	Convey("The body of the categories without validation isn't checked", t, func() {
+		w := serveJSON(category.Cat, "/_cat/indices", `not json`)
+		So(w.Code, ShouldEqual, http.StatusOK)
+	})
+}
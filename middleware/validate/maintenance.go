@@ -0,0 +1,60 @@
+package validate
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/appbaseio/reactivesearch-api/middleware"
+	"github.com/appbaseio/reactivesearch-api/model/op"
+	"github.com/appbaseio/reactivesearch-api/util"
+)
+
+const (
+	envMaintenanceRetryAfter     = "MAINTENANCE_RETRY_AFTER"
+	defaultMaintenanceRetryAfter = 300
+)
+
+// Maintenance returns a middleware that rejects the write and delete operations
+// while arc is in maintenance mode, reads continue to be served.
+func Maintenance() middleware.Middleware {
+	return timed(maintenance)
+}
+
+func maintenance(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if !util.IsMaintenanceMode() {
+			h(w, req)
+			return
+		}
+
+		reqOp, err := op.FromContext(req.Context())
+		if err != nil {
+			log.Errorln(logTag, ":", err)
+			util.WriteBackError(w, "an error occurred while validating request op", http.StatusInternalServerError)
+			return
+		}
+
+		if *reqOp == op.Write || *reqOp == op.Delete {
+			w.Header().Set("Retry-After", strconv.Itoa(maintenanceRetryAfter()))
+			util.WriteBackError(w, "arc is in maintenance mode, only read operations are allowed", http.StatusServiceUnavailable)
+			return
+		}
+
+		h(w, req)
+	}
+}
+
+// maintenanceRetryAfter returns the number of seconds clients are asked to wait before retrying.
+func maintenanceRetryAfter() int {
+	if value := os.Getenv(envMaintenanceRetryAfter); value != "" {
+		seconds, err := strconv.Atoi(value)
+		if err == nil && seconds > 0 {
+			return seconds
+		}
+		log.Warnln(logTag, ": invalid value for", envMaintenanceRetryAfter, ":", value)
+	}
+	return defaultMaintenanceRetryAfter
+}
@@ -0,0 +1,47 @@
+package validate
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/appbaseio/reactivesearch-api/model/op"
+	"github.com/appbaseio/reactivesearch-api/util"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func serveMaintenance(operation op.Operation) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/books/_doc", nil)
+	req = req.WithContext(op.NewContext(req.Context(), &operation))
+	w := httptest.NewRecorder()
+	maintenance(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})(w, req)
+	return w
+}
+
+func TestMaintenance(t *testing.T) {
+	Convey("Maintenance mode on", t, func() {
+		util.SetMaintenanceMode(true)
+		defer util.SetMaintenanceMode(false)
+
+		Convey("Rejects writes", func() {
+			w := serveMaintenance(op.Write)
+			So(w.Code, ShouldEqual, http.StatusServiceUnavailable)
+			So(w.Header().Get("Retry-After"), ShouldEqual, "300")
+		})
+		Convey("Rejects deletes", func() {
+			So(serveMaintenance(op.Delete).Code, ShouldEqual, http.StatusServiceUnavailable)
+		})
+		Convey("Allows reads", func() {
+			So(serveMaintenance(op.Read).Code, ShouldEqual, http.StatusOK)
+		})
+	})
+
+	Convey("Maintenance mode off", t, func() {
+		So(util.IsMaintenanceMode(), ShouldBeFalse)
+		So(serveMaintenance(op.Write).Code, ShouldEqual, http.StatusOK)
+		So(serveMaintenance(op.Delete).Code, ShouldEqual, http.StatusOK)
+		So(serveMaintenance(op.Read).Code, ShouldEqual, http.StatusOK)
+	})
+}
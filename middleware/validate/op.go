@@ -9,6 +9,7 @@ import (
 
 	"github.com/appbaseio/reactivesearch-api/middleware"
 	"github.com/appbaseio/reactivesearch-api/model/credential"
+	"github.com/appbaseio/reactivesearch-api/model/decision"
 	"github.com/appbaseio/reactivesearch-api/model/op"
 	"github.com/appbaseio/reactivesearch-api/model/permission"
 	"github.com/appbaseio/reactivesearch-api/util"
@@ -16,7 +17,7 @@ import (
 
 // Operation returns a middleware that validates the request operation against the credential operations.
 func Operation() middleware.Middleware {
-	return operation
+	return timed(operation)
 }
 
 func operation(h http.HandlerFunc) http.HandlerFunc {
@@ -47,10 +48,12 @@ func operation(h http.HandlerFunc) http.HandlerFunc {
 
 		if !ok {
 			msg := fmt.Sprintf(`credential cannot perform "%v" operation`, reqOp.String())
+			decision.Record(ctx, "op", false, msg)
 			w.Header().Set("www-authenticate", "Basic realm=\"Authentication Required\"")
 			util.WriteBackError(w, msg, http.StatusUnauthorized)
 			return
 		}
+		decision.Record(ctx, "op", true, "")
 
 		h(w, req)
 	}
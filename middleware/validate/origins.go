@@ -0,0 +1,56 @@
+package validate
+
+import (
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/appbaseio/reactivesearch-api/middleware"
+	"github.com/appbaseio/reactivesearch-api/model/credential"
+	"github.com/appbaseio/reactivesearch-api/model/permission"
+	"github.com/appbaseio/reactivesearch-api/util"
+)
+
+// Origins returns a middleware that validates the request origin against the permission's
+// allowed origins, read from the Origin header. The Referer is validated against the
+// permission's referers by Referers.
+func Origins() middleware.Middleware {
+	return timed(origins)
+}
+
+func origins(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+
+		reqCredential, err := credential.FromContext(ctx)
+		if err != nil {
+			log.Errorln(logTag, ":", err)
+			util.WriteBackError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if reqCredential == credential.Permission {
+			reqPermission, err := permission.FromContext(ctx)
+			if err != nil {
+				log.Errorln(logTag, ":", err)
+				util.WriteBackError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			if !reqPermission.IsOriginAllowed(requestOrigin(req)) {
+				util.WriteBackError(w, "permission doesn't allow requests from this origin", http.StatusForbidden)
+				return
+			}
+		}
+
+		h(w, req)
+	}
+}
+
+// requestOrigin returns the scheme://host[:port] the request originates from.
+func requestOrigin(req *http.Request) string {
+	if origin := req.Header.Get("Origin"); origin != "null" {
+		return origin
+	}
+	return ""
+}
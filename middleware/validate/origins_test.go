@@ -0,0 +1,61 @@
+package validate
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/appbaseio/reactivesearch-api/model/credential"
+	"github.com/appbaseio/reactivesearch-api/model/permission"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func serveOrigins(p *permission.Permission, header, value string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/books/_search", nil)
+	if header != "" {
+		req.Header.Set(header, value)
+	}
+	ctx := credential.NewContext(req.Context(), credential.Permission)
+	ctx = permission.NewContext(ctx, p)
+	req = req.WithContext(ctx)
+	w := httptest.NewRecorder()
+	origins(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})(w, req)
+	return w
+}
+
+func TestOrigins(t *testing.T) {
+	Convey("Origins", t, func() {
+		restricted, err := permission.New("admin",
+			permission.SetAllowedOrigins([]string{"https://shop.example.com"}))
+		So(err, ShouldBeNil)
+		unrestricted, err := permission.New("admin")
+		So(err, ShouldBeNil)
+
+		Convey("A request from an allowed origin is proxied", func() {
+			w := serveOrigins(restricted, "Origin", "https://shop.example.com")
+			So(w.Code, ShouldEqual, http.StatusOK)
+		})
+		Convey("The referer isn't taken for the origin, it's validated against the referers", func() {
+			w := serveOrigins(restricted, "Referer", "https://shop.example.com/cart?id=1")
+			So(w.Code, ShouldEqual, http.StatusForbidden)
+		})
+		Convey("A request from a disallowed origin is rejected", func() {
+			w := serveOrigins(restricted, "Origin", "https://evil.example.org")
+			So(w.Code, ShouldEqual, http.StatusForbidden)
+		})
+		Convey("A request without an origin is rejected", func() {
+			w := serveOrigins(restricted, "", "")
+			So(w.Code, ShouldEqual, http.StatusForbidden)
+		})
+		Convey("An empty allowlist doesn't restrict the origins", func() {
+			w := serveOrigins(unrestricted, "Origin", "https://evil.example.org")
+			So(w.Code, ShouldEqual, http.StatusOK)
+		})
+		Convey("An invalid origin is rejected while creating the permission", func() {
+			_, err := permission.New("admin", permission.SetAllowedOrigins([]string{"shop.example.com"}))
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
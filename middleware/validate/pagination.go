@@ -0,0 +1,110 @@
+package validate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/appbaseio/reactivesearch-api/middleware"
+	"github.com/appbaseio/reactivesearch-api/model/acl"
+	"github.com/appbaseio/reactivesearch-api/model/credential"
+	"github.com/appbaseio/reactivesearch-api/model/permission"
+	"github.com/appbaseio/reactivesearch-api/util"
+)
+
+// defaultSearchSize is the number of hits elasticsearch returns if a search doesn't set its size.
+const defaultSearchSize = 10
+
+// PaginationDepth returns a middleware that rejects the search requests paginating
+// deeper than the maximum pagination depth of the permission.
+func PaginationDepth() middleware.Middleware {
+	return timed(paginationDepth)
+}
+
+func paginationDepth(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+
+		reqCredential, err := credential.FromContext(ctx)
+		if err != nil {
+			log.Errorln(logTag, ":", err)
+			util.WriteBackError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		reqACL, err := acl.FromContext(ctx)
+		if err != nil || reqCredential != credential.Permission || (*reqACL != acl.Search && *reqACL != acl.Msearch) {
+			h(w, req)
+			return
+		}
+
+		reqPermission, err := permission.FromContext(ctx)
+		if err != nil {
+			log.Errorln(logTag, ":", err)
+			util.WriteBackError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if reqPermission.MaxPaginationDepth == nil || *reqPermission.MaxPaginationDepth == 0 {
+			h(w, req)
+			return
+		}
+		maxDepth := *reqPermission.MaxPaginationDepth
+
+		params := req.URL.Query()
+		depth := paginationDepthOf(params.Get("from"), params.Get("size"))
+		if req.Body != nil {
+			body, err := ioutil.ReadAll(req.Body)
+			if err != nil {
+				log.Errorln(logTag, ":", err)
+				util.WriteBackError(w, "unable to read request body", http.StatusBadRequest)
+				return
+			}
+			req.Body.Close()
+			req.Body = ioutil.NopCloser(bytes.NewReader(body))
+			if bodyDepth := bodyPaginationDepth(body); bodyDepth > depth {
+				depth = bodyDepth
+			}
+		}
+
+		if depth > maxDepth {
+			msg := fmt.Sprintf("from + size of %d exceeds the maximum pagination depth of %d, use search_after to paginate deeper", depth, maxDepth)
+			util.WriteBackError(w, msg, http.StatusBadRequest)
+			return
+		}
+
+		h(w, req)
+	}
+}
+
+// paginationDepthOf returns the from + size of a search, the unset values defaulting
+// to those of elasticsearch.
+func paginationDepthOf(from, size string) int {
+	depth := defaultSearchSize
+	if n, err := strconv.Atoi(size); err == nil && n >= 0 {
+		depth = n
+	}
+	if n, err := strconv.Atoi(from); err == nil && n > 0 {
+		depth += n
+	}
+	return depth
+}
+
+// bodyPaginationDepth returns the deepest from + size of the searches of a JSON or NDJSON
+// body. The msearch headers are counted as searches of the default size, which doesn't
+// exceed the depth of a search. Bodies that can't be parsed are left for elasticsearch to reject.
+func bodyPaginationDepth(body []byte) int {
+	depth := 0
+	eachDocument(body, func(doc map[string]interface{}) bool {
+		from, _ := doc["from"].(json.Number)
+		size, _ := doc["size"].(json.Number)
+		if d := paginationDepthOf(string(from), string(size)); d > depth {
+			depth = d
+		}
+		return true
+	})
+	return depth
+}
@@ -0,0 +1,44 @@
+package validate
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/appbaseio/reactivesearch-api/model/acl"
+	"github.com/appbaseio/reactivesearch-api/model/permission"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func servePaginationDepth(p *permission.Permission, a acl.ACL, target, body string) *httptest.ResponseRecorder {
+	return servePermission(paginationDepth, p, a, target, body)
+}
+
+func TestPaginationDepth(t *testing.T) {
+	Convey("PaginationDepth", t, func() {
+		restricted, err := permission.New("admin", permission.SetMaxPaginationDepth(1000))
+		So(err, ShouldBeNil)
+
+		Convey("A search within the depth is proxied", func() {
+			So(servePaginationDepth(restricted, acl.Search, "/books/_search", `{"from":990,"size":10}`).Code, ShouldEqual, http.StatusOK)
+			So(servePaginationDepth(restricted, acl.Search, "/books/_search?from=500", `{}`).Code, ShouldEqual, http.StatusOK)
+		})
+		Convey("A search beyond the depth is rejected", func() {
+			w := servePaginationDepth(restricted, acl.Search, "/books/_search", `{"from":995,"size":10}`)
+			So(w.Code, ShouldEqual, http.StatusBadRequest)
+			So(w.Body.String(), ShouldContainSubstring, "search_after")
+		})
+		Convey("The default size counts towards the depth", func() {
+			So(servePaginationDepth(restricted, acl.Search, "/books/_search?from=995", "").Code, ShouldEqual, http.StatusBadRequest)
+		})
+		Convey("A msearch with a search beyond the depth is rejected", func() {
+			body := "{}\n" + `{"from":0}` + "\n{}\n" + `{"from":5000,"size":20}` + "\n"
+			So(servePaginationDepth(restricted, acl.Msearch, "/_msearch", body).Code, ShouldEqual, http.StatusBadRequest)
+		})
+		Convey("The depth isn't limited without a maximum", func() {
+			unrestricted, err := permission.New("admin")
+			So(err, ShouldBeNil)
+			So(servePaginationDepth(unrestricted, acl.Search, "/books/_search", `{"from":50000}`).Code, ShouldEqual, http.StatusOK)
+		})
+	})
+}
@@ -11,9 +11,9 @@ import (
 // For e.g `validate.Plan([]util.Plan{util.ArcEnterprise}),` restricts the route to only appbase.io enterprise users.
 func Plan(validPlans []util.Plan, byPassValidation bool) middleware.Middleware {
 	if util.ValidatePlans(validPlans, byPassValidation) {
-		return validPlan
+		return timed(validPlan)
 	}
-	return invalidPlan
+	return timed(invalidPlan)
 }
 
 // Throws the payment required error
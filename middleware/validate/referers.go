@@ -15,7 +15,7 @@ import (
 
 // Referers returns a middleware that validates the request referers against the permission referers.
 func Referers() middleware.Middleware {
-	return referers
+	return timed(referers)
 }
 
 func referers(h http.HandlerFunc) http.HandlerFunc {
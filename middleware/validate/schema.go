@@ -0,0 +1,307 @@
+package validate
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/appbaseio/reactivesearch-api/middleware"
+)
+
+// envRequestSchemas points to a JSON file that maps an endpoint (e.g. "_search", "_bulk")
+// to the JSON schema its request body must satisfy.
+const envRequestSchemas = "REQUEST_SCHEMAS_PATH"
+
+var (
+	requestSchemas     map[string]*JSONSchema
+	loadRequestSchemas sync.Once
+)
+
+// JSONSchema is the subset of JSON schema keywords supported for request validation.
+type JSONSchema struct {
+	Type                 string                 `json:"type"`
+	Properties           map[string]*JSONSchema `json:"properties"`
+	Required             []string               `json:"required"`
+	AdditionalProperties *bool                  `json:"additionalProperties"`
+	Items                *JSONSchema            `json:"items"`
+	Enum                 []interface{}          `json:"enum"`
+	Minimum              *float64               `json:"minimum"`
+	Maximum              *float64               `json:"maximum"`
+	MinLength            *int                   `json:"minLength"`
+	MaxLength            *int                   `json:"maxLength"`
+	MinItems             *int                   `json:"minItems"`
+	MaxItems             *int                   `json:"maxItems"`
+}
+
+// FieldError describes a single schema violation in the request body.
+type FieldError struct {
+	Field       string `json:"field"`
+	Description string `json:"description"`
+}
+
+// Schema returns a middleware that validates the request body against the JSON schema
+// configured for the requested endpoint. Requests to endpoints without a configured
+// schema are passed through untouched.
+func Schema() middleware.Middleware {
+	loadRequestSchemas.Do(func() {
+		path := os.Getenv(envRequestSchemas)
+		if path == "" {
+			return
+		}
+		raw, err := ioutil.ReadFile(path)
+		if err != nil {
+			log.Errorln(logTag, ": unable to read request schemas:", err)
+			return
+		}
+		err = json.Unmarshal(raw, &requestSchemas)
+		if err != nil {
+			log.Errorln(logTag, ": invalid request schemas:", err)
+		}
+	})
+	return timed(schema)
+}
+
+func schema(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		endpoint := schemaEndpoint(req.URL.Path)
+		s, ok := requestSchemas[endpoint]
+		if !ok || req.Body == nil {
+			h(w, req)
+			return
+		}
+
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			log.Errorln(logTag, ": unable to read request body:", err)
+			writeBackFieldErrors(w, "unable to read request body", nil)
+			return
+		}
+		req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		var fieldErrors []FieldError
+		if endpoint == "_bulk" || endpoint == "_msearch" {
+			fieldErrors, err = s.validateNDJSON(body, requestSchemas[endpoint+"/header"], endpoint == "_bulk")
+		} else {
+			fieldErrors, err = s.validateJSON(body, "")
+		}
+		if err != nil {
+			writeBackFieldErrors(w, err.Error(), nil)
+			return
+		}
+		if len(fieldErrors) > 0 {
+			writeBackFieldErrors(w, "request body failed schema validation", fieldErrors)
+			return
+		}
+
+		h(w, req)
+	}
+}
+
+// schemaEndpoint returns the last underscore-prefixed segment of the path,
+// e.g. "/books/_search" -> "_search". The scroll requests have their own endpoint,
+// "_search/scroll", as their bodies carry the scroll id rather than a search.
+func schemaEndpoint(path string) string {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i := len(segments) - 1; i >= 0; i-- {
+		if strings.HasPrefix(segments[i], "_") {
+			if segments[i] == "_search" && i+1 < len(segments) && segments[i+1] == "scroll" {
+				return "_search/scroll"
+			}
+			return segments[i]
+		}
+	}
+	return ""
+}
+
+// validateNDJSON validates the lines of an NDJSON body, which alternate between an action
+// or header line, validated against the header schema if any, and a body line, validated
+// against the schema. The delete actions of a bulk body don't have a body line.
+func (s *JSONSchema) validateNDJSON(body []byte, header *JSONSchema, bulk bool) ([]FieldError, error) {
+	if header == nil {
+		// the header lines are only checked to be well-formed
+		header = &JSONSchema{}
+	}
+	var fieldErrors []FieldError
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 64*1024), len(body)+1)
+	line := 0
+	isHeader := true
+	for scanner.Scan() {
+		line++
+		doc := bytes.TrimSpace(scanner.Bytes())
+		if len(doc) == 0 {
+			continue
+		}
+		lineSchema := s
+		if isHeader {
+			lineSchema = header
+		}
+		errs, err := lineSchema.validateJSON(doc, fmt.Sprintf("line %d: ", line))
+		if err != nil {
+			return nil, err
+		}
+		fieldErrors = append(fieldErrors, errs...)
+		if isHeader && bulk {
+			var action map[string]interface{}
+			json.Unmarshal(doc, &action)
+			_, isDelete := action["delete"]
+			isHeader = isDelete
+			continue
+		}
+		isHeader = !isHeader
+	}
+	return fieldErrors, scanner.Err()
+}
+
+func (s *JSONSchema) validateJSON(doc []byte, prefix string) ([]FieldError, error) {
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(doc))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return nil, fmt.Errorf("%smalformed JSON body: %v", prefix, err)
+	}
+	var fieldErrors []FieldError
+	for _, e := range s.validate("", value) {
+		e.Field = prefix + e.Field
+		fieldErrors = append(fieldErrors, e)
+	}
+	return fieldErrors, nil
+}
+
+func (s *JSONSchema) validate(field string, value interface{}) []FieldError {
+	name := field
+	if name == "" {
+		name = "(root)"
+	}
+	fail := func(format string, args ...interface{}) []FieldError {
+		return []FieldError{{Field: name, Description: fmt.Sprintf(format, args...)}}
+	}
+
+	if s.Type != "" && !isJSONType(s.Type, value) {
+		return fail("invalid type, expected %s but got %s", s.Type, jsonType(value))
+	}
+	if len(s.Enum) > 0 && !inEnum(s.Enum, value) {
+		return fail("must be one of %v", s.Enum)
+	}
+
+	var fieldErrors []FieldError
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, key := range s.Required {
+			if _, ok := v[key]; !ok {
+				fieldErrors = append(fieldErrors, FieldError{
+					Field:       joinField(field, key),
+					Description: "is required",
+				})
+			}
+		}
+		for key, child := range v {
+			childSchema, ok := s.Properties[key]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					fieldErrors = append(fieldErrors, FieldError{
+						Field:       joinField(field, key),
+						Description: "additional property is not allowed",
+					})
+				}
+				continue
+			}
+			fieldErrors = append(fieldErrors, childSchema.validate(joinField(field, key), child)...)
+		}
+	case []interface{}:
+		if s.MinItems != nil && len(v) < *s.MinItems {
+			return fail("must have at least %d items", *s.MinItems)
+		}
+		if s.MaxItems != nil && len(v) > *s.MaxItems {
+			return fail("must have at most %d items", *s.MaxItems)
+		}
+		if s.Items != nil {
+			for i, item := range v {
+				fieldErrors = append(fieldErrors, s.Items.validate(fmt.Sprintf("%s.%d", name, i), item)...)
+			}
+		}
+	case string:
+		if s.MinLength != nil && len(v) < *s.MinLength {
+			return fail("must be at least %d characters long", *s.MinLength)
+		}
+		if s.MaxLength != nil && len(v) > *s.MaxLength {
+			return fail("must be at most %d characters long", *s.MaxLength)
+		}
+	case json.Number:
+		n, _ := v.Float64()
+		if s.Minimum != nil && n < *s.Minimum {
+			return fail("must be greater than or equal to %v", *s.Minimum)
+		}
+		if s.Maximum != nil && n > *s.Maximum {
+			return fail("must be less than or equal to %v", *s.Maximum)
+		}
+	}
+	return fieldErrors
+}
+
+func joinField(parent, key string) string {
+	if parent == "" {
+		return key
+	}
+	return parent + "." + key
+}
+
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	default:
+		return "null"
+	}
+}
+
+func isJSONType(expected string, value interface{}) bool {
+	actual := jsonType(value)
+	return actual == expected || (expected == "number" && actual == "integer")
+}
+
+func inEnum(enum []interface{}, value interface{}) bool {
+	actual, _ := json.Marshal(value)
+	for _, e := range enum {
+		expected, _ := json.Marshal(e)
+		if bytes.Equal(actual, expected) {
+			return true
+		}
+	}
+	return false
+}
+
+func writeBackFieldErrors(w http.ResponseWriter, msg string, fieldErrors []FieldError) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusBadRequest)
+	resp := map[string]interface{}{
+		"code":    http.StatusBadRequest,
+		"status":  http.StatusText(http.StatusBadRequest),
+		"message": msg,
+	}
+	if len(fieldErrors) > 0 {
+		resp["fields"] = fieldErrors
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"error": resp})
+}
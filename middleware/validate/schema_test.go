@@ -0,0 +1,135 @@
+package validate
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const testSearchSchema = `{
+	"_search": {
+		"type": "object",
+		"additionalProperties": false,
+		"properties": {
+			"query": {"type": "object"},
+			"size": {"type": "integer", "minimum": 0, "maximum": 100}
+		}
+	}
+}`
+
+func serveSchema(path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	w := httptest.NewRecorder()
+	schema(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})(w, req)
+	return w
+}
+
+func TestSchema(t *testing.T) {
+	err := json.Unmarshal([]byte(testSearchSchema), &requestSchemas)
+	if err != nil {
+		t.Fatalf("unable to parse test schema: %v", err)
+	}
+	defer func() { requestSchemas = nil }()
+
+	Convey("A valid body should be proxied", t, func() {
+		w := serveSchema("/books/_search", `{"query": {"match_all": {}}, "size": 10}`)
+		So(w.Code, ShouldEqual, http.StatusOK)
+	})
+
+	Convey("An invalid body should be rejected with field level errors", t, func() {
+		w := serveSchema("/books/_search", `{"query": [], "size": 500, "from": 1}`)
+		So(w.Code, ShouldEqual, http.StatusBadRequest)
+
+		var resp struct {
+			Error struct {
+				Fields []FieldError `json:"fields"`
+			} `json:"error"`
+		}
+		So(json.Unmarshal(w.Body.Bytes(), &resp), ShouldBeNil)
+		fields := make(map[string]string)
+		for _, e := range resp.Error.Fields {
+			fields[e.Field] = e.Description
+		}
+		So(fields, ShouldContainKey, "query")
+		So(fields, ShouldContainKey, "size")
+		So(fields, ShouldContainKey, "from")
+	})
+
+	Convey("A malformed body should be rejected", t, func() {
+		w := serveSchema("/books/_search", `{"query":`)
+		So(w.Code, ShouldEqual, http.StatusBadRequest)
+	})
+
+	Convey("The scroll requests aren't validated against the search schema", t, func() {
+		So(serveSchema("/_search/scroll", `{"scroll": "1m", "scroll_id": "DXF1ZXJ5"}`).Code, ShouldEqual, http.StatusOK)
+		So(schemaEndpoint("/_search/scroll/DXF1ZXJ5"), ShouldEqual, "_search/scroll")
+	})
+
+	Convey("Endpoints without a schema should be proxied", t, func() {
+		w := serveSchema("/books/_doc/1", `{"query": []}`)
+		So(w.Code, ShouldEqual, http.StatusOK)
+	})
+}
+
+const testNDJSONSchemas = `{
+	"_msearch/header": {
+		"type": "object",
+		"additionalProperties": false,
+		"properties": {"index": {"type": "string"}}
+	},
+	"_msearch": {
+		"type": "object",
+		"additionalProperties": false,
+		"properties": {"query": {"type": "object"}}
+	},
+	"_bulk": {
+		"type": "object",
+		"required": ["title"]
+	}
+}`
+
+func TestNDJSONSchema(t *testing.T) {
+	err := json.Unmarshal([]byte(testNDJSONSchemas), &requestSchemas)
+	if err != nil {
+		t.Fatalf("unable to parse test schema: %v", err)
+	}
+	defer func() { requestSchemas = nil }()
+
+	Convey("The msearch headers and searches are validated against their own schemas", t, func() {
+		body := `{"index": "books"}` + "\n" + `{"query": {"match_all": {}}}` + "\n"
+		So(serveSchema("/_msearch", body).Code, ShouldEqual, http.StatusOK)
+
+		body = `{"index": "books", "preference": "a"}` + "\n" + `{"query": {"match_all": {}}}` + "\n"
+		w := serveSchema("/_msearch", body)
+		So(w.Code, ShouldEqual, http.StatusBadRequest)
+		So(w.Body.String(), ShouldContainSubstring, "line 1: preference")
+
+		body = `{"index": "books"}` + "\n" + `{"query": {"match_all": {}}, "size": 10}` + "\n"
+		w = serveSchema("/_msearch", body)
+		So(w.Code, ShouldEqual, http.StatusBadRequest)
+		So(w.Body.String(), ShouldContainSubstring, "line 2: size")
+	})
+
+	Convey("The bulk sources are validated, not the actions", t, func() {
+		body := `{"index": {"_index": "books"}}` + "\n" + `{"title": "Dune"}` + "\n" +
+			`{"delete": {"_index": "books", "_id": "1"}}` + "\n" +
+			`{"index": {"_index": "books"}}` + "\n" + `{"title": "Emma"}` + "\n"
+		So(serveSchema("/_bulk", body).Code, ShouldEqual, http.StatusOK)
+
+		body = `{"delete": {"_index": "books", "_id": "1"}}` + "\n" +
+			`{"index": {"_index": "books"}}` + "\n" + `{"author": "Austen"}` + "\n"
+		w := serveSchema("/_bulk", body)
+		So(w.Code, ShouldEqual, http.StatusBadRequest)
+		So(w.Body.String(), ShouldContainSubstring, "line 3: title")
+	})
+
+	Convey("A malformed header is rejected", t, func() {
+		So(serveSchema("/_bulk", `{"index":`+"\n"+`{"title": "Dune"}`+"\n").Code, ShouldEqual, http.StatusBadRequest)
+	})
+}
@@ -0,0 +1,204 @@
+package validate
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/appbaseio/reactivesearch-api/middleware"
+	"github.com/appbaseio/reactivesearch-api/model/acl"
+	"github.com/appbaseio/reactivesearch-api/model/credential"
+	"github.com/appbaseio/reactivesearch-api/model/permission"
+	"github.com/appbaseio/reactivesearch-api/util"
+)
+
+// scriptKeys are the query keys that execute scripts in elasticsearch.
+var scriptKeys = map[string]bool{
+	"script":          true,
+	"script_fields":   true,
+	"scripted_metric": true,
+}
+
+// scriptedACLs are the requests whose bodies can run scripts, the bodies of the other
+// requests, e.g. the indexed documents, are data and aren't scanned.
+var scriptedACLs = []acl.ACL{
+	acl.Search,
+	acl.Msearch,
+	acl.Count,
+	acl.Explain,
+	acl.DeleteByQuery,
+	acl.Update,
+	acl.UpdateByQuery,
+	acl.Reindex,
+	acl.Bulk,
+}
+
+// dataKeys hold documents or field names rather than query DSL, so a "script" in
+// them isn't a script, e.g. a document field named "script".
+var dataKeys = map[string]bool{
+	// the partial document and the upsert of an update
+	"doc":    true,
+	"upsert": true,
+	// the documents of a percolate query
+	"document":  true,
+	"documents": true,
+	// the queries keyed by a field name
+	"term":                true,
+	"terms":               true,
+	"match":               true,
+	"match_phrase":        true,
+	"match_phrase_prefix": true,
+	"match_bool_prefix":   true,
+	"prefix":              true,
+	"wildcard":            true,
+	"regexp":              true,
+	"fuzzy":               true,
+	"range":               true,
+}
+
+// aggregationKeys are the data keys that are also the types of the aggregations taking
+// a script, e.g. `{"aggs":{"genres":{"terms":{"script":"..."}}}}`, which are inspected
+// in the aggregation bodies.
+var aggregationKeys = map[string]bool{
+	"terms": true,
+	"range": true,
+}
+
+// Scripts returns a middleware that rejects the requests using scripts in their
+// body unless the permission is allowed to use scripts.
+func Scripts() middleware.Middleware {
+	return timed(scripts)
+}
+
+func scripts(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+
+		reqCredential, err := credential.FromContext(ctx)
+		if err != nil {
+			log.Errorln(logTag, ":", err)
+			util.WriteBackError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		reqACL, err := acl.FromContext(ctx)
+		if err != nil {
+			log.Errorln(logTag, ":", err)
+			util.WriteBackError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if reqCredential == credential.Permission && acl.Contains(scriptedACLs, *reqACL) && req.Body != nil {
+			reqPermission, err := permission.FromContext(ctx)
+			if err != nil {
+				log.Errorln(logTag, ":", err)
+				util.WriteBackError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			if !reqPermission.CanUseScripts() {
+				body, err := ioutil.ReadAll(req.Body)
+				if err != nil {
+					log.Errorln(logTag, ":", err)
+					util.WriteBackError(w, "unable to read request body", http.StatusBadRequest)
+					return
+				}
+				req.Body.Close()
+				req.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+				find := findScript
+				if *reqACL == acl.Bulk {
+					find = findBulkScript
+				}
+				if key, ok := find(body); ok {
+					util.WriteBackError(w, `permission isn't allowed to use "`+key+`" in queries`, http.StatusForbidden)
+					return
+				}
+			}
+		}
+
+		h(w, req)
+	}
+}
+
+// findScript looks for scripts at any depth of a JSON or NDJSON body, outside of
+// the data keys. Bodies that can't be parsed are left for elasticsearch to reject.
+func findScript(body []byte) (string, bool) {
+	var key string
+	var found bool
+	eachDocument(body, func(doc map[string]interface{}) bool {
+		key, found = findScriptKey(doc)
+		return !found
+	})
+	return key, found
+}
+
+// findBulkScript looks for scripts in the update actions of a bulk body, the sources
+// of the other actions are documents.
+func findBulkScript(body []byte) (string, bool) {
+	for _, action := range parseBulkActions(body) {
+		if action.name != "update" {
+			continue
+		}
+		if key, ok := findScript(action.source); ok {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+func findScriptKey(value interface{}) (string, bool) {
+	return findScriptKeyIn(value, false)
+}
+
+// findScriptKeyIn looks for the script keys in a value, which is an aggregation body if
+// aggBody is set, i.e. the value of an aggregation name in the aggregations.
+func findScriptKeyIn(value interface{}, aggBody bool) (string, bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if dataKeys[key] && !(aggBody && aggregationKeys[key]) {
+				continue
+			}
+			if key == "script" && isScript(child) || key != "script" && scriptKeys[key] {
+				return key, true
+			}
+			if aggs, ok := child.(map[string]interface{}); ok && (key == "aggs" || key == "aggregations") {
+				for _, agg := range aggs {
+					if found, ok := findScriptKeyIn(agg, true); ok {
+						return found, true
+					}
+				}
+				continue
+			}
+			if found, ok := findScriptKeyIn(child, false); ok {
+				return found, true
+			}
+		}
+	case []interface{}:
+		for _, child := range v {
+			if found, ok := findScriptKeyIn(child, false); ok {
+				return found, true
+			}
+		}
+	}
+	return "", false
+}
+
+// isScript reports whether the value of a "script" key is a script, either inline as
+// a string or an object with its source or stored id.
+func isScript(value interface{}) bool {
+	switch v := value.(type) {
+	case string:
+		return true
+	case map[string]interface{}:
+		for _, key := range []string{"source", "inline", "id"} {
+			if _, ok := v[key]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package validate
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/appbaseio/reactivesearch-api/model/acl"
+	"github.com/appbaseio/reactivesearch-api/model/permission"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const scriptQuery = `{
+	"query": {
+		"bool": {
+			"filter": [
+				{"term": {"genre": "fiction"}},
+				{"script": {"script": {"source": "doc['rating'].value > 3"}}}
+			]
+		}
+	}
+}`
+
+func serveScripts(p *permission.Permission, body string) *httptest.ResponseRecorder {
+	return serveScriptsACL(p, acl.Search, body)
+}
+
+func serveScriptsACL(p *permission.Permission, reqACL acl.ACL, body string) *httptest.ResponseRecorder {
+	return servePermission(scripts, p, reqACL, "/books/_search", body)
+}
+
+func TestScripts(t *testing.T) {
+	Convey("Scripts", t, func() {
+		restricted, err := permission.New("admin")
+		So(err, ShouldBeNil)
+		privileged, err := permission.New("admin", permission.SetAllowScripts(true))
+		So(err, ShouldBeNil)
+
+		Convey("A nested script is rejected for a restricted permission", func() {
+			So(serveScripts(restricted, scriptQuery).Code, ShouldEqual, http.StatusForbidden)
+		})
+		Convey("Scripts in a msearch body are rejected for a restricted permission", func() {
+			body := "{}\n" + `{"aggs":{"total":{"scripted_metric":{}}}}` + "\n"
+			So(serveScripts(restricted, body).Code, ShouldEqual, http.StatusForbidden)
+		})
+		Convey("A script is allowed for a privileged permission", func() {
+			So(serveScripts(privileged, scriptQuery).Code, ShouldEqual, http.StatusOK)
+		})
+		Convey("A query without scripts is allowed", func() {
+			So(serveScripts(restricted, `{"query":{"match_all":{}}}`).Code, ShouldEqual, http.StatusOK)
+		})
+		Convey("A field named script isn't a script", func() {
+			So(serveScripts(restricted, `{"query":{"match":{"script":{"query":"hamlet"}}}}`).Code, ShouldEqual, http.StatusOK)
+			So(serveScripts(restricted, `{"query":{"term":{"script":"hamlet"}}}`).Code, ShouldEqual, http.StatusOK)
+		})
+		Convey("The scripts of the terms and range aggregations are rejected for a restricted permission", func() {
+			terms := `{"aggs":{"genres":{"terms":{"script":{"source":"doc['genre'].value"}}}}}`
+			So(serveScripts(restricted, terms).Code, ShouldEqual, http.StatusForbidden)
+			ranges := `{"aggs":{"ratings":{"range":{"script":"doc['rating'].value","ranges":[{"to":3}]}}}}`
+			So(serveScripts(restricted, ranges).Code, ShouldEqual, http.StatusForbidden)
+			nested := `{"aggs":{"genres":{"terms":{"field":"genre"},"aggs":{"ratings":{"range":{"script":"doc['rating'].value"}}}}}}`
+			So(serveScripts(restricted, nested).Code, ShouldEqual, http.StatusForbidden)
+			So(serveScripts(privileged, terms).Code, ShouldEqual, http.StatusOK)
+		})
+		Convey("A field named script in the terms and range queries isn't a script", func() {
+			So(serveScripts(restricted, `{"query":{"terms":{"script":["hamlet"]}}}`).Code, ShouldEqual, http.StatusOK)
+			So(serveScripts(restricted, `{"query":{"range":{"script":{"gte":"a"}}}}`).Code, ShouldEqual, http.StatusOK)
+			filtered := `{"aggs":{"plays":{"filter":{"terms":{"script":{"index":"plays","id":"1","path":"script"}}}}}}`
+			So(serveScripts(restricted, filtered).Code, ShouldEqual, http.StatusOK)
+		})
+		Convey("The documents with a script field are written", func() {
+			doc := `{"title":"Hamlet","script":{"source":"the play"}}`
+			So(serveScriptsACL(restricted, acl.Index, doc).Code, ShouldEqual, http.StatusOK)
+			So(serveScriptsACL(restricted, acl.Create, doc).Code, ShouldEqual, http.StatusOK)
+			So(serveScriptsACL(restricted, acl.Update, `{"doc":`+doc+`}`).Code, ShouldEqual, http.StatusOK)
+			bulk := `{"index":{"_index":"books"}}` + "\n" + doc + "\n"
+			So(serveScriptsACL(restricted, acl.Bulk, bulk).Code, ShouldEqual, http.StatusOK)
+		})
+		Convey("The scripted updates are rejected for a restricted permission", func() {
+			update := `{"script":{"source":"ctx._source.views++"}}`
+			So(serveScriptsACL(restricted, acl.Update, update).Code, ShouldEqual, http.StatusForbidden)
+			So(serveScriptsACL(restricted, acl.UpdateByQuery, update).Code, ShouldEqual, http.StatusForbidden)
+			bulk := `{"update":{"_index":"books","_id":"1"}}` + "\n" + update + "\n"
+			So(serveScriptsACL(restricted, acl.Bulk, bulk).Code, ShouldEqual, http.StatusForbidden)
+		})
+	})
+}
@@ -0,0 +1,139 @@
+package validate
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/appbaseio/reactivesearch-api/middleware"
+	"github.com/appbaseio/reactivesearch-api/model/acl"
+	"github.com/appbaseio/reactivesearch-api/model/credential"
+	"github.com/appbaseio/reactivesearch-api/model/permission"
+	"github.com/appbaseio/reactivesearch-api/util"
+)
+
+// SortFields returns a middleware that rejects the search requests sorting on fields
+// that aren't allowed by the permission.
+func SortFields() middleware.Middleware {
+	return timed(sortFields)
+}
+
+func sortFields(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+
+		reqCredential, err := credential.FromContext(ctx)
+		if err != nil {
+			log.Errorln(logTag, ":", err)
+			util.WriteBackError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		reqACL, err := acl.FromContext(ctx)
+		if err != nil || reqCredential != credential.Permission || (*reqACL != acl.Search && *reqACL != acl.Msearch) {
+			h(w, req)
+			return
+		}
+
+		reqPermission, err := permission.FromContext(ctx)
+		if err != nil {
+			log.Errorln(logTag, ":", err)
+			util.WriteBackError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if len(reqPermission.AllowedSortFields) == 0 {
+			h(w, req)
+			return
+		}
+
+		fields := sortParamFields(req.URL.Query().Get("sort"))
+		if req.Body != nil {
+			body, err := ioutil.ReadAll(req.Body)
+			if err != nil {
+				log.Errorln(logTag, ":", err)
+				util.WriteBackError(w, "unable to read request body", http.StatusBadRequest)
+				return
+			}
+			req.Body.Close()
+			req.Body = ioutil.NopCloser(bytes.NewReader(body))
+			fields = append(fields, sortBodyFields(body)...)
+		}
+
+		for _, field := range fields {
+			if !reqPermission.IsSortFieldAllowed(field) {
+				util.WriteBackError(w, `permission isn't allowed to sort on "`+field+`"`, http.StatusForbidden)
+				return
+			}
+		}
+
+		h(w, req)
+	}
+}
+
+// sortParamFields returns the fields of a sort parameter of the form field:direction,...
+func sortParamFields(param string) []string {
+	var fields []string
+	for _, s := range strings.Split(param, ",") {
+		if field := strings.TrimSpace(strings.SplitN(s, ":", 2)[0]); field != "" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// sortBodyFields returns the fields of the sort clauses of a JSON or NDJSON body,
+// including the ones of its top_hits and inner_hits.
+// Bodies that can't be parsed are left for elasticsearch to reject.
+func sortBodyFields(body []byte) []string {
+	var fields []string
+	eachDocument(body, func(doc map[string]interface{}) bool {
+		fields = append(fields, sortClauseFields(doc["sort"])...)
+		fields = append(fields, hitsSortFields(doc)...)
+		return true
+	})
+	return fields
+}
+
+// hitsSortFields returns the fields of the sort clauses of the top_hits and inner_hits
+// sections at any depth of a body, e.g. in the aggregations, the collapse or the queries.
+func hitsSortFields(value interface{}) []string {
+	var fields []string
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if dataKeys[key] {
+				continue
+			}
+			if hits, ok := child.(map[string]interface{}); ok && (key == "top_hits" || key == "inner_hits") {
+				fields = append(fields, sortClauseFields(hits["sort"])...)
+			}
+			fields = append(fields, hitsSortFields(child)...)
+		}
+	case []interface{}:
+		for _, child := range v {
+			fields = append(fields, hitsSortFields(child)...)
+		}
+	}
+	return fields
+}
+
+// sortClauseFields returns the fields of a sort clause, which is either a field, an object
+// keyed by the fields or an array of those.
+func sortClauseFields(clause interface{}) []string {
+	var fields []string
+	switch c := clause.(type) {
+	case string:
+		fields = append(fields, c)
+	case map[string]interface{}:
+		for field := range c {
+			fields = append(fields, field)
+		}
+	case []interface{}:
+		for _, s := range c {
+			fields = append(fields, sortClauseFields(s)...)
+		}
+	}
+	return fields
+}
@@ -0,0 +1,61 @@
+package validate
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/appbaseio/reactivesearch-api/model/acl"
+	"github.com/appbaseio/reactivesearch-api/model/permission"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func serveSortFields(p *permission.Permission, a acl.ACL, target, body string) *httptest.ResponseRecorder {
+	return servePermission(sortFields, p, a, target, body)
+}
+
+func TestSortFields(t *testing.T) {
+	Convey("SortFields", t, func() {
+		restricted, err := permission.New("admin", permission.SetAllowedSortFields([]string{"rating", "published_at"}))
+		So(err, ShouldBeNil)
+
+		Convey("An allowed sort field is proxied", func() {
+			body := `{"sort":[{"rating":{"order":"desc"}},"published_at","_score"]}`
+			So(serveSortFields(restricted, acl.Search, "/books/_search", body).Code, ShouldEqual, http.StatusOK)
+		})
+		Convey("A disallowed sort field is rejected", func() {
+			w := serveSortFields(restricted, acl.Search, "/books/_search", `{"sort":{"price":"asc"}}`)
+			So(w.Code, ShouldEqual, http.StatusForbidden)
+			So(w.Body.String(), ShouldContainSubstring, `sort on \"price\"`)
+		})
+		Convey("A disallowed sort field of a msearch body is rejected", func() {
+			body := "{}\n" + `{"sort":["rating"]}` + "\n{}\n" + `{"sort":["price"]}` + "\n"
+			So(serveSortFields(restricted, acl.Msearch, "/_msearch", body).Code, ShouldEqual, http.StatusForbidden)
+		})
+		Convey("A disallowed sort field of the inner hits is rejected", func() {
+			for _, body := range []string{
+				`{"aggs":{"by_genre":{"terms":{"field":"genre"},"aggs":{"top":{"top_hits":{"sort":[{"price":"asc"}]}}}}}}`,
+				`{"collapse":{"field":"author","inner_hits":{"name":"cheapest","sort":["price"]}}}`,
+				`{"query":{"nested":{"path":"editions","query":{"match_all":{}},"inner_hits":{"sort":{"price":"desc"}}}}}`,
+			} {
+				w := serveSortFields(restricted, acl.Search, "/books/_search", body)
+				So(w.Code, ShouldEqual, http.StatusForbidden)
+				So(w.Body.String(), ShouldContainSubstring, `sort on \"price\"`)
+			}
+		})
+		Convey("An allowed sort field of the inner hits is proxied", func() {
+			body := `{"collapse":{"field":"author","inner_hits":{"name":"best","sort":["rating"]}}}`
+			So(serveSortFields(restricted, acl.Search, "/books/_search", body).Code, ShouldEqual, http.StatusOK)
+		})
+		Convey("A disallowed sort parameter is rejected", func() {
+			w := serveSortFields(restricted, acl.Search, "/books/_search?sort=rating:desc,price:asc", "")
+			So(w.Code, ShouldEqual, http.StatusForbidden)
+		})
+		Convey("Sort fields aren't restricted without an allowlist", func() {
+			unrestricted, err := permission.New("admin")
+			So(err, ShouldBeNil)
+			w := serveSortFields(unrestricted, acl.Search, "/books/_search", `{"sort":{"price":"asc"}}`)
+			So(w.Code, ShouldEqual, http.StatusOK)
+		})
+	})
+}
@@ -18,7 +18,7 @@ const logTag = "[validate]"
 
 // Sources returns a middleware that validates the request sources against the permission sources.
 func Sources() middleware.Middleware {
-	return sources
+	return timed(sources)
 }
 
 func sources(h http.HandlerFunc) http.HandlerFunc {
@@ -0,0 +1,33 @@
+package validate
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/appbaseio/reactivesearch-api/middleware"
+	"github.com/appbaseio/reactivesearch-api/model/timing"
+)
+
+// timed adds the time the request spends in the middleware, up until it's passed on to
+// the next one or rejected, to the validate phase of the request timings.
+func timed(m middleware.Middleware) middleware.Middleware {
+	return func(h http.HandlerFunc) http.HandlerFunc {
+		untimed := m(h)
+		return func(w http.ResponseWriter, req *http.Request) {
+			if _, err := timing.FromContext(req.Context()); err != nil {
+				untimed(w, req)
+				return
+			}
+			start := time.Now()
+			served := false
+			m(func(w http.ResponseWriter, r *http.Request) {
+				served = true
+				timing.Add(r.Context(), timing.Validate, time.Since(start))
+				h(w, r)
+			})(w, req)
+			if !served {
+				timing.Add(req.Context(), timing.Validate, time.Since(start))
+			}
+		}
+	}
+}
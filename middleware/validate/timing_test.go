@@ -0,0 +1,48 @@
+package validate
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/appbaseio/reactivesearch-api/model/timing"
+	"github.com/appbaseio/reactivesearch-api/util"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTimed(t *testing.T) {
+	Convey("Time the validate middlewares", t, func() {
+		slow := func(reject bool) http.HandlerFunc {
+			return timed(func(h http.HandlerFunc) http.HandlerFunc {
+				return func(w http.ResponseWriter, req *http.Request) {
+					time.Sleep(10 * time.Millisecond)
+					if reject {
+						util.WriteBackError(w, "rejected", http.StatusForbidden)
+						return
+					}
+					h(w, req)
+				}
+			})(func(w http.ResponseWriter, req *http.Request) {
+				// the time spent after the middleware isn't part of the validate phase
+				time.Sleep(50 * time.Millisecond)
+				w.WriteHeader(http.StatusOK)
+			})
+		}
+		serve := func(h http.HandlerFunc) *timing.Timings {
+			timings := &timing.Timings{}
+			req := httptest.NewRequest(http.MethodGet, "/books/_search", nil)
+			h(httptest.NewRecorder(), req.WithContext(timing.NewContext(req.Context(), timings)))
+			return timings
+		}
+
+		Convey("The time until the request is passed on is recorded", func() {
+			d := serve(slow(false)).Duration(timing.Validate)
+			So(d, ShouldBeGreaterThanOrEqualTo, 10*time.Millisecond)
+			So(d, ShouldBeLessThan, 50*time.Millisecond)
+		})
+		Convey("The time until the request is rejected is recorded", func() {
+			So(serve(slow(true)).Duration(timing.Validate), ShouldBeGreaterThanOrEqualTo, 10*time.Millisecond)
+		})
+	})
+}
@@ -0,0 +1,70 @@
+package authmethod
+
+import (
+	"context"
+	"sync"
+
+	"github.com/appbaseio/reactivesearch-api/errors"
+)
+
+type contextKey string
+
+// ctxKey is a key against which the auth method holder of a request is stored in the context.
+const ctxKey = contextKey("auth_method")
+
+// Method is the scheme a request was authenticated with.
+type Method string
+
+// Auth methods
+const (
+	Basic     Method = "basic"
+	JWT       Method = "jwt"
+	Anonymous Method = "anonymous"
+)
+
+// Holder carries the auth method of a request up the middleware chain, i.e. the auth
+// middleware sets it for the middlewares wrapping it, such as the logs recorder.
+type Holder struct {
+	mu     sync.Mutex
+	method Method
+}
+
+// Set sets the auth method of the request.
+func (h *Holder) Set(m Method) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.method = m
+}
+
+// Method returns the auth method of the request, empty if it isn't authenticated.
+func (h *Holder) Method() Method {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.method
+}
+
+// NewContext returns a new context with the given auth method holder.
+func NewContext(ctx context.Context, h *Holder) context.Context {
+	return context.WithValue(ctx, ctxKey, h)
+}
+
+// FromContext retrieves the auth method holder stored against the authmethod.ctxKey from the context.
+func FromContext(ctx context.Context) (*Holder, error) {
+	ctxHolder := ctx.Value(ctxKey)
+	if ctxHolder == nil {
+		return nil, errors.NewNotFoundInContextError("auth method")
+	}
+	holder, ok := ctxHolder.(*Holder)
+	if !ok {
+		return nil, errors.NewInvalidCastError("ctxHolder", "*authmethod.Holder")
+	}
+	return holder, nil
+}
+
+// Set sets the auth method in the holder of the context, if the auth method of the
+// request is being tracked.
+func Set(ctx context.Context, m Method) {
+	if holder, err := FromContext(ctx); err == nil {
+		holder.Set(m)
+	}
+}
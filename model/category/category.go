@@ -14,6 +14,9 @@ type contextKey string
 // ctxKey is a key against which an category.Categories is stored in the context.
 const ctxKey = contextKey("category")
 
+// fallbackCtxKey is a key against which the category fallback indicator is stored in the context.
+const fallbackCtxKey = contextKey("category_fallback")
+
 // Category represents category type
 type Category int
 
@@ -71,6 +74,12 @@ func (c Category) String() string {
 	}[c]
 }
 
+// Code returns the numeric code of the category, which stays the same when its name
+// changes. The codes are the positions of the categories, which must only be appended.
+func (c Category) Code() int {
+	return int(c)
+}
+
 // UnmarshalJSON is an implementation of Unmarshaler interface for unmarshaling category.Categories.
 func (c *Category) UnmarshalJSON(bytes []byte) error {
 	var category string
@@ -317,22 +326,41 @@ func FromContext(ctx context.Context) (*Category, error) {
 	return reqACL, nil
 }
 
+// NewFallbackContext returns a new context that marks whether the category of the
+// request fell back to a default instead of being matched.
+func NewFallbackContext(ctx context.Context, fallback bool) context.Context {
+	return context.WithValue(ctx, fallbackCtxKey, fallback)
+}
+
+// IsFallback returns true if the category of the request fell back to a default.
+func IsFallback(ctx context.Context) bool {
+	fallback, _ := ctx.Value(fallbackCtxKey).(bool)
+	return fallback
+}
+
 // FromString returns the Categories from string tags.
 func FromString(tag string) Category {
+	c, _ := Match(tag)
+	return c
+}
+
+// Match returns the category of the string tag, and whether the tag matched one. The
+// unknown tags fall back to Misc.
+func Match(tag string) (Category, bool) {
 	switch tag {
 	case "docs":
-		return Docs
+		return Docs, true
 	case "search":
-		return Search
+		return Search, true
 	case "indices":
-		return Indices
+		return Indices, true
 	case "cat":
-		return Cat
+		return Cat, true
 	case "tasks":
-		return Clusters
+		return Clusters, true
 	case "cluster":
-		return Clusters
+		return Clusters, true
 	default:
-		return Misc
+		return Misc, false
 	}
 }
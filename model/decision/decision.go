@@ -0,0 +1,66 @@
+package decision
+
+import (
+	"context"
+	"sync"
+
+	"github.com/appbaseio/reactivesearch-api/errors"
+)
+
+type contextKey string
+
+// ctxKey is a key against which the authorization decisions of a request are stored in the context.
+const ctxKey = contextKey("decisions")
+
+// Decision is the outcome of an authorization check of a request.
+type Decision struct {
+	Check   string `json:"check"`
+	Allowed bool   `json:"allowed"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// Decisions collects the authorization decisions made while serving a request.
+type Decisions struct {
+	mu   sync.Mutex
+	list []Decision
+}
+
+// Add appends a decision.
+func (d *Decisions) Add(decision Decision) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.list = append(d.list, decision)
+}
+
+// List returns the decisions in the order they were made.
+func (d *Decisions) List() []Decision {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]Decision(nil), d.list...)
+}
+
+// NewContext returns a new context with the given decisions.
+func NewContext(ctx context.Context, d *Decisions) context.Context {
+	return context.WithValue(ctx, ctxKey, d)
+}
+
+// FromContext retrieves the decisions stored against the decision.ctxKey from the context.
+func FromContext(ctx context.Context) (*Decisions, error) {
+	ctxDecisions := ctx.Value(ctxKey)
+	if ctxDecisions == nil {
+		return nil, errors.NewNotFoundInContextError("decisions")
+	}
+	decisions, ok := ctxDecisions.(*Decisions)
+	if !ok {
+		return nil, errors.NewInvalidCastError("ctxDecisions", "*decision.Decisions")
+	}
+	return decisions, nil
+}
+
+// Record adds the outcome of a check to the decisions of the context, if the
+// decisions of the request are being recorded.
+func Record(ctx context.Context, check string, allowed bool, reason string) {
+	if decisions, err := FromContext(ctx); err == nil {
+		decisions.Add(Decision{Check: check, Allowed: allowed, Reason: reason})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"net/url"
 	"os"
 	"regexp"
 	"strings"
@@ -51,6 +52,41 @@ type Permission struct {
 	Includes    []string            `json:"include_fields"`
 	Excludes    []string            `json:"exclude_fields"`
 	Expired     bool                `json:"expired"`
+	// AllowScripts allows the usage of scripts in the queries
+	AllowScripts *bool `json:"allow_scripts,omitempty"`
+	// AllowedOrigins restricts the Origin header of the browser requests, the Referer
+	// header is restricted by Referers
+	AllowedOrigins []string `json:"allowed_origins,omitempty"`
+	// Aggregations restricts the aggregations that can be used in the queries
+	Aggregations *AggregationLimits `json:"aggregations,omitempty"`
+	// StablePreference routes the searches to the same shards using the username as preference
+	StablePreference *bool `json:"stable_preference,omitempty"`
+	// MaxBulkActions limits the number of actions in a bulk request, zero doesn't limit them
+	MaxBulkActions *int `json:"max_bulk_actions,omitempty"`
+	// TerminateAfter limits the documents collected per shard by the search requests,
+	// zero doesn't limit them
+	TerminateAfter *int `json:"terminate_after,omitempty"`
+	// DailyQuota limits the number of requests per calendar day, zero doesn't limit them
+	DailyQuota *int64 `json:"daily_quota,omitempty"`
+	// ForceSourceFiltering enforces the include and exclude fields in the _source of the
+	// search requests, in addition to filtering the responses, and rejects the searches
+	// fetching fields outside of the _source
+	ForceSourceFiltering *bool `json:"force_source_filtering,omitempty"`
+	// AllowedSortFields restricts the fields the search requests can sort on
+	AllowedSortFields []string `json:"allowed_sort_fields,omitempty"`
+	// MaxPaginationDepth limits the from + size of the search requests, zero doesn't limit them
+	MaxPaginationDepth *int `json:"max_pagination_depth,omitempty"`
+	// DeniedQueryClauses are the query clause types, e.g. `regexp`, the search requests can't use,
+	// the URI searches and the query_string and simple_query_string clauses are denied along with them
+	DeniedQueryClauses []string `json:"denied_query_clauses,omitempty"`
+}
+
+// AggregationLimits defines the aggregations a permission is allowed to use.
+// A zero value of a property doesn't restrict the aggregations.
+type AggregationLimits struct {
+	Types    []string `json:"types"`
+	MaxDepth int      `json:"max_depth"`
+	MaxSize  int      `json:"max_size"`
 }
 
 // Limits defines the rate limits for each category.
@@ -189,6 +225,158 @@ func SetExcludes(excludes []string) Options {
 	}
 }
 
+// SetAllowScripts defines whether the permission can use scripts in the queries.
+func SetAllowScripts(allowScripts bool) Options {
+	return func(p *Permission) error {
+		p.AllowScripts = &allowScripts
+		return nil
+	}
+}
+
+// SetAllowedOrigins sets the origins from which the permission can make requests.
+func SetAllowedOrigins(origins []string) Options {
+	return func(p *Permission) error {
+		if err := validateOrigins(origins); err != nil {
+			return err
+		}
+		p.AllowedOrigins = origins
+		return nil
+	}
+}
+
+// SetAllowedSortFields sets the fields the search requests of the permission can sort on.
+func SetAllowedSortFields(fields []string) Options {
+	return func(p *Permission) error {
+		if err := validateSortFields(fields); err != nil {
+			return err
+		}
+		p.AllowedSortFields = fields
+		return nil
+	}
+}
+
+func validateSortFields(fields []string) error {
+	for _, field := range fields {
+		if strings.TrimSpace(field) == "" {
+			return fmt.Errorf("allowed_sort_fields can't contain an empty field")
+		}
+	}
+	return nil
+}
+
+// SetDeniedQueryClauses sets the query clause types the search requests of the permission can't use.
+func SetDeniedQueryClauses(clauses []string) Options {
+	return func(p *Permission) error {
+		if err := validateQueryClauses(clauses); err != nil {
+			return err
+		}
+		p.DeniedQueryClauses = clauses
+		return nil
+	}
+}
+
+func validateQueryClauses(clauses []string) error {
+	for _, clause := range clauses {
+		if strings.TrimSpace(clause) == "" {
+			return fmt.Errorf("denied_query_clauses can't contain an empty clause")
+		}
+	}
+	return nil
+}
+
+// SetStablePreference defines whether the searches of the permission are routed to the same shards.
+func SetStablePreference(stablePreference bool) Options {
+	return func(p *Permission) error {
+		p.StablePreference = &stablePreference
+		return nil
+	}
+}
+
+// SetForceSourceFiltering defines whether the include and exclude fields of the permission
+// are enforced in the search requests.
+func SetForceSourceFiltering(forceSourceFiltering bool) Options {
+	return func(p *Permission) error {
+		p.ForceSourceFiltering = &forceSourceFiltering
+		return nil
+	}
+}
+
+// SetMaxBulkActions sets the maximum number of actions in a bulk request of the permission.
+func SetMaxBulkActions(maxBulkActions int) Options {
+	return func(p *Permission) error {
+		if maxBulkActions < 0 {
+			return fmt.Errorf("max_bulk_actions must be a non-negative number")
+		}
+		p.MaxBulkActions = &maxBulkActions
+		return nil
+	}
+}
+
+// SetTerminateAfter sets the maximum number of documents collected per shard by the
+// search requests of the permission.
+func SetTerminateAfter(terminateAfter int) Options {
+	return func(p *Permission) error {
+		if terminateAfter < 0 {
+			return fmt.Errorf("terminate_after must be a non-negative number")
+		}
+		p.TerminateAfter = &terminateAfter
+		return nil
+	}
+}
+
+// SetMaxPaginationDepth sets the maximum from + size of the search requests of the permission.
+func SetMaxPaginationDepth(maxPaginationDepth int) Options {
+	return func(p *Permission) error {
+		if maxPaginationDepth < 0 {
+			return fmt.Errorf("max_pagination_depth must be a non-negative number")
+		}
+		p.MaxPaginationDepth = &maxPaginationDepth
+		return nil
+	}
+}
+
+// SetDailyQuota sets the maximum number of requests of the permission per calendar day.
+func SetDailyQuota(dailyQuota int64) Options {
+	return func(p *Permission) error {
+		if dailyQuota < 0 {
+			return fmt.Errorf("daily_quota must be a non-negative number")
+		}
+		p.DailyQuota = &dailyQuota
+		return nil
+	}
+}
+
+// SetAggregations sets the aggregations the permission is allowed to use.
+func SetAggregations(aggregations *AggregationLimits) Options {
+	return func(p *Permission) error {
+		if err := validateAggregations(aggregations); err != nil {
+			return err
+		}
+		p.Aggregations = aggregations
+		return nil
+	}
+}
+
+func validateAggregations(aggregations *AggregationLimits) error {
+	if aggregations.MaxDepth < 0 {
+		return fmt.Errorf("aggregations max_depth must be a non-negative number")
+	}
+	if aggregations.MaxSize < 0 {
+		return fmt.Errorf("aggregations max_size must be a non-negative number")
+	}
+	return nil
+}
+
+func validateOrigins(origins []string) error {
+	for _, origin := range origins {
+		u, err := url.Parse(origin)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf(`origin "%s" must be of the form scheme://host[:port]`, origin)
+		}
+	}
+	return nil
+}
+
 func validateSources(sources []string) error {
 	for _, source := range sources {
 		_, _, err := net.ParseCIDR(source)
@@ -441,6 +629,40 @@ func (p *Permission) CanDo(op op.Operation) bool {
 	return false
 }
 
+// CanUseScripts checks whether the permission is allowed to use scripts in the queries.
+func (p *Permission) CanUseScripts() bool {
+	return p.AllowScripts != nil && *p.AllowScripts
+}
+
+// IsOriginAllowed checks whether a request from the given origin is allowed by the permission.
+// An empty allowlist doesn't restrict the origins.
+func (p *Permission) IsOriginAllowed(origin string) bool {
+	if len(p.AllowedOrigins) == 0 {
+		return true
+	}
+	for _, o := range p.AllowedOrigins {
+		if strings.EqualFold(strings.TrimSuffix(o, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsSortFieldAllowed checks whether the search requests of the permission can sort on the
+// given field. An empty allowlist doesn't restrict the fields, _score and _doc are always allowed.
+func (p *Permission) IsSortFieldAllowed(field string) bool {
+	if len(p.AllowedSortFields) == 0 || field == "_score" || field == "_doc" {
+		return true
+	}
+	return util.Contains(p.AllowedSortFields, field)
+}
+
+// IsQueryClauseDenied checks whether the search requests of the permission can't use
+// the given query clause type.
+func (p *Permission) IsQueryClauseDenied(clause string) bool {
+	return util.Contains(p.DeniedQueryClauses, clause)
+}
+
 // CanAccessCluster checks whether the user can access cluster level routes.
 func (p *Permission) CanAccessCluster() (bool, error) {
 	for _, pattern := range p.Indices {
@@ -686,6 +908,63 @@ func (p *Permission) GetPatch(rolePatched bool) (map[string]interface{}, error)
 	if p.Excludes != nil {
 		patch["exclude_fields"] = p.Excludes
 	}
+	if p.AllowScripts != nil {
+		patch["allow_scripts"] = *p.AllowScripts
+	}
+	if p.AllowedOrigins != nil {
+		if err := validateOrigins(p.AllowedOrigins); err != nil {
+			return nil, err
+		}
+		patch["allowed_origins"] = p.AllowedOrigins
+	}
+	if p.AllowedSortFields != nil {
+		if err := validateSortFields(p.AllowedSortFields); err != nil {
+			return nil, err
+		}
+		patch["allowed_sort_fields"] = p.AllowedSortFields
+	}
+	if p.DeniedQueryClauses != nil {
+		if err := validateQueryClauses(p.DeniedQueryClauses); err != nil {
+			return nil, err
+		}
+		patch["denied_query_clauses"] = p.DeniedQueryClauses
+	}
+	if p.StablePreference != nil {
+		patch["stable_preference"] = *p.StablePreference
+	}
+	if p.ForceSourceFiltering != nil {
+		patch["force_source_filtering"] = *p.ForceSourceFiltering
+	}
+	if p.MaxBulkActions != nil {
+		if *p.MaxBulkActions < 0 {
+			return nil, fmt.Errorf("max_bulk_actions must be a non-negative number")
+		}
+		patch["max_bulk_actions"] = *p.MaxBulkActions
+	}
+	if p.TerminateAfter != nil {
+		if *p.TerminateAfter < 0 {
+			return nil, fmt.Errorf("terminate_after must be a non-negative number")
+		}
+		patch["terminate_after"] = *p.TerminateAfter
+	}
+	if p.DailyQuota != nil {
+		if *p.DailyQuota < 0 {
+			return nil, fmt.Errorf("daily_quota must be a non-negative number")
+		}
+		patch["daily_quota"] = *p.DailyQuota
+	}
+	if p.MaxPaginationDepth != nil {
+		if *p.MaxPaginationDepth < 0 {
+			return nil, fmt.Errorf("max_pagination_depth must be a non-negative number")
+		}
+		patch["max_pagination_depth"] = *p.MaxPaginationDepth
+	}
+	if p.Aggregations != nil {
+		if err := validateAggregations(p.Aggregations); err != nil {
+			return nil, err
+		}
+		patch["aggregations"] = p.Aggregations
+	}
 
 	return patch, nil
 }
@@ -0,0 +1,56 @@
+package queuetime
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/appbaseio/reactivesearch-api/errors"
+)
+
+type contextKey string
+
+// ctxKey is a key against which the queue timer of a request is stored in the context.
+const ctxKey = contextKey("queue_time")
+
+// Timer accumulates the time a request spends waiting before it is served, e.g. behind
+// a coalesced request or for a rollover to be done.
+type Timer struct {
+	waited int64
+}
+
+// Add adds the time spent waiting to the timer.
+func (t *Timer) Add(d time.Duration) {
+	atomic.AddInt64(&t.waited, int64(d))
+}
+
+// Duration returns the total time spent waiting.
+func (t *Timer) Duration() time.Duration {
+	return time.Duration(atomic.LoadInt64(&t.waited))
+}
+
+// NewContext returns a new context with the given queue timer.
+func NewContext(ctx context.Context, t *Timer) context.Context {
+	return context.WithValue(ctx, ctxKey, t)
+}
+
+// FromContext retrieves the queue timer stored against the queuetime.ctxKey from the context.
+func FromContext(ctx context.Context) (*Timer, error) {
+	ctxTimer := ctx.Value(ctxKey)
+	if ctxTimer == nil {
+		return nil, errors.NewNotFoundInContextError("queue timer")
+	}
+	timer, ok := ctxTimer.(*Timer)
+	if !ok {
+		return nil, errors.NewInvalidCastError("ctxTimer", "*queuetime.Timer")
+	}
+	return timer, nil
+}
+
+// Add adds the time spent waiting to the queue timer of the context, if the queue
+// time of the request is being recorded.
+func Add(ctx context.Context, d time.Duration) {
+	if timer, err := FromContext(ctx); err == nil {
+		timer.Add(d)
+	}
+}
@@ -2,6 +2,8 @@ package request
 
 import (
 	"context"
+
+	"github.com/appbaseio/reactivesearch-api/errors"
 )
 
 type contextKey string
@@ -19,3 +21,46 @@ func FromContext(ctx context.Context) (*interface{}, error) {
 	ctxRequest := ctx.Value(CtxKey)
 	return &ctxRequest, nil
 }
+
+// rawBodyCtxKey is a key against which the raw api request body is stored in the context.
+const rawBodyCtxKey = contextKey("raw_body")
+
+// NewRawBodyContext returns a new context with the raw request body, e.g. for the
+// middlewares recording the body as sent rather than as parsed.
+func NewRawBodyContext(ctx context.Context, body []byte) context.Context {
+	return context.WithValue(ctx, rawBodyCtxKey, body)
+}
+
+// RawBodyFromContext retrieves the raw request body stored in the context, or nil.
+func RawBodyFromContext(ctx context.Context) []byte {
+	body, _ := ctx.Value(rawBodyCtxKey).([]byte)
+	return body
+}
+
+// esQueryCtxKey is a key against which the translated elasticsearch query is stored in the context.
+const esQueryCtxKey = contextKey("es_query")
+
+// ESQuery holds the elasticsearch query generated for an api request. It is stored
+// as a pointer so that the handlers down the chain can fill it in for the middlewares
+// up the chain, e.g. the logs recorder.
+type ESQuery struct {
+	Query string
+}
+
+// NewESQueryContext returns a new context with the given es query holder.
+func NewESQueryContext(ctx context.Context, q *ESQuery) context.Context {
+	return context.WithValue(ctx, esQueryCtxKey, q)
+}
+
+// ESQueryFromContext retrieves the es query holder stored in the context.
+func ESQueryFromContext(ctx context.Context) (*ESQuery, error) {
+	ctxESQuery := ctx.Value(esQueryCtxKey)
+	if ctxESQuery == nil {
+		return nil, errors.NewNotFoundInContextError("*ESQuery")
+	}
+	q, ok := ctxESQuery.(*ESQuery)
+	if !ok {
+		return nil, errors.NewInvalidCastError("ctxESQuery", "*ESQuery")
+	}
+	return q, nil
+}
@@ -0,0 +1,103 @@
+package timing
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/appbaseio/reactivesearch-api/errors"
+)
+
+type contextKey string
+
+// ctxKey is a key against which the phase timings of a request are stored in the context.
+const ctxKey = contextKey("timing")
+
+// Phase is a stage of serving a request.
+type Phase int
+
+const (
+	// Auth is the time spent authenticating the request, e.g. looking up its credential.
+	Auth Phase = iota
+	// Validate is the time spent in the validate middlewares.
+	Validate
+	// Upstream is the time spent waiting on elasticsearch.
+	Upstream
+	// AuthLookup is the part of Auth spent looking up the credential, either in the
+	// credential cache or in elasticsearch.
+	AuthLookup
+
+	phases
+)
+
+// Timings accumulates the time a request spends in each phase.
+type Timings struct {
+	durations [phases]int64
+	// authCacheHit is 0 until the credential is looked up, then 1 if it was served
+	// by the credential cache and 2 if it was fetched from elasticsearch
+	authCacheHit int32
+}
+
+// Add adds the time spent in the phase.
+func (t *Timings) Add(p Phase, d time.Duration) {
+	atomic.AddInt64(&t.durations[p], int64(d))
+}
+
+// Duration returns the total time spent in the phase.
+func (t *Timings) Duration(p Phase) time.Duration {
+	return time.Duration(atomic.LoadInt64(&t.durations[p]))
+}
+
+// SetAuthCacheHit sets whether the credential of the request was served by the cache.
+func (t *Timings) SetAuthCacheHit(hit bool) {
+	value := int32(2)
+	if hit {
+		value = 1
+	}
+	atomic.StoreInt32(&t.authCacheHit, value)
+}
+
+// AuthCacheHit returns whether the credential of the request was served by the cache,
+// nil if it wasn't looked up.
+func (t *Timings) AuthCacheHit() *bool {
+	value := atomic.LoadInt32(&t.authCacheHit)
+	if value == 0 {
+		return nil
+	}
+	hit := value == 1
+	return &hit
+}
+
+// NewContext returns a new context with the given timings.
+func NewContext(ctx context.Context, t *Timings) context.Context {
+	return context.WithValue(ctx, ctxKey, t)
+}
+
+// FromContext retrieves the timings stored against the timing.ctxKey from the context.
+func FromContext(ctx context.Context) (*Timings, error) {
+	ctxTimings := ctx.Value(ctxKey)
+	if ctxTimings == nil {
+		return nil, errors.NewNotFoundInContextError("timings")
+	}
+	timings, ok := ctxTimings.(*Timings)
+	if !ok {
+		return nil, errors.NewInvalidCastError("ctxTimings", "*timing.Timings")
+	}
+	return timings, nil
+}
+
+// Add adds the time spent in the phase to the timings of the context, if the
+// timings of the request are being recorded.
+func Add(ctx context.Context, p Phase, d time.Duration) {
+	if timings, err := FromContext(ctx); err == nil {
+		timings.Add(p, d)
+	}
+}
+
+// SetAuthCacheHit sets whether the credential was served by the cache in the timings
+// of the context, if the timings of the request are being recorded.
+func SetAuthCacheHit(ctx context.Context, hit bool) {
+	if timings, err := FromContext(ctx); err == nil {
+		timings.SetAuthCacheHit(hit)
+	}
+}
@@ -36,6 +36,9 @@ type User struct {
 	Email            string              `json:"email"`
 	Indices          []string            `json:"indices"`
 	CreatedAt        string              `json:"created_at"`
+	// PasswordChangedAt is when the password was last set, the passwords of the users
+	// created before it was tracked don't expire
+	PasswordChangedAt string `json:"password_changed_at,omitempty"`
 }
 
 // Options is a function type used to define a user's properties.
@@ -123,12 +126,14 @@ func New(username, password string, opts ...Options) (*User, error) {
 	}
 
 	// create a default user
+	now := time.Now().Format(time.RFC3339)
 	u := &User{
-		Username:  username,
-		Password:  password,
-		IsAdmin:   &isAdminFalse, // pointer to bool
-		Indices:   []string{},
-		CreatedAt: time.Now().Format(time.RFC3339),
+		Username:          username,
+		Password:          password,
+		IsAdmin:           &isAdminFalse, // pointer to bool
+		Indices:           []string{},
+		CreatedAt:         now,
+		PasswordChangedAt: now,
 	}
 
 	// run the options on it
@@ -154,14 +159,16 @@ func NewAdmin(username, password string, opts ...Options) (*User, error) {
 	}
 
 	// create an admin user
+	now := time.Now().Format(time.RFC3339)
 	u := &User{
-		Username:       username,
-		Password:       password,
-		IsAdmin:        &isAdminTrue,
-		Categories:     GetCategories(adminActions),
-		AllowedActions: &adminActions,
-		Indices:        []string{"*"},
-		CreatedAt:      time.Now().Format(time.RFC3339),
+		Username:          username,
+		Password:          password,
+		IsAdmin:           &isAdminTrue,
+		Categories:        GetCategories(adminActions),
+		AllowedActions:    &adminActions,
+		Indices:           []string{"*"},
+		CreatedAt:         now,
+		PasswordChangedAt: now,
 	}
 
 	// run the options on it
@@ -294,6 +301,7 @@ func (u *User) GetPatch() (map[string]interface{}, error) {
 	}
 	if u.Password != "" {
 		patch["password"] = u.Password
+		patch["password_changed_at"] = time.Now().Format(time.RFC3339)
 	}
 	if u.IsAdmin != nil {
 		patch["is_admin"] = u.IsAdmin
@@ -320,6 +328,9 @@ func (u *User) GetPatch() (map[string]interface{}, error) {
 	if u.CreatedAt != "" {
 		return nil, errors.NewUnsupportedPatchError("user", "created_at")
 	}
+	if u.PasswordChangedAt != "" {
+		return nil, errors.NewUnsupportedPatchError("user", "password_changed_at")
+	}
 
 	return patch, nil
 }
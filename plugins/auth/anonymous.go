@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/appbaseio/reactivesearch-api/model/category"
+	"github.com/appbaseio/reactivesearch-api/model/op"
+	"github.com/appbaseio/reactivesearch-api/model/permission"
+)
+
+// envAnonymousPermission is the username of the permission whose scope is granted to the
+// requests without credentials, e.g. for public search APIs.
+const envAnonymousPermission = "ANONYMOUS_PERMISSION"
+
+// anonymousPermission returns the permission applied to a request without credentials.
+// The anonymous requests are read-only, whatever the operations of the permission.
+func (a *Auth) anonymousPermission(ctx context.Context, reqCategory category.Category, reqOp op.Operation) (*permission.Permission, error) {
+	if reqOp != op.Read {
+		return nil, errors.New("anonymous requests are read-only, Basic Auth or JWT is required")
+	}
+	obj, err := a.getCredential(ctx, a.anonymousUsername)
+	// the username of both a user and a permission is resolved to the permission, as
+	// the anonymous requests have no password
+	if merged, ok := obj.(*mergedCredential); ok {
+		obj = merged.permission
+	}
+	reqPermission, ok := obj.(*permission.Permission)
+	if err != nil || !ok || reqPermission == nil {
+		log.Errorln(logTag, ": anonymous permission", a.anonymousUsername, "isn't available:", err)
+		return nil, errors.New("Basic Auth or JWT is required")
+	}
+	// cache the permission, like the ones of the requests with credentials
+	if _, ok := GetCachedCredential(a.anonymousUsername); !ok {
+		SaveCredentialToCache(a.anonymousUsername, reqPermission)
+	}
+	if !reqPermission.HasCategory(reqCategory) {
+		return nil, fmt.Errorf("anonymous requests are not allowed to access %s", reqCategory)
+	}
+	return reqPermission, nil
+}
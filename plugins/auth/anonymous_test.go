@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/appbaseio/reactivesearch-api/model/authmethod"
+	"github.com/appbaseio/reactivesearch-api/model/category"
+	"github.com/appbaseio/reactivesearch-api/model/credential"
+	"github.com/appbaseio/reactivesearch-api/model/op"
+	"github.com/appbaseio/reactivesearch-api/model/permission"
+	"github.com/appbaseio/reactivesearch-api/model/user"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestAnonymousPermission(t *testing.T) {
+	Convey("Apply the anonymous permission to the requests without credentials", t, func() {
+		public := &permission.Permission{
+			Username:   "anonymous-public",
+			Categories: []category.Category{category.Search},
+			Ops:        []op.Operation{op.Read},
+			Indices:    []string{"books"},
+		}
+		store := &fakeCredentialStore{credentials: map[string]credential.AuthCredential{
+			"anonymous-public": public,
+		}}
+		a := &Auth{
+			anonymousUsername: "anonymous-public",
+			es:                store,
+		}
+		RemoveCredentialFromCache("anonymous-public")
+		Reset(func() { RemoveCredentialFromCache("anonymous-public") })
+		serve := func(reqCategory category.Category, reqOp op.Operation) (int, *permission.Permission, authmethod.Method) {
+			req := httptest.NewRequest(http.MethodPost, "/books/_search", nil)
+			ctx := category.NewContext(req.Context(), &reqCategory)
+			ctx = op.NewContext(ctx, &reqOp)
+			holder := &authmethod.Holder{}
+			ctx = authmethod.NewContext(ctx, holder)
+			var reqPermission *permission.Permission
+			w := httptest.NewRecorder()
+			a.basicAuth(func(w http.ResponseWriter, r *http.Request) {
+				if c, err := credential.FromContext(r.Context()); err == nil && c == credential.Permission {
+					reqPermission, _ = permission.FromContext(r.Context())
+				}
+				w.WriteHeader(http.StatusOK)
+			})(w, req.WithContext(ctx))
+			return w.Code, reqPermission, holder.Method()
+		}
+
+		Convey("An anonymous read gets the scope of the permission", func() {
+			code, reqPermission, method := serve(category.Search, op.Read)
+			So(code, ShouldEqual, http.StatusOK)
+			So(reqPermission, ShouldNotBeNil)
+			So(reqPermission.Username, ShouldEqual, "anonymous-public")
+			So(reqPermission.Indices, ShouldResemble, []string{"books"})
+			So(method, ShouldEqual, authmethod.Anonymous)
+		})
+		Convey("The anonymous permission is cached", func() {
+			code, _, _ := serve(category.Search, op.Read)
+			So(code, ShouldEqual, http.StatusOK)
+			delete(store.credentials, "anonymous-public")
+			code, reqPermission, _ := serve(category.Search, op.Read)
+			So(code, ShouldEqual, http.StatusOK)
+			So(reqPermission.Username, ShouldEqual, "anonymous-public")
+		})
+		Convey("The permission of a username shared with a user is applied", func() {
+			store.credentials["anonymous-public"] = &mergedCredential{
+				user:       &user.User{Username: "anonymous-public"},
+				permission: public,
+			}
+			code, reqPermission, _ := serve(category.Search, op.Read)
+			So(code, ShouldEqual, http.StatusOK)
+			So(reqPermission, ShouldEqual, public)
+		})
+		Convey("An anonymous write is rejected", func() {
+			code, _, _ := serve(category.Docs, op.Write)
+			So(code, ShouldEqual, http.StatusUnauthorized)
+			code, _, _ = serve(category.Search, op.Delete)
+			So(code, ShouldEqual, http.StatusUnauthorized)
+		})
+		Convey("An anonymous request outside the categories of the permission is rejected", func() {
+			code, _, _ := serve(category.Cat, op.Read)
+			So(code, ShouldEqual, http.StatusUnauthorized)
+		})
+		Convey("The requests without credentials are rejected if it isn't configured", func() {
+			a.anonymousUsername = ""
+			code, _, method := serve(category.Search, op.Read)
+			So(code, ShouldEqual, http.StatusUnauthorized)
+			So(method, ShouldBeEmpty)
+		})
+	})
+}
@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/appbaseio/reactivesearch-api/model/category"
+	"github.com/appbaseio/reactivesearch-api/model/credential"
+	"github.com/appbaseio/reactivesearch-api/model/op"
+	"github.com/appbaseio/reactivesearch-api/model/permission"
+	"github.com/appbaseio/reactivesearch-api/util/audit"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// fakeAuditWriter records the audit messages written to it.
+type fakeAuditWriter struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (f *fakeAuditWriter) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.messages = append(f.messages, string(p))
+	return len(p), nil
+}
+
+func TestAuditAuthFailures(t *testing.T) {
+	Convey("Audit the failed authentications", t, func() {
+		fake := &fakeAuditWriter{}
+		sink := audit.NewSink(func() (io.Writer, error) { return fake, nil })
+		audit.SetSink(sink)
+		Reset(func() { audit.SetSink(nil) })
+		a := &Auth{es: &fakeCredentialStore{
+			credentials: map[string]credential.AuthCredential{
+				"audit-foo": &permission.Permission{Username: "audit-foo", Password: "secret", Categories: []category.Category{category.Search}},
+			},
+		}}
+		serve := func(username, password string) []audit.Event {
+			req := httptest.NewRequest(http.MethodPost, "/books/_search", nil)
+			req.SetBasicAuth(username, password)
+			reqCategory, reqOp := category.Search, op.Read
+			ctx := category.NewContext(req.Context(), &reqCategory)
+			ctx = op.NewContext(ctx, &reqOp)
+			w := httptest.NewRecorder()
+			a.basicAuth(func(w http.ResponseWriter, r *http.Request) {})(w, req.WithContext(ctx))
+			So(w.Code, ShouldEqual, http.StatusUnauthorized)
+			sink.Close()
+			var events []audit.Event
+			for _, msg := range fake.messages {
+				So(msg, ShouldNotContainSubstring, password)
+				var e audit.Event
+				So(json.Unmarshal([]byte(msg), &e), ShouldBeNil)
+				events = append(events, e)
+			}
+			return events
+		}
+
+		Convey("An invalid password is recorded without the password", func() {
+			events := serve("audit-foo", "hunter2")
+			So(events, ShouldHaveLength, 1)
+			So(events[0].Type, ShouldEqual, audit.AuthFailure)
+			So(events[0].Username, ShouldEqual, "audit-foo")
+			So(events[0].Reason, ShouldEqual, "invalid password")
+			So(events[0].ClientIP, ShouldNotBeEmpty)
+			So(events[0].Timestamp.IsZero(), ShouldBeFalse)
+		})
+		Convey("An unknown username is recorded", func() {
+			events := serve("audit-bar", "hunter2")
+			So(events, ShouldHaveLength, 1)
+			So(events[0].Username, ShouldEqual, "audit-bar")
+			So(events[0].Reason, ShouldEqual, "unknown username")
+		})
+	})
+}
@@ -53,6 +53,13 @@ type Auth struct {
 	jwtRsaPublicKey *rsa.PublicKey
 	jwtRoleKey      string
 	es              authService
+	// webhook authorizes the requests against an external service if configured
+	webhook *webhook
+	// anonymousUsername is the username of the permission applied to the requests
+	// without credentials, they're rejected if it's empty
+	anonymousUsername string
+	// passwordExpiry rejects the users whose password is too old, nil if they don't expire
+	passwordExpiry *passwordExpiry
 }
 
 // Instance returns the singleton instance of the auth plugin. Instance
@@ -88,8 +95,27 @@ func (a *Auth) InitFunc() error {
 	}
 	var err error
 
+	a.webhook, err = initWebhook()
+	if err != nil {
+		return err
+	}
+
+	if err := initRoleCache(); err != nil {
+		return err
+	}
+	a.anonymousUsername = util.NormalizeUsername(os.Getenv(envAnonymousPermission))
+	a.passwordExpiry, err = passwordExpiryFromEnv()
+	if err != nil {
+		return err
+	}
+
+	precedence, err := credentialPrecedence()
+	if err != nil {
+		return err
+	}
+
 	// initialize the dao
-	a.es, err = initPlugin(userIndex, permissionIndex)
+	a.es, err = initPlugin(userIndex, permissionIndex, precedence)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/appbaseio/reactivesearch-api/model/authmethod"
+	"github.com/appbaseio/reactivesearch-api/model/category"
+	"github.com/appbaseio/reactivesearch-api/model/credential"
+	"github.com/appbaseio/reactivesearch-api/model/op"
+	"github.com/appbaseio/reactivesearch-api/model/permission"
+	"github.com/dgrijalva/jwt-go"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// fakeCredentialStore serves the credentials of the basic auth requests along with
+// the role permissions of the JWT requests.
+type fakeCredentialStore struct {
+	fakeRoleStore
+	credentials map[string]credential.AuthCredential
+}
+
+func (s *fakeCredentialStore) getCredential(ctx context.Context, username string) (credential.AuthCredential, error) {
+	return s.credentials[username], nil
+}
+
+func TestAuthMethod(t *testing.T) {
+	Convey("Stamp the auth method of the requests", t, func() {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		So(err, ShouldBeNil)
+		categories := []category.Category{category.Search}
+		a := &Auth{
+			jwtRsaPublicKey: &key.PublicKey,
+			es: &fakeCredentialStore{
+				fakeRoleStore: fakeRoleStore{permissions: map[string]*permission.Permission{
+					"viewer": {Username: "auth-method-role", Role: "viewer", Categories: categories},
+				}},
+				credentials: map[string]credential.AuthCredential{
+					"auth-method-basic": &permission.Permission{Username: "auth-method-basic", Password: "secret", Categories: categories},
+				},
+			},
+		}
+		serve := func(req *http.Request) (int, authmethod.Method) {
+			reqCategory, reqOp := category.Search, op.Read
+			ctx := category.NewContext(req.Context(), &reqCategory)
+			ctx = op.NewContext(ctx, &reqOp)
+			holder := &authmethod.Holder{}
+			ctx = authmethod.NewContext(ctx, holder)
+			w := httptest.NewRecorder()
+			a.basicAuth(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})(w, req.WithContext(ctx))
+			return w.Code, holder.Method()
+		}
+
+		Convey("A basic auth request is stamped basic", func() {
+			req := httptest.NewRequest(http.MethodPost, "/books/_search", nil)
+			req.SetBasicAuth("auth-method-basic", "secret")
+			code, method := serve(req)
+			So(code, ShouldEqual, http.StatusOK)
+			So(method, ShouldEqual, authmethod.Basic)
+		})
+		Convey("A token request is stamped jwt", func() {
+			token, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"role": "viewer"}).SignedString(key)
+			So(err, ShouldBeNil)
+			req := httptest.NewRequest(http.MethodPost, "/books/_search", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			code, method := serve(req)
+			So(code, ShouldEqual, http.StatusOK)
+			So(method, ShouldEqual, authmethod.JWT)
+		})
+		Convey("A rejected request isn't stamped", func() {
+			req := httptest.NewRequest(http.MethodPost, "/books/_search", nil)
+			req.SetBasicAuth("auth-method-basic", "invalid")
+			code, method := serve(req)
+			So(code, ShouldEqual, http.StatusUnauthorized)
+			So(method, ShouldBeEmpty)
+		})
+	})
+}
@@ -17,6 +17,8 @@ import (
 type elasticsearch struct {
 	userIndex, userType             string
 	permissionIndex, permissionType string
+	// credentialPrecedence resolves the usernames of both a user and a permission
+	credentialPrecedence string
 }
 
 type publicKey struct {
@@ -24,12 +26,13 @@ type publicKey struct {
 	RoleKey   string `json:"role_key"`
 }
 
-func initPlugin(userIndex, permissionIndex string) (*elasticsearch, error) {
+func initPlugin(userIndex, permissionIndex, credentialPrecedence string) (*elasticsearch, error) {
 	// auth only has to establish a connection to es, users, permissions
 	// plugin handles the creation of their respective meta indices
 	es := &elasticsearch{
 		userIndex, "_doc",
 		permissionIndex, "_doc",
+		credentialPrecedence,
 	}
 
 	return es, nil
@@ -106,12 +109,15 @@ func (es *elasticsearch) getCredential(ctx context.Context, username string) (cr
 }
 
 func (es *elasticsearch) putUser(ctx context.Context, u user.User) (bool, error) {
-	_, err := util.GetClient7().Index().
-		Index(es.userIndex).
-		Type(es.userType).
-		Id(u.Username).
-		BodyJson(u).
-		Do(ctx)
+	err := util.RetryWrite(ctx, func() error {
+		_, err := util.GetClient7().Index().
+			Index(es.userIndex).
+			Type(es.userType).
+			Id(u.Username).
+			BodyJson(u).
+			Do(ctx)
+		return err
+	})
 	if err != nil {
 		return false, err
 	}
@@ -151,12 +157,15 @@ func (es *elasticsearch) getRawUser(ctx context.Context, username string) ([]byt
 }
 
 func (es *elasticsearch) putPermission(ctx context.Context, p permission.Permission) (bool, error) {
-	_, err := util.GetClient7().Index().
-		Index(es.permissionIndex).
-		Type(es.permissionType).
-		Id(p.Username).
-		BodyJson(p).
-		Do(ctx)
+	err := util.RetryWrite(ctx, func() error {
+		_, err := util.GetClient7().Index().
+			Index(es.permissionIndex).
+			Type(es.permissionType).
+			Id(p.Username).
+			BodyJson(p).
+			Do(ctx)
+		return err
+	})
 	if err != nil {
 		return false, err
 	}
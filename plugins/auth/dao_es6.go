@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
 
 	log "github.com/sirupsen/logrus"
 
@@ -44,40 +43,29 @@ func (es *elasticsearch) getCredentialEs6(ctx context.Context, username string)
 		return nil, err
 	}
 
-	if len(response.Hits.Hits) > 1 {
-		return nil, fmt.Errorf(`more than one result for "username"="%s"`, username)
-	}
-
-	// there should be either 0 or 1 hit
-	var obj credential.AuthCredential
+	// there should be at most one user and one permission
+	var u *user.User
+	var p *permission.Permission
 	for _, hit := range response.Hits.Hits {
 		if hit.Index == es.userIndex {
-			var u user.User
 			if hit.Source != nil {
-				err := json.Unmarshal(*hit.Source, &u)
+				u = &user.User{}
+				err := json.Unmarshal(*hit.Source, u)
 				if err != nil {
 					return nil, err
 				}
-				obj = &u
 			}
 		} else if hit.Index == es.permissionIndex {
-			var p permission.Permission
-
 			// unmarshal into permission
-			err := json.Unmarshal(*hit.Source, &p)
+			p = &permission.Permission{}
+			err := json.Unmarshal(*hit.Source, p)
 			if err != nil {
 				return nil, err
 			}
-
-			obj = &p
 		}
 	}
 
-	if obj == nil {
-		return nil, fmt.Errorf(`invalid username or password`)
-	}
-
-	return obj, nil
+	return resolveCredential(username, u, p, es.credentialPrecedence)
 }
 
 func (es *elasticsearch) getRawRolePermissionEs6(ctx context.Context, role string) ([]byte, error) {
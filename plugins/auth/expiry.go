@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/appbaseio/reactivesearch-api/model/user"
+)
+
+const (
+	// envPasswordMaxAge is the age after which the password of a user expires, e.g. `2160h`
+	envPasswordMaxAge = "PASSWORD_MAX_AGE"
+	// envPasswordExpiryWarning is the time before the expiry the requests are warned about it
+	envPasswordExpiryWarning = "PASSWORD_EXPIRY_WARNING"
+	// passwordExpiresHeader carries the expiry of the password within the warning window
+	passwordExpiresHeader = "X-Password-Expires-At"
+	// passwordResetPath is the route a user with an expired password can still reset it through
+	passwordResetPath = "/_user"
+)
+
+// passwordExpiry enforces the maximum age of the passwords of the users.
+type passwordExpiry struct {
+	maxAge  time.Duration
+	warning time.Duration
+	now     func() time.Time
+}
+
+// passwordExpiryFromEnv returns the configured password expiry, nil if the passwords
+// don't expire.
+func passwordExpiryFromEnv() (*passwordExpiry, error) {
+	value := os.Getenv(envPasswordMaxAge)
+	if value == "" {
+		return nil, nil
+	}
+	maxAge, err := time.ParseDuration(value)
+	if err != nil || maxAge <= 0 {
+		return nil, fmt.Errorf("invalid value for %s: %s", envPasswordMaxAge, value)
+	}
+	e := &passwordExpiry{maxAge: maxAge, now: time.Now}
+	if value := os.Getenv(envPasswordExpiryWarning); value != "" {
+		e.warning, err = time.ParseDuration(value)
+		if err != nil || e.warning < 0 || e.warning > maxAge {
+			return nil, fmt.Errorf("invalid value for %s, must be a duration up to %s: %s", envPasswordExpiryWarning, envPasswordMaxAge, value)
+		}
+	}
+	return e, nil
+}
+
+// expiresAt returns when the password of the user expires, false if it's unknown, i.e.
+// for the users created before the password changes were tracked.
+func (e *passwordExpiry) expiresAt(u *user.User) (time.Time, bool) {
+	if u.PasswordChangedAt == "" {
+		return time.Time{}, false
+	}
+	changedAt, err := time.Parse(time.RFC3339, u.PasswordChangedAt)
+	if err != nil {
+		log.Errorln(logTag, ": invalid password_changed_at of user", u.Username, ":", err)
+		return time.Time{}, false
+	}
+	return changedAt.Add(e.maxAge), true
+}
+
+// check returns an error if the password of the user expired, unless the request resets
+// it, i.e. a PATCH /_user setting the password. The requests are warned with the expiry through a header within the warning window.
+func (e *passwordExpiry) check(w http.ResponseWriter, req *http.Request, u *user.User) error {
+	expiresAt, ok := e.expiresAt(u)
+	if !ok {
+		return nil
+	}
+	now := e.now()
+	if now.Before(expiresAt) {
+		if now.After(expiresAt.Add(-e.warning)) {
+			w.Header().Set(passwordExpiresHeader, expiresAt.UTC().Format(time.RFC3339))
+		}
+		return nil
+	}
+	if req.Method == http.MethodPatch && req.URL.Path == passwordResetPath && setsPassword(req) {
+		return nil
+	}
+	return fmt.Errorf("password expired on %s, reset it with PATCH %s", expiresAt.UTC().Format(time.RFC3339), passwordResetPath)
+}
+
+// setsPassword returns true if the body of the request sets the password, the body is
+// left readable by the next handlers.
+func setsPassword(req *http.Request) bool {
+	if req.Body == nil {
+		return false
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return false
+	}
+	var patch struct {
+		Password string `json:"password"`
+	}
+	return json.Unmarshal(body, &patch) == nil && patch.Password != ""
+}
@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/appbaseio/reactivesearch-api/model/category"
+	"github.com/appbaseio/reactivesearch-api/model/credential"
+	"github.com/appbaseio/reactivesearch-api/model/op"
+	"github.com/appbaseio/reactivesearch-api/model/user"
+	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestPasswordExpiry(t *testing.T) {
+	Convey("Reject the users whose password expired", t, func() {
+		hashed, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+		So(err, ShouldBeNil)
+		now := time.Date(2021, 6, 30, 12, 0, 0, 0, time.UTC)
+		newUser := func(username string, changedAt time.Time) *user.User {
+			u, err := user.NewAdmin(username, string(hashed))
+			So(err, ShouldBeNil)
+			u.PasswordChangedAt = changedAt.Format(time.RFC3339)
+			return u
+		}
+		legacy := newUser("expiry-legacy", now)
+		legacy.PasswordChangedAt = ""
+		store := &fakeCredentialStore{credentials: map[string]credential.AuthCredential{
+			"expiry-recent":  newUser("expiry-recent", now.Add(-24*time.Hour)),
+			"expiry-warned":  newUser("expiry-warned", now.Add(-85*24*time.Hour)),
+			"expiry-expired": newUser("expiry-expired", now.Add(-91*24*time.Hour)),
+			"expiry-legacy":  legacy,
+		}}
+		a := &Auth{
+			es:             store,
+			passwordExpiry: &passwordExpiry{maxAge: 90 * 24 * time.Hour, warning: 7 * 24 * time.Hour, now: func() time.Time { return now }},
+		}
+		serveBody := func(method, target, username, body string) *httptest.ResponseRecorder {
+			defer RemoveCredentialFromCache(username)
+			req := httptest.NewRequest(method, target, strings.NewReader(body))
+			req.SetBasicAuth(username, "secret")
+			reqCategory, reqOp := category.Search, op.Read
+			ctx := category.NewContext(req.Context(), &reqCategory)
+			ctx = op.NewContext(ctx, &reqOp)
+			w := httptest.NewRecorder()
+			a.basicAuth(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})(w, req.WithContext(ctx))
+			return w
+		}
+		serve := func(method, target, username string) *httptest.ResponseRecorder {
+			return serveBody(method, target, username, "")
+		}
+
+		Convey("A recently changed password passes", func() {
+			w := serve(http.MethodPost, "/books/_search", "expiry-recent")
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(w.Header().Get(passwordExpiresHeader), ShouldBeEmpty)
+		})
+		Convey("A password about to expire passes with a warning", func() {
+			w := serve(http.MethodPost, "/books/_search", "expiry-warned")
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(w.Header().Get(passwordExpiresHeader), ShouldEqual, "2021-07-05T12:00:00Z")
+		})
+		Convey("An expired password is rejected", func() {
+			w := serve(http.MethodPost, "/books/_search", "expiry-expired")
+			So(w.Code, ShouldEqual, http.StatusUnauthorized)
+			So(w.Body.String(), ShouldContainSubstring, "password expired")
+			So(w.Body.String(), ShouldContainSubstring, "PATCH /_user")
+		})
+		Convey("An expired password can still be reset", func() {
+			w := serveBody(http.MethodPatch, "/_user", "expiry-expired", `{"password":"new-secret"}`)
+			So(w.Code, ShouldEqual, http.StatusOK)
+		})
+		Convey("The other updates of a user with an expired password are rejected", func() {
+			So(serveBody(http.MethodPatch, "/_user", "expiry-expired", `{"email":"foo@bar.com"}`).Code, ShouldEqual, http.StatusUnauthorized)
+			So(serve(http.MethodPatch, "/_user", "expiry-expired").Code, ShouldEqual, http.StatusUnauthorized)
+		})
+		Convey("The password of a user without a change date doesn't expire", func() {
+			So(serve(http.MethodPost, "/books/_search", "expiry-legacy").Code, ShouldEqual, http.StatusOK)
+		})
+		Convey("The passwords don't expire unless configured", func() {
+			a.passwordExpiry = nil
+			So(serve(http.MethodPost, "/books/_search", "expiry-expired").Code, ShouldEqual, http.StatusOK)
+		})
+	})
+}
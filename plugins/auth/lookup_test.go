@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/appbaseio/reactivesearch-api/model/credential"
+	"github.com/appbaseio/reactivesearch-api/model/permission"
+	"github.com/appbaseio/reactivesearch-api/model/timing"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// slowCredentialStore serves the credentials after a delay, as a slow elasticsearch would.
+type slowCredentialStore struct {
+	fakeCredentialStore
+	delay time.Duration
+}
+
+func (s *slowCredentialStore) getCredential(ctx context.Context, username string) (credential.AuthCredential, error) {
+	time.Sleep(s.delay)
+	return s.fakeCredentialStore.getCredential(ctx, username)
+}
+
+func TestCredentialLookupTiming(t *testing.T) {
+	Convey("Record the time spent looking up the credentials", t, func() {
+		a := &Auth{es: &slowCredentialStore{
+			fakeCredentialStore: fakeCredentialStore{credentials: map[string]credential.AuthCredential{
+				"lookup-timing": &permission.Permission{Username: "lookup-timing"},
+			}},
+			delay: 30 * time.Millisecond,
+		}}
+		RemoveCredentialFromCache("lookup-timing")
+		defer RemoveCredentialFromCache("lookup-timing")
+		lookup := func() *timing.Timings {
+			timings := &timing.Timings{}
+			c, err := a.getCredential(timing.NewContext(context.Background(), timings), "lookup-timing")
+			So(err, ShouldBeNil)
+			So(c, ShouldNotBeNil)
+			return timings
+		}
+
+		Convey("A miss records the elasticsearch lookup", func() {
+			timings := lookup()
+			So(*timings.AuthCacheHit(), ShouldBeFalse)
+			So(timings.Duration(timing.AuthLookup), ShouldBeGreaterThanOrEqualTo, 30*time.Millisecond)
+		})
+		Convey("A cache hit records a near-zero lookup", func() {
+			SaveCredentialToCache("lookup-timing", &permission.Permission{Username: "lookup-timing"})
+			timings := lookup()
+			So(*timings.AuthCacheHit(), ShouldBeTrue)
+			So(timings.Duration(timing.AuthLookup), ShouldBeLessThan, 5*time.Millisecond)
+		})
+		Convey("Nothing is recorded for a credential that isn't looked up", func() {
+			So((&timing.Timings{}).AuthCacheHit(), ShouldBeNil)
+		})
+	})
+}
@@ -5,23 +5,25 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
 	"github.com/appbaseio/reactivesearch-api/middleware"
 	"github.com/appbaseio/reactivesearch-api/middleware/classify"
 	"github.com/appbaseio/reactivesearch-api/middleware/validate"
+	"github.com/appbaseio/reactivesearch-api/model/authmethod"
 	"github.com/appbaseio/reactivesearch-api/model/category"
 	"github.com/appbaseio/reactivesearch-api/model/credential"
 	"github.com/appbaseio/reactivesearch-api/model/index"
 	"github.com/appbaseio/reactivesearch-api/model/op"
 	"github.com/appbaseio/reactivesearch-api/model/permission"
+	"github.com/appbaseio/reactivesearch-api/model/timing"
 	"github.com/appbaseio/reactivesearch-api/model/user"
 	"github.com/appbaseio/reactivesearch-api/util"
 	"github.com/dgrijalva/jwt-go"
 	"github.com/dgrijalva/jwt-go/request"
 	"github.com/gorilla/mux"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type chain struct {
@@ -40,6 +42,7 @@ func list() []middleware.Middleware {
 		BasicAuth(),
 		validate.Operation(),
 		validate.Category(),
+		validate.Maintenance(),
 	}
 }
 
@@ -74,6 +77,14 @@ func BasicAuth() middleware.Middleware {
 func (a *Auth) basicAuth(h http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		ctx := req.Context()
+		start := time.Now()
+		served := false
+		defer func() {
+			// the rejected requests spend their time authenticating
+			if !served {
+				timing.Add(ctx, timing.Auth, time.Since(start))
+			}
+		}()
 
 		reqCategory, err := category.FromContext(ctx)
 		if err != nil {
@@ -90,6 +101,7 @@ func (a *Auth) basicAuth(h http.HandlerFunc) http.HandlerFunc {
 		}
 
 		username, password, hasBasicAuth := req.BasicAuth()
+		username = util.NormalizeUsername(username)
 		jwtToken, err := request.ParseFromRequest(req, request.AuthorizationHeaderExtractor, func(token *jwt.Token) (interface{}, error) {
 			if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
 				return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
@@ -99,12 +111,29 @@ func (a *Auth) basicAuth(h http.HandlerFunc) http.HandlerFunc {
 			}
 			return a.jwtRsaPublicKey, nil
 		})
+		if !hasBasicAuth && err == request.ErrNoTokenInRequest && a.anonymousUsername != "" {
+			reqPermission, err := a.anonymousPermission(ctx, *reqCategory, *reqOp)
+			if err != nil {
+				w.Header().Set("www-authenticate", "Basic realm=\"Authentication Required\"")
+				util.WriteBackError(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+			ctx = credential.NewContext(ctx, credential.Permission)
+			ctx = permission.NewContext(ctx, reqPermission)
+			req = req.WithContext(ctx)
+			authmethod.Set(ctx, authmethod.Anonymous)
+			served = true
+			timing.Add(ctx, timing.Auth, time.Since(start))
+			h(w, req)
+			return
+		}
 		if !hasBasicAuth && err != nil {
 			var msg string
 			if err == request.ErrNoTokenInRequest {
 				msg = "Basic Auth or JWT is required"
 			} else {
 				msg = fmt.Sprintf("Unable to parse JWT: %v", err)
+				auditAuthFailure(req, "", "invalid jwt")
 			}
 			w.Header().Set("www-authenticate", "Basic realm=\"Authentication Required\"")
 			util.WriteBackError(w, msg, http.StatusUnauthorized)
@@ -119,11 +148,13 @@ func (a *Auth) basicAuth(h http.HandlerFunc) http.HandlerFunc {
 				} else if u, ok := claims["role"]; ok {
 					role = u.(string)
 				} else {
+					auditAuthFailure(req, "", "jwt without a role")
 					w.Header().Set("www-authenticate", "Basic realm=\"Authentication Required\"")
 					util.WriteBackError(w, fmt.Sprintf("Invalid JWT"), http.StatusUnauthorized)
 					return
 				}
 			} else {
+				auditAuthFailure(req, "", "invalid jwt")
 				w.Header().Set("www-authenticate", "Basic realm=\"Authentication Required\"")
 				util.WriteBackError(w, fmt.Sprintf("Invalid JWT"), http.StatusUnauthorized)
 				return
@@ -132,10 +163,11 @@ func (a *Auth) basicAuth(h http.HandlerFunc) http.HandlerFunc {
 		// we don't know if the credentials provided here are of a 'user' or a 'permission'
 		var obj credential.AuthCredential
 		if role != "" {
-			obj, err = a.es.getRolePermission(ctx, role)
+			obj, err = a.getRolePermission(ctx, role)
 			if err != nil || obj == nil {
 				msg := fmt.Sprintf("No API credentials match with provided role: %s", role)
 				log.Errorln(logTag, ":", err)
+				auditAuthFailure(req, role, "unknown role")
 				w.Header().Set("www-authenticate", "Basic realm=\"Authentication Required\"")
 				util.WriteBackError(w, msg, http.StatusUnauthorized)
 				return
@@ -145,12 +177,18 @@ func (a *Auth) basicAuth(h http.HandlerFunc) http.HandlerFunc {
 			if err != nil || obj == nil {
 				msg := fmt.Sprintf("No API credentials match with provided username: %s", username)
 				log.Errorln(logTag, ":", err)
+				auditAuthFailure(req, username, "unknown username")
 				w.Header().Set("www-authenticate", "Basic realm=\"Authentication Required\"")
 				util.WriteBackError(w, msg, http.StatusUnauthorized)
 				return
 			}
 		}
 
+		// the username of both a user and a permission is resolved by the password
+		if merged, ok := obj.(*mergedCredential); ok {
+			obj = merged.resolve(password, hasBasicAuth)
+		}
+
 		var authenticated bool
 		var errorMsg = "invalid credentials provided"
 
@@ -162,17 +200,37 @@ func (a *Auth) basicAuth(h http.HandlerFunc) http.HandlerFunc {
 
 				reqUser := obj.(*user.User)
 				// No need to validate if already validated before
-				if hasBasicAuth && !IsPasswordExist(reqUser.Username, password) && bcrypt.CompareHashAndPassword([]byte(reqUser.Password), []byte(password)) != nil {
-					w.Header().Set("www-authenticate", "Basic realm=\"Authentication Required\"")
-					util.WriteBackError(w, "invalid password", http.StatusUnauthorized)
-					return
+				if hasBasicAuth && !IsPasswordExist(reqUser.Username, password) {
+					var valid bool
+					reqUser, valid = a.verifyUserPassword(ctx, reqUser, password)
+					if !valid {
+						auditAuthFailure(req, reqUser.Username, "invalid password")
+						w.Header().Set("www-authenticate", "Basic realm=\"Authentication Required\"")
+						util.WriteBackError(w, "invalid password", http.StatusUnauthorized)
+						return
+					}
 				}
 				// Save validated username to avoid the bcrypt comparison
 				SavePassword(reqUser.Username, password)
+				if hasBasicAuth && a.passwordExpiry != nil {
+					if err := a.passwordExpiry.check(w, req, reqUser); err != nil {
+						auditAuthFailure(req, reqUser.Username, "password expired")
+						w.Header().Set("www-authenticate", "Basic realm=\"Authentication Required\"")
+						util.WriteBackError(w, err.Error(), http.StatusUnauthorized)
+						return
+					}
+				}
 
 				// ignore es auth for root route to fetch the cluster details
 				if (req.Method == http.MethodGet || req.Method == http.MethodHead) && req.RequestURI == "/" {
 					authenticated = true
+				} else if validate.CategoryDefaultDeny() {
+					// only the categories the user is granted can be accessed
+					if reqUser.HasCategory(*reqCategory) {
+						authenticated = true
+					} else {
+						errorMsg = "user not allowed to access" + " " + (*reqCategory).String()
+					}
 				} else if *reqUser.IsAdmin {
 					authenticated = true
 				} else if reqCategory.IsFromES() {
@@ -210,6 +268,7 @@ func (a *Auth) basicAuth(h http.HandlerFunc) http.HandlerFunc {
 			{
 				reqPermission := obj.(*permission.Permission)
 				if hasBasicAuth && reqPermission.Password != password {
+					auditAuthFailure(req, reqPermission.Username, "invalid password")
 					w.Header().Set("www-authenticate", "Basic realm=\"Authentication Required\"")
 					util.WriteBackError(w, "invalid password", http.StatusUnauthorized)
 					return
@@ -244,22 +303,42 @@ func (a *Auth) basicAuth(h http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
+		if hasBasicAuth {
+			authmethod.Set(ctx, authmethod.Basic)
+		} else {
+			authmethod.Set(ctx, authmethod.JWT)
+		}
+
 		// remove user/permission from cache on write operation
 		if *reqOp == op.Write || *reqOp == op.Delete {
-			username := mux.Vars(req)["username"]
+			username := util.NormalizeUsername(mux.Vars(req)["username"])
 			RemoveCredentialFromCache(username)
 		}
 
+		served = true
+		timing.Add(ctx, timing.Auth, time.Since(start))
 		h(w, req)
 	}
 }
 
 func (a *Auth) getCredential(ctx context.Context, username string) (credential.AuthCredential, error) {
+	username = util.NormalizeUsername(username)
+	start := time.Now()
+	defer func() { timing.Add(ctx, timing.AuthLookup, time.Since(start)) }()
 	c, ok := GetCachedCredential(username)
+	timing.SetAuthCacheHit(ctx, ok)
 	if ok {
 		return c, nil
 	}
-	return a.es.getCredential(ctx, username)
+	c, err := a.es.getCredential(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	// the merged credential is cached as is, since it resolves per request
+	if merged, ok := c.(*mergedCredential); ok {
+		SaveCredentialToCache(username, merged)
+	}
+	return c, nil
 }
 
 // GetCachedCredential returns the cached credential
@@ -0,0 +1,144 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/pbkdf2"
+
+	"github.com/appbaseio/reactivesearch-api/model/user"
+)
+
+const (
+	hashTypeBcrypt   = "bcrypt"
+	hashTypeArgon2id = "argon2id"
+	hashTypePbkdf2   = "pbkdf2"
+)
+
+// errPasswordMismatch is returned when the password doesn't match the stored hash.
+var errPasswordMismatch = errors.New("password doesn't match the stored hash")
+
+// pbkdf2Hashes are the supported digests of the pbkdf2 hashes.
+var pbkdf2Hashes = map[string]func() hash.Hash{
+	"pbkdf2_sha256": sha256.New,
+	"pbkdf2_sha512": sha512.New,
+}
+
+// verifyPassword compares the password with the hash of the given hash type. The
+// users without a hash type are hashed with bcrypt on startup.
+func verifyPassword(hashType, hashedPassword, password string) error {
+	switch hashType {
+	case "", hashTypeBcrypt:
+		return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
+	case hashTypeArgon2id:
+		return verifyArgon2id(hashedPassword, password)
+	case hashTypePbkdf2:
+		return verifyPbkdf2(hashedPassword, password)
+	default:
+		return fmt.Errorf("unsupported password hash type: %s", hashType)
+	}
+}
+
+// verifyArgon2id verifies an argon2id hash in the PHC string format, i.e.
+// `$argon2id$v=19$m=65536,t=3,p=4$<salt>$<hash>` with base64 encoded salt and hash.
+func verifyArgon2id(hashedPassword, password string) error {
+	parts := strings.Split(hashedPassword, "$")
+	if len(parts) != 6 || parts[1] != hashTypeArgon2id {
+		return errors.New("invalid argon2id hash")
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return fmt.Errorf("invalid argon2id hash version: %v", err)
+	}
+	if version != argon2.Version {
+		return fmt.Errorf("unsupported argon2id hash version: %d", version)
+	}
+	var memory, time uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil {
+		return fmt.Errorf("invalid argon2id hash parameters: %v", err)
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return fmt.Errorf("invalid argon2id hash salt: %v", err)
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return fmt.Errorf("invalid argon2id hash: %v", err)
+	}
+	derived := argon2.IDKey([]byte(password), salt, time, memory, threads, uint32(len(key)))
+	if subtle.ConstantTimeCompare(derived, key) != 1 {
+		return errPasswordMismatch
+	}
+	return nil
+}
+
+// verifyPbkdf2 verifies a pbkdf2 hash in the `<algorithm>$<iterations>$<salt>$<hash>`
+// format, e.g. `pbkdf2_sha256$260000$salt$<base64 hash>`.
+func verifyPbkdf2(hashedPassword, password string) error {
+	parts := strings.Split(hashedPassword, "$")
+	if len(parts) != 4 {
+		return errors.New("invalid pbkdf2 hash")
+	}
+	h, ok := pbkdf2Hashes[parts[0]]
+	if !ok {
+		return fmt.Errorf("unsupported pbkdf2 algorithm: %s", parts[0])
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations <= 0 {
+		return fmt.Errorf("invalid pbkdf2 iterations: %s", parts[1])
+	}
+	key, err := base64.StdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return fmt.Errorf("invalid pbkdf2 hash: %v", err)
+	}
+	derived := pbkdf2.Key([]byte(password), []byte(parts[2]), iterations, len(key), h)
+	if subtle.ConstantTimeCompare(derived, key) != 1 {
+		return errPasswordMismatch
+	}
+	return nil
+}
+
+// verifyUserPassword verifies the password of the user against its stored hash. The
+// passwords hashed with an algorithm other than bcrypt, e.g. of the users migrated from
+// other systems, are rehashed with bcrypt once verified, in which case the rehashed
+// user is returned in place of the given one.
+func (a *Auth) verifyUserPassword(ctx context.Context, u *user.User, password string) (*user.User, bool) {
+	if err := verifyPassword(u.PasswordHashType, u.Password, password); err != nil {
+		if err != errPasswordMismatch && err != bcrypt.ErrMismatchedHashAndPassword {
+			log.Errorln(logTag, ": unable to verify the password of", u.Username, ":", err)
+		}
+		return u, false
+	}
+	if u.PasswordHashType == "" || u.PasswordHashType == hashTypeBcrypt {
+		return u, true
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		log.Errorln(logTag, ": unable to rehash the password of", u.Username, ":", err)
+		return u, true
+	}
+	rehashed := *u
+	rehashed.Password = string(hashedPassword)
+	rehashed.PasswordHashType = hashTypeBcrypt
+	if _, err := a.es.putUser(ctx, rehashed); err != nil {
+		log.Errorln(logTag, ": unable to save the rehashed password of", u.Username, ":", err)
+		return u, true
+	}
+	// the cached user still has the previous hash
+	RemoveCredentialFromCache(u.Username)
+	log.Println(logTag, ": rehashed password for user", u.Username, "using bcrypt")
+	return &rehashed, true
+}
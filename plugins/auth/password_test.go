@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/pbkdf2"
+
+	"github.com/appbaseio/reactivesearch-api/model/user"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// fakeUserStore records the users put by the auth plugin.
+type fakeUserStore struct {
+	authService
+	users []user.User
+}
+
+func (s *fakeUserStore) putUser(ctx context.Context, u user.User) (bool, error) {
+	s.users = append(s.users, u)
+	return true, nil
+}
+
+func argon2idHash(password string) string {
+	salt := []byte("somesaltsomesalt")
+	key := argon2.IDKey([]byte(password), salt, 1, 64*1024, 2, 32)
+	return fmt.Sprintf("$argon2id$v=%d$m=65536,t=1,p=2$%s$%s", argon2.Version,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+}
+
+func TestVerifyPassword(t *testing.T) {
+	Convey("Verify the password hashes", t, func() {
+		Convey("bcrypt", func() {
+			hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+			So(err, ShouldBeNil)
+			So(verifyPassword(hashTypeBcrypt, string(hash), "secret"), ShouldBeNil)
+			So(verifyPassword(hashTypeBcrypt, string(hash), "wrong"), ShouldNotBeNil)
+		})
+		Convey("argon2id", func() {
+			So(verifyPassword(hashTypeArgon2id, argon2idHash("secret"), "secret"), ShouldBeNil)
+			So(verifyPassword(hashTypeArgon2id, argon2idHash("secret"), "wrong"), ShouldEqual, errPasswordMismatch)
+			So(verifyPassword(hashTypeArgon2id, "$argon2id$v=19$invalid", "secret"), ShouldNotBeNil)
+		})
+		Convey("pbkdf2", func() {
+			key := pbkdf2.Key([]byte("secret"), []byte("salt"), 1000, 32, sha256.New)
+			hash := "pbkdf2_sha256$1000$salt$" + base64.StdEncoding.EncodeToString(key)
+			So(verifyPassword(hashTypePbkdf2, hash, "secret"), ShouldBeNil)
+			So(verifyPassword(hashTypePbkdf2, hash, "wrong"), ShouldEqual, errPasswordMismatch)
+			So(verifyPassword(hashTypePbkdf2, "pbkdf2_md5$1000$salt$"+base64.StdEncoding.EncodeToString(key), "secret"), ShouldNotBeNil)
+		})
+		Convey("Unknown hash types are rejected", func() {
+			So(verifyPassword("md5", "5ebe2294ecd0e0f08eab7690d2a6ee69", "secret"), ShouldNotBeNil)
+		})
+	})
+}
+
+func TestVerifyUserPassword(t *testing.T) {
+	Convey("Verify the password of a user", t, func() {
+		store := &fakeUserStore{}
+		a := &Auth{es: store}
+		u := &user.User{Username: "migrated", Password: argon2idHash("secret"), PasswordHashType: hashTypeArgon2id}
+
+		Convey("An argon2id hash is accepted and upgraded to bcrypt", func() {
+			verified, ok := a.verifyUserPassword(context.Background(), u, "secret")
+			So(ok, ShouldBeTrue)
+			So(verified.PasswordHashType, ShouldEqual, hashTypeBcrypt)
+			So(bcrypt.CompareHashAndPassword([]byte(verified.Password), []byte("secret")), ShouldBeNil)
+			So(store.users, ShouldHaveLength, 1)
+			So(store.users[0].Username, ShouldEqual, "migrated")
+			So(store.users[0].PasswordHashType, ShouldEqual, hashTypeBcrypt)
+			So(store.users[0].Password, ShouldEqual, verified.Password)
+			// the given user isn't modified
+			So(u.PasswordHashType, ShouldEqual, hashTypeArgon2id)
+
+			Convey("The upgraded hash is verified with bcrypt", func() {
+				_, ok := a.verifyUserPassword(context.Background(), verified, "secret")
+				So(ok, ShouldBeTrue)
+				So(store.users, ShouldHaveLength, 1)
+			})
+		})
+		Convey("A wrong password isn't accepted nor rehashed", func() {
+			_, ok := a.verifyUserPassword(context.Background(), u, "wrong")
+			So(ok, ShouldBeFalse)
+			So(store.users, ShouldBeEmpty)
+		})
+	})
+}
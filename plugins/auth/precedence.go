@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/appbaseio/reactivesearch-api/model/credential"
+	"github.com/appbaseio/reactivesearch-api/model/permission"
+	"github.com/appbaseio/reactivesearch-api/model/user"
+)
+
+const (
+	envCredentialPrecedence = "CREDENTIAL_PRECEDENCE"
+	// precedenceError rejects the usernames of both a user and a permission
+	precedenceError = "error"
+	// precedenceUser resolves the usernames of both a user and a permission to the user
+	precedenceUser = "user"
+	// precedencePermission resolves the usernames of both a user and a permission to
+	// the permission
+	precedencePermission = "permission"
+	// precedenceMerge resolves the usernames of both a user and a permission to the one
+	// whose password matches the request's
+	precedenceMerge = "merge"
+)
+
+// credentialPrecedence returns the configured precedence of the usernames of both a
+// user and a permission.
+func credentialPrecedence() (string, error) {
+	switch value := os.Getenv(envCredentialPrecedence); value {
+	case "":
+		return precedenceError, nil
+	case precedenceError, precedenceUser, precedencePermission, precedenceMerge:
+		return value, nil
+	default:
+		return "", fmt.Errorf("invalid value for %s: %s", envCredentialPrecedence, value)
+	}
+}
+
+// mergedCredential is the credential of a username of both a user and a permission,
+// which is resolved to either of them by the password of the request.
+type mergedCredential struct {
+	user       *user.User
+	permission *permission.Permission
+}
+
+// Id returns the username of the credential.
+func (c *mergedCredential) Id() string {
+	return c.user.Id()
+}
+
+// resolve returns the permission if the password matches its password, otherwise the
+// user, whose password is verified along with the user.
+func (c *mergedCredential) resolve(password string, hasBasicAuth bool) credential.AuthCredential {
+	if hasBasicAuth && c.permission.Password == password {
+		return c.permission
+	}
+	return c.user
+}
+
+// resolveCredential returns the credential of the username among the user and the
+// permission found for it, according to the precedence.
+func resolveCredential(username string, u *user.User, p *permission.Permission, precedence string) (credential.AuthCredential, error) {
+	switch {
+	case u == nil && p == nil:
+		return nil, fmt.Errorf(`invalid username or password`)
+	case p == nil:
+		return u, nil
+	case u == nil:
+		return p, nil
+	}
+	switch precedence {
+	case precedenceUser:
+		return u, nil
+	case precedencePermission:
+		return p, nil
+	case precedenceMerge:
+		return &mergedCredential{user: u, permission: p}, nil
+	default:
+		return nil, fmt.Errorf(`more than one result for "username"="%s"`, username)
+	}
+}
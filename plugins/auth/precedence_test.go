@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"os"
+	"testing"
+
+	"github.com/appbaseio/reactivesearch-api/model/permission"
+	"github.com/appbaseio/reactivesearch-api/model/user"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestResolveCredential(t *testing.T) {
+	Convey("Resolve a username of both a user and a permission", t, func() {
+		u := &user.User{Username: "foo"}
+		p := &permission.Permission{Username: "foo", Password: "secret"}
+
+		Convey("Error precedence rejects the username", func() {
+			_, err := resolveCredential("foo", u, p, precedenceError)
+			So(err, ShouldNotBeNil)
+		})
+		Convey("User precedence resolves to the user", func() {
+			c, err := resolveCredential("foo", u, p, precedenceUser)
+			So(err, ShouldBeNil)
+			So(c, ShouldHaveSameTypeAs, &user.User{})
+		})
+		Convey("Permission precedence resolves to the permission", func() {
+			c, err := resolveCredential("foo", u, p, precedencePermission)
+			So(err, ShouldBeNil)
+			So(c, ShouldHaveSameTypeAs, &permission.Permission{})
+		})
+		Convey("Merge precedence resolves by the password", func() {
+			c, err := resolveCredential("foo", u, p, precedenceMerge)
+			So(err, ShouldBeNil)
+			merged, ok := c.(*mergedCredential)
+			So(ok, ShouldBeTrue)
+			So(merged.Id(), ShouldEqual, "foo")
+			So(merged.resolve("secret", true), ShouldEqual, p)
+			So(merged.resolve("other", true), ShouldEqual, u)
+			So(merged.resolve("", false), ShouldEqual, u)
+		})
+		Convey("A single credential resolves regardless of the precedence", func() {
+			c, err := resolveCredential("foo", u, nil, precedenceError)
+			So(err, ShouldBeNil)
+			So(c, ShouldEqual, u)
+			c, err = resolveCredential("foo", nil, p, precedenceUser)
+			So(err, ShouldBeNil)
+			So(c, ShouldEqual, p)
+			_, err = resolveCredential("foo", nil, nil, precedenceMerge)
+			So(err, ShouldNotBeNil)
+		})
+		Convey("Precedence is read from the env", func() {
+			defer os.Unsetenv(envCredentialPrecedence)
+			precedence, err := credentialPrecedence()
+			So(err, ShouldBeNil)
+			So(precedence, ShouldEqual, precedenceError)
+			os.Setenv(envCredentialPrecedence, "merge")
+			precedence, err = credentialPrecedence()
+			So(err, ShouldBeNil)
+			So(precedence, ShouldEqual, precedenceMerge)
+			os.Setenv(envCredentialPrecedence, "both")
+			_, err = credentialPrecedence()
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,164 @@
+package auth
+
+import (
+	lru "container/list"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/appbaseio/reactivesearch-api/model/permission"
+)
+
+const (
+	envRoleCacheTTL     = "ROLE_PERMISSION_CACHE_TTL"
+	envRoleCacheSize    = "ROLE_PERMISSION_CACHE_SIZE"
+	defaultRoleCacheTTL = time.Minute
+	// defaultRoleCacheSize bounds the cached roles, the least recently used one is
+	// evicted once it is reached
+	defaultRoleCacheSize = 1000
+)
+
+// rolePermissionCache caches the permissions of the roles resolved for the JWT requests,
+// since looking a role up is a search rather than a get.
+var rolePermissionCache = newRoleCache(defaultRoleCacheSize, defaultRoleCacheTTL)
+
+type roleCacheEntry struct {
+	role       string
+	permission *permission.Permission
+	expiresAt  time.Time
+}
+
+// roleCache is a concurrency-safe LRU cache of role to permission whose entries
+// expire after the TTL. A zero TTL disables the cache.
+type roleCache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	entries map[string]*lru.Element
+	// order holds the entries from the most to the least recently used
+	order *lru.List
+	now   func() time.Time
+}
+
+func newRoleCache(size int, ttl time.Duration) *roleCache {
+	return &roleCache{
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[string]*lru.Element),
+		order:   lru.New(),
+		now:     time.Now,
+	}
+}
+
+// initRoleCache configures the role permission cache from the env.
+func initRoleCache() error {
+	ttl := defaultRoleCacheTTL
+	if value := os.Getenv(envRoleCacheTTL); value != "" {
+		var err error
+		ttl, err = time.ParseDuration(value)
+		if err != nil || ttl < 0 {
+			return fmt.Errorf("invalid value for %s: %s", envRoleCacheTTL, value)
+		}
+	}
+	size := defaultRoleCacheSize
+	if value := os.Getenv(envRoleCacheSize); value != "" {
+		var err error
+		size, err = strconv.Atoi(value)
+		if err != nil || size <= 0 {
+			return fmt.Errorf("invalid value for %s: %s", envRoleCacheSize, value)
+		}
+	}
+	rolePermissionCache.configure(size, ttl)
+	return nil
+}
+
+// configure resets the cache with the given size and TTL.
+func (c *roleCache) configure(size int, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.size = size
+	c.ttl = ttl
+	c.entries = make(map[string]*lru.Element)
+	c.order.Init()
+}
+
+func (c *roleCache) get(role string) (*permission.Permission, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[role]
+	if !ok {
+		return nil, false
+	}
+	entry := e.Value.(*roleCacheEntry)
+	if !c.now().Before(entry.expiresAt) {
+		c.removeElement(e)
+		return nil, false
+	}
+	c.order.MoveToFront(e)
+	return entry.permission, true
+}
+
+func (c *roleCache) put(role string, p *permission.Permission) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ttl == 0 || p == nil {
+		return
+	}
+	if e, ok := c.entries[role]; ok {
+		c.removeElement(e)
+	}
+	c.entries[role] = c.order.PushFront(&roleCacheEntry{
+		role:       role,
+		permission: p,
+		expiresAt:  c.now().Add(c.ttl),
+	})
+	for c.order.Len() > c.size {
+		c.removeElement(c.order.Back())
+	}
+}
+
+func (c *roleCache) remove(role string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[role]; ok {
+		c.removeElement(e)
+	}
+}
+
+// removeUsername removes the role of the permission with the given username, whose
+// role isn't known by the callers that only have its username.
+func (c *roleCache) removeUsername(username string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, e := range c.entries {
+		if e.Value.(*roleCacheEntry).permission.Username == username {
+			c.removeElement(e)
+		}
+	}
+}
+
+func (c *roleCache) removeElement(e *lru.Element) {
+	c.order.Remove(e)
+	delete(c.entries, e.Value.(*roleCacheEntry).role)
+}
+
+// getRolePermission returns the permission of the role, from the cache if present.
+func (a *Auth) getRolePermission(ctx context.Context, role string) (*permission.Permission, error) {
+	if p, ok := rolePermissionCache.get(role); ok {
+		return p, nil
+	}
+	p, err := a.es.getRolePermission(ctx, role)
+	if err != nil {
+		return nil, err
+	}
+	rolePermissionCache.put(role, p)
+	return p, nil
+}
+
+// RemoveRolePermissionFromCache removes the cached permission of the role.
+func RemoveRolePermissionFromCache(role string) {
+	rolePermissionCache.remove(role)
+}
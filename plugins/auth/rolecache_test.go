@@ -0,0 +1,112 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/appbaseio/reactivesearch-api/model/permission"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// fakeRoleStore counts the role permission searches of the auth plugin.
+type fakeRoleStore struct {
+	authService
+	mu          sync.Mutex
+	searches    int
+	permissions map[string]*permission.Permission
+}
+
+func (s *fakeRoleStore) getRolePermission(ctx context.Context, role string) (*permission.Permission, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.searches++
+	p := *s.permissions[role]
+	return &p, nil
+}
+
+func TestRolePermissionCache(t *testing.T) {
+	Convey("Cache the role permissions", t, func() {
+		store := &fakeRoleStore{permissions: map[string]*permission.Permission{
+			"admin":  {Username: "foo", Role: "admin"},
+			"viewer": {Username: "bar", Role: "viewer"},
+		}}
+		a := &Auth{es: store}
+		now := time.Now()
+		rolePermissionCache.configure(defaultRoleCacheSize, time.Minute)
+		rolePermissionCache.now = func() time.Time { return now }
+		Reset(func() {
+			rolePermissionCache.configure(defaultRoleCacheSize, defaultRoleCacheTTL)
+			rolePermissionCache.now = time.Now
+		})
+		ctx := context.Background()
+
+		Convey("A cached role lookup avoids the search", func() {
+			p, err := a.getRolePermission(ctx, "admin")
+			So(err, ShouldBeNil)
+			So(p.Username, ShouldEqual, "foo")
+			p, err = a.getRolePermission(ctx, "admin")
+			So(err, ShouldBeNil)
+			So(p.Username, ShouldEqual, "foo")
+			So(store.searches, ShouldEqual, 1)
+		})
+		Convey("An expired role is searched again", func() {
+			a.getRolePermission(ctx, "admin")
+			now = now.Add(time.Minute)
+			a.getRolePermission(ctx, "admin")
+			So(store.searches, ShouldEqual, 2)
+		})
+		Convey("A role update invalidates the cached role", func() {
+			a.getRolePermission(ctx, "admin")
+			store.permissions["admin"] = &permission.Permission{Username: "baz", Role: "admin"}
+			RemoveRolePermissionFromCache("admin")
+			p, _ := a.getRolePermission(ctx, "admin")
+			So(p.Username, ShouldEqual, "baz")
+			So(store.searches, ShouldEqual, 2)
+		})
+		Convey("A permission update invalidates its cached role", func() {
+			a.getRolePermission(ctx, "admin")
+			a.getRolePermission(ctx, "viewer")
+			ClearLocalUser("foo")
+			a.getRolePermission(ctx, "admin")
+			a.getRolePermission(ctx, "viewer")
+			So(store.searches, ShouldEqual, 3)
+		})
+		Convey("The least recently used role is evicted", func() {
+			rolePermissionCache.configure(1, time.Minute)
+			a.getRolePermission(ctx, "admin")
+			a.getRolePermission(ctx, "viewer")
+			a.getRolePermission(ctx, "viewer")
+			a.getRolePermission(ctx, "admin")
+			So(store.searches, ShouldEqual, 3)
+		})
+		Convey("A zero TTL disables the cache", func() {
+			rolePermissionCache.configure(defaultRoleCacheSize, 0)
+			a.getRolePermission(ctx, "admin")
+			a.getRolePermission(ctx, "admin")
+			So(store.searches, ShouldEqual, 2)
+		})
+		Convey("Concurrent lookups are safe", func() {
+			var wg sync.WaitGroup
+			for i := 0; i < 50; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					role := "admin"
+					if i%2 == 0 {
+						role = "viewer"
+					}
+					a.getRolePermission(ctx, role)
+					if i%10 == 0 {
+						RemoveRolePermissionFromCache(role)
+					}
+				}(i)
+			}
+			wg.Wait()
+			p, err := a.getRolePermission(ctx, "viewer")
+			So(err, ShouldBeNil)
+			So(p.Username, ShouldEqual, "bar")
+		})
+	})
+}
@@ -1,7 +1,11 @@
 package auth
 
 import (
+	"net/http"
 	"sync"
+
+	"github.com/appbaseio/reactivesearch-api/util/audit"
+	"github.com/appbaseio/reactivesearch-api/util/iplookup"
 )
 
 // UserToPasswordCache represents a map of bcrypt validated users
@@ -41,4 +45,16 @@ func ClearLocalUser(username string) {
 	ClearPassword(username)
 	// Clear user record from the user cache
 	RemoveCredentialFromCache(username)
+	// Clear the role of the permission from the role cache
+	rolePermissionCache.removeUsername(username)
+}
+
+// auditAuthFailure emits an audit event for a request that failed to authenticate.
+func auditAuthFailure(req *http.Request, username, reason string) {
+	audit.Emit(audit.Event{
+		Type:     audit.AuthFailure,
+		Username: username,
+		ClientIP: iplookup.FromRequest(req),
+		Reason:   reason,
+	})
 }
@@ -0,0 +1,257 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/appbaseio/reactivesearch-api/middleware"
+	"github.com/appbaseio/reactivesearch-api/model/category"
+	"github.com/appbaseio/reactivesearch-api/model/credential"
+	"github.com/appbaseio/reactivesearch-api/model/index"
+	"github.com/appbaseio/reactivesearch-api/model/op"
+	"github.com/appbaseio/reactivesearch-api/model/permission"
+	"github.com/appbaseio/reactivesearch-api/model/user"
+	"github.com/appbaseio/reactivesearch-api/util"
+)
+
+const (
+	envWebhookURL         = "AUTH_WEBHOOK_URL"
+	envWebhookTimeout     = "AUTH_WEBHOOK_TIMEOUT"
+	envWebhookCacheTTL    = "AUTH_WEBHOOK_CACHE_TTL"
+	envWebhookFailOpen    = "AUTH_WEBHOOK_FAIL_OPEN"
+	defaultWebhookTimeout = 2 * time.Second
+	defaultWebhookTTL     = time.Minute
+	// maxWebhookDecisions bounds the cached decisions, the expired ones are evicted
+	// once it is reached and the new ones aren't cached while it still is
+	maxWebhookDecisions = 10000
+)
+
+// webhookRequest is the request metadata posted to the authorization webhook.
+type webhookRequest struct {
+	Username   string   `json:"username"`
+	Credential string   `json:"credential"`
+	Category   string   `json:"category"`
+	Op         string   `json:"op"`
+	Indices    []string `json:"indices"`
+	Method     string   `json:"method"`
+	Path       string   `json:"path"`
+}
+
+// webhookResponse is the decision of the authorization webhook.
+type webhookResponse struct {
+	Allow  bool   `json:"allow"`
+	Reason string `json:"reason"`
+}
+
+type webhookDecision struct {
+	webhookResponse
+	expiresAt time.Time
+}
+
+// webhook authorizes the requests against an external authorization service.
+type webhook struct {
+	url      string
+	client   *http.Client
+	cacheTTL time.Duration
+	// failOpen allows the requests when the webhook is unavailable
+	failOpen bool
+
+	mu        sync.Mutex
+	decisions map[string]webhookDecision
+}
+
+func newWebhook(url string, timeout, cacheTTL time.Duration, failOpen bool) *webhook {
+	return &webhook{
+		url:       url,
+		client:    &http.Client{Timeout: timeout},
+		cacheTTL:  cacheTTL,
+		failOpen:  failOpen,
+		decisions: make(map[string]webhookDecision),
+	}
+}
+
+// initWebhook configures the authorization webhook from the env, it is disabled
+// unless AUTH_WEBHOOK_URL is set.
+func initWebhook() (*webhook, error) {
+	url := os.Getenv(envWebhookURL)
+	if url == "" {
+		return nil, nil
+	}
+	timeout := defaultWebhookTimeout
+	if value := os.Getenv(envWebhookTimeout); value != "" {
+		var err error
+		timeout, err = time.ParseDuration(value)
+		if err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("invalid value for %s: %s", envWebhookTimeout, value)
+		}
+	}
+	cacheTTL := defaultWebhookTTL
+	if value := os.Getenv(envWebhookCacheTTL); value != "" {
+		var err error
+		cacheTTL, err = time.ParseDuration(value)
+		if err != nil || cacheTTL < 0 {
+			return nil, fmt.Errorf("invalid value for %s: %s", envWebhookCacheTTL, value)
+		}
+	}
+	return newWebhook(url, timeout, cacheTTL, os.Getenv(envWebhookFailOpen) == "true"), nil
+}
+
+// Webhook returns a middleware that authorizes the authenticated requests against the
+// external authorization service configured by AUTH_WEBHOOK_URL. It must follow the
+// BasicAuth middleware.
+func Webhook() middleware.Middleware {
+	return Instance().authorizeWebhook
+}
+
+func (a *Auth) authorizeWebhook(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if a.webhook == nil {
+			h(w, req)
+			return
+		}
+
+		payload, err := newWebhookRequest(req)
+		if err != nil {
+			log.Errorln(logTag, ":", err)
+			util.WriteBackError(w, "error occurred while authorizing the request", http.StatusInternalServerError)
+			return
+		}
+
+		decision, err := a.webhook.authorize(req.Context(), payload)
+		if err != nil {
+			log.Errorln(logTag, ": authorization webhook is unavailable:", err)
+			if a.webhook.failOpen {
+				h(w, req)
+				return
+			}
+			util.WriteBackError(w, "unable to authorize the request", http.StatusServiceUnavailable)
+			return
+		}
+		if !decision.Allow {
+			msg := decision.Reason
+			if msg == "" {
+				msg = fmt.Sprintf(`request of "username"="%s" is denied by the authorization webhook`, payload.Username)
+			}
+			util.WriteBackError(w, msg, http.StatusForbidden)
+			return
+		}
+
+		h(w, req)
+	}
+}
+
+// newWebhookRequest collects the metadata of an authenticated request.
+func newWebhookRequest(req *http.Request) (*webhookRequest, error) {
+	ctx := req.Context()
+	payload := &webhookRequest{Method: req.Method, Path: req.URL.Path}
+
+	reqCredential, err := credential.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	switch reqCredential {
+	case credential.User:
+		reqUser, err := user.FromContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		payload.Username = reqUser.Username
+		payload.Credential = "user"
+	case credential.Permission:
+		reqPermission, err := permission.FromContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		payload.Username = reqPermission.Username
+		payload.Credential = "permission"
+	}
+
+	reqCategory, err := category.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	payload.Category = reqCategory.String()
+
+	reqOp, err := op.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	payload.Op = reqOp.String()
+
+	// indices aren't classified for all the routes
+	payload.Indices, _ = index.FromContext(ctx)
+	return payload, nil
+}
+
+// authorize returns the decision of the webhook for the request, the decisions are
+// cached for the cache TTL.
+func (wh *webhook) authorize(ctx context.Context, payload *webhookRequest) (*webhookResponse, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(body)
+	key := hex.EncodeToString(sum[:])
+
+	wh.mu.Lock()
+	decision, ok := wh.decisions[key]
+	if ok && time.Now().After(decision.expiresAt) {
+		delete(wh.decisions, key)
+		ok = false
+	}
+	wh.mu.Unlock()
+	if ok {
+		return &decision.webhookResponse, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wh.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := wh.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("authorization webhook responded with %d", res.StatusCode)
+	}
+	var response webhookResponse
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("invalid authorization webhook response: %v", err)
+	}
+
+	if wh.cacheTTL > 0 {
+		wh.mu.Lock()
+		wh.store(key, response, time.Now())
+		wh.mu.Unlock()
+	}
+	return &response, nil
+}
+
+// store caches the decision, wh.mu must be held. The decision isn't cached if the
+// cache is still full once the expired decisions are evicted.
+func (wh *webhook) store(key string, response webhookResponse, now time.Time) {
+	if _, ok := wh.decisions[key]; !ok && len(wh.decisions) >= maxWebhookDecisions {
+		for k, d := range wh.decisions {
+			if now.After(d.expiresAt) {
+				delete(wh.decisions, k)
+			}
+		}
+		if len(wh.decisions) >= maxWebhookDecisions {
+			return
+		}
+	}
+	wh.decisions[key] = webhookDecision{webhookResponse: response, expiresAt: now.Add(wh.cacheTTL)}
+}
@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/appbaseio/reactivesearch-api/model/category"
+	"github.com/appbaseio/reactivesearch-api/model/credential"
+	"github.com/appbaseio/reactivesearch-api/model/index"
+	"github.com/appbaseio/reactivesearch-api/model/op"
+	"github.com/appbaseio/reactivesearch-api/model/permission"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func serveWebhook(a *Auth, username string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/books/_search", nil)
+	reqCategory := category.Search
+	reqOp := op.Read
+	ctx := credential.NewContext(req.Context(), credential.Permission)
+	ctx = permission.NewContext(ctx, &permission.Permission{Username: username})
+	ctx = category.NewContext(ctx, &reqCategory)
+	ctx = op.NewContext(ctx, &reqOp)
+	ctx = index.NewContext(ctx, []string{"books"})
+	w := httptest.NewRecorder()
+	a.authorizeWebhook(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})(w, req.WithContext(ctx))
+	return w
+}
+
+func TestWebhook(t *testing.T) {
+	Convey("Authorization webhook", t, func() {
+		var calls int32
+		var delay time.Duration
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			time.Sleep(delay)
+			var payload webhookRequest
+			json.NewDecoder(r.Body).Decode(&payload)
+			if payload.Username == "foo" && payload.Category == "search" && payload.Op == "read" &&
+				len(payload.Indices) == 1 && payload.Indices[0] == "books" {
+				w.Write([]byte(`{"allow":true}`))
+				return
+			}
+			w.Write([]byte(`{"allow":false,"reason":"denied by policy"}`))
+		}))
+		defer ts.Close()
+
+		Convey("Allowed requests are served", func() {
+			a := &Auth{webhook: newWebhook(ts.URL, time.Second, time.Minute, false)}
+			So(serveWebhook(a, "foo").Code, ShouldEqual, http.StatusOK)
+		})
+		Convey("Denied requests are rejected with the reason", func() {
+			a := &Auth{webhook: newWebhook(ts.URL, time.Second, time.Minute, false)}
+			w := serveWebhook(a, "bar")
+			So(w.Code, ShouldEqual, http.StatusForbidden)
+			So(w.Body.String(), ShouldContainSubstring, "denied by policy")
+		})
+		Convey("Decisions are cached for the ttl", func() {
+			a := &Auth{webhook: newWebhook(ts.URL, time.Second, time.Minute, false)}
+			serveWebhook(a, "foo")
+			serveWebhook(a, "foo")
+			So(atomic.LoadInt32(&calls), ShouldEqual, 1)
+
+			a.webhook.cacheTTL = 0
+			serveWebhook(a, "bar")
+			serveWebhook(a, "bar")
+			So(atomic.LoadInt32(&calls), ShouldEqual, 3)
+		})
+		Convey("Decisions aren't cached once the cache is full", func() {
+			wh := newWebhook(ts.URL, time.Second, time.Minute, false)
+			now := time.Now()
+			for i := 0; i < maxWebhookDecisions; i++ {
+				wh.store(fmt.Sprint(i), webhookResponse{Allow: true}, now)
+			}
+			wh.store("new", webhookResponse{Allow: true}, now)
+			So(wh.decisions, ShouldHaveLength, maxWebhookDecisions)
+			So(wh.decisions, ShouldNotContainKey, "new")
+
+			// the expired decisions make room for the new ones
+			wh.store("new", webhookResponse{Allow: true}, now.Add(2*time.Minute))
+			So(wh.decisions, ShouldHaveLength, 1)
+			So(wh.decisions, ShouldContainKey, "new")
+		})
+		Convey("Requests are rejected on timeout when failing closed", func() {
+			delay = 100 * time.Millisecond
+			a := &Auth{webhook: newWebhook(ts.URL, 10*time.Millisecond, time.Minute, false)}
+			So(serveWebhook(a, "foo").Code, ShouldEqual, http.StatusServiceUnavailable)
+		})
+		Convey("Requests are served on timeout when failing open", func() {
+			delay = 100 * time.Millisecond
+			a := &Auth{webhook: newWebhook(ts.URL, 10*time.Millisecond, time.Minute, true)}
+			So(serveWebhook(a, "bar").Code, ShouldEqual, http.StatusOK)
+		})
+		Convey("Requests are served without a webhook", func() {
+			So(serveWebhook(&Auth{}, "bar").Code, ShouldEqual, http.StatusOK)
+		})
+	})
+}
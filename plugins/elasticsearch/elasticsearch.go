@@ -1,13 +1,21 @@
 package elasticsearch
 
 import (
+	"os"
 	"sync"
 
+	log "github.com/sirupsen/logrus"
+
 	"github.com/appbaseio/reactivesearch-api/middleware"
+	"github.com/appbaseio/reactivesearch-api/model/op"
 	"github.com/appbaseio/reactivesearch-api/plugins"
+	"github.com/appbaseio/reactivesearch-api/util"
 )
 
-const logTag = "[elasticsearch]"
+const (
+	logTag             = "[elasticsearch]"
+	envMaintenanceMode = "MAINTENANCE_MODE"
+)
 
 var (
 	singleton *elasticsearch
@@ -16,6 +24,10 @@ var (
 
 type elasticsearch struct {
 	specs []api
+	// dispatchers of the operations routed to their own endpoints
+	dispatchers map[op.Operation]dispatcher
+	// shadow mirrors the reads to a shadow cluster, nil if they aren't mirrored
+	shadow *shadow
 }
 
 func Instance() *elasticsearch {
@@ -28,6 +40,19 @@ func (es *elasticsearch) Name() string {
 }
 
 func (es *elasticsearch) InitFunc(mw []middleware.Middleware) error {
+	if os.Getenv(envMaintenanceMode) == "true" {
+		log.Warnln(logTag, ": starting in maintenance mode, write operations will be rejected")
+		util.SetMaintenanceMode(true)
+	}
+	if err := initExpandWildcards(); err != nil {
+		return err
+	}
+	if err := es.initOperationEndpoints(); err != nil {
+		return err
+	}
+	if err := es.initShadow(); err != nil {
+		return err
+	}
 	return es.preprocess(mw)
 }
 
@@ -18,6 +18,7 @@ import (
 	"github.com/appbaseio/reactivesearch-api/model/acl"
 	"github.com/appbaseio/reactivesearch-api/model/category"
 	"github.com/appbaseio/reactivesearch-api/model/op"
+	"github.com/appbaseio/reactivesearch-api/model/timing"
 	"github.com/appbaseio/reactivesearch-api/util"
 )
 
@@ -83,7 +84,8 @@ func (es *elasticsearch) handler() http.HandlerFunc {
 			requestOptions.Body = string(body)
 		}
 		start := time.Now()
-		response, err := util.GetClient7().PerformRequest(ctx, requestOptions)
+		response, err := es.dispatcher(*reqOp).PerformRequest(ctx, requestOptions)
+		timing.Add(ctx, timing.Upstream, time.Since(start))
 		log.Println(fmt.Sprintf("TIME TAKEN BY ES: %dms", time.Since(start).Milliseconds()))
 		if err != nil {
 			log.Errorln(logTag, ": error while sending request :", r.URL.Path, err)
@@ -94,6 +96,10 @@ func (es *elasticsearch) handler() http.HandlerFunc {
 			util.WriteBackError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		// only the idempotent reads are mirrored, their responses are compared in the background
+		if es.shadow != nil && *reqOp == op.Read {
+			es.shadow.mirror(requestOptions, response)
+		}
 		// Copy the headers
 		if response.Header != nil {
 			for k, v := range response.Header {
@@ -141,3 +147,30 @@ func (es *elasticsearch) healthCheck() http.HandlerFunc {
 		util.WriteBackRaw(w, finalResponseInBytes, code)
 	}
 }
+
+type maintenanceState struct {
+	MaintenanceMode *bool `json:"maintenance_mode"`
+}
+
+func (es *elasticsearch) maintenance() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			var state maintenanceState
+			err := json.NewDecoder(r.Body).Decode(&state)
+			if err != nil || state.MaintenanceMode == nil {
+				util.WriteBackError(w, `request body must contain a boolean "maintenance_mode"`, http.StatusBadRequest)
+				return
+			}
+			util.SetMaintenanceMode(*state.MaintenanceMode)
+			log.Println(logTag, ": maintenance mode set to", *state.MaintenanceMode)
+		}
+		enabled := util.IsMaintenanceMode()
+		raw, err := json.Marshal(maintenanceState{MaintenanceMode: &enabled})
+		if err != nil {
+			log.Errorln(logTag, ":", err)
+			util.WriteBackError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		util.WriteBackRaw(w, raw, http.StatusOK)
+	}
+}
@@ -15,6 +15,7 @@ import (
 
 	"github.com/appbaseio/reactivesearch-api/middleware"
 	"github.com/appbaseio/reactivesearch-api/middleware/classify"
+	"github.com/appbaseio/reactivesearch-api/middleware/coalesce"
 	"github.com/appbaseio/reactivesearch-api/middleware/ratelimiter"
 	"github.com/appbaseio/reactivesearch-api/middleware/validate"
 	"github.com/appbaseio/reactivesearch-api/model/acl"
@@ -23,6 +24,7 @@ import (
 	"github.com/appbaseio/reactivesearch-api/model/op"
 	"github.com/appbaseio/reactivesearch-api/model/permission"
 	"github.com/appbaseio/reactivesearch-api/model/sourcefilter"
+	"github.com/appbaseio/reactivesearch-api/model/user"
 	"github.com/appbaseio/reactivesearch-api/plugins/auth"
 	"github.com/appbaseio/reactivesearch-api/plugins/logs"
 	"github.com/appbaseio/reactivesearch-api/util"
@@ -49,18 +51,83 @@ func list() []middleware.Middleware {
 		classify.Indices(),
 		logs.Recorder(),
 		auth.BasicAuth(),
+		auth.Webhook(),
 		ratelimiter.Limit(),
+		ratelimiter.DailyQuota(),
+		ratelimiter.IndexRate(),
+		ratelimiter.IndexCreations(),
+		ratelimiter.Scrolls(),
 		validate.Sources(),
 		validate.Referers(),
+		validate.Origins(),
 		validate.Indices(),
 		validate.Category(),
 		validate.ACL(),
 		validate.Operation(),
+		validate.WriteDenylist(),
+		validate.IndexExistence(),
+		validate.Maintenance(),
+		logs.RolloverWrites(),
 		validate.PermissionExpiry(),
+		validate.JSON(),
+		validate.Schema(),
+		validate.Scripts(),
+		validate.QueryClauses(),
+		validate.Aggregations(),
+		validate.SortFields(),
+		validate.PaginationDepth(),
+		validate.BulkSize(),
+		preference,
+		terminateAfter,
+		expandWildcards,
+		forceSource,
+		coalesce.Coalesce(),
 		intercept,
 	}
 }
 
+type adminChain struct {
+	middleware.Fifo
+}
+
+func (c *adminChain) Wrap(h http.HandlerFunc) http.HandlerFunc {
+	return c.Adapt(h, adminList()...)
+}
+
+// adminList returns the middlewares for the arc administration routes,
+// which are only accessible to the admin users.
+func adminList() []middleware.Middleware {
+	return []middleware.Middleware{
+		classifyClusterCategory,
+		classify.Op(),
+		auth.BasicAuth(),
+		isAdmin,
+	}
+}
+
+func classifyClusterCategory(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		clusterCategory := category.Clusters
+
+		ctx := category.NewContext(req.Context(), &clusterCategory)
+		req = req.WithContext(ctx)
+
+		h(w, req)
+	}
+}
+
+func isAdmin(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		reqUser, err := user.FromContext(req.Context())
+		if err != nil || reqUser.IsAdmin == nil || !*reqUser.IsAdmin {
+			w.Header().Set("www-authenticate", "Basic realm=\"Authentication Required\"")
+			util.WriteBackError(w, "only admin users are allowed to access this route", http.StatusUnauthorized)
+			return
+		}
+		h(w, req)
+	}
+}
+
 func classifyCategory(h http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		route := mux.CurrentRoute(req)
@@ -72,16 +139,19 @@ func classifyCategory(h http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 		key := fmt.Sprintf("%s:%s", req.Method, template)
-		routeSpec := routeSpecs[key]
+		routeSpec, ok := routeSpecs[key]
 		routeCategory := routeSpec.category
+		fallback := !ok || routeSpec.categoryFallback
 
 		// classify streams explicitly
 		stream := req.Header.Get("X-Request-Category")
 		if stream == "streams" {
 			routeCategory = category.Streams
+			fallback = false
 		}
 
 		ctx := category.NewContext(req.Context(), &routeCategory)
+		ctx = category.NewFallbackContext(ctx, fallback)
 		req = req.WithContext(ctx)
 
 		h(w, req)
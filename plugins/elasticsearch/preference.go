@@ -0,0 +1,49 @@
+package elasticsearch
+
+import (
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/appbaseio/reactivesearch-api/model/acl"
+	"github.com/appbaseio/reactivesearch-api/model/permission"
+)
+
+// headerPreferenceSession carries a client session id, e.g. of a paginated search,
+// that is used as the elasticsearch preference.
+const headerPreferenceSession = "X-Preference-Session"
+
+// preference injects a stable elasticsearch preference into the search requests so
+// that the consecutive searches of a session or a credential hit the same shards.
+// The session header takes precedence over the credential setting, and an explicit
+// preference parameter is never overridden.
+func preference(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		reqACL, err := acl.FromContext(ctx)
+		if err != nil {
+			log.Errorln(logTag, ":", err)
+			h(w, req)
+			return
+		}
+		params := req.URL.Query()
+		if (*reqACL != acl.Search && *reqACL != acl.Msearch) || params.Get("preference") != "" {
+			h(w, req)
+			return
+		}
+
+		value := req.Header.Get(headerPreferenceSession)
+		if value == "" {
+			if reqPermission, err := permission.FromContext(ctx); err == nil &&
+				reqPermission.StablePreference != nil && *reqPermission.StablePreference {
+				value = reqPermission.Username
+			}
+		}
+		if value != "" {
+			params.Set("preference", value)
+			req.URL.RawQuery = params.Encode()
+		}
+
+		h(w, req)
+	}
+}
@@ -0,0 +1,59 @@
+package elasticsearch
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/appbaseio/reactivesearch-api/model/acl"
+	"github.com/appbaseio/reactivesearch-api/model/permission"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// servePreference returns the preference parameter that reaches the handler.
+func servePreference(reqACL acl.ACL, target string, p *permission.Permission, session string) string {
+	req := httptest.NewRequest(http.MethodPost, target, nil)
+	if session != "" {
+		req.Header.Set(headerPreferenceSession, session)
+	}
+	ctx := acl.NewContext(req.Context(), &reqACL)
+	if p != nil {
+		ctx = permission.NewContext(ctx, p)
+	}
+	var value string
+	preference(func(w http.ResponseWriter, r *http.Request) {
+		value = r.URL.Query().Get("preference")
+	})(httptest.NewRecorder(), req.WithContext(ctx))
+	return value
+}
+
+func TestPreference(t *testing.T) {
+	Convey("Stable search preference", t, func() {
+		stable := true
+		p := &permission.Permission{Username: "foo", StablePreference: &stable}
+
+		Convey("Username is injected into the searches of a stable permission", func() {
+			So(servePreference(acl.Search, "/books/_search", p, ""), ShouldEqual, "foo")
+			So(servePreference(acl.Msearch, "/_msearch", p, ""), ShouldEqual, "foo")
+		})
+
+		Convey("Session header takes precedence", func() {
+			So(servePreference(acl.Search, "/books/_search", p, "session-1"), ShouldEqual, "session-1")
+			So(servePreference(acl.Search, "/books/_search", nil, "session-1"), ShouldEqual, "session-1")
+		})
+
+		Convey("Explicit preference is kept", func() {
+			So(servePreference(acl.Search, "/books/_search?preference=_local", p, "session-1"), ShouldEqual, "_local")
+		})
+
+		Convey("Writes are left untouched", func() {
+			So(servePreference(acl.Index, "/books/_doc", p, "session-1"), ShouldBeEmpty)
+			So(servePreference(acl.Bulk, "/_bulk", p, ""), ShouldBeEmpty)
+		})
+
+		Convey("Nothing is injected without a setting or header", func() {
+			So(servePreference(acl.Search, "/books/_search", &permission.Permission{Username: "foo"}, ""), ShouldBeEmpty)
+			So(servePreference(acl.Search, "/books/_search", nil, ""), ShouldBeEmpty)
+		})
+	})
+}
@@ -29,9 +29,11 @@ var (
 type api struct {
 	name     string
 	category category.Category
-	acl      acl.ACL
-	op       op.Operation
-	spec     *spec
+	// categoryFallback is set if the category couldn't be decoded from the spec
+	categoryFallback bool
+	acl              acl.ACL
+	op               op.Operation
+	spec             *spec
 }
 
 type spec struct {
@@ -116,7 +118,14 @@ func (es *elasticsearch) preprocess(mw []middleware.Middleware) error {
 		HandlerFunc: es.healthCheck(),
 		Description: "Retrieve the cluster health, both appbase.io and Elasticsearch",
 	}
-	routes = append(routes, indexRoute, healthCheckRoute)
+	maintenanceRoute := plugins.Route{
+		Name:        "maintenance mode",
+		Methods:     []string{http.MethodGet, http.MethodPut},
+		Path:        "/arc/maintenance",
+		HandlerFunc: (&adminChain{}).Wrap(es.maintenance()),
+		Description: "Retrieve or toggle the read-only maintenance mode",
+	}
+	routes = append(routes, indexRoute, healthCheckRoute, maintenanceRoute)
 	return nil
 }
 
@@ -175,7 +184,7 @@ func decodeSpecFile(box *packr.Box, file string, wg *sync.WaitGroup, apis chan<-
 	}
 
 	specName := strings.TrimSuffix(filepath.Base(file), ".json")
-	specCategory := decodeCategory(&s)
+	specCategory, matched := decodeCategory(&s)
 	specOp := decodeOp(&s)
 	specACL, err := decodeACL(specName, &s)
 	if err != nil {
@@ -186,20 +195,23 @@ func decodeSpecFile(box *packr.Box, file string, wg *sync.WaitGroup, apis chan<-
 	}
 
 	apis <- api{
-		name:     specName,
-		category: specCategory,
-		op:       specOp,
-		acl:      *specACL,
-		spec:     &s,
+		name:             specName,
+		category:         specCategory,
+		categoryFallback: !matched,
+		op:               specOp,
+		acl:              *specACL,
+		spec:             &s,
 	}
 }
 
-func decodeCategory(spec *spec) category.Category {
+// decodeCategory returns the category of the spec from its documentation url, and
+// whether it matched one rather than falling back to the default category.
+func decodeCategory(spec *spec) (category.Category, bool) {
 	docTokens := strings.Split(spec.Documentation, "/")
 	tag := strings.TrimSuffix(docTokens[len(docTokens)-1], ".html")
 	tagTokens := strings.Split(tag, "-")
 	tagName := tagTokens[0]
-	return category.FromString(tagName)
+	return category.Match(tagName)
 }
 
 func decodeACL(specName string, spec *spec) (*acl.ACL, error) {
@@ -0,0 +1,27 @@
+package elasticsearch
+
+import (
+	"testing"
+
+	"github.com/appbaseio/reactivesearch-api/model/category"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDecodeCategory(t *testing.T) {
+	Convey("Decode the category of a spec", t, func() {
+		decode := func(documentation string) (category.Category, bool) {
+			return decodeCategory(&spec{Documentation: documentation})
+		}
+
+		Convey("Clearly classified spec is matched", func() {
+			c, matched := decode("https://www.elastic.co/guide/en/elasticsearch/reference/master/search-search.html")
+			So(c, ShouldEqual, category.Search)
+			So(matched, ShouldBeTrue)
+		})
+		Convey("Ambiguous spec falls back to misc", func() {
+			c, matched := decode("https://www.elastic.co/guide/en/elasticsearch/reference/master/modules-scripting.html")
+			So(c, ShouldEqual, category.Misc)
+			So(matched, ShouldBeFalse)
+		})
+	})
+}
@@ -0,0 +1,78 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	es7 "github.com/olivere/elastic/v7"
+
+	"github.com/appbaseio/reactivesearch-api/model/op"
+	"github.com/appbaseio/reactivesearch-api/util"
+)
+
+// envOperationEndpoints is a comma separated list of the operations and the endpoints their
+// requests are proxied to, e.g. "read:http://coordinating:9200,write:http://ingest:9200".
+const envOperationEndpoints = "ES_OPERATION_ENDPOINTS"
+
+// dispatcher performs the proxied requests, i.e. an elasticsearch client.
+type dispatcher interface {
+	PerformRequest(ctx context.Context, opt es7.PerformRequestOptions) (*es7.Response, error)
+}
+
+// parseOperationEndpoints parses the operations and the endpoints of envOperationEndpoints.
+func parseOperationEndpoints(value string) (map[op.Operation]string, error) {
+	endpoints := make(map[op.Operation]string)
+	for _, token := range strings.Split(value, ",") {
+		token = strings.TrimSpace(token)
+		if token == "" {
+			continue
+		}
+		parts := strings.SplitN(token, ":", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[1]) == "" {
+			return nil, fmt.Errorf("invalid value for %s: %s", envOperationEndpoints, value)
+		}
+		var operation op.Operation
+		if err := json.Unmarshal([]byte(`"`+strings.TrimSpace(parts[0])+`"`), &operation); err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %s", envOperationEndpoints, value)
+		}
+		if _, ok := endpoints[operation]; ok {
+			return nil, fmt.Errorf("invalid value for %s, %s is routed twice: %s", envOperationEndpoints, operation, value)
+		}
+		endpoints[operation] = strings.TrimSpace(parts[1])
+	}
+	return endpoints, nil
+}
+
+// initOperationEndpoints initializes the clients of the endpoints the operations are routed to.
+func (es *elasticsearch) initOperationEndpoints() error {
+	value := os.Getenv(envOperationEndpoints)
+	if value == "" {
+		return nil
+	}
+	endpoints, err := parseOperationEndpoints(value)
+	if err != nil {
+		return err
+	}
+	es.dispatchers = make(map[op.Operation]dispatcher)
+	for operation, url := range endpoints {
+		// the requests must stay on the endpoint's nodes rather than the sniffed ones
+		client, err := util.NewClient7(url, es7.SetSniff(false), es7.SetHealthcheck(false))
+		if err != nil {
+			return fmt.Errorf("invalid value for %s: %v", envOperationEndpoints, err)
+		}
+		es.dispatchers[operation] = client
+	}
+	return nil
+}
+
+// dispatcher returns the dispatcher the requests of the operation are proxied through,
+// the client of ES_CLUSTER_URL if the operation isn't routed to its own endpoint.
+func (es *elasticsearch) dispatcher(operation op.Operation) dispatcher {
+	if d, ok := es.dispatchers[operation]; ok {
+		return d
+	}
+	return util.GetClient7()
+}
@@ -0,0 +1,193 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	es7 "github.com/olivere/elastic/v7"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/appbaseio/reactivesearch-api/util"
+)
+
+const (
+	// envShadowURL is the URL of a shadow cluster the reads are mirrored to, e.g. to
+	// validate a new cluster or version against the production traffic.
+	envShadowURL = "ES_SHADOW_URL"
+	// shadowTimeout bounds a mirrored request
+	shadowTimeout = 30 * time.Second
+	// maxShadowInFlight bounds the concurrent mirrored requests
+	maxShadowInFlight = 100
+)
+
+// volatileFields differ between the responses of two clusters serving the same request.
+var volatileFields = []string{"took", "_shards", "timed_out"}
+
+// shadow mirrors the reads to a shadow cluster in the background and logs the responses
+// that differ from the ones of the cluster the requests are proxied to.
+type shadow struct {
+	client dispatcher
+	// inFlight holds a slot per mirrored request, the reads aren't mirrored while it's full
+	inFlight chan struct{}
+	wg       sync.WaitGroup
+}
+
+func newShadow(client dispatcher) *shadow {
+	return &shadow{client: client, inFlight: make(chan struct{}, maxShadowInFlight)}
+}
+
+// initShadow initializes the client of the shadow cluster if configured.
+func (es *elasticsearch) initShadow() error {
+	url := os.Getenv(envShadowURL)
+	if url == "" {
+		return nil
+	}
+	// the shadow cluster being unavailable must not hold off the startup
+	client, err := util.NewClient7(url, es7.SetSniff(false), es7.SetHealthcheck(false))
+	if err != nil {
+		return fmt.Errorf("invalid value for %s: %v", envShadowURL, err)
+	}
+	es.shadow = newShadow(client)
+	log.Println(logTag, ": mirroring the reads to the shadow cluster")
+	return nil
+}
+
+// mirror sends the read to the shadow cluster without holding off the response, and
+// compares the shadow response with the primary one.
+func (s *shadow) mirror(opt es7.PerformRequestOptions, primary *es7.Response) {
+	// the scroll contexts only exist on the primary cluster
+	if strings.Contains(opt.Path, "/_search/scroll") || opt.Params.Get("scroll") != "" {
+		return
+	}
+	select {
+	case s.inFlight <- struct{}{}:
+	default:
+		log.Warnln(logTag, ": too many mirrored requests in flight, not mirroring", opt.Method, opt.Path)
+		return
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer func() { <-s.inFlight }()
+		ctx, cancel := context.WithTimeout(context.Background(), shadowTimeout)
+		defer cancel()
+		response, err := s.client.PerformRequest(ctx, opt)
+		if diff := diffShadowResponse(primary, response, err); diff != "" {
+			log.Warnln(logTag, ": shadow cluster response differs for", opt.Method, opt.Path, ":", diff)
+		}
+	}()
+}
+
+// wait waits for the mirrored requests in flight.
+func (s *shadow) wait() {
+	s.wg.Wait()
+}
+
+// diffShadowResponse describes the first difference between the primary and the shadow
+// responses, empty if they match once their volatile fields are ignored.
+func diffShadowResponse(primary, shadow *es7.Response, err error) string {
+	if shadow == nil {
+		return fmt.Sprintf("shadow request failed: %v", err)
+	}
+	if primary.StatusCode != shadow.StatusCode {
+		return fmt.Sprintf("status %d, shadow status %d", primary.StatusCode, shadow.StatusCode)
+	}
+	primaryBody, ok := decodeShadowBody(primary.Body)
+	shadowBody, shadowOk := decodeShadowBody(shadow.Body)
+	if !ok || !shadowOk {
+		if !bytes.Equal(primary.Body, shadow.Body) {
+			return "bodies differ"
+		}
+		return ""
+	}
+	return diffValues("", primaryBody, shadowBody)
+}
+
+func decodeShadowBody(body json.RawMessage) (interface{}, bool) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, false
+	}
+	return value, true
+}
+
+// diffValues returns the path of the first difference between the values, along with
+// the hashes of the differing values since the documents may hold personal data.
+func diffValues(path string, a, b interface{}) string {
+	switch av := a.(type) {
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make(map[string]bool)
+		for key := range av {
+			keys[key] = true
+		}
+		for key := range bv {
+			keys[key] = true
+		}
+		sorted := make([]string, 0, len(keys))
+		for key := range keys {
+			if !util.Contains(volatileFields, key) {
+				sorted = append(sorted, key)
+			}
+		}
+		sort.Strings(sorted)
+		for _, key := range sorted {
+			if diff := diffValues(path+"."+key, av[key], bv[key]); diff != "" {
+				return diff
+			}
+		}
+		return ""
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok {
+			break
+		}
+		if len(av) != len(bv) {
+			return fmt.Sprintf("%s has %d items, shadow has %d", fieldPath(path), len(av), len(bv))
+		}
+		for i := range av {
+			if diff := diffValues(fmt.Sprintf("%s[%d]", path, i), av[i], bv[i]); diff != "" {
+				return diff
+			}
+		}
+		return ""
+	}
+	if reflect.DeepEqual(a, b) {
+		return ""
+	}
+	return fmt.Sprintf("%s differs, hash %s, shadow hash %s", fieldPath(path), shadowValue(a), shadowValue(b))
+}
+
+func fieldPath(path string) string {
+	if path == "" {
+		return "body"
+	}
+	return strings.TrimPrefix(path, ".")
+}
+
+func shadowValue(value interface{}) string {
+	if value == nil {
+		return "missing"
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		raw = []byte(fmt.Sprint(value))
+	}
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:4])
+}
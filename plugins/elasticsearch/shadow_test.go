@@ -0,0 +1,101 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+
+	es7 "github.com/olivere/elastic/v7"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+
+	"github.com/appbaseio/reactivesearch-api/model/acl"
+	"github.com/appbaseio/reactivesearch-api/model/op"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// shadowDispatcher records the paths of the requests mirrored to it and responds with body.
+type shadowDispatcher struct {
+	mu    sync.Mutex
+	paths []string
+	body  string
+}
+
+func (d *shadowDispatcher) PerformRequest(ctx context.Context, opt es7.PerformRequestOptions) (*es7.Response, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.paths = append(d.paths, opt.Method+" "+opt.Path)
+	return &es7.Response{StatusCode: http.StatusOK, Body: json.RawMessage(d.body)}, nil
+}
+
+func TestShadowMirroring(t *testing.T) {
+	Convey("Mirror the reads to the shadow cluster", t, func() {
+		hook := logtest.NewGlobal()
+		Reset(hook.Reset)
+		primary := &fakeDispatcher{}
+		shadowClient := &shadowDispatcher{body: `{}`}
+		es := &elasticsearch{
+			dispatchers: map[op.Operation]dispatcher{op.Read: primary, op.Write: primary},
+			shadow:      newShadow(shadowClient),
+		}
+		mismatches := func() []string {
+			var messages []string
+			for _, entry := range hook.AllEntries() {
+				if entry.Level.String() == "warning" {
+					messages = append(messages, entry.Message)
+				}
+			}
+			return messages
+		}
+
+		Convey("A read is mirrored to the shadow cluster", func() {
+			So(serveOperation(es, http.MethodGet, "/books/_doc/1", acl.Get, op.Read), ShouldEqual, http.StatusOK)
+			es.shadow.wait()
+			So(shadowClient.paths, ShouldResemble, []string{"GET /books/_doc/1"})
+			So(mismatches(), ShouldBeEmpty)
+		})
+		Convey("A differing response is logged", func() {
+			shadowClient.body = `{"found":false}`
+			So(serveOperation(es, http.MethodGet, "/books/_doc/1", acl.Get, op.Read), ShouldEqual, http.StatusOK)
+			es.shadow.wait()
+			So(mismatches(), ShouldHaveLength, 1)
+			So(mismatches()[0], ShouldContainSubstring, "GET /books/_doc/1")
+			So(mismatches()[0], ShouldContainSubstring, "found differs, hash missing, shadow hash fcbcf165")
+		})
+		Convey("A write isn't mirrored", func() {
+			So(serveOperation(es, http.MethodPut, "/books/_doc/1", acl.Index, op.Write), ShouldEqual, http.StatusOK)
+			es.shadow.wait()
+			So(shadowClient.paths, ShouldBeEmpty)
+			So(primary.paths, ShouldResemble, []string{"PUT /books/_doc/1"})
+		})
+	})
+
+	Convey("Compare the shadow responses", t, func() {
+		response := func(code int, body string) *es7.Response {
+			return &es7.Response{StatusCode: code, Body: json.RawMessage(body)}
+		}
+
+		Convey("The volatile fields are ignored", func() {
+			diff := diffShadowResponse(
+				response(http.StatusOK, `{"took":3,"_shards":{"total":5},"hits":{"total":{"value":1},"hits":[{"_id":"1"}]}}`),
+				response(http.StatusOK, `{"took":12,"_shards":{"total":1},"hits":{"total":{"value":1},"hits":[{"_id":"1"}]}}`), nil)
+			So(diff, ShouldBeEmpty)
+		})
+		Convey("The first difference is described", func() {
+			diff := diffShadowResponse(
+				response(http.StatusOK, `{"hits":{"hits":[{"_id":"1"},{"_id":"2"}]}}`),
+				response(http.StatusOK, `{"hits":{"hits":[{"_id":"1"},{"_id":"3"}]}}`), nil)
+			So(diff, ShouldEqual, "hits.hits[1]._id differs, hash cc11310c, shadow hash a4aab3f1")
+			// the values themselves are never logged
+			So(diff, ShouldNotContainSubstring, `"2"`)
+			diff = diffShadowResponse(
+				response(http.StatusOK, `{"hits":{"hits":[{"_id":"1"}]}}`),
+				response(http.StatusOK, `{"hits":{"hits":[]}}`), nil)
+			So(diff, ShouldEqual, "hits.hits has 1 items, shadow has 0")
+		})
+		Convey("A differing status is described", func() {
+			So(diffShadowResponse(response(http.StatusOK, `{}`), response(http.StatusNotFound, `{}`), nil), ShouldEqual, "status 200, shadow status 404")
+		})
+	})
+}
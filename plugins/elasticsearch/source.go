@@ -0,0 +1,376 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/appbaseio/reactivesearch-api/model/acl"
+	"github.com/appbaseio/reactivesearch-api/model/permission"
+	"github.com/appbaseio/reactivesearch-api/util"
+)
+
+// sourceParams are the query parameters that override the _source of a search body.
+var sourceParams = []string{"_source", "_source_includes", "_source_include", "_source_excludes", "_source_exclude"}
+
+// fetchKeys fetch the values of the fields outside of the _source of the hits, which
+// can't be filtered, so they're rejected for the permissions forcing the source filtering.
+var fetchKeys = map[string]bool{
+	"docvalue_fields": true,
+	"fields":          true,
+	"stored_fields":   true,
+	"script_fields":   true,
+	"highlight":       true,
+}
+
+// fetchParams are the query parameters fetching the fields outside of the _source.
+var fetchParams = []string{"docvalue_fields", "stored_fields"}
+
+// fieldQueries are keyed by a field name, which could be named like a hits section.
+var fieldQueries = map[string]bool{
+	"term":                true,
+	"terms":               true,
+	"match":               true,
+	"match_phrase":        true,
+	"match_phrase_prefix": true,
+	"prefix":              true,
+	"wildcard":            true,
+	"regexp":              true,
+	"fuzzy":               true,
+	"range":               true,
+}
+
+// fetchError is returned for the searches fetching the fields outside of the _source.
+type fetchError struct {
+	key string
+}
+
+func (e fetchError) Error() string {
+	return fmt.Sprintf(`permission forces the source filtering, "%s" isn't allowed`, e.key)
+}
+
+// isFetch reports whether the key of a hits section fetches fields outside of the
+// _source, the stored fields can only be turned off with "_none_".
+func isFetch(key string, value interface{}) bool {
+	return fetchKeys[key] && !(key == "stored_fields" && value == "_none_")
+}
+
+// forceSource rewrites the _source of the search requests of the permissions that force
+// the source filtering, so that the excluded fields are never fetched no matter what the
+// client requested. The client's excludes are kept along with the permission's ones.
+// The _source of the top_hits and inner_hits sections is enforced too, and the searches
+// fetching the fields outside of the _source are rejected.
+func forceSource(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		reqACL, err := acl.FromContext(ctx)
+		if err != nil {
+			log.Errorln(logTag, ":", err)
+			h(w, req)
+			return
+		}
+		if *reqACL != acl.Search && *reqACL != acl.Msearch {
+			h(w, req)
+			return
+		}
+		reqPermission, err := permission.FromContext(ctx)
+		if err != nil || reqPermission.ForceSourceFiltering == nil || !*reqPermission.ForceSourceFiltering {
+			h(w, req)
+			return
+		}
+
+		var body []byte
+		if req.Body != nil {
+			body, err = ioutil.ReadAll(req.Body)
+			if err != nil {
+				log.Errorln(logTag, ":", err)
+				util.WriteBackError(w, "unable to read request body", http.StatusBadRequest)
+				return
+			}
+			req.Body.Close()
+		}
+
+		// the source parameters are folded into the body, where they're enforced
+		params := req.URL.Query()
+		for _, param := range fetchParams {
+			if value := params.Get(param); value != "" && isFetch(param, value) {
+				util.WriteBackError(w, fetchError{param}.Error(), http.StatusForbidden)
+				return
+			}
+		}
+		paramSource := sourceFromParams(params)
+		for _, param := range sourceParams {
+			params.Del(param)
+		}
+		req.URL.RawQuery = params.Encode()
+
+		if *reqACL == acl.Msearch {
+			body, err = forceMsearchSource(body, paramSource, reqPermission.Includes, reqPermission.Excludes)
+		} else {
+			body, err = forceSearchSource(body, paramSource, reqPermission.Includes, reqPermission.Excludes)
+		}
+		if fetchErr, ok := err.(fetchError); ok {
+			util.WriteBackError(w, fetchErr.Error(), http.StatusForbidden)
+			return
+		}
+		if err != nil {
+			util.WriteBackError(w, "malformed request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		req.ContentLength = int64(len(body))
+
+		h(w, req)
+	}
+}
+
+// sourceFilter is the _source of a search body.
+type sourceFilter struct {
+	// disabled is set for a `"_source": false`
+	disabled bool
+	includes []string
+	excludes []string
+}
+
+// sourceFromParams returns the source filtering of the query parameters, or nil if
+// they don't define any.
+func sourceFromParams(params url.Values) *sourceFilter {
+	fields := func(names ...string) []string {
+		var values []string
+		for _, name := range names {
+			if value := params.Get(name); value != "" {
+				values = append(values, strings.Split(value, ",")...)
+			}
+		}
+		return values
+	}
+	source := &sourceFilter{
+		includes: fields("_source_includes", "_source_include"),
+		excludes: fields("_source_excludes", "_source_exclude"),
+	}
+	switch value := params.Get("_source"); value {
+	case "", "true":
+	case "false":
+		source.disabled = true
+	default:
+		source.includes = append(strings.Split(value, ","), source.includes...)
+	}
+	if !source.disabled && len(source.includes) == 0 && len(source.excludes) == 0 {
+		return nil
+	}
+	return source
+}
+
+// parseSource parses the _source of a search body, i.e. a boolean, a field pattern, an
+// array of them, or an object of the includes and excludes.
+func parseSource(raw json.RawMessage) (*sourceFilter, error) {
+	var value interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return nil, err
+	}
+	return sourceFromValue(value), nil
+}
+
+// sourceFromValue returns the source filtering of a decoded _source.
+func sourceFromValue(value interface{}) *sourceFilter {
+	source := &sourceFilter{}
+	switch v := value.(type) {
+	case bool:
+		source.disabled = !v
+	case string:
+		source.includes = []string{v}
+	case []interface{}:
+		source.includes = toStrings(v)
+	case map[string]interface{}:
+		for _, key := range []string{"includes", "include"} {
+			source.includes = append(source.includes, toStrings(v[key])...)
+		}
+		for _, key := range []string{"excludes", "exclude"} {
+			source.excludes = append(source.excludes, toStrings(v[key])...)
+		}
+	}
+	return source
+}
+
+func toStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var values []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// enforce returns the _source of the client's source filtering restricted by the
+// permission's include and exclude fields.
+func (s *sourceFilter) enforce(includes, excludes []string) interface{} {
+	if s != nil && s.disabled {
+		return false
+	}
+	source := map[string]interface{}{}
+	// the include fields of the permission take precedence over the client's ones
+	if len(includes) > 0 && includes[0] != "*" {
+		source["includes"] = includes
+	} else if s != nil && len(s.includes) > 0 {
+		source["includes"] = s.includes
+	}
+	var forcedExcludes []string
+	if s != nil {
+		forcedExcludes = append(forcedExcludes, s.excludes...)
+	}
+	forcedExcludes = append(forcedExcludes, excludes...)
+	if len(forcedExcludes) > 0 {
+		source["excludes"] = forcedExcludes
+	}
+	return source
+}
+
+// forceSearchSource sets the enforced _source in a search body. The source of the
+// query parameters takes precedence over the one of the body.
+func forceSearchSource(body []byte, paramSource *sourceFilter, includes, excludes []string) ([]byte, error) {
+	query := map[string]json.RawMessage{}
+	if len(bytes.TrimSpace(body)) > 0 {
+		if err := json.Unmarshal(body, &query); err != nil {
+			return nil, err
+		}
+	}
+	for key, raw := range query {
+		var value interface{}
+		json.Unmarshal(raw, &value)
+		if isFetch(key, value) {
+			return nil, fetchError{key}
+		}
+	}
+	// the hits of the aggregations, the collapsed hits and the inner hits of the
+	// queries are fetched with their own _source
+	for _, key := range []string{"aggs", "aggregations", "collapse", "query", "post_filter"} {
+		raw, ok := query[key]
+		if !ok {
+			continue
+		}
+		var value interface{}
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		decoder.UseNumber()
+		if err := decoder.Decode(&value); err != nil {
+			return nil, err
+		}
+		forced, err := forceHitsSource(value, includes, excludes)
+		if err != nil {
+			return nil, err
+		}
+		if forced {
+			if query[key], err = json.Marshal(value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	source := paramSource
+	if raw, ok := query["_source"]; ok && source == nil {
+		var err error
+		if source, err = parseSource(raw); err != nil {
+			return nil, err
+		}
+	}
+	raw, err := json.Marshal(source.enforce(includes, excludes))
+	if err != nil {
+		return nil, err
+	}
+	query["_source"] = raw
+	return json.Marshal(query)
+}
+
+// forceHitsSource sets the enforced _source in the top_hits and inner_hits sections
+// at any depth of the value. It returns whether any section was found.
+func forceHitsSource(value interface{}, includes, excludes []string) (bool, error) {
+	forced := false
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if fieldQueries[key] {
+				continue
+			}
+			if key == "top_hits" || key == "inner_hits" {
+				// the inner_hits of a collapse can be an array of sections
+				sections, ok := child.([]interface{})
+				if !ok {
+					sections = []interface{}{child}
+				}
+				for _, section := range sections {
+					hits, ok := section.(map[string]interface{})
+					if !ok {
+						continue
+					}
+					if err := forceSectionSource(hits, includes, excludes); err != nil {
+						return false, err
+					}
+					forced = true
+				}
+			}
+			childForced, err := forceHitsSource(child, includes, excludes)
+			if err != nil {
+				return false, err
+			}
+			forced = forced || childForced
+		}
+	case []interface{}:
+		for _, child := range v {
+			childForced, err := forceHitsSource(child, includes, excludes)
+			if err != nil {
+				return false, err
+			}
+			forced = forced || childForced
+		}
+	}
+	return forced, nil
+}
+
+// forceSectionSource sets the enforced _source in a top_hits or inner_hits section,
+// which must not fetch the fields bypassing it.
+func forceSectionSource(hits map[string]interface{}, includes, excludes []string) error {
+	for hitsKey, hitsValue := range hits {
+		if isFetch(hitsKey, hitsValue) {
+			return fetchError{hitsKey}
+		}
+	}
+	var source *sourceFilter
+	if raw, ok := hits["_source"]; ok {
+		source = sourceFromValue(raw)
+	}
+	hits["_source"] = source.enforce(includes, excludes)
+	return nil
+}
+
+// forceMsearchSource sets the enforced _source in the search bodies of an NDJSON
+// msearch body.
+func forceMsearchSource(body []byte, paramSource *sourceFilter, includes, excludes []string) ([]byte, error) {
+	var out bytes.Buffer
+	isHeader := true
+	for _, line := range bytes.Split(body, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		if !isHeader {
+			var err error
+			if line, err = forceSearchSource(line, paramSource, includes, excludes); err != nil {
+				return nil, err
+			}
+		}
+		out.Write(line)
+		out.WriteByte('\n')
+		isHeader = !isHeader
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,159 @@
+package elasticsearch
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/appbaseio/reactivesearch-api/model/acl"
+	"github.com/appbaseio/reactivesearch-api/model/permission"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// serveForceSource returns the query and the body that reach the handler.
+func serveForceSource(reqACL acl.ACL, target, body string, p *permission.Permission) (string, string) {
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	ctx := acl.NewContext(req.Context(), &reqACL)
+	ctx = permission.NewContext(ctx, p)
+	var query, forwarded string
+	forceSource(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		b, _ := ioutil.ReadAll(r.Body)
+		forwarded = string(b)
+	})(httptest.NewRecorder(), req.WithContext(ctx))
+	return query, forwarded
+}
+
+// forceSourceCode returns the status code of a request served by forceSource.
+func forceSourceCode(target, body string, p *permission.Permission) int {
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	reqACL := acl.Search
+	ctx := acl.NewContext(req.Context(), &reqACL)
+	ctx = permission.NewContext(ctx, p)
+	w := httptest.NewRecorder()
+	forceSource(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})(w, req.WithContext(ctx))
+	return w.Code
+}
+
+// valueAt returns the value at the path of the keys of a JSON body.
+func valueAt(body string, keys ...string) interface{} {
+	var value interface{}
+	json.Unmarshal([]byte(body), &value)
+	for _, key := range keys {
+		object, _ := value.(map[string]interface{})
+		value = object[key]
+	}
+	return value
+}
+
+func sourceOf(body string) interface{} {
+	var query map[string]interface{}
+	json.Unmarshal([]byte(body), &query)
+	return query["_source"]
+}
+
+func TestForceSource(t *testing.T) {
+	Convey("Forced source filtering", t, func() {
+		force := true
+		p := &permission.Permission{
+			Username:             "foo",
+			Includes:             []string{"*"},
+			Excludes:             []string{"email", "ssn"},
+			ForceSourceFiltering: &force,
+		}
+
+		Convey("Excludes are injected even when the client requested the fields", func() {
+			_, body := serveForceSource(acl.Search, "/users/_search", `{"_source":["name","email"],"query":{"match_all":{}}}`, p)
+			So(sourceOf(body), ShouldResemble, map[string]interface{}{
+				"includes": []interface{}{"name", "email"},
+				"excludes": []interface{}{"email", "ssn"},
+			})
+			So(body, ShouldContainSubstring, `"query":{"match_all":{}}`)
+		})
+		Convey("Source parameters are folded into the body", func() {
+			query, body := serveForceSource(acl.Search, "/users/_search?_source=email&_source_excludes=name&size=1", ``, p)
+			So(query, ShouldEqual, "size=1")
+			So(sourceOf(body), ShouldResemble, map[string]interface{}{
+				"includes": []interface{}{"email"},
+				"excludes": []interface{}{"name", "email", "ssn"},
+			})
+		})
+		Convey("Includes of the permission take precedence", func() {
+			restricted := *p
+			restricted.Includes = []string{"name"}
+			_, body := serveForceSource(acl.Search, "/users/_search", `{"_source":{"includes":["email"]}}`, &restricted)
+			So(sourceOf(body), ShouldResemble, map[string]interface{}{
+				"includes": []interface{}{"name"},
+				"excludes": []interface{}{"email", "ssn"},
+			})
+		})
+		Convey("Disabled source stays disabled", func() {
+			_, body := serveForceSource(acl.Search, "/users/_search", `{"_source":false}`, p)
+			So(sourceOf(body), ShouldEqual, false)
+		})
+		Convey("Every search of a msearch is enforced", func() {
+			_, body := serveForceSource(acl.Msearch, "/_msearch", "{\"index\":\"users\"}\n{\"_source\":true}\n{}\n{}\n", p)
+			lines := strings.Split(strings.TrimSpace(body), "\n")
+			So(lines, ShouldHaveLength, 4)
+			So(lines[0], ShouldEqual, `{"index":"users"}`)
+			So(sourceOf(lines[1]), ShouldResemble, map[string]interface{}{"excludes": []interface{}{"email", "ssn"}})
+			So(sourceOf(lines[3]), ShouldResemble, map[string]interface{}{"excludes": []interface{}{"email", "ssn"}})
+		})
+		Convey("Fields fetched outside of the source are rejected", func() {
+			for _, body := range []string{
+				`{"docvalue_fields":["email"]}`,
+				`{"fields":["email"]}`,
+				`{"stored_fields":["email"]}`,
+				`{"script_fields":{"mail":{"script":"doc['email']"}}}`,
+				`{"highlight":{"fields":{"email":{}}}}`,
+				`{"aggs":{"top":{"top_hits":{"docvalue_fields":["email"]}}}}`,
+				`{"collapse":{"field":"name","inner_hits":{"name":"last","highlight":{"fields":{"ssn":{}}}}}}`,
+			} {
+				So(forceSourceCode("/users/_search", body, p), ShouldEqual, http.StatusForbidden)
+			}
+			So(forceSourceCode("/users/_search?docvalue_fields=email", ``, p), ShouldEqual, http.StatusForbidden)
+			So(forceSourceCode("/users/_search?stored_fields=email", ``, p), ShouldEqual, http.StatusForbidden)
+		})
+		Convey("Disabled stored fields and queried fields are allowed", func() {
+			So(forceSourceCode("/users/_search", `{"stored_fields":"_none_"}`, p), ShouldEqual, http.StatusOK)
+			So(forceSourceCode("/users/_search", `{"query":{"multi_match":{"query":"foo","fields":["name"]}}}`, p), ShouldEqual, http.StatusOK)
+		})
+		Convey("Source of the top hits of the aggregations is enforced", func() {
+			_, body := serveForceSource(acl.Search, "/users/_search", `{"aggs":{"by_name":{"terms":{"field":"name"},"aggs":{"top":{"top_hits":{"size":1,"_source":["email"]}}}}}}`, p)
+			So(valueAt(body, "aggs", "by_name", "aggs", "top", "top_hits", "_source"), ShouldResemble, map[string]interface{}{
+				"includes": []interface{}{"email"},
+				"excludes": []interface{}{"email", "ssn"},
+			})
+			So(valueAt(body, "aggs", "by_name", "aggs", "top", "top_hits", "size"), ShouldEqual, 1)
+		})
+		Convey("Source of the collapsed inner hits is enforced", func() {
+			_, body := serveForceSource(acl.Search, "/users/_search", `{"collapse":{"field":"name","inner_hits":{"name":"last"}}}`, p)
+			So(valueAt(body, "collapse", "inner_hits", "_source"), ShouldResemble, map[string]interface{}{"excludes": []interface{}{"email", "ssn"}})
+		})
+		Convey("Source of each section of the collapsed inner hits is enforced", func() {
+			_, body := serveForceSource(acl.Search, "/users/_search", `{"collapse":{"field":"name","inner_hits":[{"name":"first"},{"name":"last","_source":["email"]}]}}`, p)
+			sections, _ := valueAt(body, "collapse", "inner_hits").([]interface{})
+			So(len(sections), ShouldEqual, 2)
+			So(sections[0].(map[string]interface{})["_source"], ShouldResemble, map[string]interface{}{"excludes": []interface{}{"email", "ssn"}})
+			So(sections[1].(map[string]interface{})["_source"], ShouldResemble, map[string]interface{}{
+				"includes": []interface{}{"email"},
+				"excludes": []interface{}{"email", "ssn"},
+			})
+			fetch := `{"collapse":{"field":"name","inner_hits":[{"name":"first"},{"name":"last","docvalue_fields":["ssn"]}]}}`
+			So(forceSourceCode("/users/_search", fetch, p), ShouldEqual, http.StatusForbidden)
+		})
+		Convey("Source of the inner hits of the nested queries is enforced", func() {
+			_, body := serveForceSource(acl.Search, "/users/_search", `{"query":{"nested":{"path":"contacts","query":{"match_all":{}},"inner_hits":{"_source":true}}}}`, p)
+			So(valueAt(body, "query", "nested", "inner_hits", "_source"), ShouldResemble, map[string]interface{}{"excludes": []interface{}{"email", "ssn"}})
+		})
+		Convey("Requests of the other permissions aren't rewritten", func() {
+			_, body := serveForceSource(acl.Search, "/users/_search", `{"_source":["email"]}`, &permission.Permission{Excludes: []string{"email"}})
+			So(body, ShouldEqual, `{"_source":["email"]}`)
+		})
+	})
+}
@@ -0,0 +1,109 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/appbaseio/reactivesearch-api/model/acl"
+	"github.com/appbaseio/reactivesearch-api/model/permission"
+	"github.com/appbaseio/reactivesearch-api/util"
+)
+
+// terminateAfter injects the permission's terminate_after into the search and count
+// requests to cap the documents elasticsearch collects per shard for them. An explicit
+// terminate_after parameter is kept if it's lower than the permission's. The searches of
+// a msearch are capped in their bodies, and the scroll requests are left untouched as
+// elasticsearch doesn't accept the parameter for them.
+func terminateAfter(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		reqACL, err := acl.FromContext(ctx)
+		if err != nil {
+			log.Errorln(logTag, ":", err)
+			h(w, req)
+			return
+		}
+		if *reqACL != acl.Search && *reqACL != acl.Count && *reqACL != acl.Msearch {
+			h(w, req)
+			return
+		}
+		if strings.Contains(req.URL.Path, "/_search/scroll") {
+			h(w, req)
+			return
+		}
+		reqPermission, err := permission.FromContext(ctx)
+		if err != nil || reqPermission.TerminateAfter == nil || *reqPermission.TerminateAfter == 0 {
+			h(w, req)
+			return
+		}
+
+		limit := *reqPermission.TerminateAfter
+		if *reqACL == acl.Msearch {
+			body, err := ioutil.ReadAll(req.Body)
+			if err != nil {
+				log.Errorln(logTag, ":", err)
+				util.WriteBackError(w, "can't read request body", http.StatusBadRequest)
+				return
+			}
+			if body, err = msearchTerminateAfter(body, limit); err != nil {
+				util.WriteBackError(w, "malformed request body: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			req.Body = ioutil.NopCloser(bytes.NewReader(body))
+			req.ContentLength = int64(len(body))
+			h(w, req)
+			return
+		}
+
+		params := req.URL.Query()
+		if value, err := strconv.Atoi(params.Get("terminate_after")); err == nil && value > 0 && value < limit {
+			limit = value
+		}
+		params.Set("terminate_after", strconv.Itoa(limit))
+		req.URL.RawQuery = params.Encode()
+
+		h(w, req)
+	}
+}
+
+// msearchTerminateAfter caps the terminate_after of every search of a msearch body.
+func msearchTerminateAfter(body []byte, limit int) ([]byte, error) {
+	var out bytes.Buffer
+	isHeader := true
+	for _, line := range bytes.Split(body, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		if !isHeader {
+			var query map[string]json.RawMessage
+			if err := json.Unmarshal(line, &query); err != nil {
+				return nil, err
+			}
+			if query == nil {
+				query = make(map[string]json.RawMessage)
+			}
+			value := limit
+			if raw, ok := query["terminate_after"]; ok {
+				var current int
+				if json.Unmarshal(raw, &current) == nil && current > 0 && current < limit {
+					value = current
+				}
+			}
+			query["terminate_after"] = json.RawMessage(strconv.Itoa(value))
+			var err error
+			if line, err = json.Marshal(query); err != nil {
+				return nil, err
+			}
+		}
+		out.Write(line)
+		out.WriteByte('\n')
+		isHeader = !isHeader
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,83 @@
+package elasticsearch
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/appbaseio/reactivesearch-api/model/acl"
+	"github.com/appbaseio/reactivesearch-api/model/permission"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// serveTerminateAfter returns the terminate_after parameter that reaches the handler.
+func serveTerminateAfter(reqACL acl.ACL, target string, p *permission.Permission) string {
+	req := httptest.NewRequest(http.MethodPost, target, nil)
+	ctx := acl.NewContext(req.Context(), &reqACL)
+	if p != nil {
+		ctx = permission.NewContext(ctx, p)
+	}
+	var value string
+	terminateAfter(func(w http.ResponseWriter, r *http.Request) {
+		value = r.URL.Query().Get("terminate_after")
+	})(httptest.NewRecorder(), req.WithContext(ctx))
+	return value
+}
+
+// serveMsearchTerminateAfter returns the msearch body that reaches the handler.
+func serveMsearchTerminateAfter(body string, p *permission.Permission) string {
+	req := httptest.NewRequest(http.MethodPost, "/_msearch", strings.NewReader(body))
+	reqACL := acl.Msearch
+	ctx := acl.NewContext(req.Context(), &reqACL)
+	ctx = permission.NewContext(ctx, p)
+	var forwarded string
+	terminateAfter(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		forwarded = string(b)
+	})(httptest.NewRecorder(), req.WithContext(ctx))
+	return forwarded
+}
+
+func TestTerminateAfter(t *testing.T) {
+	Convey("Permission-scoped terminate_after", t, func() {
+		limit := 1000
+		p := &permission.Permission{Username: "foo", TerminateAfter: &limit}
+
+		Convey("It's injected into the searches of a limited permission", func() {
+			So(serveTerminateAfter(acl.Search, "/books/_search", p), ShouldEqual, "1000")
+			So(serveTerminateAfter(acl.Count, "/books/_count", p), ShouldEqual, "1000")
+		})
+
+		Convey("A higher explicit value is capped", func() {
+			So(serveTerminateAfter(acl.Search, "/books/_search?terminate_after=5000", p), ShouldEqual, "1000")
+		})
+
+		Convey("A lower explicit value is kept", func() {
+			So(serveTerminateAfter(acl.Search, "/books/_search?terminate_after=10", p), ShouldEqual, "10")
+		})
+
+		Convey("It's omitted for an unlimited permission", func() {
+			unlimited := 0
+			So(serveTerminateAfter(acl.Search, "/books/_search", &permission.Permission{Username: "foo"}), ShouldBeEmpty)
+			So(serveTerminateAfter(acl.Search, "/books/_search", &permission.Permission{Username: "foo", TerminateAfter: &unlimited}), ShouldBeEmpty)
+			So(serveTerminateAfter(acl.Search, "/books/_search", nil), ShouldBeEmpty)
+		})
+
+		Convey("It's injected into every search of a msearch", func() {
+			body := "{\"index\":\"books\"}\n{\"query\":{\"match_all\":{}}}\n{}\n{\"terminate_after\":10}\n"
+			So(serveMsearchTerminateAfter(body, p), ShouldEqual,
+				"{\"index\":\"books\"}\n{\"query\":{\"match_all\":{}},\"terminate_after\":1000}\n{}\n{\"terminate_after\":10}\n")
+		})
+
+		Convey("The scroll requests are left untouched", func() {
+			So(serveTerminateAfter(acl.Search, "/_search/scroll", p), ShouldBeEmpty)
+			So(serveTerminateAfter(acl.Search, "/_search/scroll/abc", p), ShouldBeEmpty)
+		})
+
+		Convey("The other requests are left untouched", func() {
+			So(serveTerminateAfter(acl.Index, "/books/_doc", p), ShouldBeEmpty)
+		})
+	})
+}
@@ -0,0 +1,228 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/appbaseio/reactivesearch-api/model/acl"
+	"github.com/appbaseio/reactivesearch-api/model/index"
+	"github.com/appbaseio/reactivesearch-api/model/user"
+	"github.com/appbaseio/reactivesearch-api/util"
+)
+
+// envAllowedExpandWildcards is a comma separated list of the expand_wildcards values the
+// non-admin credentials may use, e.g. "open,closed".
+const envAllowedExpandWildcards = "ALLOWED_EXPAND_WILDCARDS"
+
+// allowedExpandWildcards are the expand_wildcards values allowed to the non-admin
+// credentials, which are injected if a request doesn't set them.
+var allowedExpandWildcards = []string{"open"}
+
+// parseExpandWildcards parses a comma separated list of the expand_wildcards values.
+func parseExpandWildcards(value string) ([]string, error) {
+	var values []string
+	for _, token := range strings.Split(value, ",") {
+		token = strings.TrimSpace(token)
+		if token == "" {
+			continue
+		}
+		switch token {
+		case "open", "closed", "hidden", "none", "all":
+			values = append(values, token)
+		default:
+			return nil, fmt.Errorf("invalid value for %s: %s", envAllowedExpandWildcards, value)
+		}
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("invalid value for %s: %s", envAllowedExpandWildcards, value)
+	}
+	return values, nil
+}
+
+// initExpandWildcards configures the allowed expand_wildcards values from the env.
+func initExpandWildcards() error {
+	value := os.Getenv(envAllowedExpandWildcards)
+	if value == "" {
+		return nil
+	}
+	values, err := parseExpandWildcards(value)
+	if err != nil {
+		return err
+	}
+	allowedExpandWildcards = values
+	return nil
+}
+
+// allIndicesEndpoints search every index when they're requested without any index.
+var allIndicesEndpoints = map[string]bool{
+	"/_search":  true,
+	"/_msearch": true,
+	"/_count":   true,
+}
+
+// hasWildcardIndex returns true if the indices of a request may expand to any index, i.e.
+// if they're a pattern, `_all` or not given at all.
+func hasWildcardIndex(indices []string) bool {
+	if len(indices) == 0 {
+		return true
+	}
+	for _, indexName := range indices {
+		if indexName == "_all" || strings.Contains(indexName, "*") {
+			return true
+		}
+	}
+	return false
+}
+
+// expandWildcards constrains the index patterns of the searches of the non-admin
+// credentials to the allowed expand_wildcards, so that `*` doesn't reach the hidden
+// and system indices. The allowed values are injected if a request doesn't set them,
+// along with allow_no_indices so that a pattern matching none of the allowed indices
+// results in no hits rather than an error, and any other value is rejected. The same
+// applies to the header lines of the msearch bodies.
+func expandWildcards(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		reqACL, err := acl.FromContext(ctx)
+		if err != nil {
+			log.Errorln(logTag, ":", err)
+			h(w, req)
+			return
+		}
+		if *reqACL != acl.Search && *reqACL != acl.Msearch && *reqACL != acl.Count {
+			h(w, req)
+			return
+		}
+		if reqUser, err := user.FromContext(ctx); err == nil && reqUser.IsAdmin != nil && *reqUser.IsAdmin {
+			h(w, req)
+			return
+		}
+		// the scroll requests continue a search whose indices were already constrained,
+		// and don't accept the parameters
+		if strings.Contains(req.URL.Path, "/_search/scroll") {
+			h(w, req)
+			return
+		}
+		// the msearch header lines override the parameters of the request
+		if *reqACL == acl.Msearch && req.Body != nil {
+			body, err := ioutil.ReadAll(req.Body)
+			if err != nil {
+				log.Errorln(logTag, ":", err)
+				util.WriteBackError(w, "unable to read request body", http.StatusBadRequest)
+				return
+			}
+			req.Body.Close()
+			body, token := constrainMsearchHeaders(body)
+			if token != "" {
+				util.WriteBackError(w, expandWildcardsError(token), http.StatusForbidden)
+				return
+			}
+			req.Body = ioutil.NopCloser(bytes.NewReader(body))
+			req.ContentLength = int64(len(body))
+		}
+
+		reqIndices, err := index.FromContext(ctx)
+		if err != nil || !hasWildcardIndex(reqIndices) {
+			h(w, req)
+			return
+		}
+		// without any index, only the searches of every index are expanded
+		if len(reqIndices) == 0 && !allIndicesEndpoints[strings.TrimSuffix(req.URL.Path, "/")] {
+			h(w, req)
+			return
+		}
+
+		params := req.URL.Query()
+		if value := params.Get("expand_wildcards"); value != "" {
+			if token := disallowedExpandWildcard(strings.Split(value, ",")); token != "" {
+				util.WriteBackError(w, expandWildcardsError(token), http.StatusForbidden)
+				return
+			}
+		} else {
+			params.Set("expand_wildcards", strings.Join(allowedExpandWildcards, ","))
+		}
+		if params.Get("allow_no_indices") == "" {
+			params.Set("allow_no_indices", "true")
+		}
+		req.URL.RawQuery = params.Encode()
+
+		h(w, req)
+	}
+}
+
+// disallowedExpandWildcard returns the first of the expand_wildcards values that isn't
+// allowed, if any.
+func disallowedExpandWildcard(values []string) string {
+	for _, value := range values {
+		if !util.Contains(allowedExpandWildcards, strings.TrimSpace(value)) {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
+
+func expandWildcardsError(token string) string {
+	return fmt.Sprintf("expand_wildcards=%s isn't allowed, the allowed values are: %s",
+		token, strings.Join(allowedExpandWildcards, ","))
+}
+
+// headerValues returns the values of an msearch header field, which is either a comma
+// separated string or an array of strings.
+func headerValues(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return strings.Split(v, ",")
+	case []interface{}:
+		var values []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// constrainMsearchHeaders constrains the expand_wildcards of the header lines of an
+// NDJSON msearch body. The allowed values are injected into the headers of the wildcard
+// indices that don't set them, along with allow_no_indices, and the first disallowed
+// value of a header is returned. The malformed lines are left for elasticsearch to reject.
+func constrainMsearchHeaders(body []byte) ([]byte, string) {
+	var out bytes.Buffer
+	isHeader := true
+	for _, line := range bytes.Split(body, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		if isHeader {
+			var header map[string]interface{}
+			if json.Unmarshal(line, &header) == nil && header != nil {
+				if value, ok := header["expand_wildcards"]; ok {
+					if token := disallowedExpandWildcard(headerValues(value)); token != "" {
+						return nil, token
+					}
+				} else if indices, ok := header["index"]; ok && hasWildcardIndex(headerValues(indices)) {
+					header["expand_wildcards"] = strings.Join(allowedExpandWildcards, ",")
+					if _, ok := header["allow_no_indices"]; !ok {
+						header["allow_no_indices"] = true
+					}
+					if raw, err := json.Marshal(header); err == nil {
+						line = raw
+					}
+				}
+			}
+		}
+		out.Write(line)
+		out.WriteByte('\n')
+		isHeader = !isHeader
+	}
+	return out.Bytes(), ""
+}
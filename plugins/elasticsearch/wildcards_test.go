@@ -0,0 +1,144 @@
+package elasticsearch
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/appbaseio/reactivesearch-api/model/acl"
+	"github.com/appbaseio/reactivesearch-api/model/index"
+	"github.com/appbaseio/reactivesearch-api/model/user"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// serveExpandWildcards returns the response code along with the query parameters that
+// reach the handler, which are nil if the request is rejected.
+func serveExpandWildcards(target string, indices []string, u *user.User) (int, url.Values) {
+	req := httptest.NewRequest(http.MethodPost, target, nil)
+	reqACL := acl.Search
+	ctx := acl.NewContext(req.Context(), &reqACL)
+	ctx = index.NewContext(ctx, indices)
+	if u != nil {
+		ctx = user.NewContext(ctx, u)
+	}
+	var params url.Values
+	w := httptest.NewRecorder()
+	expandWildcards(func(w http.ResponseWriter, r *http.Request) {
+		params = r.URL.Query()
+	})(w, req.WithContext(ctx))
+	return w.Code, params
+}
+
+// serveMsearchWildcards returns the response code along with the msearch body that
+// reaches the handler, which is empty if the request is rejected.
+func serveMsearchWildcards(target string, indices []string, body string, u *user.User) (int, string) {
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	reqACL := acl.Msearch
+	ctx := acl.NewContext(req.Context(), &reqACL)
+	ctx = index.NewContext(ctx, indices)
+	ctx = user.NewContext(ctx, u)
+	var served []byte
+	w := httptest.NewRecorder()
+	expandWildcards(func(w http.ResponseWriter, r *http.Request) {
+		served, _ = ioutil.ReadAll(r.Body)
+	})(w, req.WithContext(ctx))
+	return w.Code, string(served)
+}
+
+func TestExpandWildcards(t *testing.T) {
+	Convey("Constrain the wildcard expansion", t, func() {
+		isAdmin, isNotAdmin := true, false
+		admin := &user.User{Username: "foo", IsAdmin: &isAdmin}
+		developer := &user.User{Username: "bar", IsAdmin: &isNotAdmin}
+
+		Convey("A wildcard search is constrained to the open indices", func() {
+			for _, indices := range [][]string{{"*"}, {"-*"}, {"_all"}, {}, {"books", "logs-*"}} {
+				code, params := serveExpandWildcards("/_search", indices, developer)
+				So(code, ShouldEqual, http.StatusOK)
+				So(params.Get("expand_wildcards"), ShouldEqual, "open")
+				So(params.Get("allow_no_indices"), ShouldEqual, "true")
+			}
+		})
+
+		Convey("An expansion to the hidden indices is rejected", func() {
+			for _, value := range []string{"hidden", "all", "open,hidden"} {
+				code, params := serveExpandWildcards("/*/_search?expand_wildcards="+value, []string{"*"}, developer)
+				So(code, ShouldEqual, http.StatusForbidden)
+				So(params, ShouldBeNil)
+			}
+		})
+
+		Convey("An allowed expansion is kept", func() {
+			_, params := serveExpandWildcards("/*/_search?expand_wildcards=open&allow_no_indices=false", []string{"*"}, developer)
+			So(params.Get("expand_wildcards"), ShouldEqual, "open")
+			So(params.Get("allow_no_indices"), ShouldEqual, "false")
+		})
+
+		Convey("An admin's expansion is allowed through", func() {
+			code, params := serveExpandWildcards("/*/_search?expand_wildcards=all", []string{"*"}, admin)
+			So(code, ShouldEqual, http.StatusOK)
+			So(params.Get("expand_wildcards"), ShouldEqual, "all")
+			So(params.Get("allow_no_indices"), ShouldBeEmpty)
+		})
+
+		Convey("The scroll requests are left untouched", func() {
+			for _, target := range []string{"/_search/scroll", "/_search/scroll/abc"} {
+				code, params := serveExpandWildcards(target, []string{}, developer)
+				So(code, ShouldEqual, http.StatusOK)
+				So(params.Get("expand_wildcards"), ShouldBeEmpty)
+				So(params.Get("allow_no_indices"), ShouldBeEmpty)
+			}
+		})
+
+		Convey("A concrete index is left untouched", func() {
+			_, params := serveExpandWildcards("/books/_search", []string{"books"}, developer)
+			So(params.Get("expand_wildcards"), ShouldBeEmpty)
+		})
+
+		Convey("The msearch headers are constrained", func() {
+			search := `{"query":{"match_all":{}}}`
+			for _, target := range []string{"/_msearch", "/books/_msearch"} {
+				for _, header := range []string{
+					`{"index":"*","expand_wildcards":"all"}`,
+					`{"index":"books","expand_wildcards":"open,hidden"}`,
+					`{"expand_wildcards":["open","hidden"]}`,
+				} {
+					code, body := serveMsearchWildcards(target, []string{"books"}, "{}\n"+search+"\n"+header+"\n"+search+"\n", developer)
+					So(code, ShouldEqual, http.StatusForbidden)
+					So(body, ShouldBeEmpty)
+				}
+			}
+
+			code, body := serveMsearchWildcards("/books/_msearch", []string{"books"}, `{"index":["books","*"]}`+"\n"+search+"\n"+`{}`+"\n"+search+"\n", developer)
+			So(code, ShouldEqual, http.StatusOK)
+			lines := strings.Split(body, "\n")
+			So(lines[0], ShouldEqual, `{"allow_no_indices":true,"expand_wildcards":"open","index":["books","*"]}`)
+			So(lines[1], ShouldEqual, search)
+			So(lines[2], ShouldEqual, `{}`)
+
+			code, body = serveMsearchWildcards("/_msearch", []string{}, `{"index":"*","expand_wildcards":"open","allow_no_indices":false}`+"\n"+search+"\n", developer)
+			So(code, ShouldEqual, http.StatusOK)
+			So(body, ShouldEqual, `{"index":"*","expand_wildcards":"open","allow_no_indices":false}`+"\n"+search+"\n")
+
+			code, body = serveMsearchWildcards("/_msearch", []string{}, `{"index":"*","expand_wildcards":"all"}`+"\n"+search+"\n", admin)
+			So(code, ShouldEqual, http.StatusOK)
+			So(body, ShouldContainSubstring, `"expand_wildcards":"all"`)
+		})
+
+		Convey("The configured values are allowed", func() {
+			values, err := parseExpandWildcards("open, hidden")
+			So(err, ShouldBeNil)
+			allowedExpandWildcards = values
+			Reset(func() { allowedExpandWildcards = []string{"open"} })
+			code, params := serveExpandWildcards("/*/_search?expand_wildcards=hidden", []string{"*"}, developer)
+			So(code, ShouldEqual, http.StatusOK)
+			So(params.Get("expand_wildcards"), ShouldEqual, "hidden")
+
+			_, err = parseExpandWildcards("open,visible")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
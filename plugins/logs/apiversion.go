@@ -0,0 +1,67 @@
+package logs
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+)
+
+const (
+	envAPIVersionHeader  = "LOGS_API_VERSION_HEADER"
+	envAPIVersionPattern = "LOGS_API_VERSION_PATTERN"
+	envAPIVersionDefault = "LOGS_API_VERSION_DEFAULT"
+	// defaultAPIVersion is recorded for the requests that don't target a version
+	defaultAPIVersion = "unversioned"
+)
+
+// apiVersionDetector detects the API version a request targets, from its header or
+// from its path.
+type apiVersionDetector struct {
+	header string
+	// pattern matches the version in the path, e.g. `^/v(\d+)/`, its first group
+	// being the version if it has one
+	pattern  *regexp.Regexp
+	fallback string
+}
+
+// apiVersionDetectorFromEnv returns the detector configured by the env, nil if neither
+// the header nor the path pattern is set.
+func apiVersionDetectorFromEnv() (*apiVersionDetector, error) {
+	header := strings.TrimSpace(os.Getenv(envAPIVersionHeader))
+	value := os.Getenv(envAPIVersionPattern)
+	if header == "" && value == "" {
+		return nil, nil
+	}
+	d := &apiVersionDetector{header: header, fallback: defaultAPIVersion}
+	if value != "" {
+		pattern, err := regexp.Compile(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %v", envAPIVersionPattern, err)
+		}
+		d.pattern = pattern
+	}
+	if fallback := strings.TrimSpace(os.Getenv(envAPIVersionDefault)); fallback != "" {
+		d.fallback = fallback
+	}
+	return d, nil
+}
+
+// detect returns the API version of the request, the header taking precedence over the path.
+func (d *apiVersionDetector) detect(r *http.Request) string {
+	if d.header != "" {
+		if version := strings.TrimSpace(r.Header.Get(d.header)); version != "" {
+			return version
+		}
+	}
+	if d.pattern != nil {
+		if match := d.pattern.FindStringSubmatch(r.URL.Path); match != nil {
+			if len(match) > 1 && match[1] != "" {
+				return match[1]
+			}
+			return match[0]
+		}
+	}
+	return d.fallback
+}
@@ -0,0 +1,57 @@
+package logs
+
+import (
+	"net/http"
+	"os"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRecordAPIVersion(t *testing.T) {
+	Convey("Record the API version of the requests", t, func() {
+		defer os.Unsetenv(envAPIVersionHeader)
+		defer os.Unsetenv(envAPIVersionPattern)
+		defer os.Unsetenv(envAPIVersionDefault)
+		os.Setenv(envAPIVersionHeader, "X-API-Version")
+		os.Setenv(envAPIVersionPattern, `^/v(\d+)/`)
+
+		l := newTestLogs(t)
+		var err error
+		l.apiVersion, err = apiVersionDetectorFromEnv()
+		So(err, ShouldBeNil)
+
+		Convey("The version of the path is recorded", func() {
+			rec := recordTestResponse(t, l, newTestRequest("POST", "/v2/books/_reactivesearch", `{}`), http.StatusOK, `{}`)
+			So(rec.Request.APIVersion, ShouldEqual, "2")
+		})
+		Convey("The version of the header takes precedence over the path", func() {
+			req := newTestRequest("POST", "/v2/books/_reactivesearch", `{}`)
+			req.Header.Set("X-API-Version", "2024-01-01")
+			So(recordTestResponse(t, l, req, http.StatusOK, `{}`).Request.APIVersion, ShouldEqual, "2024-01-01")
+		})
+		Convey("An unversioned request records the default version", func() {
+			rec := recordTestResponse(t, l, newTestRequest("POST", "/books/_search", `{}`), http.StatusOK, `{}`)
+			So(rec.Request.APIVersion, ShouldEqual, defaultAPIVersion)
+
+			os.Setenv(envAPIVersionDefault, "v1")
+			l.apiVersion, err = apiVersionDetectorFromEnv()
+			So(err, ShouldBeNil)
+			rec = recordTestResponse(t, l, newTestRequest("POST", "/books/_search", `{}`), http.StatusOK, `{}`)
+			So(rec.Request.APIVersion, ShouldEqual, "v1")
+		})
+		Convey("The version isn't recorded unless its detection is configured", func() {
+			os.Unsetenv(envAPIVersionHeader)
+			os.Unsetenv(envAPIVersionPattern)
+			l.apiVersion, err = apiVersionDetectorFromEnv()
+			So(err, ShouldBeNil)
+			So(l.apiVersion, ShouldBeNil)
+			So(recordTestResponse(t, l, newTestRequest("POST", "/v2/books/_search", `{}`), http.StatusOK, `{}`).Request.APIVersion, ShouldBeEmpty)
+		})
+		Convey("An invalid path pattern is rejected", func() {
+			os.Setenv(envAPIVersionPattern, `^/v(\d+/`)
+			_, err := apiVersionDetectorFromEnv()
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,53 @@
+package logs
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// bodyHashSize is the number of bytes of the SHA-256 digest kept in the body hash.
+const bodyHashSize = 16
+
+// bodyHash returns a hex encoded, truncated SHA-256 hash of the normalized body, so that
+// the logically identical bodies, e.g. JSON with a different key order or whitespace,
+// hash the same. The NDJSON bodies are normalized line by line. An empty body has no hash.
+func bodyHash(body []byte) string {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return ""
+	}
+	normalized, ok := normalizeJSON(body)
+	if !ok {
+		var lines [][]byte
+		for _, line := range bytes.Split(body, []byte("\n")) {
+			if line = bytes.TrimSpace(line); len(line) == 0 {
+				continue
+			}
+			if normalized, ok := normalizeJSON(line); ok {
+				line = normalized
+			}
+			lines = append(lines, line)
+		}
+		normalized = bytes.Join(lines, []byte("\n"))
+	}
+	sum := sha256.Sum256(normalized)
+	return hex.EncodeToString(sum[:bodyHashSize])
+}
+
+// normalizeJSON re-encodes a JSON value with its object keys sorted and without
+// whitespace, the numbers are kept as is.
+func normalizeJSON(raw []byte) ([]byte, bool) {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil || decoder.More() {
+		return nil, false
+	}
+	normalized, err := json.Marshal(value)
+	if err != nil {
+		return nil, false
+	}
+	return normalized, true
+}
@@ -0,0 +1,43 @@
+package logs
+
+import (
+	"net/http"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestBodyHash(t *testing.T) {
+	Convey("Hash the normalized request bodies", t, func() {
+		Convey("Logically identical JSON bodies hash the same", func() {
+			a := bodyHash([]byte(`{"query":{"match":{"title":"go"}},"size":10}`))
+			b := bodyHash([]byte(`{ "size": 10,
+				"query": { "match": { "title": "go" } } }`))
+			So(a, ShouldNotBeEmpty)
+			So(a, ShouldHaveLength, 2*bodyHashSize)
+			So(a, ShouldEqual, b)
+		})
+		Convey("Different bodies hash differently", func() {
+			a := bodyHash([]byte(`{"query":{"match":{"title":"go"}},"size":10}`))
+			So(bodyHash([]byte(`{"query":{"match":{"title":"rust"}},"size":10}`)), ShouldNotEqual, a)
+			So(bodyHash([]byte(`{"query":{"match":{"title":"go"}},"size":10.0}`)), ShouldNotEqual, a)
+		})
+		Convey("NDJSON bodies are normalized line by line", func() {
+			a := bodyHash([]byte("{\"index\":\"books\"}\n{\"size\":1,\"from\":0}\n"))
+			b := bodyHash([]byte("{ \"index\": \"books\" }\n{\"from\":0, \"size\":1}"))
+			So(a, ShouldEqual, b)
+		})
+		Convey("Empty bodies have no hash", func() {
+			So(bodyHash(nil), ShouldBeEmpty)
+			So(bodyHash([]byte("  \n")), ShouldBeEmpty)
+		})
+		Convey("The recorder stores the hash", func() {
+			l := newTestLogs(t)
+			l.recordBodyHash = true
+			a := recordTestResponse(t, l, newTestRequest("POST", "/books/_search", `{"size":1,"from":0}`), http.StatusOK, `{}`)
+			b := recordTestResponse(t, l, newTestRequest("POST", "/books/_search", `{"from":0,"size":1}`), http.StatusOK, `{}`)
+			So(a.Request.BodyHash, ShouldNotBeEmpty)
+			So(a.Request.BodyHash, ShouldEqual, b.Request.BodyHash)
+		})
+	})
+}
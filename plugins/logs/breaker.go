@@ -0,0 +1,157 @@
+package logs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	envBreakerThreshold    = "LOGS_INDEX_BREAKER_THRESHOLD"
+	envBreakerCooldown     = "LOGS_INDEX_BREAKER_COOLDOWN"
+	envIndexTimeout        = "LOGS_INDEX_TIMEOUT"
+	defaultBreakerCooldown = 30 * time.Second
+
+	breakerClosed   = "closed"
+	breakerOpen     = "open"
+	breakerHalfOpen = "half_open"
+)
+
+// errBreakerOpen is returned for the records that aren't indexed while the breaker is open.
+var errBreakerOpen = errors.New("indexing of the log records is paused")
+
+// indexBreaker stops indexing the records into elasticsearch after threshold consecutive
+// failures, so that a slow or unavailable cluster doesn't pile up the indexing calls. Once
+// the cooldown elapses a single record is let through to probe the cluster, its success
+// closes the breaker and its failure opens it again.
+type indexBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	state     string
+	failures  int
+	openedAt  time.Time
+	trips     int64
+	skipped   int64
+	now       func() time.Time
+}
+
+// breakerStatus is the observable state of the breaker.
+type breakerStatus struct {
+	State               string     `json:"state"`
+	Threshold           int        `json:"threshold"`
+	Cooldown            string     `json:"cooldown"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	Trips               int64      `json:"trips"`
+	SkippedRecords      int64      `json:"skipped_records"`
+	OpenedAt            *time.Time `json:"opened_at,omitempty"`
+}
+
+func newIndexBreaker(threshold int, cooldown time.Duration) *indexBreaker {
+	return &indexBreaker{threshold: threshold, cooldown: cooldown, state: breakerClosed, now: time.Now}
+}
+
+// breakerConfigFromEnv returns the breaker configured by LOGS_INDEX_BREAKER_THRESHOLD and
+// LOGS_INDEX_BREAKER_COOLDOWN, nil if the threshold isn't set.
+func breakerConfigFromEnv() (*indexBreaker, error) {
+	value := os.Getenv(envBreakerThreshold)
+	if value == "" {
+		return nil, nil
+	}
+	threshold, err := strconv.Atoi(value)
+	if err != nil || threshold < 0 {
+		return nil, fmt.Errorf("invalid value for %s: %s", envBreakerThreshold, value)
+	}
+	if threshold == 0 {
+		return nil, nil
+	}
+	cooldown := defaultBreakerCooldown
+	if value := os.Getenv(envBreakerCooldown); value != "" {
+		cooldown, err = time.ParseDuration(value)
+		if err != nil || cooldown <= 0 {
+			return nil, fmt.Errorf("invalid value for %s: %s", envBreakerCooldown, value)
+		}
+	}
+	return newIndexBreaker(threshold, cooldown), nil
+}
+
+// indexTimeoutFromEnv returns the timeout of indexing a record, zero if it isn't bounded.
+func indexTimeoutFromEnv() (time.Duration, error) {
+	value := os.Getenv(envIndexTimeout)
+	if value == "" {
+		return 0, nil
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout < 0 {
+		return 0, fmt.Errorf("invalid value for %s: %s", envIndexTimeout, value)
+	}
+	return timeout, nil
+}
+
+// allow returns whether a record should be indexed.
+func (b *indexBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case breakerClosed:
+		return true
+	case breakerOpen:
+		if b.now().Sub(b.openedAt) >= b.cooldown {
+			b.state = breakerHalfOpen
+			return true
+		}
+	}
+	// cooling down, or the probe of the half open breaker is in flight
+	b.skipped++
+	return false
+}
+
+// success records a successful indexing, closing the breaker.
+func (b *indexBreaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state != breakerClosed {
+		log.Infoln(logTag, ": elasticsearch recovered, resuming the indexing of the log records")
+	}
+	b.state = breakerClosed
+	b.failures = 0
+}
+
+// failure records a failed indexing, opening the breaker once the threshold is reached
+// or if the probe of a half open breaker failed.
+func (b *indexBreaker) failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.state == breakerHalfOpen || (b.state == breakerClosed && b.failures >= b.threshold) {
+		if b.state == breakerClosed {
+			b.trips++
+			log.Warnln(logTag, ": indexing failed", b.failures, "consecutive times, the log records are only written to the log file for", b.cooldown)
+		}
+		b.state = breakerOpen
+		b.openedAt = b.now()
+	}
+}
+
+func (b *indexBreaker) status() breakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	status := breakerStatus{
+		State:               b.state,
+		Threshold:           b.threshold,
+		Cooldown:            b.cooldown.String(),
+		ConsecutiveFailures: b.failures,
+		Trips:               b.trips,
+		SkippedRecords:      b.skipped,
+	}
+	if b.state != breakerClosed {
+		openedAt := b.openedAt
+		status.OpenedAt = &openedAt
+	}
+	return status
+}
@@ -0,0 +1,103 @@
+package logs
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestIndexBreaker(t *testing.T) {
+	Convey("Stop indexing the records while elasticsearch fails", t, func() {
+		server := useTestES(t, ".logs")
+		now := time.Now()
+		breaker := newIndexBreaker(2, time.Minute)
+		breaker.now = func() time.Time { return now }
+		es := &elasticsearch{indexName: ".logs", breaker: breaker}
+		rec := record{Indices: []string{"books"}, Timestamp: time.Now()}
+
+		es.indexRecord(context.Background(), rec)
+		So(es.breakerStatus().State, ShouldEqual, breakerClosed)
+		es.indexRecord(context.Background(), rec)
+		status := es.breakerStatus()
+		So(status.State, ShouldEqual, breakerOpen)
+		So(status.ConsecutiveFailures, ShouldEqual, 2)
+		So(status.Trips, ShouldEqual, 1)
+
+		Convey("The records aren't indexed until the cooldown elapses", func() {
+			server.reset("")
+			es.indexRecord(context.Background(), rec)
+			So(server.indexed, ShouldBeEmpty)
+			So(es.breakerStatus().SkippedRecords, ShouldEqual, 1)
+
+			now = now.Add(time.Minute)
+			es.indexRecord(context.Background(), rec)
+			So(server.indexed, ShouldResemble, []string{".logs"})
+			status := es.breakerStatus()
+			So(status.State, ShouldEqual, breakerClosed)
+			So(status.ConsecutiveFailures, ShouldEqual, 0)
+			So(status.OpenedAt, ShouldBeNil)
+		})
+		Convey("A failed probe opens the breaker again", func() {
+			now = now.Add(time.Minute)
+			es.indexRecord(context.Background(), rec)
+			So(es.breakerStatus().State, ShouldEqual, breakerOpen)
+			So(es.breakerStatus().Trips, ShouldEqual, 1)
+
+			server.reset("")
+			es.indexRecord(context.Background(), rec)
+			So(server.indexed, ShouldBeEmpty)
+		})
+	})
+
+	Convey("The breaker trips on the recorded requests", t, func() {
+		useTestES(t, ".logs")
+		l := newTestLogs(t)
+		l.es = &elasticsearch{indexName: ".logs", breaker: newIndexBreaker(1, time.Minute)}
+		recordTestResponse(t, l, newTestRequest("POST", "/books/_search", `{}`), http.StatusOK, `{"took":1}`)
+		recordTestResponse(t, l, newTestRequest("POST", "/books/_search", `{}`), http.StatusOK, `{"took":1}`)
+
+		w := httptest.NewRecorder()
+		l.getBreaker()(w, httptest.NewRequest(http.MethodGet, "/_logs/_breaker", nil))
+		So(w.Code, ShouldEqual, http.StatusOK)
+		var status breakerStatus
+		So(json.Unmarshal(w.Body.Bytes(), &status), ShouldBeNil)
+		So(status.State, ShouldEqual, breakerOpen)
+		So(status.Trips, ShouldEqual, 1)
+		So(status.SkippedRecords, ShouldEqual, 1)
+		// the records are still written to the log file
+		So(len(readTestRecords(t, l)), ShouldEqual, 2)
+	})
+
+	Convey("Configure the breaker", t, func() {
+		defer os.Unsetenv(envBreakerThreshold)
+		defer os.Unsetenv(envBreakerCooldown)
+
+		breaker, err := breakerConfigFromEnv()
+		So(err, ShouldBeNil)
+		So(breaker, ShouldBeNil)
+
+		os.Setenv(envBreakerThreshold, "5")
+		breaker, err = breakerConfigFromEnv()
+		So(err, ShouldBeNil)
+		So(breaker.threshold, ShouldEqual, 5)
+		So(breaker.cooldown, ShouldEqual, defaultBreakerCooldown)
+
+		os.Setenv(envBreakerCooldown, "10s")
+		breaker, err = breakerConfigFromEnv()
+		So(err, ShouldBeNil)
+		So(breaker.cooldown, ShouldEqual, 10*time.Second)
+
+		os.Setenv(envBreakerCooldown, "0s")
+		_, err = breakerConfigFromEnv()
+		So(err, ShouldNotBeNil)
+		os.Setenv(envBreakerThreshold, "-1")
+		_, err = breakerConfigFromEnv()
+		So(err, ShouldNotBeNil)
+	})
+}
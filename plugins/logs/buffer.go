@@ -0,0 +1,166 @@
+package logs
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	envBufferSize     = "LOGS_BUFFER_SIZE"
+	envWorkers        = "LOGS_WORKERS"
+	defaultBufferSize = 10000
+	defaultWorkers    = 4
+	// maxWriteBatch bounds the records a worker writes at once
+	maxWriteBatch = 100
+)
+
+// pendingRecord is a served request waiting to be recorded.
+type pendingRecord struct {
+	w          *httptest.ResponseRecorder
+	r          *http.Request
+	reqBody    []byte
+	latency    time.Duration
+	stackTrace string
+}
+
+// recordBuffer queues the served requests to be recorded by a fixed pool of workers,
+// each of which writes the records it drains in batches. The requests are dropped once
+// the buffer is full rather than holding off the responses.
+type recordBuffer struct {
+	mu      sync.RWMutex
+	closed  bool
+	pending chan pendingRecord
+	dropped uint64
+	wg      sync.WaitGroup
+}
+
+// recordBufferConfigFromEnv returns the size of the buffer and the number of its workers.
+func recordBufferConfigFromEnv() (int, int, error) {
+	size, workers := defaultBufferSize, defaultWorkers
+	if value := os.Getenv(envBufferSize); value != "" {
+		var err error
+		size, err = strconv.Atoi(value)
+		if err != nil || size < 1 {
+			return 0, 0, fmt.Errorf("invalid value for %s: %s", envBufferSize, value)
+		}
+	}
+	if value := os.Getenv(envWorkers); value != "" {
+		var err error
+		workers, err = strconv.Atoi(value)
+		if err != nil || workers < 1 {
+			return 0, 0, fmt.Errorf("invalid value for %s: %s", envWorkers, value)
+		}
+	}
+	return size, workers, nil
+}
+
+// startRecordBuffer starts the workers recording the requests queued in the buffer.
+func (l *Logs) startRecordBuffer(size, workers int) *recordBuffer {
+	b := &recordBuffer{pending: make(chan pendingRecord, size)}
+	b.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer b.wg.Done()
+			l.drainRecords(b.pending)
+		}()
+	}
+	return b
+}
+
+// push queues the request without blocking, it's dropped if the buffer is full or closed.
+func (b *recordBuffer) push(p pendingRecord) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return false
+	}
+	select {
+	case b.pending <- p:
+		return true
+	default:
+		if dropped := atomic.AddUint64(&b.dropped, 1); dropped%1000 == 1 {
+			log.Errorln(logTag, ": record buffer is full, records dropped so far:", dropped)
+		}
+		return false
+	}
+}
+
+// droppedRecords returns the number of requests dropped because the buffer was full.
+func (b *recordBuffer) droppedRecords() uint64 {
+	return atomic.LoadUint64(&b.dropped)
+}
+
+// close stops accepting the requests and waits for the queued ones to be recorded.
+func (b *recordBuffer) close() {
+	b.mu.Lock()
+	if !b.closed {
+		b.closed = true
+		close(b.pending)
+	}
+	b.mu.Unlock()
+	b.wg.Wait()
+}
+
+// flushOnShutdown records the queued requests and flushes the records pending to be
+// indexed before the process is terminated, the plugin being loaded without a hook into
+// the shutdown of the server.
+func (l *Logs) flushOnShutdown() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		log.Println(logTag, ": recording the queued requests before shutting down")
+		if l.buffer != nil {
+			l.buffer.close()
+		}
+		if l.kafka != nil {
+			l.kafka.close()
+		}
+		if l.es != nil {
+			if err := l.es.close(); err != nil {
+				log.Errorln(logTag, ": error flushing the log records :", err)
+			}
+		}
+		// terminate as the signal would have without being caught
+		signal.Reset(sig)
+		if p, err := os.FindProcess(os.Getpid()); err == nil {
+			p.Signal(sig)
+		}
+	}()
+}
+
+// drainRecords records the queued requests in the order they're received, writing the
+// records of the requests already queued at once, until the buffer is closed.
+func (l *Logs) drainRecords(pending <-chan pendingRecord) {
+	for p := range pending {
+		recs := l.buildRecords(p.w, p.r, p.reqBody, p.latency, p.stackTrace)
+		closed := false
+	batch:
+		for i := 1; i < maxWriteBatch; i++ {
+			select {
+			case next, ok := <-pending:
+				if !ok {
+					closed = true
+					break batch
+				}
+				recs = append(recs, l.buildRecords(next.w, next.r, next.reqBody, next.latency, next.stackTrace)...)
+			default:
+				break batch
+			}
+		}
+		l.writeRecords(recs)
+		if closed {
+			return
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package logs
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"os"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRecordBuffer(t *testing.T) {
+	Convey("Record the requests through the buffer", t, func() {
+		l := newTestLogs(t)
+		pending := func(uri string) pendingRecord {
+			req := newTestRequest("POST", uri, `{}`)
+			dump, err := httputil.DumpRequest(req, true)
+			So(err, ShouldBeNil)
+			w := httptest.NewRecorder()
+			w.WriteHeader(http.StatusOK)
+			return pendingRecord{w: w, r: req, reqBody: dump, latency: time.Millisecond}
+		}
+
+		Convey("A worker records the requests in the order they're queued", func() {
+			l.buffer = l.startRecordBuffer(500, 1)
+			var uris []string
+			for i := 0; i < 250; i++ {
+				uri := fmt.Sprintf("/books-%d/_search", i)
+				uris = append(uris, uri)
+				So(l.buffer.push(pending(uri)), ShouldBeTrue)
+			}
+			// the queued requests are recorded once the buffer is closed
+			l.buffer.close()
+			records := readTestRecords(t, l)
+			So(len(records), ShouldEqual, len(uris))
+			for i, rec := range records {
+				So(rec.Request.URI, ShouldEqual, uris[i])
+			}
+			So(l.buffer.push(pending("/books/_search")), ShouldBeFalse)
+		})
+
+		Convey("A full buffer doesn't block the handler", func() {
+			// without workers, the buffer isn't drained
+			l.buffer = l.startRecordBuffer(1, 0)
+			handler := l.recorder(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+			served := make(chan struct{})
+			go func() {
+				for i := 0; i < 3; i++ {
+					handler(httptest.NewRecorder(), newTestRequest("POST", "/books/_search", `{}`))
+				}
+				close(served)
+			}()
+			select {
+			case <-served:
+			case <-time.After(5 * time.Second):
+				t.Fatal("the handler was blocked by the full buffer")
+			}
+			So(l.buffer.droppedRecords(), ShouldEqual, 2)
+
+			w := httptest.NewRecorder()
+			l.getDroppedRecords()(w, httptest.NewRequest(http.MethodGet, "/_logs/_dropped", nil))
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(w.Body.String(), ShouldEqual, `{"buffer":2,"kafka":0}`)
+		})
+	})
+
+	Convey("Configure the record buffer", t, func() {
+		Reset(func() {
+			os.Unsetenv(envBufferSize)
+			os.Unsetenv(envWorkers)
+		})
+		size, workers, err := recordBufferConfigFromEnv()
+		So(err, ShouldBeNil)
+		So(size, ShouldEqual, defaultBufferSize)
+		So(workers, ShouldEqual, defaultWorkers)
+
+		os.Setenv(envBufferSize, "100")
+		os.Setenv(envWorkers, "2")
+		size, workers, err = recordBufferConfigFromEnv()
+		So(err, ShouldBeNil)
+		So(size, ShouldEqual, 100)
+		So(workers, ShouldEqual, 2)
+
+		os.Setenv(envWorkers, "0")
+		_, _, err = recordBufferConfigFromEnv()
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,53 @@
+package logs
+
+import (
+	"github.com/buger/jsonparser"
+
+	"github.com/appbaseio/reactivesearch-api/util"
+)
+
+// maxBulkErrorReasons bounds the sample of the failure reasons recorded for a bulk request.
+const maxBulkErrorReasons = 5
+
+// BulkErrors are the item-level results of a bulk request, which is responded with a
+// 200 even if some of its items failed.
+type BulkErrors struct {
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+	// Reasons are a sample of the distinct failure reasons, e.g.
+	// `version_conflict_engine_exception: [1]: version conflict`
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+// bulkErrors returns the item-level results of the bulk response, or nil if it
+// has no items.
+func bulkErrors(responseBody []byte) *BulkErrors {
+	var result BulkErrors
+	found := false
+	jsonparser.ArrayEach(responseBody, func(item []byte, dataType jsonparser.ValueType, offset int, err error) {
+		// each item is keyed by its action, e.g. {"index": {"status": 201, ...}}
+		jsonparser.ObjectEach(item, func(action []byte, itemResult []byte, dataType jsonparser.ValueType, offset int) error {
+			found = true
+			itemError, dataType, _, err := jsonparser.Get(itemResult, "error")
+			if err != nil {
+				result.Succeeded++
+				return nil
+			}
+			result.Failed++
+			reason := string(itemError)
+			if dataType == jsonparser.Object {
+				errorType, _ := jsonparser.GetString(itemError, "type")
+				errorReason, _ := jsonparser.GetString(itemError, "reason")
+				reason = errorType + ": " + errorReason
+			}
+			if len(result.Reasons) < maxBulkErrorReasons && !util.Contains(result.Reasons, reason) {
+				result.Reasons = append(result.Reasons, reason)
+			}
+			return nil
+		})
+	}, "items")
+	if !found {
+		return nil
+	}
+	return &result
+}
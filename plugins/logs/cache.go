@@ -0,0 +1,67 @@
+package logs
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// CacheHeaders are the caching headers of a response.
+type CacheHeaders struct {
+	CacheControl string `json:"cache_control,omitempty"`
+	// MaxAge is the s-maxage of the Cache-Control header, or its max-age
+	MaxAge *int64 `json:"max_age,omitempty"`
+	ETag   string `json:"etag,omitempty"`
+	Age    *int64 `json:"age,omitempty"`
+	// Cacheable is set if a shared cache may store the response
+	Cacheable bool `json:"cacheable"`
+}
+
+// cacheHeaders returns the caching headers of the response, or nil if it has none.
+func cacheHeaders(header http.Header) *CacheHeaders {
+	cacheControl := header.Get("Cache-Control")
+	etag := header.Get("ETag")
+	age := header.Get("Age")
+	if cacheControl == "" && etag == "" && age == "" {
+		return nil
+	}
+	headers := &CacheHeaders{CacheControl: cacheControl, ETag: etag}
+	if seconds, err := strconv.ParseInt(strings.TrimSpace(age), 10, 64); err == nil {
+		headers.Age = &seconds
+	}
+
+	var maxAge, sharedMaxAge *int64
+	cacheable := etag != ""
+	for _, directive := range strings.Split(cacheControl, ",") {
+		name, value := strings.TrimSpace(strings.ToLower(directive)), ""
+		if i := strings.Index(name, "="); i >= 0 {
+			name, value = name[:i], strings.Trim(name[i+1:], `"`)
+		}
+		switch name {
+		case "no-store", "private":
+			headers.Cacheable = false
+			return headers
+		case "public":
+			cacheable = true
+		case "max-age", "s-maxage":
+			seconds, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				continue
+			}
+			if name == "max-age" {
+				maxAge = &seconds
+			} else {
+				sharedMaxAge = &seconds
+			}
+		}
+	}
+	headers.MaxAge = maxAge
+	if sharedMaxAge != nil {
+		headers.MaxAge = sharedMaxAge
+	}
+	if headers.MaxAge != nil {
+		cacheable = *headers.MaxAge > 0 || etag != ""
+	}
+	headers.Cacheable = cacheable
+	return headers
+}
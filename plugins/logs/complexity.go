@@ -0,0 +1,160 @@
+package logs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// complexityWeights weigh the features of a search body in its complexity score.
+type complexityWeights struct {
+	// Clauses weighs the query clauses, i.e. the objects nested in the query
+	Clauses float64
+	// Depth weighs the maximum nesting depth of the body
+	Depth float64
+	// Aggregations weighs the aggregations, the sub-aggregations included
+	Aggregations float64
+}
+
+var defaultComplexityWeights = complexityWeights{Clauses: 1, Depth: 2, Aggregations: 5}
+
+// parseComplexityWeights parses a comma separated list of key:weight pairs, e.g.
+// "clauses:1,depth:2,aggs:5". The weights that aren't set keep their default.
+func parseComplexityWeights(value string) (complexityWeights, error) {
+	weights := defaultComplexityWeights
+	for _, token := range strings.Split(value, ",") {
+		token = strings.TrimSpace(token)
+		if token == "" {
+			continue
+		}
+		kv := strings.SplitN(token, ":", 2)
+		if len(kv) != 2 {
+			return weights, fmt.Errorf("invalid weight: %s", token)
+		}
+		weight, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64)
+		if err != nil || weight < 0 {
+			return weights, fmt.Errorf("invalid weight: %s", token)
+		}
+		switch strings.TrimSpace(kv[0]) {
+		case "clauses":
+			weights.Clauses = weight
+		case "depth":
+			weights.Depth = weight
+		case "aggs":
+			weights.Aggregations = weight
+		default:
+			return weights, fmt.Errorf("invalid weight: %s", token)
+		}
+	}
+	return weights, nil
+}
+
+// complexityScore returns the weighted sum of the query clauses, the nesting depth and
+// the aggregations of a search body. The clauses and aggregations of the NDJSON bodies,
+// i.e. of a msearch, are summed over their searches, and their depth is the deepest one.
+// A body that isn't JSON has no score.
+func complexityScore(body []byte, weights complexityWeights) (float64, bool) {
+	var clauses, depth, aggs int
+	scored := false
+	// the NDJSON lines are decoded as consecutive values
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	for {
+		var value interface{}
+		if err := decoder.Decode(&value); err == io.EOF {
+			break
+		} else if err != nil {
+			return 0, false
+		}
+		scored = true
+		if d := jsonDepth(value); d > depth {
+			depth = d
+		}
+		search, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, key := range []string{"query", "post_filter"} {
+			clauses += countClauses(search[key])
+		}
+		aggs += countAggregations(search)
+	}
+	if !scored {
+		return 0, false
+	}
+	score := weights.Clauses*float64(clauses) + weights.Depth*float64(depth) + weights.Aggregations*float64(aggs)
+	return score, true
+}
+
+// jsonDepth returns the nesting depth of the objects and arrays of a JSON value.
+func jsonDepth(value interface{}) int {
+	max := 0
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, child := range v {
+			if d := jsonDepth(child); d > max {
+				max = d
+			}
+		}
+	case []interface{}:
+		for _, child := range v {
+			if d := jsonDepth(child); d > max {
+				max = d
+			}
+		}
+	default:
+		return 0
+	}
+	return max + 1
+}
+
+// countClauses counts the clauses of a query, i.e. the keys whose value is an object,
+// e.g. `{"bool": {"must": [{"term": {"title": "foo"}}]}}` has the bool and term clauses.
+func countClauses(value interface{}) int {
+	count := 0
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, child := range v {
+			if _, ok := child.(map[string]interface{}); ok {
+				count++
+			}
+			count += countClauses(child)
+		}
+	case []interface{}:
+		for _, child := range v {
+			count += countClauses(child)
+		}
+	}
+	return count
+}
+
+// countAggregations counts the aggregations of a search body or an aggregation, the
+// sub-aggregations included.
+func countAggregations(body map[string]interface{}) int {
+	count := 0
+	for _, key := range []string{"aggs", "aggregations"} {
+		aggs, ok := body[key].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, agg := range aggs {
+			count++
+			if agg, ok := agg.(map[string]interface{}); ok {
+				count += countAggregations(agg)
+			}
+		}
+	}
+	return count
+}
+
+// complexityScore returns the complexity score of a search body with the configured
+// weights, or nil if the body can't be scored.
+func (l *Logs) complexityScore(body []byte) *float64 {
+	score, ok := complexityScore(body, l.complexityWeights)
+	if !ok {
+		return nil
+	}
+	return &score
+}
@@ -0,0 +1,64 @@
+package logs
+
+import (
+	"net/http"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestComplexityScore(t *testing.T) {
+	Convey("Score the complexity of the search bodies", t, func() {
+		score := func(body string) float64 {
+			s, ok := complexityScore([]byte(body), defaultComplexityWeights)
+			So(ok, ShouldBeTrue)
+			return s
+		}
+		term := score(`{"query":{"term":{"title":"go"}}}`)
+
+		Convey("A term query scores its clause and depth", func() {
+			So(term, ShouldEqual, 1*1+3*2)
+		})
+		Convey("A nested query scores higher than a term query", func() {
+			nested := score(`{"query":{"bool":{"must":[{"term":{"title":"go"}},
+				{"bool":{"should":[{"match":{"author":"rob"}},{"range":{"year":{"gte":2009}}}]}}]}}}`)
+			So(nested, ShouldBeGreaterThan, term)
+		})
+		Convey("An aggregation heavy query scores higher than a term query", func() {
+			aggs := score(`{"query":{"term":{"title":"go"}},"aggs":{"authors":{"terms":{"field":"author"},
+				"aggs":{"years":{"terms":{"field":"year"}}}},"pages":{"avg":{"field":"pages"}}}}`)
+			So(aggs, ShouldBeGreaterThan, term)
+			So(aggs-term, ShouldBeGreaterThanOrEqualTo, 3*defaultComplexityWeights.Aggregations)
+		})
+		Convey("The searches of a msearch are summed", func() {
+			msearch := score("{\"index\":\"books\"}\n{\"query\":{\"term\":{\"title\":\"go\"}}}\n{}\n{\"query\":{\"term\":{\"title\":\"rust\"}}}\n")
+			So(msearch, ShouldEqual, term+1)
+		})
+		Convey("The weights are configurable", func() {
+			weights, err := parseComplexityWeights("clauses:10, depth:0")
+			So(err, ShouldBeNil)
+			So(weights, ShouldResemble, complexityWeights{Clauses: 10, Depth: 0, Aggregations: 5})
+			s, _ := complexityScore([]byte(`{"query":{"term":{"title":"go"}}}`), weights)
+			So(s, ShouldEqual, 10)
+
+			for _, value := range []string{"clauses", "depth:-1", "size:2"} {
+				_, err := parseComplexityWeights(value)
+				So(err, ShouldNotBeNil)
+			}
+		})
+		Convey("A body that isn't JSON has no score", func() {
+			_, ok := complexityScore([]byte(`query`), defaultComplexityWeights)
+			So(ok, ShouldBeFalse)
+			_, ok = complexityScore(nil, defaultComplexityWeights)
+			So(ok, ShouldBeFalse)
+		})
+		Convey("The recorder stores the score of the searches", func() {
+			l := newTestLogs(t)
+			l.recordComplexity = true
+			l.complexityWeights = defaultComplexityWeights
+			rec := recordTestResponse(t, l, newTestRequest("POST", "/books/_search", `{"query":{"term":{"title":"go"}}}`), http.StatusOK, `{}`)
+			So(rec.Request.ComplexityScore, ShouldNotBeNil)
+			So(*rec.Request.ComplexityScore, ShouldEqual, term)
+		})
+	})
+}
@@ -0,0 +1,37 @@
+package logs
+
+import (
+	"encoding/json"
+)
+
+// RSComponents are the query components of a reactivesearch request.
+type RSComponents struct {
+	// Count is the number of the query components
+	Count int `json:"count"`
+	// Types is the number of the query components per type, e.g. search or geo
+	Types map[string]int `json:"types"`
+}
+
+// rsComponents returns the query components of a marshalled reactivesearch request
+// body, or nil if the body doesn't have any.
+func rsComponents(body []byte) *RSComponents {
+	var rsQuery struct {
+		Query []struct {
+			Type string `json:"type"`
+		} `json:"query"`
+	}
+	if err := json.Unmarshal(body, &rsQuery); err != nil || len(rsQuery.Query) == 0 {
+		return nil
+	}
+	components := &RSComponents{Types: make(map[string]int)}
+	for _, query := range rsQuery.Query {
+		// the queries without a type are search queries
+		queryType := query.Type
+		if queryType == "" {
+			queryType = "search"
+		}
+		components.Count++
+		components.Types[queryType]++
+	}
+	return components
+}
@@ -0,0 +1,54 @@
+package logs
+
+import (
+	"fmt"
+	"mime"
+	"strings"
+)
+
+// binaryBodyPlaceholder is recorded instead of the bodies whose content type isn't captured.
+const binaryBodyPlaceholder = "[binary body omitted]"
+
+// defaultCaptureContentTypes are the content types whose bodies are captured by default.
+var defaultCaptureContentTypes = []string{"application/json", "application/x-ndjson", "text/*"}
+
+// parseContentTypes parses a comma separated list of media types, e.g.
+// "application/json,text/*", where the subtype can be a `*` wildcard.
+func parseContentTypes(value string) ([]string, error) {
+	var contentTypes []string
+	for _, token := range strings.Split(value, ",") {
+		token = strings.ToLower(strings.TrimSpace(token))
+		if token == "" {
+			continue
+		}
+		parts := strings.Split(token, "/")
+		if len(parts) != 2 || parts[0] == "" || parts[0] == "*" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid content type: %s", token)
+		}
+		contentTypes = append(contentTypes, token)
+	}
+	return contentTypes, nil
+}
+
+// capturesContentType returns true if a body of the content type is recorded as is. The
+// bodies without a content type are captured since there's nothing to judge them by.
+func (l *Logs) capturesContentType(contentType string) bool {
+	if strings.TrimSpace(contentType) == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	allowed := l.captureContentTypes
+	if allowed == nil {
+		allowed = defaultCaptureContentTypes
+	}
+	for _, pattern := range allowed {
+		if pattern == mediaType ||
+			(strings.HasSuffix(pattern, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(pattern, "*"))) {
+			return true
+		}
+	}
+	return false
+}
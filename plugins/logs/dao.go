@@ -5,12 +5,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"regexp"
-	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
 	"github.com/appbaseio/reactivesearch-api/middleware/classify"
+	"github.com/appbaseio/reactivesearch-api/model/category"
 	"github.com/appbaseio/reactivesearch-api/model/reindex"
 	"github.com/appbaseio/reactivesearch-api/util"
 	es7 "github.com/olivere/elastic/v7"
@@ -18,13 +20,66 @@ import (
 
 type elasticsearch struct {
 	indexName string
+	// fallbackIndex is the prefix of the date stamped index the records are
+	// written to when the alias is unavailable, fallback is disabled if empty
+	fallbackIndex string
+	// indexPerCategory records to a separate alias per category, e.g. `.logs-search`
+	indexPerCategory bool
+	// categoryAliases holds the category aliases that have been initialized
+	categoryAliases sync.Map
+	// categoryRetention holds the retention of the category aliases that don't use the default one
+	categoryRetention map[string]retention
+	// bulkProcessor batches the records in the background if configured, instead of
+	// indexing each of them with its own bulk request
+	bulkProcessor *es7.BulkProcessor
+	// secondary is the client of the cluster the records are also written to, e.g. for
+	// disaster recovery, the writes failing on it don't fail the records
+	secondary          *es7.Client
+	secondaryProcessor *es7.BulkProcessor
+	// breaker stops indexing the records while the primary cluster keeps failing, nil
+	// if the records are always indexed
+	breaker *indexBreaker
+	// indexTimeout bounds the indexing of a record, zero doesn't bound it
+	indexTimeout time.Duration
+	// wal holds the records until they're indexed, nil if they aren't logged ahead
+	wal *wal
+	// readAlias is a stable alias over all the logs indices for the external tools to
+	// query, it isn't maintained if empty
+	readAlias string
+	// baseRetention is the retention set in the environment, whose unset values
+	// default to the plan's at each rollover
+	baseRetention retention
 }
 
-func initPlugin(alias, config string) (*elasticsearch, error) {
+// bulkProcessorConfig configures the bulk processor the records are indexed through.
+type bulkProcessorConfig struct {
+	workers       int
+	bulkActions   int
+	flushInterval time.Duration
+}
+
+func initPlugin(alias, fallbackIndex, readAlias, config string, indexPerCategory bool, categoryRetention map[category.Category]retention, baseRetention retention) (*elasticsearch, error) {
 
 	ctx := context.Background()
 
-	var es = &elasticsearch{alias}
+	var es = &elasticsearch{
+		indexName:         alias,
+		fallbackIndex:     fallbackIndex,
+		indexPerCategory:  indexPerCategory,
+		categoryRetention: make(map[string]retention),
+		readAlias:         readAlias,
+		baseRetention:     baseRetention,
+	}
+
+	// the aliases of the categories with a retention are rolled over from the start
+	for c, r := range categoryRetention {
+		categoryAlias := es.categoryAlias(c)
+		if err := initCategoryAlias(ctx, categoryAlias, config); err != nil {
+			return nil, err
+		}
+		es.categoryAliases.Store(categoryAlias, true)
+		es.categoryRetention[categoryAlias] = r
+	}
 
 	// Check if alias exists instead of index and create first index if not exists with `${alias}-000001`
 	res, err := util.GetClient7().Aliases().Index("_all").Do(ctx)
@@ -39,6 +94,7 @@ func initPlugin(alias, config string) (*elasticsearch, error) {
 
 	if exists {
 		log.Println(logTag, ": index named", alias, "already exists, skipping ...")
+		es.updateReadAlias(ctx)
 		return es, nil
 	}
 
@@ -101,28 +157,380 @@ func initPlugin(alias, config string) (*elasticsearch, error) {
 	classify.SetIndexAlias(indexName, alias)
 	classify.SetAliasIndex(alias, indexName)
 
-	rolloverConditions := make(map[string]interface{})
+	es.updateReadAlias(ctx)
+	return es, nil
+}
 
-	rolloverConfiguration := fmt.Sprintf(rolloverConfig, "7d", 10000, "1gb")
-	if util.IsProductionPlan() {
-		rolloverConfiguration = fmt.Sprintf(rolloverConfig, "30d", 1000000, "10gb")
+// updateReadAlias points the read alias at all the `${alias}-*` indices, so that it
+// covers the indices created since, e.g. by a rollover. The deleted indices are
+// removed from the alias by elasticsearch.
+func (es *elasticsearch) updateReadAlias(ctx context.Context) {
+	if es.readAlias == "" {
+		return
 	}
-	json.Unmarshal([]byte(rolloverConfiguration), &rolloverConditions)
-	return es, nil
+	indices, err := util.GetClient7().CatIndices().Index(es.indexName + "-*").Do(ctx)
+	if err != nil {
+		log.Errorln(logTag, ": error getting the indices of the read alias", es.readAlias, ":", err)
+		return
+	}
+	if len(indices) == 0 {
+		return
+	}
+	aliasService := util.GetClient7().Alias()
+	for _, row := range indices {
+		aliasService = aliasService.Add(row.Index, es.readAlias)
+	}
+	if _, err := aliasService.Do(ctx); err != nil {
+		log.Errorln(logTag, ": error updating the read alias", es.readAlias, ":", err)
+	}
+}
+
+// initCategoryAlias creates the first index of a category alias if the alias doesn't exist.
+func initCategoryAlias(ctx context.Context, alias, config string) error {
+	res, err := util.GetClient7().Aliases().Index("_all").Do(ctx)
+	if err != nil {
+		return fmt.Errorf("error while checking if alias already exists: %v", err)
+	}
+	if len(res.IndicesByAlias(alias)) > 0 {
+		return nil
+	}
+
+	settings := fmt.Sprintf(config, alias, util.HiddenIndexSettings(), util.GetReplicas(), LogsMappings)
+	if util.GetVersion() == 6 {
+		mappings := fmt.Sprintf(`{"_doc": %s}`, LogsMappings)
+		settings = fmt.Sprintf(config, alias, util.HiddenIndexSettings(), util.GetReplicas(), mappings)
+	}
+	indexName := alias + `-000001`
+	_, err = util.GetClient7().CreateIndex(indexName).
+		Body(settings).
+		Do(ctx)
+	if err != nil {
+		return fmt.Errorf("error while creating index named \"%s\" %v", indexName, err)
+	}
+	log.Println(logTag, ": successfully created index name", indexName)
+
+	classify.SetIndexAlias(indexName, alias)
+	classify.SetAliasIndex(alias, indexName)
+	return nil
+}
+
+// recordAlias returns the alias a record must be indexed into.
+func (es *elasticsearch) recordAlias(ctx context.Context, rec record) string {
+	if !es.indexPerCategory {
+		return es.indexName
+	}
+	alias := es.categoryAlias(rec.Category)
+	if _, ok := es.categoryAliases.Load(alias); ok {
+		return alias
+	}
+	if err := initCategoryAlias(ctx, alias, config); err != nil {
+		log.Errorln(logTag, ": unable to initialize the category alias", alias, ":", err)
+		return es.indexName
+	}
+	es.categoryAliases.Store(alias, true)
+	return alias
+}
+
+// categoryAlias returns the alias of the records of a category, e.g. `.logs-search`.
+func (es *elasticsearch) categoryAlias(c category.Category) string {
+	return es.indexName + "-" + c.String()
+}
+
+// retention returns the retention of an alias.
+func (es *elasticsearch) retention(alias string) retention {
+	defaults := es.baseRetention.withDefaults(defaultRetention())
+	if r, ok := es.categoryRetention[alias]; ok {
+		return r.inherit(defaults)
+	}
+	return defaults
+}
+
+// aliases returns the aliases the records are indexed into.
+func (es *elasticsearch) aliases() []string {
+	aliases := []string{es.indexName}
+	es.categoryAliases.Range(func(alias, _ interface{}) bool {
+		aliases = append(aliases, alias.(string))
+		return true
+	})
+	return aliases
+}
+
+// startBulkProcessor starts the bulk processor the records are indexed through, and
+// the one of the secondary cluster if configured.
+func (es *elasticsearch) startBulkProcessor(ctx context.Context, config bulkProcessorConfig) error {
+	processor, err := util.GetClient7().BulkProcessor().
+		Name("logs").
+		Workers(config.workers).
+		BulkActions(config.bulkActions).
+		FlushInterval(config.flushInterval).
+		After(afterBulk("")).
+		Do(ctx)
+	if err != nil {
+		return fmt.Errorf("error while starting the bulk processor: %v", err)
+	}
+	es.bulkProcessor = processor
+	if es.secondary == nil {
+		return nil
+	}
+	processor, err = es.secondary.BulkProcessor().
+		Name("logs-secondary").
+		Workers(config.workers).
+		BulkActions(config.bulkActions).
+		FlushInterval(config.flushInterval).
+		After(afterBulk("the secondary cluster ")).
+		Do(ctx)
+	if err != nil {
+		return fmt.Errorf("error while starting the bulk processor of the secondary cluster: %v", err)
+	}
+	es.secondaryProcessor = processor
+	return nil
+}
+
+// afterBulk returns a callback that logs the records that the bulk processor failed to
+// index, the cluster is prefixed to the index names.
+func afterBulk(cluster string) func(executionID int64, requests []es7.BulkableRequest, res *es7.BulkResponse, err error) {
+	return func(executionID int64, requests []es7.BulkableRequest, res *es7.BulkResponse, err error) {
+		if err != nil {
+			log.Errorln(logTag, ": error indexing", len(requests), "log records into", cluster+"elasticsearch :", err)
+			return
+		}
+		if res == nil {
+			return
+		}
+		for _, item := range res.Failed() {
+			log.Errorln(logTag, ": error indexing log record into", cluster+item.Index, ":", &es7.Error{Status: item.Status, Details: item.Error})
+		}
+	}
+}
+
+// close flushes the records pending in the bulk processors and stops them.
+func (es *elasticsearch) close() error {
+	if es.secondaryProcessor != nil {
+		if err := es.secondaryProcessor.Close(); err != nil {
+			log.Errorln(logTag, ": error closing the bulk processor of the secondary cluster :", err)
+		}
+	}
+	if es.wal != nil {
+		if err := es.wal.close(); err != nil {
+			log.Errorln(logTag, ": error closing the write-ahead log :", err)
+		}
+	}
+	if es.bulkProcessor == nil {
+		return nil
+	}
+	return es.bulkProcessor.Close()
 }
 
 func (es *elasticsearch) indexRecord(ctx context.Context, rec record) {
+	alias := es.recordAlias(ctx, rec)
+	if rollovers.currentPolicy() != "" {
+		rollovers.wait(alias)
+	}
+	if es.wal == nil {
+		es.indexPrimaryRecord(ctx, alias, rec)
+	} else if seq, err := es.wal.append(rec); err != nil {
+		log.Errorln(logTag, ": error writing log record to the write-ahead log :", err)
+		es.indexPrimaryRecord(ctx, alias, rec)
+	} else if es.indexPrimaryRecord(ctx, alias, rec) == nil {
+		es.wal.ack(seq)
+	}
+	es.indexSecondaryRecord(ctx, alias, rec)
+}
+
+// indexPrimaryRecord indexes the record into the primary cluster, returning an error if
+// it isn't indexed.
+func (es *elasticsearch) indexPrimaryRecord(ctx context.Context, alias string, rec record) error {
+	if es.bulkProcessor != nil {
+		// the processor retries the failed bulk requests, the records aren't
+		// redirected to the fallback index nor stringified on mapping conflicts
+		es.bulkProcessor.Add(bulkIndexRequest(alias, rec.DocumentID, rec))
+		return nil
+	}
+	if es.breaker == nil {
+		return es.indexWithFallback(ctx, alias, rec)
+	}
+	if !es.breaker.allow() {
+		// the record is still written to the log file
+		return errBreakerOpen
+	}
+	if err := es.indexWithFallback(ctx, alias, rec); err != nil {
+		es.breaker.failure()
+		return err
+	}
+	es.breaker.success()
+	return nil
+}
+
+// indexWithFallback indexes the record into the alias, or into the fallback index if
+// the alias is unavailable. The error of the last attempt is returned.
+func (es *elasticsearch) indexWithFallback(ctx context.Context, alias string, rec record) error {
+	if es.indexTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, es.indexTimeout)
+		defer cancel()
+	}
+	err := es.bulkIndexRecord(ctx, util.GetClient7(), alias, rec)
+	if err == nil {
+		return nil
+	}
+	if es.fallbackIndex == "" || !isAliasError(err) {
+		log.Errorln(logTag, ": error indexing log record :", err)
+		return err
+	}
+	fallbackIndex := es.fallbackIndex + "-" + time.Now().UTC().Format("2006.01.02")
+	log.Errorln(logTag, ": alias", alias, "is unavailable, indexing log record into the fallback index",
+		fallbackIndex, ", the alias must be fixed :", err)
+	err = es.bulkIndexRecord(ctx, util.GetClient7(), fallbackIndex, rec)
+	if err != nil {
+		log.Errorln(logTag, ": error indexing log record into the fallback index :", err)
+	}
+	return err
+}
+
+// breakerStatus returns the state of the indexing breaker, nil if it isn't configured.
+func (es *elasticsearch) breakerStatus() *breakerStatus {
+	if es.breaker == nil {
+		return nil
+	}
+	status := es.breaker.status()
+	return &status
+}
+
+// indexSecondaryRecord writes the record to the secondary cluster if configured. Its
+// failures are only logged, the record is already written to the primary cluster.
+func (es *elasticsearch) indexSecondaryRecord(ctx context.Context, alias string, rec record) {
+	if es.secondary == nil {
+		return
+	}
+	if es.secondaryProcessor != nil {
+		es.secondaryProcessor.Add(bulkIndexRequest(alias, rec.DocumentID, rec))
+		return
+	}
+	if err := es.bulkIndexRecord(ctx, es.secondary, alias, rec); err != nil {
+		log.Errorln(logTag, ": error indexing log record into the secondary cluster :", err)
+	}
+}
+
+// bulkIndexRecord indexes the record, stringifying the fields whose type conflicts
+// with the mappings of the index so that the record isn't lost to a schema drift.
+func (es *elasticsearch) bulkIndexRecord(ctx context.Context, client *es7.Client, indexName string, rec record) error {
+	var doc interface{} = rec
+	for conflicts := 0; ; conflicts++ {
+		err := bulkIndexDocument(ctx, client, indexName, rec.DocumentID, doc)
+		field := mappingConflictField(err)
+		if field == "" || conflicts == maxMappingConflicts {
+			return err
+		}
+		if doc, err = stringifyField(doc, field); err != nil {
+			return err
+		}
+		log.Warnln(logTag, ": field", field, "conflicts with the mappings of", indexName, ", indexing it as a string")
+	}
+}
+
+func bulkIndexRequest(indexName, id string, doc interface{}) *es7.BulkIndexRequest {
 	bulkIndex := es7.NewBulkIndexRequest().
-		Index(es.indexName).
+		Index(indexName).
 		Type("_doc").
-		Doc(rec)
+		Doc(doc)
+	// fall back to an auto generated id when the request can't be identified
+	if id != "" {
+		bulkIndex.Id(id)
+	}
+	return bulkIndex
+}
 
-	_, err := util.GetClient7().Bulk().
-		Add(bulkIndex).
+func bulkIndexDocument(ctx context.Context, client *es7.Client, indexName, id string, doc interface{}) error {
+	res, err := client.Bulk().
+		Add(bulkIndexRequest(indexName, id, doc)).
 		Do(ctx)
 	if err != nil {
-		log.Errorln(logTag, ": error indexing log record :", err)
+		return err
+	}
+	for _, item := range res.Failed() {
+		return &es7.Error{Status: item.Status, Details: item.Error}
 	}
+	return nil
+}
+
+// maxMappingConflicts is the maximum number of conflicting fields of a record that are
+// stringified before the record is dropped.
+const maxMappingConflicts = 5
+
+var (
+	parseFieldConflict  = regexp.MustCompile(`failed to parse field \[([^\]]+)\]`)
+	objectFieldConflict = regexp.MustCompile(`object mapping for \[([^\]]+)\]`)
+)
+
+// mappingConflictField returns the path of the field that couldn't be indexed because
+// its type conflicts with the mappings, or an empty string for the other errors.
+func mappingConflictField(err error) string {
+	e, ok := err.(*es7.Error)
+	if !ok || e.Details == nil || e.Details.Type != "mapper_parsing_exception" {
+		return ""
+	}
+	for _, re := range []*regexp.Regexp{parseFieldConflict, objectFieldConflict} {
+		if match := re.FindStringSubmatch(e.Details.Reason); match != nil {
+			return match[1]
+		}
+	}
+	return ""
+}
+
+// stringifyField replaces the value of the dotted field path with its JSON encoding. A
+// value that is already a string, i.e. where an object is expected, is moved under
+// the `_raw` field instead.
+func stringifyField(doc interface{}, field string) (map[string]interface{}, error) {
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+
+	parent := fields
+	path := strings.Split(field, ".")
+	for _, key := range path[:len(path)-1] {
+		child, ok := parent[key].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("conflicting field %s not found in the record", field)
+		}
+		parent = child
+	}
+	key := path[len(path)-1]
+	value, ok := parent[key]
+	if !ok {
+		return nil, fmt.Errorf("conflicting field %s not found in the record", field)
+	}
+	if str, isString := value.(string); isString {
+		delete(parent, key)
+		rawFields, _ := fields["_raw"].(map[string]interface{})
+		if rawFields == nil {
+			rawFields = make(map[string]interface{})
+			fields["_raw"] = rawFields
+		}
+		rawFields[field] = str
+		return fields, nil
+	}
+	stringified, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	parent[key] = string(stringified)
+	return fields, nil
+}
+
+// isAliasError returns true if the record couldn't be indexed because the alias
+// is missing or doesn't have a write index.
+func isAliasError(err error) bool {
+	e, ok := err.(*es7.Error)
+	if !ok || e.Details == nil {
+		return false
+	}
+	return e.Details.Type == "index_not_found_exception" ||
+		(e.Details.Type == "illegal_argument_exception" && strings.Contains(e.Details.Reason, "alias"))
 }
 
 type logsFilter struct {
@@ -131,6 +539,7 @@ type logsFilter struct {
 	EndDate        string
 	StartLatency   *int
 	EndLatency     *int
+	MinShards      *int
 	OrderByLatency string
 	Size           int
 	Filter         string
@@ -148,12 +557,7 @@ func (es *elasticsearch) getRawLogs(ctx context.Context, logsFilter logsFilter)
 
 func (es *elasticsearch) rolloverIndexJob(alias string) {
 	ctx := context.Background()
-	rolloverConditions := make(map[string]interface{})
-	rolloverConfiguration := fmt.Sprintf(rolloverConfig, "7d", 10000, "1gb")
-	if util.IsProductionPlan() {
-		rolloverConfiguration = fmt.Sprintf(rolloverConfig, "30d", 1000000, "10gb")
-	}
-	json.Unmarshal([]byte(rolloverConfiguration), &rolloverConditions)
+	aliasRetention := es.retention(alias)
 	settingsString := fmt.Sprintf(`{%s "index.number_of_shards": 1, "index.number_of_replicas": %d}`, util.HiddenIndexSettings(), util.GetReplicas())
 	settings := make(map[string]interface{})
 	json.Unmarshal([]byte(settingsString), &settings)
@@ -165,14 +569,18 @@ func (es *elasticsearch) rolloverIndexJob(alias string) {
 
 	mappings := make(map[string]interface{})
 	json.Unmarshal([]byte(mappingString), &mappings)
+	// the writes to the alias are held off while its write index is swapped
+	rollovers.begin(alias)
 	rolloverService, err := es7.NewIndicesRolloverService(util.GetClient7()).
 		Alias(alias).
-		Conditions(rolloverConditions).
+		Conditions(aliasRetention.conditions()).
 		Settings(settings).
 		Mappings(mappings).
 		Do(ctx)
+	rollovers.end(alias)
 	if err != nil {
 		log.Println(logTag, "error while creating a rollover service", alias, err)
+		return
 	}
 	log.Println(logTag, ": rollover res oldIndex", rolloverService.OldIndex)
 	log.Println(logTag, ": rollover res newIndex", rolloverService.NewIndex)
@@ -185,38 +593,31 @@ func (es *elasticsearch) rolloverIndexJob(alias string) {
 
 	// We cannot rely on rollover service response here,
 	// Because it returns rollover as false when we restart ReactiveSearch.
-	// To preserve the last 2 index and delete others:
-	// -> cat all the indices with .logs-*
-	// -> if count is > 2
-	//   -> sort them based on -[Number]
-	//   -> preserve last 2 and delete all
-	// -> else do not delete any index
+	// To preserve the latest indices of the alias as per its retention:
+	// -> cat all the indices with ${alias}-*
+	// -> keep the ones of the alias, i.e. ${alias}-[Number]
+	// -> sort them based on -[Number]
+	// -> preserve the latest and delete the rest
 
 	// cat all the indices starting with `${alias}-Number` pattern
 	indices, err := util.GetClient7().CatIndices().Index(alias + "-*").
 		Do(ctx)
 	if err != nil {
 		log.Errorln(logTag, ": rollover cronjob error getting indices", err)
+		return
 	}
 
-	if len(indices) > 2 {
-		rolloverIndices := []string{}
-		r, _ := regexp.Compile(fmt.Sprintf("%s-[0-9]+", alias))
-		for _, catResRow := range indices {
-			if r.MatchString(catResRow.Index) {
-				rolloverIndices = append(rolloverIndices, catResRow.Index)
-			}
-		}
-
-		sort.Strings(rolloverIndices)
-
-		// ignore last 2 indices
-		rolloverIndices = rolloverIndices[:len(rolloverIndices)-2]
-
+	var indexNames []string
+	for _, catResRow := range indices {
+		indexNames = append(indexNames, catResRow.Index)
+	}
+	rolloverIndices := staleIndices(alias, indexNames, aliasRetention.Retain)
+	if len(rolloverIndices) > 0 {
 		log.Println(logTag, ": rollover cronjob, indices to delete", rolloverIndices)
 		_, err = util.GetClient7().DeleteIndex(strings.Join(rolloverIndices, ",")).Do(ctx)
 		if err != nil {
 			log.Errorln(logTag, ": rollover cronjob, error while deleting indices", err)
 		}
 	}
+	es.updateReadAlias(ctx)
 }
@@ -47,6 +47,9 @@ func (es *elasticsearch) getRawLogsES6(ctx context.Context, logsFilter logsFilte
 		}
 		query.Filter(latencyRangeQuery)
 	}
+	if logsFilter.MinShards != nil {
+		query.Filter(es6.NewRangeQuery("response.shards_touched").Gte(*logsFilter.MinShards))
+	}
 
 	searchQuery := util.GetClient6().Search(es.indexName).
 		Query(query).
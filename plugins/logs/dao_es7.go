@@ -46,6 +46,9 @@ func (es *elasticsearch) getRawLogsES7(ctx context.Context, logsFilter logsFilte
 		}
 		query.Filter(latencyRangeQuery)
 	}
+	if logsFilter.MinShards != nil {
+		query.Filter(es7.NewRangeQuery("response.shards_touched").Gte(*logsFilter.MinShards))
+	}
 
 	searchQuery := util.GetClient7().Search(es.indexName).
 		Query(query).
@@ -0,0 +1,525 @@
+package logs
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/appbaseio/reactivesearch-api/model/category"
+	"github.com/appbaseio/reactivesearch-api/util"
+	es7 "github.com/olivere/elastic/v7"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// fakeES is an elasticsearch stub that records the indices the documents are
+// indexed into. Indexing into brokenAlias fails as if it doesn't have a write index,
+// and the documents with a non-string conflictField are rejected as mapping conflicts.
+type fakeES struct {
+	mu            sync.Mutex
+	brokenAlias   string
+	conflictField string
+	created       []string
+	indexed       []string
+	docs          []map[string]interface{}
+	// existing are the indices listed by the cat api, deleted the ones deleted since
+	existing  []string
+	deleted   []string
+	rollovers map[string]map[string]interface{}
+	// aliased holds the indices added to each alias by the alias actions
+	aliased map[string][]string
+}
+
+func (f *fakeES) reset(brokenAlias string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.brokenAlias = brokenAlias
+	f.conflictField = ""
+	f.created = nil
+	f.indexed = nil
+	f.docs = nil
+	f.existing = nil
+	f.deleted = nil
+	f.rollovers = make(map[string]map[string]interface{})
+	f.aliased = make(map[string][]string)
+}
+
+func (f *fakeES) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/_bulk"):
+		f.bulk(w, r)
+	case strings.HasSuffix(r.URL.Path, "/_rollover"):
+		var body struct {
+			Conditions map[string]interface{} `json:"conditions"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		alias := strings.Trim(strings.TrimSuffix(r.URL.Path, "/_rollover"), "/")
+		f.rollovers[alias] = body.Conditions
+		fmt.Fprintf(w, `{"acknowledged":true,"old_index":"%s-000001","rolled_over":false}`, alias)
+	case strings.HasPrefix(r.URL.Path, "/_cat/indices/"):
+		prefix := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/_cat/indices/"), "*")
+		var rows []string
+		for _, index := range f.existing {
+			if strings.HasPrefix(index, prefix) {
+				rows = append(rows, fmt.Sprintf(`{"index":%q}`, index))
+			}
+		}
+		fmt.Fprintf(w, `[%s]`, strings.Join(rows, ","))
+	case r.Method == http.MethodDelete:
+		deleted := strings.Split(strings.Trim(r.URL.Path, "/"), ",")
+		f.deleted = append(f.deleted, deleted...)
+		var existing []string
+		for _, index := range f.existing {
+			if !util.Contains(deleted, index) {
+				existing = append(existing, index)
+			}
+		}
+		f.existing = existing
+		fmt.Fprint(w, `{"acknowledged":true}`)
+	case r.Method == http.MethodPost && r.URL.Path == "/_aliases":
+		var body struct {
+			Actions []map[string]struct {
+				Index string `json:"index"`
+				Alias string `json:"alias"`
+			} `json:"actions"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		for _, action := range body.Actions {
+			if add, ok := action["add"]; ok && !util.Contains(f.aliased[add.Alias], add.Index) {
+				f.aliased[add.Alias] = append(f.aliased[add.Alias], add.Index)
+			}
+		}
+		fmt.Fprint(w, `{"acknowledged":true}`)
+	case strings.Contains(r.URL.Path, "/_alias"):
+		fmt.Fprint(w, `{}`)
+	case r.Method == http.MethodPut:
+		index := strings.Trim(r.URL.Path, "/")
+		f.created = append(f.created, index)
+		fmt.Fprintf(w, `{"acknowledged":true,"index":%q}`, index)
+	default:
+		fmt.Fprint(w, `{"version":{"number":"7.10.0"}}`)
+	}
+}
+
+func (f *fakeES) bulk(w http.ResponseWriter, r *http.Request) {
+	var items []string
+	errors := false
+	scanner := bufio.NewScanner(r.Body)
+	for scanner.Scan() {
+		var action map[string]struct {
+			Index string `json:"_index"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &action); err != nil {
+			continue
+		}
+		meta, ok := action["index"]
+		if !ok {
+			continue
+		}
+		scanner.Scan()
+		var doc map[string]interface{}
+		json.Unmarshal(scanner.Bytes(), &doc)
+		if _, isString := doc[f.conflictField].(string); f.conflictField != "" && doc[f.conflictField] != nil && !isString {
+			errors = true
+			items = append(items, fmt.Sprintf(`{"index":{"_index":%q,"status":400,"error":{"type":"mapper_parsing_exception","reason":"failed to parse field [%s] of type [keyword] in document with id '1'"}}}`, meta.Index, f.conflictField))
+			continue
+		}
+		if meta.Index == f.brokenAlias {
+			errors = true
+			items = append(items, fmt.Sprintf(`{"index":{"_index":%q,"status":400,"error":{"type":"illegal_argument_exception","reason":"no write index is defined for alias [%s]"}}}`, meta.Index, meta.Index))
+			continue
+		}
+		f.indexed = append(f.indexed, meta.Index)
+		f.docs = append(f.docs, doc)
+		items = append(items, fmt.Sprintf(`{"index":{"_index":%q,"status":201,"result":"created"}}`, meta.Index))
+	}
+	fmt.Fprintf(w, `{"took":1,"errors":%t,"items":[%s]}`, errors, strings.Join(items, ","))
+}
+
+var (
+	testES     = &fakeES{}
+	testESOnce sync.Once
+)
+
+// useTestES points the es client to the elasticsearch stub. The client is
+// initialized only once, so the stub is shared by all the tests.
+func useTestES(t *testing.T, brokenAlias string) *fakeES {
+	testESOnce.Do(func() {
+		ts := httptest.NewServer(testES)
+		os.Setenv("ES_CLUSTER_URL", ts.URL)
+		if util.GetClient7() == nil {
+			t.Fatal("unable to initialize the es client")
+		}
+	})
+	testES.reset(brokenAlias)
+	return testES
+}
+
+func TestIndexRecordFallback(t *testing.T) {
+	Convey("Index a record when the alias is unavailable", t, func() {
+		server := useTestES(t, ".logs")
+		rec := record{Indices: []string{"books"}, Timestamp: time.Now()}
+
+		Convey("Indexes the record into the date stamped fallback index", func() {
+			es := &elasticsearch{indexName: ".logs", fallbackIndex: ".logs-fallback"}
+			es.indexRecord(context.Background(), rec)
+			So(server.indexed, ShouldResemble, []string{".logs-fallback-" + time.Now().UTC().Format("2006.01.02")})
+		})
+		Convey("Drops the record without a fallback index", func() {
+			es := &elasticsearch{indexName: ".logs"}
+			es.indexRecord(context.Background(), rec)
+			So(server.indexed, ShouldBeEmpty)
+		})
+		Convey("The recorded requests are indexed into the fallback index", func() {
+			l := newTestLogs(t)
+			l.es = &elasticsearch{indexName: ".logs", fallbackIndex: ".logs-fallback"}
+			recordTestResponse(t, l, newTestRequest("POST", "/books/_search", `{}`), http.StatusOK, `{"took":1}`)
+			So(server.indexed, ShouldResemble, []string{".logs-fallback-" + time.Now().UTC().Format("2006.01.02")})
+		})
+	})
+}
+
+func TestSecondaryCluster(t *testing.T) {
+	Convey("Write the records to the secondary cluster", t, func() {
+		server := useTestES(t, "")
+		secondary := &fakeES{}
+		secondary.reset("")
+		ts := httptest.NewServer(secondary)
+		defer ts.Close()
+		client, err := util.NewClient7(ts.URL, es7.SetHealthcheck(false), es7.SetSniff(false))
+		So(err, ShouldBeNil)
+		es := &elasticsearch{indexName: ".logs", secondary: client}
+		rec := record{Indices: []string{"books"}, Timestamp: time.Now()}
+
+		Convey("A record is written to both clusters", func() {
+			es.indexRecord(context.Background(), rec)
+			So(server.indexed, ShouldResemble, []string{".logs"})
+			So(secondary.indexed, ShouldResemble, []string{".logs"})
+		})
+		Convey("A secondary failure doesn't fail the primary write", func() {
+			secondary.reset(".logs")
+			es.indexRecord(context.Background(), rec)
+			So(server.indexed, ShouldResemble, []string{".logs"})
+			So(secondary.indexed, ShouldBeEmpty)
+		})
+		Convey("A recorded request is written to both clusters", func() {
+			l := newTestLogs(t)
+			l.es = es
+			recordTestResponse(t, l, newTestRequest("POST", "/books/_search", `{}`), http.StatusOK, `{"took":1}`)
+			So(server.indexed, ShouldResemble, []string{".logs"})
+			So(secondary.indexed, ShouldResemble, []string{".logs"})
+		})
+		Convey("An unavailable secondary doesn't fail the primary write", func() {
+			ts.Close()
+			es.indexRecord(context.Background(), rec)
+			So(server.indexed, ShouldResemble, []string{".logs"})
+		})
+	})
+}
+
+func TestIndexPerCategory(t *testing.T) {
+	Convey("Index the records into the category aliases", t, func() {
+		server := useTestES(t, "")
+		search := record{Category: category.Search, Timestamp: time.Now()}
+		docs := record{Category: category.Docs, Timestamp: time.Now()}
+
+		Convey("Records land in the alias of their category", func() {
+			es := &elasticsearch{indexName: ".logs", indexPerCategory: true}
+			es.indexRecord(context.Background(), search)
+			es.indexRecord(context.Background(), docs)
+			es.indexRecord(context.Background(), search)
+			So(server.indexed, ShouldResemble, []string{".logs-search", ".logs-docs", ".logs-search"})
+			// the first index of each category alias is created once
+			So(server.created, ShouldResemble, []string{".logs-search-000001", ".logs-docs-000001"})
+			So(es.aliases(), ShouldContain, ".logs-search")
+			So(es.aliases(), ShouldContain, ".logs-docs")
+		})
+		Convey("Records land in the default alias", func() {
+			es := &elasticsearch{indexName: ".logs"}
+			es.indexRecord(context.Background(), search)
+			es.indexRecord(context.Background(), docs)
+			So(server.indexed, ShouldResemble, []string{".logs", ".logs"})
+			So(server.created, ShouldBeEmpty)
+		})
+	})
+}
+
+func TestIndexRecordMappingConflict(t *testing.T) {
+	Convey("Index a record whose field conflicts with the mappings", t, func() {
+		server := useTestES(t, "")
+		server.conflictField = "tags"
+		es := &elasticsearch{indexName: ".logs"}
+
+		Convey("Retries the record with the conflicting field stringified", func() {
+			es.indexRecord(context.Background(), record{Tags: map[string]string{"env": "prod"}, Timestamp: time.Now()})
+			So(server.indexed, ShouldResemble, []string{".logs"})
+			So(server.docs[0]["tags"], ShouldEqual, `{"env":"prod"}`)
+		})
+		Convey("Indexes the records without conflicts as they are", func() {
+			es.indexRecord(context.Background(), record{Indices: []string{"books"}, Timestamp: time.Now()})
+			So(server.indexed, ShouldResemble, []string{".logs"})
+			So(server.docs[0]["indices"], ShouldResemble, []interface{}{"books"})
+		})
+		Convey("Retries the recorded requests with the conflicting field stringified", func() {
+			server.conflictField = "indices"
+			l := newTestLogs(t)
+			l.es = es
+			recordTestResponse(t, l, newTestRequest("POST", "/books/_search", `{}`), http.StatusOK, `{"took":1}`)
+			So(server.indexed, ShouldResemble, []string{".logs"})
+			So(server.docs[0]["indices"], ShouldEqual, `["books"]`)
+		})
+	})
+}
+
+func TestStringifyField(t *testing.T) {
+	Convey("Stringify a conflicting field", t, func() {
+		rec := record{Request: Request{URI: "/books/_search", Headers: map[string][]string{"Accept": {"*/*"}}}}
+
+		Convey("Nested values are replaced with their JSON encoding", func() {
+			doc, err := stringifyField(rec, "request.header")
+			So(err, ShouldBeNil)
+			So(doc["request"].(map[string]interface{})["header"], ShouldEqual, `{"Accept":["*/*"]}`)
+		})
+		Convey("String values are moved under the raw field", func() {
+			doc, err := stringifyField(rec, "request.uri")
+			So(err, ShouldBeNil)
+			So(doc["request"].(map[string]interface{}), ShouldNotContainKey, "uri")
+			So(doc["_raw"], ShouldResemble, map[string]interface{}{"request.uri": "/books/_search"})
+		})
+		Convey("Missing fields are an error", func() {
+			_, err := stringifyField(rec, "response.missing")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestCategoryRetention(t *testing.T) {
+	Convey("Roll over the category aliases as per their retention", t, func() {
+		plan := util.Sandbox
+		util.SetTier(&plan)
+		server := useTestES(t, "")
+		for i := 1; i <= 5; i++ {
+			server.existing = append(server.existing,
+				fmt.Sprintf(".logs-%06d", i),
+				fmt.Sprintf(".logs-search-%06d", i),
+				fmt.Sprintf(".logs-docs-%06d", i))
+		}
+		categoryRetention, err := parseCategoryRetention(`{"search":{"max_age":"1d","retain":2},"docs":{"max_docs":100,"retain":4}}`)
+		So(err, ShouldBeNil)
+		es, err := initPlugin(".logs", "", "", config, true, categoryRetention, retention{})
+		So(err, ShouldBeNil)
+		So(es.aliases(), ShouldContain, ".logs-search")
+		So(es.aliases(), ShouldContain, ".logs-docs")
+
+		Convey("Each category keeps its configured number of indices", func() {
+			es.rolloverIndexJob(".logs-search")
+			So(server.deleted, ShouldResemble, []string{".logs-search-000001", ".logs-search-000002", ".logs-search-000003"})
+			So(server.rollovers[".logs-search"], ShouldResemble, map[string]interface{}{"max_age": "1d"})
+
+			server.deleted = nil
+			es.rolloverIndexJob(".logs-docs")
+			So(server.deleted, ShouldResemble, []string{".logs-docs-000001"})
+			So(server.rollovers[".logs-docs"], ShouldResemble, map[string]interface{}{"max_docs": float64(100)})
+		})
+		Convey("The default alias keeps the default number of indices", func() {
+			es.rolloverIndexJob(".logs")
+			So(server.deleted, ShouldResemble, []string{".logs-000001", ".logs-000002", ".logs-000003"})
+		})
+	})
+}
+
+func TestParseCategoryRetention(t *testing.T) {
+	Convey("Parse the category retention", t, func() {
+		plan := util.Sandbox
+		util.SetTier(&plan)
+		categoryRetention, err := parseCategoryRetention(`{"search":{"retain":7}}`)
+		So(err, ShouldBeNil)
+		// the unset rollover conditions are inherited from the default retention
+		r := categoryRetention[category.Search].inherit(defaultRetention())
+		So(r.Retain, ShouldEqual, 7)
+		So(r.MaxAge, ShouldEqual, defaultRetention().MaxAge)
+
+		_, err = parseCategoryRetention(`{"unknown":{"retain":7}}`)
+		So(err, ShouldNotBeNil)
+		_, err = parseCategoryRetention(`{"search":{"retain":-1}}`)
+		So(err, ShouldNotBeNil)
+		for _, value := range []string{`{"search":{"max_age":"1 day"}}`, `{"search":{"max_size":"10"}}`, `{"search":{"max_docs":-1}}`} {
+			_, err = parseCategoryRetention(value)
+			So(err, ShouldNotBeNil)
+		}
+	})
+}
+
+func TestRolloverConditionsFromEnv(t *testing.T) {
+	Convey("Roll over the logs alias with the conditions set in the environment", t, func() {
+		plan := util.Sandbox
+		util.SetTier(&plan)
+		server := useTestES(t, "")
+		os.Setenv(envRolloverMaxAge, "1d")
+		os.Setenv(envRolloverMaxDocs, "500")
+		defer os.Unsetenv(envRolloverMaxAge)
+		defer os.Unsetenv(envRolloverMaxDocs)
+
+		baseRetention, err := retentionFromEnv()
+		So(err, ShouldBeNil)
+		es, err := initPlugin(".logs", "", "", config, false, nil, baseRetention)
+		So(err, ShouldBeNil)
+		es.rolloverIndexJob(".logs")
+		// the unset max size keeps the default of the plan
+		So(server.rollovers[".logs"], ShouldResemble, map[string]interface{}{
+			"max_age":  "1d",
+			"max_docs": float64(500),
+			"max_size": "1gb",
+		})
+
+		Convey("The unset conditions follow the plan after the startup", func() {
+			plan := util.ProductionFirst2021
+			util.SetTier(&plan)
+			es.rolloverIndexJob(".logs")
+			So(server.rollovers[".logs"], ShouldResemble, map[string]interface{}{
+				"max_age":  "1d",
+				"max_docs": float64(500),
+				"max_size": "10gb",
+			})
+		})
+
+		Convey("The invalid conditions are rejected", func() {
+			os.Setenv(envRolloverMaxSize, "large")
+			defer os.Unsetenv(envRolloverMaxSize)
+			_, err := retentionFromEnv()
+			So(err, ShouldNotBeNil)
+
+			os.Unsetenv(envRolloverMaxSize)
+			os.Setenv(envRolloverMaxDocs, "0")
+			_, err = retentionFromEnv()
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestRetainIndicesFromEnv(t *testing.T) {
+	Convey("Keep the number of indices set in the environment", t, func() {
+		plan := util.Sandbox
+		util.SetTier(&plan)
+		server := useTestES(t, "")
+		for i := 1; i <= 5; i++ {
+			server.existing = append(server.existing, fmt.Sprintf(".logs-%06d", i))
+		}
+		os.Setenv(envRetainIndices, "3")
+		defer os.Unsetenv(envRetainIndices)
+
+		baseRetention, err := retentionFromEnv()
+		So(err, ShouldBeNil)
+		So(baseRetention.Retain, ShouldEqual, 3)
+		es, err := initPlugin(".logs", "", "", config, false, nil, baseRetention)
+		So(err, ShouldBeNil)
+		es.rolloverIndexJob(".logs")
+		So(server.deleted, ShouldResemble, []string{".logs-000001", ".logs-000002"})
+
+		Convey("Nothing is deleted while there are fewer indices than retained", func() {
+			So(staleIndices(".logs", []string{".logs-000001", ".logs-000002"}, 3), ShouldBeEmpty)
+		})
+		Convey("Retaining no index is rejected", func() {
+			os.Setenv(envRetainIndices, "0")
+			_, err := retentionFromEnv()
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestBulkProcessor(t *testing.T) {
+	Convey("Index the records through the bulk processor", t, func() {
+		server := useTestES(t, "")
+		es := &elasticsearch{indexName: ".logs"}
+		err := es.startBulkProcessor(context.Background(), bulkProcessorConfig{workers: 1, bulkActions: 100, flushInterval: time.Hour})
+		So(err, ShouldBeNil)
+
+		for i := 0; i < 3; i++ {
+			es.indexRecord(context.Background(), record{Indices: []string{"books"}, Timestamp: time.Now()})
+		}
+		// the records are pending until the processor flushes
+		server.mu.Lock()
+		So(server.indexed, ShouldBeEmpty)
+		server.mu.Unlock()
+
+		So(es.close(), ShouldBeNil)
+		server.mu.Lock()
+		defer server.mu.Unlock()
+		So(server.indexed, ShouldResemble, []string{".logs", ".logs", ".logs"})
+	})
+
+	Convey("Flush once the bulk actions are reached", t, func() {
+		server := useTestES(t, "")
+		es := &elasticsearch{indexName: ".logs"}
+		err := es.startBulkProcessor(context.Background(), bulkProcessorConfig{workers: 1, bulkActions: 2, flushInterval: time.Hour})
+		So(err, ShouldBeNil)
+		defer es.close()
+
+		es.indexRecord(context.Background(), record{Timestamp: time.Now()})
+		es.indexRecord(context.Background(), record{Timestamp: time.Now()})
+		So(func() int {
+			for i := 0; i < 100; i++ {
+				server.mu.Lock()
+				n := len(server.indexed)
+				server.mu.Unlock()
+				if n == 2 {
+					return n
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			return 0
+		}(), ShouldEqual, 2)
+	})
+
+	Convey("Index the recorded requests through the bulk processor", t, func() {
+		server := useTestES(t, "")
+		es := &elasticsearch{indexName: ".logs"}
+		err := es.startBulkProcessor(context.Background(), bulkProcessorConfig{workers: 1, bulkActions: 100, flushInterval: time.Hour})
+		So(err, ShouldBeNil)
+		l := newTestLogs(t)
+		l.es = es
+
+		recordTestResponse(t, l, newTestRequest("POST", "/books/_search", `{}`), http.StatusOK, `{"took":1}`)
+		So(es.close(), ShouldBeNil)
+		server.mu.Lock()
+		defer server.mu.Unlock()
+		So(server.indexed, ShouldResemble, []string{".logs"})
+		So(server.docs[0]["indices"], ShouldResemble, []interface{}{"books"})
+	})
+}
+
+func TestReadAlias(t *testing.T) {
+	Convey("Maintain the read alias over the logs indices", t, func() {
+		server := useTestES(t, "")
+		server.existing = []string{".logs-000001", ".logs-000002", ".logs-search-000001"}
+
+		Convey("The read alias covers the existing indices", func() {
+			_, err := initPlugin(".logs", "", "logs-read", config, false, nil, retention{})
+			So(err, ShouldBeNil)
+			So(server.aliased["logs-read"], ShouldResemble, []string{".logs-000001", ".logs-000002", ".logs-search-000001"})
+		})
+		Convey("The read alias covers the index created by a rollover", func() {
+			es, err := initPlugin(".logs", "", "logs-read", config, false, nil, retention{})
+			So(err, ShouldBeNil)
+			server.existing = append(server.existing, ".logs-000003")
+			es.rolloverIndexJob(".logs")
+			So(server.aliased["logs-read"], ShouldContain, ".logs-000003")
+		})
+		Convey("The read alias isn't maintained unless it's configured", func() {
+			es, err := initPlugin(".logs", "", "", config, false, nil, retention{})
+			So(err, ShouldBeNil)
+			es.rolloverIndexJob(".logs")
+			So(server.aliased, ShouldBeEmpty)
+		})
+	})
+}
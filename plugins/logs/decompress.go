@@ -0,0 +1,89 @@
+package logs
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"strings"
+
+	"github.com/appbaseio/reactivesearch-api/util"
+)
+
+const (
+	// defaultMaxCompressionRatio is the decompressed to compressed size ratio above
+	// which a body is considered a possible decompression bomb.
+	defaultMaxCompressionRatio = 100
+	// decompressionChunkSize is the size of the chunks a body is decompressed in, the
+	// compression ratio is checked after each of them.
+	decompressionChunkSize = 32 * 1024
+)
+
+// Flags mark the records of suspicious requests.
+type Flags struct {
+	// PossibleZipBomb is set if a body expanded beyond the maximum compression ratio
+	PossibleZipBomb bool `json:"possible_zip_bomb,omitempty"`
+}
+
+// decompressed is a decompressed request or response body.
+type decompressed struct {
+	body []byte
+	// ratio of the decompressed to the compressed size, a lower bound if the
+	// decompression was aborted
+	ratio float64
+	// possibleBomb is set if the decompression was aborted for exceeding the
+	// maximum compression ratio
+	possibleBomb bool
+}
+
+// decompressBody decompresses a gzip or deflate encoded body up to limit bytes. The
+// decompression is aborted as soon as the body expands beyond maxRatio times its
+// compressed size. It returns nil for the bodies that aren't compressed.
+func decompressBody(body []byte, encoding string, limit int, maxRatio float64) (*decompressed, error) {
+	if len(body) == 0 {
+		return nil, nil
+	}
+	var reader io.Reader
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "gzip", "x-gzip":
+		gz, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		reader = gz
+	case "deflate":
+		zr, err := zlib.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		reader = zr
+	default:
+		return nil, nil
+	}
+
+	maxSize := maxRatio * float64(len(body))
+	result := &decompressed{}
+	var out bytes.Buffer
+	chunk := make([]byte, decompressionChunkSize)
+	for out.Len() < limit {
+		n, err := reader.Read(chunk[:util.Min(len(chunk), limit-out.Len())])
+		out.Write(chunk[:n])
+		if float64(out.Len()) > maxSize {
+			result.possibleBomb = true
+			break
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	result.ratio = float64(out.Len()) / float64(len(body))
+	if !result.possibleBomb {
+		result.body = out.Bytes()
+	}
+	return result, nil
+}
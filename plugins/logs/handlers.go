@@ -1,6 +1,7 @@
 package logs
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -136,6 +137,17 @@ func (l *Logs) logsHandler(w http.ResponseWriter, req *http.Request, isSearchLog
 			}
 			logsFilterConfig.EndLatency = &endLatencyAsInt
 		}
+		minShards := req.URL.Query().Get("min_shards")
+		if minShards != "" {
+			minShardsAsInt, err := strconv.Atoi(minShards)
+			if err != nil {
+				errMsg := fmt.Errorf(`invalid value "%v" for query param "min_shards"`, minShards)
+				log.Errorln(logTag, ": ", errMsg)
+				util.WriteBackError(w, errMsg.Error(), http.StatusBadRequest)
+				return
+			}
+			logsFilterConfig.MinShards = &minShardsAsInt
+		}
 		orderBy := req.URL.Query().Get("order_by_latency")
 		if orderBy != "" {
 			if !(orderBy == "asc" || orderBy == "desc") {
@@ -174,3 +186,47 @@ func (l *Logs) getSearchLogs() http.HandlerFunc {
 		l.logsHandler(w, req, true)
 	}
 }
+
+// getBreaker returns the state of the breaker around the indexing of the log records.
+func (l *Logs) getBreaker() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		status := l.es.breakerStatus()
+		if status == nil {
+			util.WriteBackError(w, "indexing breaker isn't configured, see "+envBreakerThreshold, http.StatusNotFound)
+			return
+		}
+		raw, err := json.Marshal(status)
+		if err != nil {
+			util.WriteBackError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		util.WriteBackRaw(w, raw, http.StatusOK)
+	}
+}
+
+// droppedRecords are the numbers of the records dropped since the startup because a
+// buffer was full.
+type droppedRecords struct {
+	Buffer uint64 `json:"buffer"`
+	Kafka  uint64 `json:"kafka"`
+}
+
+// getDroppedRecords returns the numbers of the records dropped by the record buffer and
+// the kafka sink.
+func (l *Logs) getDroppedRecords() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		var dropped droppedRecords
+		if l.buffer != nil {
+			dropped.Buffer = l.buffer.droppedRecords()
+		}
+		if l.kafka != nil {
+			dropped.Kafka = l.kafka.droppedRecords()
+		}
+		raw, err := json.Marshal(dropped)
+		if err != nil {
+			util.WriteBackError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		util.WriteBackRaw(w, raw, http.StatusOK)
+	}
+}
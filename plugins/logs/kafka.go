@@ -0,0 +1,164 @@
+package logs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/appbaseio/reactivesearch-api/util"
+)
+
+const (
+	defaultKafkaBufferSize    = 10000
+	defaultKafkaBatchSize     = 500
+	defaultKafkaFlushInterval = time.Second
+)
+
+// kafkaProducer produces a batch of messages to a kafka topic.
+type kafkaProducer interface {
+	produce(topic string, messages [][]byte) error
+}
+
+// kafkaRESTProducer produces the messages through the Confluent REST proxy.
+type kafkaRESTProducer struct {
+	url string
+}
+
+func (p *kafkaRESTProducer) produce(topic string, messages [][]byte) error {
+	records := make([]map[string]json.RawMessage, len(messages))
+	for i, message := range messages {
+		records[i] = map[string]json.RawMessage{"value": message}
+	}
+	body, err := json.Marshal(map[string]interface{}{"records": records})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(p.url, "/")+"/topics/"+topic, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.json.v2+json")
+	res, err := util.HTTPClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 300 {
+		msg, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("kafka rest proxy responded with %d: %s", res.StatusCode, msg)
+	}
+	return nil
+}
+
+// kafkaSink produces the records to a kafka topic in batches. Records are buffered
+// while the brokers are unavailable, once the buffer is full they are dropped.
+type kafkaSink struct {
+	producer      kafkaProducer
+	topic         string
+	batchSize     int
+	flushInterval time.Duration
+	records       chan []byte
+	dropped       uint64
+	closed        chan struct{}
+	wg            sync.WaitGroup
+}
+
+func newKafkaSink(producer kafkaProducer, topic string, bufferSize, batchSize int, flushInterval time.Duration) *kafkaSink {
+	s := &kafkaSink{
+		producer:      producer,
+		topic:         topic,
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		records:       make(chan []byte, bufferSize),
+		closed:        make(chan struct{}),
+	}
+	s.wg.Add(1)
+	go s.run()
+	return s
+}
+
+// write queues the record without blocking the request.
+func (s *kafkaSink) write(rec record) {
+	message, err := json.Marshal(rec)
+	if err != nil {
+		log.Errorln(logTag, "error encountered while marshalling record :", err)
+		return
+	}
+	select {
+	case s.records <- message:
+	default:
+		if dropped := atomic.AddUint64(&s.dropped, 1); dropped%1000 == 1 {
+			log.Errorln(logTag, ": kafka buffer is full, records dropped so far:", dropped)
+		}
+	}
+}
+
+// droppedRecords returns the number of records dropped because the buffer was full.
+func (s *kafkaSink) droppedRecords() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// close flushes the buffered records and stops the sink.
+func (s *kafkaSink) close() {
+	close(s.closed)
+	s.wg.Wait()
+}
+
+func (s *kafkaSink) run() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+
+	var batch [][]byte
+	for {
+		// a full batch that failed to be produced is retried on the next tick,
+		// the new records wait in the bounded buffer till then
+		records := s.records
+		if len(batch) >= s.batchSize {
+			records = nil
+		}
+		select {
+		case message := <-records:
+			batch = append(batch, message)
+			if len(batch) >= s.batchSize {
+				batch = s.flush(batch)
+			}
+		case <-ticker.C:
+			batch = s.flush(batch)
+		case <-s.closed:
+			for {
+				select {
+				case message := <-s.records:
+					batch = append(batch, message)
+					if len(batch) >= s.batchSize {
+						batch = s.flush(batch)
+					}
+				default:
+					s.flush(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+// flush produces the batch and returns the records that are still pending. A failed
+// batch is retried on the next flush, meanwhile the new records wait in the buffer.
+func (s *kafkaSink) flush(batch [][]byte) [][]byte {
+	if len(batch) == 0 {
+		return batch
+	}
+	if err := s.producer.produce(s.topic, batch); err != nil {
+		log.Errorln(logTag, ": unable to produce", len(batch), "records to kafka :", err)
+		return batch
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package logs
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// mockProducer records the produced messages, failing while unavailable is set.
+type mockProducer struct {
+	mu          sync.Mutex
+	unavailable bool
+	messages    map[string][][]byte
+	batches     int
+}
+
+func newMockProducer() *mockProducer {
+	return &mockProducer{messages: make(map[string][][]byte)}
+}
+
+func (p *mockProducer) produce(topic string, messages [][]byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.unavailable {
+		return errors.New("brokers unavailable")
+	}
+	p.messages[topic] = append(p.messages[topic], messages...)
+	p.batches++
+	return nil
+}
+
+func (p *mockProducer) setUnavailable(unavailable bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.unavailable = unavailable
+}
+
+func (p *mockProducer) produced(topic string) [][]byte {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.messages[topic]
+}
+
+func TestKafkaSink(t *testing.T) {
+	Convey("Kafka sink", t, func() {
+		producer := newMockProducer()
+
+		Convey("Records are produced to the topic in batches", func() {
+			l := newTestLogs(t)
+			l.kafka = newKafkaSink(producer, "arc-logs", 100, 2, time.Hour)
+			l.kafkaOnly = true
+			for _, uri := range []string{"/books/_search", "/movies/_search", "/songs/_search"} {
+				l.writeRecord(record{Request: Request{URI: uri}})
+			}
+			l.kafka.close()
+
+			messages := producer.produced("arc-logs")
+			So(len(messages), ShouldEqual, 3)
+			var rec record
+			So(json.Unmarshal(messages[2], &rec), ShouldBeNil)
+			So(rec.Request.URI, ShouldEqual, "/songs/_search")
+			So(producer.batches, ShouldEqual, 2)
+			// kafka only mode doesn't write to the log file
+			So(readTestRecords(t, l), ShouldBeEmpty)
+		})
+
+		Convey("Records are buffered while the brokers are unavailable", func() {
+			producer.setUnavailable(true)
+			sink := newKafkaSink(producer, "arc-logs", 2, 1, time.Hour)
+			sink.write(record{})
+			// wait for the first record to be picked up in a batch that fails
+			for len(sink.records) > 0 {
+				time.Sleep(time.Millisecond)
+			}
+			for i := 0; i < 4; i++ {
+				sink.write(record{})
+			}
+			// one record is pending in the failed batch, two are buffered
+			So(sink.droppedRecords(), ShouldEqual, 2)
+			producer.setUnavailable(false)
+			sink.close()
+			So(len(producer.produced("arc-logs")), ShouldEqual, 3)
+		})
+	})
+}
+
+func TestKafkaRESTProducer(t *testing.T) {
+	Convey("Produce through the kafka rest proxy", t, func() {
+		var path, contentType string
+		var body map[string][]map[string]json.RawMessage
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			path = r.URL.Path
+			contentType = r.Header.Get("Content-Type")
+			json.NewDecoder(r.Body).Decode(&body)
+			w.Write([]byte(`{"offsets":[]}`))
+		}))
+		defer ts.Close()
+
+		producer := &kafkaRESTProducer{url: ts.URL}
+		So(producer.produce("arc-logs", [][]byte{[]byte(`{"indices":["books"]}`)}), ShouldBeNil)
+		So(path, ShouldEqual, "/topics/arc-logs")
+		So(contentType, ShouldEqual, "application/vnd.kafka.json.v2+json")
+		So(string(body["records"][0]["value"]), ShouldEqual, `{"indices":["books"]}`)
+	})
+}
@@ -1,12 +1,19 @@
 package logs
 
 import (
+	"context"
+	"fmt"
 	"os"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/appbaseio/reactivesearch-api/middleware"
 	"github.com/appbaseio/reactivesearch-api/plugins"
+	"github.com/appbaseio/reactivesearch-api/util"
 	"github.com/natefinch/lumberjack"
+	es7 "github.com/olivere/elastic/v7"
 	"github.com/robfig/cron"
 	log "github.com/sirupsen/logrus"
 )
@@ -18,6 +25,57 @@ const (
 	envLogsEsIndex     = "LOGS_ES_INDEX"
 	defaultLogFilePath = "/var/log/arc/es.json"
 	envLogFilePath     = "LOG_FILE_PATH"
+	envDropBodyStatus  = "LOGS_DROP_RESPONSE_BODY_STATUS"
+	envRecordTLS       = "LOGS_RECORD_TLS"
+	envAnonymizeIP     = "LOGS_ANONYMIZE_IP"
+	envRecordESQuery   = "LOGS_RECORD_ES_QUERY"
+	envChunkBulk       = "LOGS_CHUNK_BULK"
+	envSynchronous     = "LOGS_SYNCHRONOUS"
+	envMetadataOnly    = "LOGS_METADATA_ONLY_INDICES"
+	envStatsdHost      = "LOGS_STATSD_HOST"
+	envFallbackIndex   = "LOGS_FALLBACK_INDEX"
+	envRecordStack     = "LOGS_RECORD_STACK_TRACE"
+	envIndexPerCat     = "LOGS_INDEX_PER_CATEGORY"
+	envCatRetention    = "LOGS_CATEGORY_RETENTION"
+	envKafkaRESTURL    = "LOGS_KAFKA_REST_URL"
+	envKafkaTopic      = "LOGS_KAFKA_TOPIC"
+	envKafkaOnly       = "LOGS_KAFKA_ONLY"
+	envKafkaBufferSize = "LOGS_KAFKA_BUFFER_SIZE"
+	envStatsdPrefix    = "LOGS_STATSD_PREFIX"
+	envStatsdTags      = "LOGS_STATSD_TAGS"
+	envSizeBuckets     = "LOGS_STATSD_SIZE_BUCKETS"
+	envTags            = "LOGS_TAGS"
+	envRecordQueueTime = "LOGS_RECORD_QUEUE_TIME"
+	envRecordTiming    = "LOGS_RECORD_TIMING"
+	envSampleRate      = "LOGS_SAMPLE_RATE"
+	envRecordDecisions = "LOGS_RECORD_AUTH_DECISIONS"
+	envSlowThreshold   = "LOGS_SLOW_REQUEST_THRESHOLD"
+	envDecompress      = "LOGS_DECOMPRESS_BODIES"
+	envMaxCompression  = "LOGS_MAX_COMPRESSION_RATIO"
+	envRecordFallback  = "LOGS_RECORD_CATEGORY_FALLBACK"
+	envRecordBodyHash  = "LOGS_RECORD_BODY_HASH"
+	envRecordRSComps   = "LOGS_RECORD_RS_COMPONENTS"
+	envRolloverPolicy  = "LOGS_ROLLOVER_WRITE_POLICY"
+	envRolloverGrace   = "LOGS_ROLLOVER_GRACE"
+	envRolloverMaxAge  = "LOGS_ROLLOVER_MAX_AGE"
+	envRolloverMaxDocs = "LOGS_ROLLOVER_MAX_DOCS"
+	envRolloverMaxSize = "LOGS_ROLLOVER_MAX_SIZE"
+	envRetainIndices   = "LOGS_RETAIN_INDICES"
+	envBulkProcessor   = "LOGS_BULK_PROCESSOR"
+	envBulkWorkers     = "LOGS_BULK_WORKERS"
+	envBulkActions     = "LOGS_BULK_ACTIONS"
+	envBulkFlush       = "LOGS_BULK_FLUSH_INTERVAL"
+	envSecondaryURL    = "LOGS_SECONDARY_ES_URL"
+	envPreserveNumbers = "LOGS_PRESERVE_JSON_NUMBERS"
+	envCaptureTypes    = "LOGS_CAPTURE_CONTENT_TYPES"
+	envNormalizeIndex  = "LOGS_INDEX_NORMALIZATION"
+	envRecordComplex   = "LOGS_RECORD_COMPLEXITY_SCORE"
+	envComplexWeights  = "LOGS_COMPLEXITY_WEIGHTS"
+	envMaskFields      = "LOGS_MASK_FIELDS"
+	envPromoteHeaders  = "LOGS_PROMOTED_HEADERS"
+	envMaxBodyBytes    = "LOGS_MAX_BODY_BYTES"
+	envReadAlias       = "LOGS_READ_ALIAS"
+	envRecordStatus    = "LOGS_RECORD_STATUS"
 	config             = `
 	{
 	  "aliases": {
@@ -32,11 +90,6 @@ const (
 	  },
 	  "mappings": %s
 	}`
-	rolloverConfig = `{
-		"max_age":  "%s",
-		"max_docs": %d,
-		"max_size": "%s"
-	}`
 )
 
 var (
@@ -48,6 +101,79 @@ var (
 type Logs struct {
 	es         logsService
 	lumberjack lumberjack.Logger
+	// response bodies aren't recorded for these status codes
+	dropBodyStatus []statusRange
+	// only the requests with these status codes are recorded, all of them if nil
+	recordStatus []statusRange
+	// these request headers are recorded as dedicated fields
+	promotedHeaders []string
+	// the values of these request and response headers are redacted, the default ones
+	// if nil
+	redactedHeaders []string
+	// detects the API version of the requests to record it, if configured
+	apiVersion *apiVersionDetector
+	// records the protocol and TLS version of the requests
+	recordTLS bool
+	// masks the client IPs before recording them
+	anonymizeIP bool
+	// records the es query generated for the reactivesearch requests
+	recordESQuery bool
+	// splits large bulk request bodies across multiple records instead of truncating them
+	chunkBulk bool
+	// records the requests before writing the response instead of asynchronously
+	synchronous bool
+	// request and response bodies aren't recorded for these index patterns
+	metadataOnlyIndices []string
+	// rewrite the recorded index names, e.g. to group the dated indices
+	indexNormalizations []indexNormalization
+	// values of these fields of the JSON bodies are masked before they're recorded
+	maskedFields []fieldPath
+	// only the bodies of these content types are recorded, the default ones if nil
+	captureContentTypes []string
+	// recovers from the panics while serving the requests and records their stack trace
+	recordStackTrace bool
+	// emits the request metrics to statsd if configured
+	statsd *statsd
+	// buckets of the request and response size histograms emitted to statsd, if set
+	sizeBuckets []int64
+	// produces the records to kafka if configured
+	kafka *kafkaSink
+	// records are only produced to kafka and not written to the log file
+	kafkaOnly bool
+	// records the time the requests wait before being served, e.g. behind a coalesced request
+	recordQueueTime bool
+	// records the time the requests spend authenticating, validating and waiting on elasticsearch
+	recordTiming bool
+	// records the outcome of the authorization checks of the requests
+	recordDecisions bool
+	// records whether the category of the requests fell back to a default
+	recordCategoryFallback bool
+	// records the hash of the normalized request bodies
+	recordBodyHash bool
+	// records the complexity score of the search bodies, weighed by complexityWeights
+	recordComplexity  bool
+	complexityWeights complexityWeights
+	// records the query components of the reactivesearch requests
+	recordRSComponents bool
+	// records the reactivesearch request bodies with their numbers as sent
+	preserveNumbers bool
+	// records only the sampleRate fraction of the requests faster than slowThreshold
+	sampling      bool
+	sampleRate    float64
+	slowThreshold time.Duration
+	// static tags stamped on every record
+	tags map[string]string
+	// records the gzip and deflate encoded bodies decompressed, the bodies expanding
+	// beyond maxCompressionRatio times their size are flagged as possible bombs
+	decompressBodies    bool
+	maxCompressionRatio float64
+	// the size the recorded bodies are truncated to, 0 to record them in full
+	maxBodyBytes int
+	// buffer queues the requests to be recorded by its workers, nil records each
+	// request in its own goroutine
+	buffer *recordBuffer
+	// streams the written records to the clients of the logs stream
+	streams logStreams
 }
 
 // Instance returns the singleton instance of Logs plugin.
@@ -72,17 +198,204 @@ func (l *Logs) InitFunc() error {
 		indexName = defaultLogsEsIndex
 	}
 
-	// initialize the elasticsearch client
+	// static tags are validated before connecting to elasticsearch
 	var err error
-	l.es, err = initPlugin(indexName, config)
+	l.tags, err = parseTags(os.Getenv(envTags))
+	if err != nil {
+		return fmt.Errorf("invalid value for %s: %v", envTags, err)
+	}
+
+	baseRetention, err := retentionFromEnv()
 	if err != nil {
 		return err
 	}
+	indexPerCategory := os.Getenv(envIndexPerCat) == "true"
+	categoryRetention, err := parseCategoryRetention(os.Getenv(envCatRetention))
+	if err != nil {
+		return fmt.Errorf("invalid value for %s: %v", envCatRetention, err)
+	}
+	if len(categoryRetention) > 0 && !indexPerCategory {
+		return fmt.Errorf("%s requires %s to be set to true", envCatRetention, envIndexPerCat)
+	}
+
+	breaker, err := breakerConfigFromEnv()
+	if err != nil {
+		return err
+	}
+	indexTimeout, err := indexTimeoutFromEnv()
+	if err != nil {
+		return err
+	}
+
+	var bulkConfig *bulkProcessorConfig
+	if os.Getenv(envBulkProcessor) == "true" {
+		bulkConfig, err = bulkProcessorConfigFromEnv()
+		if err != nil {
+			return err
+		}
+	}
+
+	readAlias := strings.TrimSpace(os.Getenv(envReadAlias))
+	if readAlias != "" && readAlias == indexName {
+		return fmt.Errorf("%s must differ from the logs alias %s", envReadAlias, indexName)
+	}
+
+	// initialize the elasticsearch client
+	es, err := initPlugin(indexName, os.Getenv(envFallbackIndex), readAlias, config, indexPerCategory, categoryRetention, baseRetention)
+	if err != nil {
+		return err
+	}
+	if url := os.Getenv(envSecondaryURL); url != "" {
+		// the secondary cluster being unavailable at startup doesn't hold off the records
+		es.secondary, err = util.NewClient7(url, es7.SetHealthcheck(false))
+		if err != nil {
+			return fmt.Errorf("invalid value for %s: %v", envSecondaryURL, err)
+		}
+	}
+	es.breaker = breaker
+	es.indexTimeout = indexTimeout
+	if path := os.Getenv(envWALPath); path != "" {
+		if bulkConfig != nil {
+			return fmt.Errorf("%s can't be set along with %s", envWALPath, envBulkProcessor)
+		}
+		var entries []walEntry
+		es.wal, entries, err = openWAL(path)
+		if err != nil {
+			return err
+		}
+		go es.replayWAL(context.Background(), entries)
+	}
+	if bulkConfig != nil {
+		if err := es.startBulkProcessor(context.Background(), *bulkConfig); err != nil {
+			return err
+		}
+	}
+	l.es = es
 	filePath := os.Getenv(envLogFilePath)
 	if filePath == "" {
 		log.Warnln(logTag, envLogFilePath+" is not defined log will get stored at ", defaultLogFilePath)
 		filePath = defaultLogFilePath
 	}
+	l.dropBodyStatus, err = parseStatusRanges(os.Getenv(envDropBodyStatus))
+	if err != nil {
+		return fmt.Errorf("invalid value for %s: %v", envDropBodyStatus, err)
+	}
+	l.recordStatus, err = parseRecordStatus(os.Getenv(envRecordStatus))
+	if err != nil {
+		return fmt.Errorf("invalid value for %s: %v", envRecordStatus, err)
+	}
+	l.metadataOnlyIndices, err = parseIndexPatterns(os.Getenv(envMetadataOnly))
+	if err != nil {
+		return fmt.Errorf("invalid value for %s: %v", envMetadataOnly, err)
+	}
+	l.indexNormalizations, err = parseIndexNormalizations(os.Getenv(envNormalizeIndex))
+	if err != nil {
+		return fmt.Errorf("invalid value for %s: %v", envNormalizeIndex, err)
+	}
+	l.redactedHeaders, err = parseRedactHeaders(os.Getenv(envRedactHeaders))
+	if err != nil {
+		return fmt.Errorf("invalid value for %s: %v", envRedactHeaders, err)
+	}
+	l.promotedHeaders, err = parsePromotedHeaders(os.Getenv(envPromoteHeaders))
+	if err != nil {
+		return fmt.Errorf("invalid value for %s: %v", envPromoteHeaders, err)
+	}
+	l.apiVersion, err = apiVersionDetectorFromEnv()
+	if err != nil {
+		return err
+	}
+	l.maskedFields, err = parseMaskFields(os.Getenv(envMaskFields))
+	if err != nil {
+		return fmt.Errorf("invalid value for %s: %v", envMaskFields, err)
+	}
+	if value := os.Getenv(envCaptureTypes); value != "" {
+		l.captureContentTypes, err = parseContentTypes(value)
+		if err != nil {
+			return fmt.Errorf("invalid value for %s: %v", envCaptureTypes, err)
+		}
+	}
+	if host := os.Getenv(envStatsdHost); host != "" {
+		prefix, ok := os.LookupEnv(envStatsdPrefix)
+		if !ok {
+			prefix = defaultStatsdPrefix
+		}
+		l.statsd, err = newStatsd(host, prefix, parseStatsdTags(os.Getenv(envStatsdTags)))
+		if err != nil {
+			return fmt.Errorf("invalid value for %s: %v", envStatsdHost, err)
+		}
+		l.sizeBuckets, err = parseSizeBuckets(os.Getenv(envSizeBuckets))
+		if err != nil {
+			return fmt.Errorf("invalid value for %s: %v", envSizeBuckets, err)
+		}
+	}
+	if url := os.Getenv(envKafkaRESTURL); url != "" {
+		topic := os.Getenv(envKafkaTopic)
+		if topic == "" {
+			return fmt.Errorf("%s must be set along with %s", envKafkaTopic, envKafkaRESTURL)
+		}
+		bufferSize := defaultKafkaBufferSize
+		if value := os.Getenv(envKafkaBufferSize); value != "" {
+			bufferSize, err = strconv.Atoi(value)
+			if err != nil || bufferSize <= 0 {
+				return fmt.Errorf("invalid value for %s: %s", envKafkaBufferSize, value)
+			}
+		}
+		l.kafka = newKafkaSink(&kafkaRESTProducer{url: url}, topic, bufferSize, defaultKafkaBatchSize, defaultKafkaFlushInterval)
+		l.kafkaOnly = os.Getenv(envKafkaOnly) == "true"
+	}
+	if value := os.Getenv(envSampleRate); value != "" {
+		l.sampleRate, err = strconv.ParseFloat(value, 64)
+		if err != nil || l.sampleRate < 0 || l.sampleRate > 1 {
+			return fmt.Errorf("invalid value for %s, must be between 0 and 1: %s", envSampleRate, value)
+		}
+		l.sampling = l.sampleRate < 1
+	}
+	if value := os.Getenv(envSlowThreshold); value != "" {
+		l.slowThreshold, err = time.ParseDuration(value)
+		if err != nil || l.slowThreshold < 0 {
+			return fmt.Errorf("invalid value for %s: %s", envSlowThreshold, value)
+		}
+	}
+	l.maxBodyBytes = defaultMaxBodyBytes
+	if value := os.Getenv(envMaxBodyBytes); value != "" {
+		l.maxBodyBytes, err = strconv.Atoi(value)
+		if err != nil || l.maxBodyBytes < 0 {
+			return fmt.Errorf("invalid value for %s, must be a non-negative number of bytes: %s", envMaxBodyBytes, value)
+		}
+	}
+	if value := os.Getenv(envMaxCompression); value != "" {
+		l.maxCompressionRatio, err = strconv.ParseFloat(value, 64)
+		if err != nil || l.maxCompressionRatio < 1 {
+			return fmt.Errorf("invalid value for %s, must be at least 1: %s", envMaxCompression, value)
+		}
+	}
+	l.complexityWeights, err = parseComplexityWeights(os.Getenv(envComplexWeights))
+	if err != nil {
+		return fmt.Errorf("invalid value for %s: %v", envComplexWeights, err)
+	}
+	policy, grace, err := rolloverPolicyFromEnv()
+	if err != nil {
+		return err
+	}
+	rollovers.configure(policy, grace)
+	l.decompressBodies = os.Getenv(envDecompress) == "true"
+	l.recordTLS = os.Getenv(envRecordTLS) == "true"
+	l.recordStackTrace = os.Getenv(envRecordStack) == "true"
+	l.recordQueueTime = os.Getenv(envRecordQueueTime) == "true"
+	l.recordTiming = os.Getenv(envRecordTiming) == "true"
+	l.recordDecisions = os.Getenv(envRecordDecisions) == "true"
+	l.recordCategoryFallback = os.Getenv(envRecordFallback) == "true"
+	l.recordBodyHash = os.Getenv(envRecordBodyHash) == "true"
+	l.recordRSComponents = os.Getenv(envRecordRSComps) == "true"
+	l.recordComplexity = os.Getenv(envRecordComplex) == "true"
+	l.preserveNumbers = os.Getenv(envPreserveNumbers) == "true"
+	l.anonymizeIP = os.Getenv(envAnonymizeIP) == "true"
+	l.recordESQuery = os.Getenv(envRecordESQuery) == "true"
+	l.chunkBulk = os.Getenv(envChunkBulk) == "true"
+	l.synchronous = os.Getenv(envSynchronous) == "true"
+	if l.synchronous {
+		log.Warnln(logTag, ": requests are recorded synchronously, this is meant for debugging only")
+	}
 	// configure lumberjack
 	l.lumberjack = lumberjack.Logger{
 		Filename:   filePath,
@@ -90,15 +403,62 @@ func (l *Logs) InitFunc() error {
 		MaxBackups: 3,
 		MaxAge:     30, //days
 	}
+	if !l.synchronous {
+		bufferSize, workers, err := recordBufferConfigFromEnv()
+		if err != nil {
+			return err
+		}
+		l.buffer = l.startRecordBuffer(bufferSize, workers)
+	}
+	l.flushOnShutdown()
 
 	// init cron job
 	cronjob := cron.New()
-	cronjob.AddFunc("@midnight", func() { l.es.rolloverIndexJob(indexName) })
+	cronjob.AddFunc("@midnight", func() {
+		// each of the category aliases is rolled over independently
+		for _, alias := range l.es.aliases() {
+			l.es.rolloverIndexJob(alias)
+		}
+	})
 	cronjob.Start()
 
 	return nil
 }
 
+// bulkProcessorConfigFromEnv returns the configuration of the bulk processor, it
+// defaults to a single worker flushing every 1000 records or every second.
+func bulkProcessorConfigFromEnv() (*bulkProcessorConfig, error) {
+	config := &bulkProcessorConfig{workers: 1, bulkActions: 1000, flushInterval: time.Second}
+	if value := os.Getenv(envBulkWorkers); value != "" {
+		workers, err := strconv.Atoi(value)
+		if err != nil || workers < 1 {
+			return nil, fmt.Errorf("invalid value for %s: %s", envBulkWorkers, value)
+		}
+		config.workers = workers
+	}
+	if value := os.Getenv(envBulkActions); value != "" {
+		bulkActions, err := strconv.Atoi(value)
+		if err != nil || bulkActions < 1 {
+			return nil, fmt.Errorf("invalid value for %s: %s", envBulkActions, value)
+		}
+		config.bulkActions = bulkActions
+	}
+	if value := os.Getenv(envBulkFlush); value != "" {
+		flushInterval, err := time.ParseDuration(value)
+		if err != nil || flushInterval <= 0 {
+			return nil, fmt.Errorf("invalid value for %s: %s", envBulkFlush, value)
+		}
+		config.flushInterval = flushInterval
+	}
+	return config, nil
+}
+
+// PreservesNumbers returns true if the reactivesearch request bodies are recorded with
+// their numbers as sent, for which the raw request bodies are kept in the context.
+func (l *Logs) PreservesNumbers() bool {
+	return l.preserveNumbers
+}
+
 // Routes returns an empty slice of routes, since Logs is solely a middleware.
 func (l *Logs) Routes() []plugins.Route {
 	return l.routes()
@@ -0,0 +1,122 @@
+package logs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// maskedValue replaces the values of the masked fields in the recorded bodies.
+const maskedValue = "[REDACTED]"
+
+// fieldPath is a dot separated path to a field of a JSON body split into its segments,
+// a `*` segment matches any key of an object or any element of an array.
+type fieldPath []string
+
+// parseMaskFields parses a comma separated list of the paths of the fields to mask,
+// e.g. `user.password,payment.card,orders.*.card`.
+func parseMaskFields(value string) ([]fieldPath, error) {
+	var paths []fieldPath
+	for _, p := range strings.Split(value, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		path := fieldPath(strings.Split(p, "."))
+		for _, segment := range path {
+			if segment == "" {
+				return nil, fmt.Errorf("invalid field path %s", p)
+			}
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// maskFields replaces the values of the fields at the paths of a JSON or NDJSON body
+// with maskedValue. The body is returned as is if it can't be parsed or if none of its
+// fields is masked.
+func maskFields(body []byte, paths []fieldPath) ([]byte, bool) {
+	if len(paths) == 0 || len(body) == 0 {
+		return body, false
+	}
+	var docs []interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	// the numbers are re-encoded as sent
+	decoder.UseNumber()
+	for {
+		var doc interface{}
+		err := decoder.Decode(&doc)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return body, false
+		}
+		docs = append(docs, doc)
+	}
+	masked := false
+	for _, doc := range docs {
+		for _, path := range paths {
+			masked = maskPath(doc, path) || masked
+		}
+	}
+	if !masked {
+		return body, false
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	for _, doc := range docs {
+		if err := encoder.Encode(doc); err != nil {
+			return body, false
+		}
+	}
+	result := buf.Bytes()
+	if !bytes.HasSuffix(body, []byte("\n")) {
+		result = bytes.TrimSuffix(result, []byte("\n"))
+	}
+	return result, true
+}
+
+// maskPath masks the fields of the value at the path, returning whether any was masked.
+func maskPath(value interface{}, path fieldPath) bool {
+	segment, rest := path[0], path[1:]
+	masked := false
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if segment != "*" && segment != key {
+				continue
+			}
+			if len(rest) == 0 {
+				v[key] = maskedValue
+				masked = true
+				continue
+			}
+			masked = maskPath(child, rest) || masked
+		}
+	case []interface{}:
+		for i, child := range v {
+			if segment != "*" && segment != strconv.Itoa(i) {
+				continue
+			}
+			if len(rest) == 0 {
+				v[i] = maskedValue
+				masked = true
+				continue
+			}
+			masked = maskPath(child, rest) || masked
+		}
+	}
+	return masked
+}
+
+// mask masks the configured fields of a recorded body.
+func (l *Logs) mask(body []byte) []byte {
+	masked, _ := maskFields(body, l.maskedFields)
+	return masked
+}
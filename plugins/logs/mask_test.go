@@ -0,0 +1,78 @@
+package logs
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMaskFields(t *testing.T) {
+	Convey("Mask the fields of the bodies", t, func() {
+		paths, err := parseMaskFields("user.password, payment.card,orders.*.card,hits.hits.*._source.ssn")
+		So(err, ShouldBeNil)
+		So(len(paths), ShouldEqual, 4)
+		decode := func(body []byte) map[string]interface{} {
+			var doc map[string]interface{}
+			So(json.Unmarshal(body, &doc), ShouldBeNil)
+			return doc
+		}
+
+		Convey("Nested fields are masked and the others preserved", func() {
+			body, masked := maskFields([]byte(`{"user":{"name":"jane","password":"secret"},"payment":{"card":"4111","amount":12345678901234567890}}`), paths)
+			So(masked, ShouldBeTrue)
+			So(string(body), ShouldEqual, `{"payment":{"amount":12345678901234567890,"card":"[REDACTED]"},"user":{"name":"jane","password":"[REDACTED]"}}`)
+		})
+		Convey("Fields of the array elements are masked", func() {
+			body, masked := maskFields([]byte(`{"orders":[{"id":1,"card":"4111"},{"id":2,"card":{"number":"5500"}}]}`), paths)
+			So(masked, ShouldBeTrue)
+			orders := decode(body)["orders"].([]interface{})
+			So(orders[0], ShouldResemble, map[string]interface{}{"id": 1.0, "card": maskedValue})
+			So(orders[1], ShouldResemble, map[string]interface{}{"id": 2.0, "card": maskedValue})
+		})
+		Convey("Fields of a search response are masked", func() {
+			body, masked := maskFields([]byte(`{"hits":{"total":1,"hits":[{"_id":"1","_source":{"name":"jane","ssn":"123-45-6789"}}]}}`), paths)
+			So(masked, ShouldBeTrue)
+			hit := decode(body)["hits"].(map[string]interface{})["hits"].([]interface{})[0].(map[string]interface{})
+			So(hit["_source"], ShouldResemble, map[string]interface{}{"name": "jane", "ssn": maskedValue})
+			So(hit["_id"], ShouldEqual, "1")
+		})
+		Convey("An array element is masked by its position", func() {
+			indexed, err := parseMaskFields("cards.0")
+			So(err, ShouldBeNil)
+			body, _ := maskFields([]byte(`{"cards":["4111","5500"]}`), indexed)
+			So(string(body), ShouldEqual, `{"cards":["[REDACTED]","5500"]}`)
+		})
+		Convey("The documents of a NDJSON body are masked", func() {
+			body, masked := maskFields([]byte("{\"index\":{\"_index\":\"users\"}}\n{\"user\":{\"password\":\"secret\"}}\n"), paths)
+			So(masked, ShouldBeTrue)
+			So(string(body), ShouldEqual, "{\"index\":{\"_index\":\"users\"}}\n{\"user\":{\"password\":\"[REDACTED]\"}}\n")
+		})
+		Convey("The bodies without the fields are kept as sent", func() {
+			raw := []byte(`{ "query": {"match_all": {}} }`)
+			body, masked := maskFields(raw, paths)
+			So(masked, ShouldBeFalse)
+			So(string(body), ShouldEqual, string(raw))
+		})
+		Convey("The bodies that aren't JSON are kept as sent", func() {
+			body, masked := maskFields([]byte(`user.password=secret`), paths)
+			So(masked, ShouldBeFalse)
+			So(string(body), ShouldEqual, `user.password=secret`)
+		})
+		Convey("The paths with empty segments are invalid", func() {
+			_, err := parseMaskFields("user..password")
+			So(err, ShouldNotBeNil)
+		})
+	})
+
+	Convey("Record the bodies with the fields masked", t, func() {
+		l := newTestLogs(t)
+		l.maskedFields, _ = parseMaskFields("user.password,hits.hits.*._source.ssn")
+		rec := recordTestResponse(t, l,
+			newTestRequest("POST", "/books/_search", `{"user":{"name":"jane","password":"secret"}}`),
+			http.StatusOK, `{"took":1,"hits":{"hits":[{"_source":{"ssn":"123-45-6789"}}]}}`)
+		So(rec.Request.Body, ShouldEqual, `{"user":{"name":"jane","password":"[REDACTED]"}}`)
+		So(rec.Response.Body, ShouldEqual, `{"hits":{"hits":[{"_source":{"ssn":"[REDACTED]"}}]},"took":1}`)
+	})
+}
@@ -1,26 +1,48 @@
 package logs
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
+	"math"
+	"math/rand"
 	"net/http"
 	"net/http/httptest"
 	"net/http/httputil"
+	"runtime/debug"
 	"strings"
 	"time"
 
 	"github.com/appbaseio/reactivesearch-api/middleware"
 	"github.com/appbaseio/reactivesearch-api/middleware/classify"
 	"github.com/appbaseio/reactivesearch-api/middleware/validate"
+	"github.com/appbaseio/reactivesearch-api/model/authmethod"
 	"github.com/appbaseio/reactivesearch-api/model/category"
+	"github.com/appbaseio/reactivesearch-api/model/decision"
 	"github.com/appbaseio/reactivesearch-api/model/index"
+	"github.com/appbaseio/reactivesearch-api/model/op"
+	"github.com/appbaseio/reactivesearch-api/model/queuetime"
 	"github.com/appbaseio/reactivesearch-api/model/request"
+	"github.com/appbaseio/reactivesearch-api/model/timing"
 	"github.com/appbaseio/reactivesearch-api/plugins/auth"
 	"github.com/appbaseio/reactivesearch-api/util"
+	"github.com/appbaseio/reactivesearch-api/util/iplookup"
 	"github.com/buger/jsonparser"
 	log "github.com/sirupsen/logrus"
 )
 
+// defaultMaxBodyBytes is the maximum size of the request and response bodies in a record
+// unless LOGS_MAX_BODY_BYTES is set
+const defaultMaxBodyBytes = 1000000
+
+// maxStackTraceSize is the maximum size of the stack trace of a recovered panic in a record
+const maxStackTraceSize = 16384
+
 type chain struct {
 	middleware.Fifo
 }
@@ -68,10 +90,31 @@ func classifyCategory(h http.HandlerFunc) http.HandlerFunc {
 }
 
 type Request struct {
-	URI     string              `json:"uri"`
-	Method  string              `json:"method"`
-	Headers map[string][]string `json:"header"`
-	Body    string              `json:"body"`
+	URI           string              `json:"uri"`
+	Method        string              `json:"method"`
+	Headers       map[string][]string `json:"header"`
+	Body          string              `json:"body"`
+	ESQuery       string              `json:"es_query,omitempty"`
+	ClientIP      string              `json:"client_ip,omitempty"`
+	ContentLength int64               `json:"content_length"`
+	Chunked       bool                `json:"chunked"`
+	Protocol      string              `json:"protocol,omitempty"`
+	TLSVersion    string              `json:"tls_version,omitempty"`
+	// CompressionRatio is the decompressed to compressed size ratio of the body
+	CompressionRatio float64 `json:"compression_ratio,omitempty"`
+	// QueueTimeMs is the time the request waited in the limiters before being served
+	QueueTimeMs int64 `json:"queue_time_ms,omitempty"`
+	// BodyHash is the hash of the normalized body, the same for the logically identical bodies
+	BodyHash string `json:"body_hash,omitempty"`
+	// RSComponents are the query components of a reactivesearch request
+	RSComponents *RSComponents `json:"rs_components,omitempty"`
+	// ComplexityScore weighs the clauses, nesting depth and aggregations of a search body
+	ComplexityScore *float64 `json:"complexity_score,omitempty"`
+	// APIVersion is the API version the request targets, detected from its header or path
+	APIVersion string `json:"api_version,omitempty"`
+	// PromotedHeaders are the configured headers recorded as dedicated fields rather
+	// than in the headers, keyed by their lowercased names, e.g. `x_app_name`
+	PromotedHeaders map[string]string `json:"promoted_headers,omitempty"`
 }
 
 type Response struct {
@@ -80,14 +123,82 @@ type Response struct {
 	Headers map[string][]string
 	Took    *float64 `json:"took,omitempty"`
 	Body    string   `json:"body"`
+	// CompressionRatio is the decompressed to compressed size ratio of the body
+	CompressionRatio float64 `json:"compression_ratio,omitempty"`
+	// ResolvedIndex is the concrete index a write landed in, e.g. the write index of an alias
+	ResolvedIndex string `json:"resolved_index,omitempty"`
+	// ShardsTouched is the number of shards a search request fanned out to
+	ShardsTouched *int64 `json:"shards_touched,omitempty"`
+	// StackTrace of the panic recovered while serving the request
+	StackTrace string `json:"stack_trace,omitempty"`
+	// Cache are the caching headers of the response
+	Cache *CacheHeaders `json:"cache,omitempty"`
+	// BulkErrors are the item-level results of a bulk request
+	BulkErrors *BulkErrors `json:"bulk_errors,omitempty"`
+}
+
+// Chunk links the records of a request body that has been split across multiple records.
+type Chunk struct {
+	RequestID string `json:"request_id"`
+	Index     int    `json:"index"`
+	Total     int    `json:"total"`
+}
+
+// Timing is the time in milliseconds a request spent in each phase of being served,
+// the remainder of the total is spent in the rest of the middlewares and the handler.
+type Timing struct {
+	AuthMs     float64 `json:"auth_ms"`
+	ValidateMs float64 `json:"validate_ms"`
+	UpstreamMs float64 `json:"upstream_ms"`
+	TotalMs    float64 `json:"total_ms"`
+	// AuthLookupMs is the part of AuthMs spent looking up the credential
+	AuthLookupMs float64 `json:"auth_lookup_ms"`
 }
 
 type record struct {
-	Indices   []string          `json:"indices"`
-	Category  category.Category `json:"category"`
-	Request   Request           `json:"request"`
-	Response  Response          `json:"response"`
-	Timestamp time.Time         `json:"timestamp"`
+	// DocumentID is derived from the request id or idempotency key so that
+	// retried requests don't produce duplicate log documents.
+	DocumentID string   `json:"document_id,omitempty"`
+	Indices    []string `json:"indices"`
+	// RawIndices are the indices of the request as sent, set if Indices were normalized
+	RawIndices []string          `json:"raw_indices,omitempty"`
+	Category   category.Category `json:"category"`
+	Request    Request           `json:"request"`
+	Response   Response          `json:"response"`
+	Timestamp  time.Time         `json:"timestamp"`
+	Chunk      *Chunk            `json:"chunk,omitempty"`
+	// CategoryCode is the numeric code of the category, stable across its renames
+	CategoryCode int `json:"category_code"`
+	// AuthDecisions are the outcomes of the authorization checks of the request
+	AuthDecisions []decision.Decision `json:"auth_decisions,omitempty"`
+	// Tags are the static tags configured for the deployment, e.g. its environment
+	Tags map[string]string `json:"tags,omitempty"`
+	// CategoryFallback is set if the category of the request fell back to a default
+	// rather than being matched by the classifier
+	CategoryFallback *bool `json:"category_fallback,omitempty"`
+	// Flags mark the suspicious requests, e.g. with a possible decompression bomb
+	Flags *Flags `json:"flags,omitempty"`
+	// AuthMethod is the scheme the request was authenticated with, e.g. "basic" or "jwt"
+	AuthMethod authmethod.Method `json:"auth_method,omitempty"`
+	// Timing breaks the latency of the request down into its phases
+	Timing *Timing `json:"timing,omitempty"`
+	// AuthCacheHit is set if the credential of the request was served by the credential
+	// cache rather than fetched from elasticsearch, recorded along with Timing
+	AuthCacheHit *bool `json:"auth_cache_hit,omitempty"`
+}
+
+// documentID returns a deterministic document id for the request based on its
+// idempotency key or request id, or an empty string if neither is present.
+func documentID(r *http.Request) string {
+	key := r.Header.Get("Idempotency-Key")
+	if key == "" {
+		key = r.Header.Get("X-Request-Id")
+	}
+	if key == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(r.Method + " " + r.URL.Path + " " + key))
+	return hex.EncodeToString(sum[:])
 }
 
 // Recorder records a log "record" for every request.
@@ -118,9 +229,43 @@ func (l *Logs) recorder(h http.HandlerFunc) http.HandlerFunc {
 				return
 			}
 		}
+		if *reqCategory == category.ReactiveSearch && l.recordESQuery {
+			// querytranslate fills in the generated query
+			ctx = request.NewESQueryContext(ctx, &request.ESQuery{})
+			r = r.WithContext(ctx)
+		}
+		// the auth middleware sets the scheme the request is authenticated with
+		ctx = authmethod.NewContext(ctx, &authmethod.Holder{})
+		r = r.WithContext(ctx)
+		if l.recordDecisions {
+			// the validate middlewares add the outcome of their checks
+			ctx = decision.NewContext(ctx, &decision.Decisions{})
+			r = r.WithContext(ctx)
+		}
+		if l.recordQueueTime {
+			// the limiters add the time the request waits in them
+			ctx = queuetime.NewContext(ctx, &queuetime.Timer{})
+			r = r.WithContext(ctx)
+		}
+		if l.recordTiming {
+			// the auth and validate middlewares and the upstream calls add their time
+			ctx = timing.NewContext(ctx, &timing.Timings{})
+			r = r.WithContext(ctx)
+		}
 		// Serve using response recorder
 		respRecorder := httptest.NewRecorder()
-		h(respRecorder, r)
+		start := time.Now()
+		var stackTrace string
+		if l.recordStackTrace {
+			stackTrace = serveRecovered(h, respRecorder, r)
+		} else {
+			h(respRecorder, r)
+		}
+		latency := time.Since(start)
+		if l.synchronous {
+			// Record the document before the response is written
+			l.recordResponse(respRecorder, r, dumpRequest, latency, stackTrace)
+		}
 		// Copy the response to writer
 		for k, v := range respRecorder.Header() {
 			w.Header()[k] = v
@@ -128,12 +273,110 @@ func (l *Logs) recorder(h http.HandlerFunc) http.HandlerFunc {
 		w.WriteHeader(respRecorder.Code)
 		w.Write(respRecorder.Body.Bytes())
 		// Record the document
+		if !l.synchronous {
+			l.queueRecord(pendingRecord{respRecorder, r, dumpRequest, latency, stackTrace})
+		}
+	}
+}
 
-		go l.recordResponse(respRecorder, r, dumpRequest)
+// queueRecord records the served request in the background, through the record buffer
+// if it's started.
+func (l *Logs) queueRecord(p pendingRecord) {
+	if l.buffer == nil {
+		go l.recordResponse(p.w, p.r, p.reqBody, p.latency, p.stackTrace)
+		return
+	}
+	if !l.buffer.push(p) && l.statsd != nil {
+		l.statsd.count("records_dropped", 1)
+	}
+}
+
+// shardsTouched returns the total number of shards of a search response, summed over
+// the responses of a msearch, or nil if the response doesn't report them.
+func shardsTouched(responseBody []byte) *int64 {
+	if total, err := jsonparser.GetInt(responseBody, "_shards", "total"); err == nil {
+		return &total
+	}
+	var total int64
+	found := false
+	jsonparser.ArrayEach(responseBody, func(value []byte, dataType jsonparser.ValueType, offset int, err error) {
+		if shards, err := jsonparser.GetInt(value, "_shards", "total"); err == nil {
+			total += shards
+			found = true
+		}
+	}, "responses")
+	if !found {
+		return nil
 	}
+	return &total
 }
 
-func (l *Logs) recordResponse(w *httptest.ResponseRecorder, r *http.Request, reqBody []byte) {
+// resolvedIndex returns the concrete index a write response reports the document was
+// written to, i.e. its `_index`. The bulk responses report it only if all of their items
+// were written to the same index.
+func resolvedIndex(responseBody []byte) string {
+	if indexName, err := jsonparser.GetString(responseBody, "_index"); err == nil {
+		return indexName
+	}
+	var resolved string
+	mixed := false
+	jsonparser.ArrayEach(responseBody, func(item []byte, dataType jsonparser.ValueType, offset int, err error) {
+		// each item is keyed by its action, e.g. {"index": {"_index": ...}}
+		jsonparser.ObjectEach(item, func(action []byte, result []byte, dataType jsonparser.ValueType, offset int) error {
+			indexName, err := jsonparser.GetString(result, "_index")
+			if err != nil {
+				return nil
+			}
+			if resolved != "" && resolved != indexName {
+				mixed = true
+			}
+			resolved = indexName
+			return nil
+		})
+	}, "items")
+	if mixed {
+		return ""
+	}
+	return resolved
+}
+
+// sampled decides whether a request is recorded once its latency is known. The slow
+// requests are always recorded, the rest are sampled at the configured rate.
+func (l *Logs) sampled(latency time.Duration) bool {
+	if !l.sampling || (l.slowThreshold > 0 && latency >= l.slowThreshold) {
+		return true
+	}
+	return rand.Float64() < l.sampleRate
+}
+
+// serveRecovered serves the request and recovers from a panic in the handler with a
+// 500 response. It returns the truncated stack trace of the recovered panic. The
+// http.ErrAbortHandler panics abort the response as intended and aren't recovered.
+func serveRecovered(h http.HandlerFunc, w *httptest.ResponseRecorder, r *http.Request) (stackTrace string) {
+	defer func() {
+		if err := recover(); err != nil {
+			if err == http.ErrAbortHandler {
+				panic(err)
+			}
+			stack := debug.Stack()
+			log.Errorln(logTag, ": recovered from panic:", err, "\n", string(stack))
+			stackTrace = fmt.Sprintf("%v\n%s", err, stack[:util.Min(len(stack), maxStackTraceSize)])
+			// discard the partially written response
+			*w = *httptest.NewRecorder()
+			util.WriteBackError(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+	}()
+	h(w, r)
+	return ""
+}
+
+func (l *Logs) recordResponse(w *httptest.ResponseRecorder, r *http.Request, reqBody []byte, latency time.Duration, stackTrace string) {
+	l.writeRecords(l.buildRecords(w, r, reqBody, latency, stackTrace))
+}
+
+// buildRecords returns the records of the request and its response, none if the request
+// isn't recorded, or its chunks if the bulk requests are chunked.
+func (l *Logs) buildRecords(w *httptest.ResponseRecorder, r *http.Request, reqBody []byte, latency time.Duration, stackTrace string) []record {
 	var headers = make(map[string][]string)
 
 	for key, values := range r.Header {
@@ -145,30 +388,49 @@ func (l *Logs) recordResponse(w *httptest.ResponseRecorder, r *http.Request, req
 	reqCategory, err := category.FromContext(ctx)
 	if err != nil {
 		log.Errorln(logTag, ":", err)
-		return
+		return nil
 	}
 
 	reqIndices, err := index.FromContext(ctx)
 	if err != nil {
 		log.Errorln(logTag, ":", err)
-		return
+		return nil
 	}
 
 	var rec record
-	rec.Indices = reqIndices
+	rec.DocumentID = documentID(r)
+	if normalized, ok := normalizeIndices(l.indexNormalizations, reqIndices); ok {
+		rec.Indices = normalized
+		rec.RawIndices = reqIndices
+	} else {
+		rec.Indices = reqIndices
+	}
 	rec.Category = *reqCategory
+	rec.CategoryCode = reqCategory.Code()
 	rec.Timestamp = time.Now()
+	rec.Tags = l.tags
+	if l.recordCategoryFallback {
+		fallback := category.IsFallback(ctx)
+		rec.CategoryFallback = &fallback
+	}
 
 	// record response
 	response := w.Result()
 	rec.Response.Code = response.StatusCode
 	rec.Response.Status = http.StatusText(response.StatusCode)
 	rec.Response.Headers = response.Header
+	rec.Response.StackTrace = stackTrace
+	rec.Response.Cache = cacheHeaders(response.Header)
 
 	responseBody, err := ioutil.ReadAll(response.Body)
 	if err != nil {
 		log.Errorln(logTag, "can't read response body: ", err)
-		return
+		return nil
+	}
+	// the sizes are of the bodies as sent, before they're decompressed
+	requestSize, responseSize := 0, len(responseBody)
+	if l.decompressBodies {
+		responseBody, rec.Response.CompressionRatio = l.decompress(&rec, responseBody, response.Header.Get("Content-Encoding"))
 	}
 	if *reqCategory == category.Search {
 		var resBody SearchResponseBody
@@ -180,7 +442,17 @@ func (l *Logs) recordResponse(w *httptest.ResponseRecorder, r *http.Request, req
 		if err == nil {
 			rec.Response.Took = &resBody.Took
 		}
+		rec.Response.ShardsTouched = shardsTouched(responseBody)
+	}
+	if reqOp, err := op.FromContext(ctx); err == nil && *reqOp == op.Write {
+		rec.Response.ResolvedIndex = resolvedIndex(responseBody)
+	}
+	if isBulkRequest(r) {
+		rec.Response.BulkErrors = bulkErrors(responseBody)
 	}
+	responseBody = l.mask(responseBody)
+	// request body of the non reactivesearch requests
+	var parsedBody []byte
 	if *reqCategory == category.ReactiveSearch {
 		// Read request body from context
 		rsRequestBody, err := request.FromContext(ctx)
@@ -191,14 +463,37 @@ func (l *Logs) recordResponse(w *httptest.ResponseRecorder, r *http.Request, req
 		marshalled, err := json.Marshal(rsRequestBody)
 		if err != nil {
 			log.Errorln(logTag, "error encountered while marshalling request body:", err)
-			return
+			return nil
+		}
+		requestSize = len(marshalled)
+		if raw := request.RawBodyFromContext(ctx); raw != nil {
+			requestSize = len(raw)
 		}
+		// the large integers, e.g. 64-bit ids, are kept exact rather than as floats
+		if l.preserveNumbers {
+			if normalized, ok := normalizeJSON(request.RawBodyFromContext(ctx)); ok {
+				marshalled = normalized
+			}
+		}
+		marshalled = l.mask(marshalled)
 		rec.Request = Request{
 			URI:     r.URL.Path,
 			Headers: headers,
-			Body:    string(marshalled[:util.Min(len(marshalled), 1000000)]),
+			Body:    string(marshalled[:l.bodyLength(len(marshalled))]),
 			Method:  r.Method,
 		}
+		if l.recordBodyHash {
+			rec.Request.BodyHash = bodyHash(marshalled)
+		}
+		if l.recordRSComponents {
+			rec.Request.RSComponents = rsComponents(marshalled)
+		}
+		if esQuery, err := request.ESQueryFromContext(ctx); err == nil {
+			rec.Request.ESQuery = esQuery.Query[:l.bodyLength(len(esQuery.Query))]
+			if l.recordComplexity {
+				rec.Request.ComplexityScore = l.complexityScore([]byte(esQuery.Query))
+			}
+		}
 		// read success response from context
 		tookValue, err := jsonparser.GetFloat(w.Body.Bytes(), "settings", "took")
 		if err != nil {
@@ -208,33 +503,309 @@ func (l *Logs) recordResponse(w *httptest.ResponseRecorder, r *http.Request, req
 			rec.Response.Took = &tookValue
 		}
 		// read error response from response recorder body
-		rec.Response.Body = string(responseBody[:util.Min(len(responseBody), 1000000)])
+		rec.Response.Body = string(responseBody[:l.bodyLength(len(responseBody))])
 	} else {
 		requestBody := strings.Split(string(reqBody), "\r\n\r\n")
-		var parsedBody []byte
 		if len(requestBody) > 1 {
 			parsedBody = []byte(requestBody[1])
 		}
+		requestSize = len(parsedBody)
+		var compressionRatio float64
+		if l.decompressBodies {
+			parsedBody, compressionRatio = l.decompress(&rec, parsedBody, r.Header.Get("Content-Encoding"))
+		}
+		parsedBody = l.mask(parsedBody)
 		// record request
 		rec.Request = Request{
 			URI:     r.URL.Path,
 			Headers: headers,
-			Body:    string(parsedBody[:util.Min(len(parsedBody), 1000000)]),
+			Body:    string(parsedBody[:l.bodyLength(len(parsedBody))]),
 			Method:  r.Method,
+
+			CompressionRatio: compressionRatio,
+		}
+		if l.recordBodyHash {
+			rec.Request.BodyHash = bodyHash(parsedBody)
 		}
-		rec.Response.Body = string(responseBody[:util.Min(len(responseBody), 1000000)])
+		if l.recordComplexity && *reqCategory == category.Search {
+			rec.Request.ComplexityScore = l.complexityScore(parsedBody)
+		}
+		rec.Response.Body = string(responseBody[:l.bodyLength(len(responseBody))])
 	}
-	marshalledLog, err := json.Marshal(rec)
-	if err != nil {
-		log.Errorln(logTag, "error encountered while marshalling record :", err)
+	rec.Request.ContentLength = r.ContentLength
+	if l.apiVersion != nil {
+		rec.Request.APIVersion = l.apiVersion.detect(r)
+	}
+	for _, encoding := range r.TransferEncoding {
+		if encoding == "chunked" {
+			rec.Request.Chunked = true
+		}
+	}
+	if decisions, err := decision.FromContext(ctx); err == nil {
+		rec.AuthDecisions = decisions.List()
+	}
+	if holder, err := authmethod.FromContext(ctx); err == nil {
+		rec.AuthMethod = holder.Method()
+	}
+	if timer, err := queuetime.FromContext(ctx); err == nil {
+		rec.Request.QueueTimeMs = timer.Duration().Milliseconds()
+	}
+	if timings, err := timing.FromContext(ctx); err == nil {
+		rec.Timing = &Timing{
+			AuthMs:     milliseconds(timings.Duration(timing.Auth)),
+			ValidateMs: milliseconds(timings.Duration(timing.Validate)),
+			UpstreamMs: milliseconds(timings.Duration(timing.Upstream)),
+			TotalMs:    milliseconds(latency),
+
+			AuthLookupMs: milliseconds(timings.Duration(timing.AuthLookup)),
+		}
+		rec.AuthCacheHit = timings.AuthCacheHit()
+	}
+	// the credentials must never be stored
+	rec.Request.Headers = redactHeaders(rec.Request.Headers, l.redactedHeaders)
+	rec.Response.Headers = redactHeaders(rec.Response.Headers, l.redactedHeaders)
+	rec.Request.ClientIP = iplookup.FromRequest(r)
+	if l.anonymizeIP {
+		rec.Request.ClientIP = iplookup.Anonymize(rec.Request.ClientIP)
+		anonymizeIPHeaders(rec.Request.Headers)
+	}
+	rec.Request.PromotedHeaders = promoteHeaders(rec.Request.Headers, l.promotedHeaders)
+	if l.recordTLS {
+		rec.Request.Protocol = r.Proto
+		rec.Request.TLSVersion = tlsVersion(r)
+	}
+	if l.statsd != nil {
+		l.statsd.emitMetrics(rec, latency)
+		if l.sizeBuckets != nil {
+			l.statsd.emitSizes(rec, l.sizeBuckets, requestSize, responseSize)
+		}
+	}
+	// the metrics account for every request, the records are filtered by status and sampled
+	if l.recordStatus != nil && !matchesStatus(l.recordStatus, rec.Response.Code) {
+		return nil
+	}
+	if !l.sampled(latency) {
+		return nil
+	}
+	if matchesStatus(l.dropBodyStatus, rec.Response.Code) {
+		rec.Response.Body = ""
+	}
+	targets := append(append([]string{}, reqIndices...), bodyIndices(r, parsedBody)...)
+	if rec.Response.ResolvedIndex != "" {
+		targets = append(targets, rec.Response.ResolvedIndex)
+	}
+	if targetsIndex(l.metadataOnlyIndices, targets) {
+		// the bodies of the sensitive indices, and whatever is derived from them, must
+		// never be stored
+		rec.Request = Request{
+			URI:             rec.Request.URI,
+			Method:          rec.Request.Method,
+			Headers:         rec.Request.Headers,
+			ClientIP:        rec.Request.ClientIP,
+			ContentLength:   rec.Request.ContentLength,
+			Chunked:         rec.Request.Chunked,
+			Protocol:        rec.Request.Protocol,
+			TLSVersion:      rec.Request.TLSVersion,
+			QueueTimeMs:     rec.Request.QueueTimeMs,
+			APIVersion:      rec.Request.APIVersion,
+			PromotedHeaders: rec.Request.PromotedHeaders,
+		}
+		rec.Response = Response{
+			Code:       rec.Response.Code,
+			Status:     rec.Response.Status,
+			Headers:    rec.Response.Headers,
+			Took:       rec.Response.Took,
+			StackTrace: rec.Response.StackTrace,
+			Cache:      rec.Response.Cache,
+		}
+		return []record{rec}
+	}
+	// the reactivesearch request bodies are recorded as re-encoded JSON
+	if *reqCategory != category.ReactiveSearch && rec.Request.Body != "" &&
+		!l.capturesContentType(r.Header.Get("Content-Type")) {
+		rec.Request.Body = binaryBodyPlaceholder
+		parsedBody = nil
+	}
+	if rec.Response.Body != "" && !l.capturesContentType(response.Header.Get("Content-Type")) {
+		rec.Response.Body = binaryBodyPlaceholder
+	}
+	if l.chunkBulk && l.maxBodyBytes > 0 && len(parsedBody) > l.maxBodyBytes && isBulkRequest(r) {
+		return chunkRecord(rec, parsedBody, l.maxBodyBytes)
+	}
+	return []record{rec}
+}
+
+// bodyLength returns the length a body of the given size is truncated to in a record.
+func (l *Logs) bodyLength(size int) int {
+	if l.maxBodyBytes == 0 {
+		return size
+	}
+	return util.Min(size, l.maxBodyBytes)
+}
+
+func (l *Logs) writeRecord(rec record) {
+	l.writeRecords([]record{rec})
+}
+
+// writeRecords writes the records to the log file at once, produces them to kafka if
+// configured, and sends them to the clients of the logs stream.
+func (l *Logs) writeRecords(recs []record) {
+	var lines bytes.Buffer
+	for _, rec := range recs {
+		l.streams.publish(rec)
+		if l.es != nil {
+			l.es.indexRecord(context.Background(), rec)
+		}
+		if l.kafka != nil {
+			l.kafka.write(rec)
+			if l.kafkaOnly {
+				continue
+			}
+		}
+		marshalledLog, err := json.Marshal(rec)
+		if err != nil {
+			log.Errorln(logTag, "error encountered while marshalling record :", err)
+			continue
+		}
+		lines.Write(marshalledLog)
+		// Add new line character so filebeat can sync it with ES
+		lines.WriteByte('\n')
+	}
+	if lines.Len() == 0 {
 		return
 	}
-	n, err := l.lumberjack.Write(marshalledLog)
+	n, err := l.lumberjack.Write(lines.Bytes())
 	if err != nil {
 		log.Errorln(logTag, "error encountered while writing logs :", err)
 		return
 	}
-	// Add new line character so filebeat can sync it with ES
-	l.lumberjack.Write([]byte("\n"))
-	log.Println(logTag, "logged request successfully", n)
+	log.Println(logTag, "logged", len(recs), "records successfully", n)
+}
+
+// decompress returns the decompressed body and its compression ratio if the body is
+// compressed, otherwise the body as is. The record is flagged if the body expands beyond
+// the maximum compression ratio, in which case the body isn't recorded.
+func (l *Logs) decompress(rec *record, body []byte, encoding string) ([]byte, float64) {
+	maxRatio := l.maxCompressionRatio
+	if maxRatio <= 0 {
+		maxRatio = defaultMaxCompressionRatio
+	}
+	limit := l.maxBodyBytes
+	if limit == 0 {
+		// the body is recorded in full, its expansion is bounded by the ratio alone
+		limit = math.MaxInt32
+	}
+	result, err := decompressBody(body, encoding, limit, maxRatio)
+	if err != nil {
+		log.Errorln(logTag, ": unable to decompress the body:", err)
+		return body, 0
+	}
+	if result == nil {
+		return body, 0
+	}
+	if result.possibleBomb {
+		if rec.Flags == nil {
+			rec.Flags = &Flags{}
+		}
+		rec.Flags.PossibleZipBomb = true
+	}
+	return result.body, result.ratio
+}
+
+// milliseconds returns the duration in fractional milliseconds.
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func isBulkRequest(r *http.Request) bool {
+	return strings.HasSuffix(strings.TrimSuffix(r.URL.Path, "/"), "/_bulk")
+}
+
+// chunkRecord splits the request body across multiple copies of the record, linked
+// by a shared request id. The body is split at line boundaries where possible so
+// that each chunk holds complete bulk actions.
+func chunkRecord(rec record, body []byte, size int) []record {
+	requestID := rec.DocumentID
+	if requestID == "" {
+		requestID = util.RandStr()
+	}
+	chunks := splitLines(body, size)
+	records := make([]record, len(chunks))
+	for i, chunk := range chunks {
+		records[i] = rec
+		records[i].Request.Body = string(chunk)
+		records[i].Chunk = &Chunk{
+			RequestID: requestID,
+			Index:     i,
+			Total:     len(chunks),
+		}
+		if rec.DocumentID != "" {
+			records[i].DocumentID = fmt.Sprintf("%s-%d", rec.DocumentID, i)
+		}
+		// the response is only recorded once
+		if i > 0 {
+			records[i].Response.Body = ""
+		}
+	}
+	return records
+}
+
+// splitLines splits the body into chunks of at most size bytes, breaking at
+// newlines unless a single line is larger than size.
+func splitLines(body []byte, size int) [][]byte {
+	var chunks [][]byte
+	for len(body) > size {
+		end := bytes.LastIndexByte(body[:size], '\n') + 1
+		if end == 0 {
+			end = size
+		}
+		chunks = append(chunks, body[:end])
+		body = body[end:]
+	}
+	if len(body) > 0 {
+		chunks = append(chunks, body)
+	}
+	return chunks
+}
+
+// tlsVersion returns the negotiated TLS version of the request. Requests behind a
+// TLS terminating proxy rely on the X-Forwarded-Tls-Version header instead.
+func tlsVersion(r *http.Request) string {
+	if r.TLS == nil {
+		return r.Header.Get("X-Forwarded-Tls-Version")
+	}
+	switch r.TLS.Version {
+	case tls.VersionTLS10:
+		return "TLS 1.0"
+	case tls.VersionTLS11:
+		return "TLS 1.1"
+	case tls.VersionTLS12:
+		return "TLS 1.2"
+	case tls.VersionTLS13:
+		return "TLS 1.3"
+	default:
+		return fmt.Sprintf("0x%04x", r.TLS.Version)
+	}
+}
+
+// ipHeaders are the request headers that carry client IPs.
+var ipHeaders = []string{"X-Forwarded-For", "X-Real-Ip"}
+
+// anonymizeIPHeaders replaces the IPs in the recorded headers with their anonymized values.
+func anonymizeIPHeaders(headers map[string][]string) {
+	for _, key := range ipHeaders {
+		values, ok := headers[key]
+		if !ok {
+			continue
+		}
+		anonymized := make([]string, len(values))
+		for i, value := range values {
+			addresses := strings.Split(value, ",")
+			for j, address := range addresses {
+				addresses[j] = iplookup.Anonymize(strings.TrimSpace(address))
+			}
+			anonymized[i] = strings.Join(addresses, ", ")
+		}
+		headers[key] = anonymized
+	}
 }
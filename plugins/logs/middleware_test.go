@@ -0,0 +1,1197 @@
+package logs
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/appbaseio/reactivesearch-api/middleware/validate"
+	"github.com/appbaseio/reactivesearch-api/model/authmethod"
+	"github.com/appbaseio/reactivesearch-api/model/category"
+	"github.com/appbaseio/reactivesearch-api/model/credential"
+	"github.com/appbaseio/reactivesearch-api/model/decision"
+	"github.com/appbaseio/reactivesearch-api/model/index"
+	"github.com/appbaseio/reactivesearch-api/model/op"
+	"github.com/appbaseio/reactivesearch-api/model/permission"
+	"github.com/appbaseio/reactivesearch-api/model/queuetime"
+	"github.com/appbaseio/reactivesearch-api/model/request"
+	"github.com/appbaseio/reactivesearch-api/model/timing"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// newTestLogs returns a Logs instance that writes its records to a temporary file.
+func newTestLogs(t *testing.T) *Logs {
+	l := &Logs{maxBodyBytes: defaultMaxBodyBytes}
+	l.lumberjack.Filename = filepath.Join(t.TempDir(), "es.json")
+	return l
+}
+
+// newTestRequest returns a search request with the category and indices set in its context.
+func newTestRequest(method, target, body string) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	reqCategory := category.Search
+	ctx := category.NewContext(req.Context(), &reqCategory)
+	ctx = index.NewContext(ctx, []string{"books"})
+	return req.WithContext(ctx)
+}
+
+// recordTestResponse records the request along with a response of the given
+// status code and body, and returns the written record.
+func recordTestResponse(t *testing.T, l *Logs, req *http.Request, code int, body string) record {
+	dump, err := httputil.DumpRequest(req, true)
+	if err != nil {
+		t.Fatalf("unable to dump request: %v", err)
+	}
+	w := httptest.NewRecorder()
+	w.WriteHeader(code)
+	w.WriteString(body)
+	l.recordResponse(w, req, dump, time.Millisecond, "")
+
+	records := readTestRecords(t, l)
+	if len(records) == 0 {
+		t.Fatalf("no record was written")
+	}
+	return records[len(records)-1]
+}
+
+func readTestRecords(t *testing.T, l *Logs) []record {
+	f, err := os.Open(l.lumberjack.Filename)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatalf("unable to open log file: %v", err)
+	}
+	defer f.Close()
+
+	var records []record
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var rec record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			t.Fatalf("unable to parse record: %v", err)
+		}
+		records = append(records, rec)
+	}
+	return records
+}
+
+// waitForTestRecords waits for the asynchronously recorded records to be written.
+func waitForTestRecords(t *testing.T, l *Logs, n int) []record {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		records := readTestRecords(t, l)
+		if len(records) >= n {
+			return records
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d records, found %d", n, len(records))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestDropResponseBody(t *testing.T) {
+	Convey("Drop response body by status", t, func() {
+		l := newTestLogs(t)
+		var err error
+		l.dropBodyStatus, err = parseStatusRanges("2xx")
+		So(err, ShouldBeNil)
+
+		Convey("Drops the body of a 2xx response", func() {
+			rec := recordTestResponse(t, l, newTestRequest("POST", "/books/_search", `{}`), http.StatusOK, `{"took":1}`)
+			So(rec.Response.Code, ShouldEqual, http.StatusOK)
+			So(rec.Response.Body, ShouldBeEmpty)
+		})
+		Convey("Keeps the body of a 4xx response", func() {
+			rec := recordTestResponse(t, l, newTestRequest("POST", "/books/_search", `{}`), http.StatusBadRequest, `{"error":"bad"}`)
+			So(rec.Response.Code, ShouldEqual, http.StatusBadRequest)
+			So(rec.Response.Body, ShouldEqual, `{"error":"bad"}`)
+		})
+	})
+}
+
+func TestParseStatusRanges(t *testing.T) {
+	Convey("Parse status ranges", t, func() {
+		ranges, err := parseStatusRanges("2xx, 404,500-503")
+		So(err, ShouldBeNil)
+		So(ranges, ShouldResemble, []statusRange{{200, 299}, {404, 404}, {500, 503}})
+		So(matchesStatus(ranges, 201), ShouldBeTrue)
+		So(matchesStatus(ranges, 404), ShouldBeTrue)
+		So(matchesStatus(ranges, 504), ShouldBeFalse)
+
+		_, err = parseStatusRanges("5xy")
+		So(err, ShouldNotBeNil)
+		_, err = parseStatusRanges("503-500")
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestDocumentID(t *testing.T) {
+	Convey("Document id", t, func() {
+		Convey("Same idempotency key produces the same id", func() {
+			l := newTestLogs(t)
+			req1 := newTestRequest("POST", "/books/_doc", `{"title":"a"}`)
+			req1.Header.Set("Idempotency-Key", "key-1")
+			req2 := newTestRequest("POST", "/books/_doc", `{"title":"a"}`)
+			req2.Header.Set("Idempotency-Key", "key-1")
+
+			rec1 := recordTestResponse(t, l, req1, http.StatusCreated, `{}`)
+			rec2 := recordTestResponse(t, l, req2, http.StatusCreated, `{}`)
+			So(rec1.DocumentID, ShouldNotBeEmpty)
+			So(rec1.DocumentID, ShouldEqual, rec2.DocumentID)
+		})
+		Convey("Different keys produce different ids", func() {
+			req1 := newTestRequest("POST", "/books/_doc", `{}`)
+			req1.Header.Set("X-Request-Id", "1")
+			req2 := newTestRequest("POST", "/books/_doc", `{}`)
+			req2.Header.Set("X-Request-Id", "2")
+			So(documentID(req1), ShouldNotEqual, documentID(req2))
+		})
+		Convey("Falls back to an auto id without a key", func() {
+			So(documentID(newTestRequest("POST", "/books/_doc", `{}`)), ShouldBeEmpty)
+		})
+	})
+}
+
+func TestRecordTLS(t *testing.T) {
+	Convey("Record TLS info", t, func() {
+		l := newTestLogs(t)
+		l.recordTLS = true
+
+		Convey("Captures the TLS version of a TLS request", func() {
+			req := newTestRequest("GET", "https://localhost/books/_search", "")
+			req.TLS = &tls.ConnectionState{Version: tls.VersionTLS12}
+			rec := recordTestResponse(t, l, req, http.StatusOK, `{}`)
+			So(rec.Request.Protocol, ShouldEqual, "HTTP/1.1")
+			So(rec.Request.TLSVersion, ShouldEqual, "TLS 1.2")
+		})
+		Convey("Leaves the TLS version empty for a plain request", func() {
+			rec := recordTestResponse(t, l, newTestRequest("GET", "/books/_search", ""), http.StatusOK, `{}`)
+			So(rec.Request.Protocol, ShouldEqual, "HTTP/1.1")
+			So(rec.Request.TLSVersion, ShouldBeEmpty)
+		})
+		Convey("Uses the forwarded TLS version behind a proxy", func() {
+			req := newTestRequest("GET", "/books/_search", "")
+			req.Header.Set("X-Forwarded-Tls-Version", "TLS 1.3")
+			rec := recordTestResponse(t, l, req, http.StatusOK, `{}`)
+			So(rec.Request.TLSVersion, ShouldEqual, "TLS 1.3")
+		})
+	})
+}
+
+func TestAnonymizeIP(t *testing.T) {
+	Convey("Anonymize client IPs", t, func() {
+		l := newTestLogs(t)
+		l.anonymizeIP = true
+
+		Convey("Masks an IPv4 client", func() {
+			req := newTestRequest("GET", "/books/_search", "")
+			req.Header.Set("X-Forwarded-For", "203.0.113.195, 10.0.0.1")
+			rec := recordTestResponse(t, l, req, http.StatusOK, `{}`)
+			So(rec.Request.ClientIP, ShouldEqual, "203.0.113.0")
+			So(rec.Request.Headers["X-Forwarded-For"], ShouldResemble, []string{"203.0.113.0, 10.0.0.0"})
+			// the request itself must not be modified
+			So(req.Header.Get("X-Forwarded-For"), ShouldEqual, "203.0.113.195, 10.0.0.1")
+		})
+		Convey("Masks an IPv6 client", func() {
+			req := newTestRequest("GET", "/books/_search", "")
+			req.RemoteAddr = "[2001:db8:85a3:8d3:1319:8a2e:370:7348]:443"
+			rec := recordTestResponse(t, l, req, http.StatusOK, `{}`)
+			So(rec.Request.ClientIP, ShouldEqual, "2001:db8:85a3::")
+		})
+	})
+}
+
+func TestRecordESQuery(t *testing.T) {
+	Convey("Record the generated es query", t, func() {
+		l := newTestLogs(t)
+		l.recordESQuery = true
+
+		req := httptest.NewRequest("POST", "/books/_reactivesearch.v3", nil)
+		reqCategory := category.ReactiveSearch
+		ctx := category.NewContext(req.Context(), &reqCategory)
+		ctx = index.NewContext(ctx, []string{"books"})
+		ctx = request.NewContext(ctx, map[string]interface{}{"query": []interface{}{}})
+		req = req.WithContext(ctx)
+
+		Convey("The recorder makes the es query holder available down the chain", func() {
+			var esQuery *request.ESQuery
+			l.recorder(func(w http.ResponseWriter, r *http.Request) {
+				esQuery, _ = request.ESQueryFromContext(r.Context())
+			})(httptest.NewRecorder(), req)
+			So(esQuery, ShouldNotBeNil)
+			// wait for the async record so that the temp dir can be cleaned up
+			waitForTestRecords(t, l, 1)
+		})
+		Convey("The es query is recorded for a reactivesearch request", func() {
+			query := `{"preference":"search"}` + "\n" + `{"query":{"match_all":{}}}` + "\n"
+			req = req.WithContext(request.NewESQueryContext(req.Context(), &request.ESQuery{Query: query}))
+			rec := recordTestResponse(t, l, req, http.StatusOK, `{"settings":{"took":1}}`)
+			So(rec.Request.ESQuery, ShouldEqual, query)
+		})
+	})
+}
+
+func TestPreserveJSONNumbers(t *testing.T) {
+	Convey("Record the large integers of the reactivesearch requests exactly", t, func() {
+		l := newTestLogs(t)
+		raw := []byte(`{"query":[{"id":"search","dataField":"title","value":9007199254740993}]}`)
+		req := httptest.NewRequest("POST", "/books/_reactivesearch.v3", nil)
+		reqCategory := category.ReactiveSearch
+		ctx := category.NewContext(req.Context(), &reqCategory)
+		ctx = index.NewContext(ctx, []string{"books"})
+		// the parsed body holds the numbers as floats
+		var parsed interface{}
+		So(json.Unmarshal(raw, &parsed), ShouldBeNil)
+		ctx = request.NewContext(ctx, parsed)
+		req = req.WithContext(request.NewRawBodyContext(ctx, raw))
+
+		Convey("A large integer id round-trips without precision loss", func() {
+			l.preserveNumbers = true
+			rec := recordTestResponse(t, l, req, http.StatusOK, `{"settings":{"took":1}}`)
+			So(rec.Request.Body, ShouldContainSubstring, `"value":9007199254740993`)
+		})
+		Convey("The parsed body is recorded by default", func() {
+			rec := recordTestResponse(t, l, req, http.StatusOK, `{"settings":{"took":1}}`)
+			So(rec.Request.Body, ShouldNotContainSubstring, "9007199254740993")
+		})
+	})
+}
+
+func TestRecordRSComponents(t *testing.T) {
+	Convey("Record the query components of the reactivesearch requests", t, func() {
+		l := newTestLogs(t)
+		l.recordRSComponents = true
+		newRSRequest := func(body interface{}) *http.Request {
+			req := httptest.NewRequest("POST", "/books/_reactivesearch.v3", nil)
+			reqCategory := category.ReactiveSearch
+			ctx := category.NewContext(req.Context(), &reqCategory)
+			ctx = index.NewContext(ctx, []string{"books"})
+			return req.WithContext(request.NewContext(ctx, body))
+		}
+
+		Convey("Mixed component types are counted per type", func() {
+			req := newRSRequest(map[string]interface{}{"query": []interface{}{
+				map[string]interface{}{"id": "search", "dataField": "title"},
+				map[string]interface{}{"id": "author", "type": "term", "dataField": "author"},
+				map[string]interface{}{"id": "genre", "type": "term", "dataField": "genre"},
+				map[string]interface{}{"id": "price", "type": "range", "dataField": "price"},
+				map[string]interface{}{"id": "location", "type": "geo", "dataField": "location"},
+				map[string]interface{}{"id": "result", "type": "search", "react": map[string]interface{}{"and": "search"}},
+			}})
+			rec := recordTestResponse(t, l, req, http.StatusOK, `{"settings":{"took":1}}`)
+			So(rec.Request.RSComponents, ShouldResemble, &RSComponents{
+				Count: 6,
+				Types: map[string]int{"search": 2, "term": 2, "range": 1, "geo": 1},
+			})
+		})
+		Convey("A request without components records none", func() {
+			rec := recordTestResponse(t, l, newRSRequest(map[string]interface{}{"query": []interface{}{}}), http.StatusOK, `{}`)
+			So(rec.Request.RSComponents, ShouldBeNil)
+		})
+	})
+}
+
+func TestCacheHeaders(t *testing.T) {
+	Convey("Record the caching headers of the responses", t, func() {
+		l := newTestLogs(t)
+		record := func(header map[string]string) record {
+			req := newTestRequest("GET", "/books/_search", "")
+			dump, err := httputil.DumpRequest(req, true)
+			So(err, ShouldBeNil)
+			w := httptest.NewRecorder()
+			for name, value := range header {
+				w.Header().Set(name, value)
+			}
+			w.WriteHeader(http.StatusOK)
+			l.recordResponse(w, req, dump, time.Millisecond, "")
+			records := readTestRecords(t, l)
+			return records[len(records)-1]
+		}
+
+		Convey("Cacheable response", func() {
+			rec := record(map[string]string{"Cache-Control": "public, max-age=60", "ETag": `"abc"`, "Age": "12"})
+			So(rec.Response.Cache, ShouldNotBeNil)
+			So(rec.Response.Cache.CacheControl, ShouldEqual, "public, max-age=60")
+			So(rec.Response.Cache.ETag, ShouldEqual, `"abc"`)
+			So(*rec.Response.Cache.MaxAge, ShouldEqual, 60)
+			So(*rec.Response.Cache.Age, ShouldEqual, 12)
+			So(rec.Response.Cache.Cacheable, ShouldBeTrue)
+		})
+		Convey("Shared max age takes precedence", func() {
+			rec := record(map[string]string{"Cache-Control": "max-age=0, s-maxage=300"})
+			So(*rec.Response.Cache.MaxAge, ShouldEqual, 300)
+			So(rec.Response.Cache.Cacheable, ShouldBeTrue)
+		})
+		Convey("Non cacheable response", func() {
+			rec := record(map[string]string{"Cache-Control": "no-store"})
+			So(rec.Response.Cache.CacheControl, ShouldEqual, "no-store")
+			So(rec.Response.Cache.Cacheable, ShouldBeFalse)
+			rec = record(map[string]string{"Cache-Control": "max-age=0"})
+			So(rec.Response.Cache.Cacheable, ShouldBeFalse)
+		})
+		Convey("Response without caching headers", func() {
+			So(record(nil).Response.Cache, ShouldBeNil)
+		})
+	})
+}
+
+func TestRolloverWrites(t *testing.T) {
+	Convey("Writes during the rollover windows", t, func() {
+		Reset(func() {
+			rollovers.configure("", defaultRolloverGrace)
+			rollovers.closing = make(map[string]time.Time)
+		})
+		l := newTestLogs(t)
+		timer := &queuetime.Timer{}
+		serve := func(reqOp op.Operation, indexName string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodPost, "/"+indexName+"/_doc", nil)
+			ctx := op.NewContext(req.Context(), &reqOp)
+			ctx = queuetime.NewContext(ctx, timer)
+			ctx = index.NewContext(ctx, []string{indexName})
+			w := httptest.NewRecorder()
+			l.rolloverWrites(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})(w, req.WithContext(ctx))
+			return w
+		}
+
+		Convey("Writes aren't held off without a policy", func() {
+			rollovers.begin(".logs")
+			So(serve(op.Write, ".logs").Code, ShouldEqual, http.StatusOK)
+		})
+		Convey("Reject policy", func() {
+			rollovers.configure(rolloverPolicyReject, 0)
+			rollovers.begin(".logs")
+
+			Convey("Writes to the alias and its indices are rejected", func() {
+				w := serve(op.Write, ".logs")
+				So(w.Code, ShouldEqual, http.StatusServiceUnavailable)
+				So(w.Header().Get("Retry-After"), ShouldNotBeEmpty)
+				So(serve(op.Write, ".logs-000002").Code, ShouldEqual, http.StatusServiceUnavailable)
+			})
+			Convey("Reads and the other aliases aren't held off", func() {
+				So(serve(op.Read, ".logs").Code, ShouldEqual, http.StatusOK)
+				So(serve(op.Write, ".logs-search").Code, ShouldEqual, http.StatusOK)
+				So(serve(op.Write, "books").Code, ShouldEqual, http.StatusOK)
+			})
+			Convey("Writes resume after the rollover", func() {
+				rollovers.end(".logs")
+				So(serve(op.Write, ".logs").Code, ShouldEqual, http.StatusOK)
+			})
+		})
+		Convey("Buffer policy holds the writes until the rollover is done", func() {
+			rollovers.configure(rolloverPolicyBuffer, 50*time.Millisecond)
+			rollovers.begin(".logs")
+			done := make(chan time.Time, 1)
+			go func() {
+				serve(op.Write, ".logs")
+				done <- time.Now()
+			}()
+			time.Sleep(50 * time.Millisecond)
+			ended := time.Now()
+			rollovers.end(".logs")
+			So((<-done).After(ended.Add(50*time.Millisecond)), ShouldBeTrue)
+			// the hold is recorded as the queue time of the write
+			So(timer.Duration(), ShouldBeGreaterThanOrEqualTo, 50*time.Millisecond)
+			So(serve(op.Write, ".logs").Code, ShouldEqual, http.StatusOK)
+		})
+	})
+}
+
+func TestCaptureContentTypes(t *testing.T) {
+	Convey("Capture the bodies by their content type", t, func() {
+		l := newTestLogs(t)
+		withContentType := func(body, contentType string) *http.Request {
+			req := newTestRequest("POST", "/books/_doc", body)
+			req.Header.Set("Content-Type", contentType)
+			return req
+		}
+
+		Convey("A JSON body is captured", func() {
+			rec := recordTestResponse(t, l, withContentType(`{"title":"go"}`, "application/json; charset=UTF-8"), http.StatusOK, `{}`)
+			So(rec.Request.Body, ShouldEqual, `{"title":"go"}`)
+		})
+		Convey("A text body is captured", func() {
+			rec := recordTestResponse(t, l, withContentType("title: go", "text/plain"), http.StatusOK, `{}`)
+			So(rec.Request.Body, ShouldEqual, "title: go")
+		})
+		Convey("An image body is replaced with the placeholder", func() {
+			rec := recordTestResponse(t, l, withContentType("\x89PNG\r\n", "image/png"), http.StatusOK, `{}`)
+			So(rec.Request.Body, ShouldEqual, binaryBodyPlaceholder)
+		})
+		Convey("An octet-stream body is replaced with the placeholder", func() {
+			rec := recordTestResponse(t, l, withContentType("\x00\x01\x02", "application/octet-stream"), http.StatusOK, `{}`)
+			So(rec.Request.Body, ShouldEqual, binaryBodyPlaceholder)
+		})
+		Convey("The response body is judged by its own content type", func() {
+			w := httptest.NewRecorder()
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.WriteHeader(http.StatusOK)
+			w.WriteString("\x00\x01")
+			req := withContentType(`{"title":"go"}`, "application/json")
+			dump, _ := httputil.DumpRequest(req, true)
+			l.recordResponse(w, req, dump, time.Millisecond, "")
+			rec := readTestRecords(t, l)[0]
+			So(rec.Request.Body, ShouldEqual, `{"title":"go"}`)
+			So(rec.Response.Body, ShouldEqual, binaryBodyPlaceholder)
+		})
+		Convey("The allowlist is configurable", func() {
+			var err error
+			l.captureContentTypes, err = parseContentTypes("application/json, image/*")
+			So(err, ShouldBeNil)
+			rec := recordTestResponse(t, l, withContentType("<svg/>", "image/svg+xml"), http.StatusOK, `{}`)
+			So(rec.Request.Body, ShouldEqual, "<svg/>")
+			rec = recordTestResponse(t, l, withContentType("title: go", "text/plain"), http.StatusOK, `{}`)
+			So(rec.Request.Body, ShouldEqual, binaryBodyPlaceholder)
+
+			_, err = parseContentTypes("json")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestIndexNormalization(t *testing.T) {
+	Convey("Normalize the recorded index names", t, func() {
+		l := newTestLogs(t)
+		var err error
+		l.indexNormalizations, err = parseIndexNormalizations(`[{"pattern": "-\\d{4}\\.\\d{2}\\.\\d{2}$", "replacement": "-*"}]`)
+		So(err, ShouldBeNil)
+		record := func(indices ...string) record {
+			req := newTestRequest("POST", "/_search", `{}`)
+			req = req.WithContext(index.NewContext(req.Context(), indices))
+			return recordTestResponse(t, l, req, http.StatusOK, `{}`)
+		}
+
+		Convey("Dated index is normalized while its raw name is preserved", func() {
+			rec := record("logs-2024.01.01")
+			So(rec.Indices, ShouldResemble, []string{"logs-*"})
+			So(rec.RawIndices, ShouldResemble, []string{"logs-2024.01.01"})
+		})
+		Convey("Indices normalized to the same name are grouped", func() {
+			rec := record("logs-2024.01.01", "logs-2024.01.02", "books")
+			So(rec.Indices, ShouldResemble, []string{"logs-*", "books"})
+			So(rec.RawIndices, ShouldResemble, []string{"logs-2024.01.01", "logs-2024.01.02", "books"})
+		})
+		Convey("Unmatched index is recorded as is", func() {
+			rec := record("books")
+			So(rec.Indices, ShouldResemble, []string{"books"})
+			So(rec.RawIndices, ShouldBeNil)
+		})
+		Convey("Invalid rules are rejected", func() {
+			_, err := parseIndexNormalizations(`[{"pattern": "("}]`)
+			So(err, ShouldNotBeNil)
+			_, err = parseIndexNormalizations(`[{"replacement": "-*"}]`)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestResolvedIndex(t *testing.T) {
+	Convey("Record the concrete index a write landed in", t, func() {
+		l := newTestLogs(t)
+		write := func(target, body, response string) record {
+			req := newTestRequest("POST", target, body)
+			reqOp := op.Write
+			req = req.WithContext(op.NewContext(req.Context(), &reqOp))
+			return recordTestResponse(t, l, req, http.StatusCreated, response)
+		}
+
+		Convey("A write through an alias records the concrete index", func() {
+			rec := write("/books/_doc", `{"title":"go"}`, `{"_index":"books-000002","_id":"1","result":"created"}`)
+			So(rec.Indices, ShouldResemble, []string{"books"})
+			So(rec.Response.ResolvedIndex, ShouldEqual, "books-000002")
+		})
+		Convey("A bulk write into a single index records it", func() {
+			rec := write("/_bulk", "", `{"took":1,"errors":false,"items":[`+
+				`{"index":{"_index":"books-000002","_id":"1"}},{"update":{"_index":"books-000002","_id":"2"}}]}`)
+			So(rec.Response.ResolvedIndex, ShouldEqual, "books-000002")
+		})
+		Convey("A bulk write into multiple indices records none", func() {
+			rec := write("/_bulk", "", `{"took":1,"errors":false,"items":[`+
+				`{"index":{"_index":"books-000002","_id":"1"}},{"index":{"_index":"movies","_id":"2"}}]}`)
+			So(rec.Response.ResolvedIndex, ShouldBeEmpty)
+		})
+		Convey("Reads don't record the index", func() {
+			rec := recordTestResponse(t, l, newTestRequest("GET", "/books/_doc/1", ""), http.StatusOK, `{"_index":"books-000002","_id":"1"}`)
+			So(rec.Response.ResolvedIndex, ShouldBeEmpty)
+		})
+	})
+}
+
+func TestBulkErrors(t *testing.T) {
+	Convey("Record the item-level failures of the bulk requests", t, func() {
+		l := newTestLogs(t)
+
+		Convey("A bulk response with mixed results records the failures", func() {
+			rec := recordTestResponse(t, l, newTestRequest("POST", "/_bulk", ""), http.StatusOK, `{"took":3,"errors":true,"items":[`+
+				`{"index":{"_index":"books","_id":"1","status":201}},`+
+				`{"create":{"_index":"books","_id":"2","status":409,"error":{"type":"version_conflict_engine_exception","reason":"[2]: version conflict"}}},`+
+				`{"create":{"_index":"books","_id":"2","status":409,"error":{"type":"version_conflict_engine_exception","reason":"[2]: version conflict"}}},`+
+				`{"update":{"_index":"books","_id":"3","status":404,"error":{"type":"document_missing_exception","reason":"[3]: document missing"}}}]}`)
+			So(rec.Response.Code, ShouldEqual, http.StatusOK)
+			So(rec.Response.BulkErrors, ShouldResemble, &BulkErrors{
+				Succeeded: 1,
+				Failed:    3,
+				Reasons: []string{
+					"version_conflict_engine_exception: [2]: version conflict",
+					"document_missing_exception: [3]: document missing",
+				},
+			})
+		})
+		Convey("A successful bulk response records no reasons", func() {
+			rec := recordTestResponse(t, l, newTestRequest("POST", "/books/_bulk", ""), http.StatusOK, `{"took":1,"errors":false,"items":[`+
+				`{"index":{"_index":"books","_id":"1","status":201}},{"delete":{"_index":"books","_id":"2","status":200}}]}`)
+			So(rec.Response.BulkErrors, ShouldResemble, &BulkErrors{Succeeded: 2})
+		})
+		Convey("The sample of the reasons is bounded", func() {
+			items := make([]string, 0, maxBulkErrorReasons+2)
+			for i := 0; i < maxBulkErrorReasons+2; i++ {
+				items = append(items, fmt.Sprintf(`{"index":{"status":400,"error":{"type":"mapper_parsing_exception","reason":"failed to parse field [f%d]"}}}`, i))
+			}
+			rec := recordTestResponse(t, l, newTestRequest("POST", "/_bulk", ""), http.StatusOK, `{"errors":true,"items":[`+strings.Join(items, ",")+`]}`)
+			So(rec.Response.BulkErrors.Failed, ShouldEqual, maxBulkErrorReasons+2)
+			So(rec.Response.BulkErrors.Reasons, ShouldHaveLength, maxBulkErrorReasons)
+		})
+		Convey("The other requests don't record bulk errors", func() {
+			rec := recordTestResponse(t, l, newTestRequest("POST", "/books/_doc", ""), http.StatusCreated, `{"_index":"books","_id":"1"}`)
+			So(rec.Response.BulkErrors, ShouldBeNil)
+		})
+	})
+}
+
+func TestChunkBulk(t *testing.T) {
+	Convey("Chunk large bulk requests", t, func() {
+		l := newTestLogs(t)
+		l.chunkBulk = true
+
+		// 25000 lines of 100 bytes, i.e. 2.5MB
+		line := `{"index":{"_index":"books"}}` + strings.Repeat(" ", 71) + "\n"
+		body := strings.Repeat(line, 25000)
+
+		Convey("Splits the body into records sharing a request id", func() {
+			req := newTestRequest("POST", "/_bulk", body)
+			recordTestResponse(t, l, req, http.StatusOK, `{"errors":false}`)
+			records := readTestRecords(t, l)
+			So(len(records), ShouldEqual, 3)
+
+			var recovered string
+			for i, rec := range records {
+				So(rec.Chunk, ShouldNotBeNil)
+				So(rec.Chunk.RequestID, ShouldEqual, records[0].Chunk.RequestID)
+				So(rec.Chunk.Index, ShouldEqual, i)
+				So(rec.Chunk.Total, ShouldEqual, 3)
+				recovered += rec.Request.Body
+			}
+			So(recovered, ShouldEqual, body)
+		})
+		Convey("Doesn't split small bulk requests", func() {
+			req := newTestRequest("POST", "/_bulk", line)
+			rec := recordTestResponse(t, l, req, http.StatusOK, `{"errors":false}`)
+			So(rec.Chunk, ShouldBeNil)
+			So(len(readTestRecords(t, l)), ShouldEqual, 1)
+		})
+	})
+}
+
+func TestSynchronous(t *testing.T) {
+	Convey("Synchronous recording", t, func() {
+		l := newTestLogs(t)
+		l.synchronous = true
+
+		w := httptest.NewRecorder()
+		l.recorder(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"took":1}`))
+		})(w, newTestRequest("POST", "/books/_search", `{}`))
+
+		// the record is persisted by the time the handler returns
+		records := readTestRecords(t, l)
+		So(len(records), ShouldEqual, 1)
+		So(records[0].Response.Body, ShouldEqual, `{"took":1}`)
+		So(w.Body.String(), ShouldEqual, `{"took":1}`)
+	})
+}
+
+func TestIndexRecords(t *testing.T) {
+	Convey("The recorded requests are indexed into the logs alias", t, func() {
+		server := useTestES(t, "")
+		l := newTestLogs(t)
+
+		Convey("Into the logs alias", func() {
+			l.es = &elasticsearch{indexName: ".logs"}
+			recordTestResponse(t, l, newTestRequest("POST", "/books/_search", `{}`), http.StatusOK, `{"took":1}`)
+			So(server.indexed, ShouldResemble, []string{".logs"})
+		})
+		Convey("Into the category alias", func() {
+			es := &elasticsearch{indexName: ".logs", indexPerCategory: true}
+			es.categoryAliases.Store(".logs-search", true)
+			l.es = es
+			recordTestResponse(t, l, newTestRequest("POST", "/books/_search", `{}`), http.StatusOK, `{"took":1}`)
+			So(server.indexed, ShouldResemble, []string{".logs-search"})
+		})
+		Convey("The records are still written to the log file", func() {
+			l.es = &elasticsearch{indexName: ".logs"}
+			rec := recordTestResponse(t, l, newTestRequest("POST", "/books/_search", `{}`), http.StatusOK, `{"took":1}`)
+			So(rec.Indices, ShouldResemble, []string{"books"})
+		})
+	})
+}
+
+func TestContentLength(t *testing.T) {
+	Convey("Record content length", t, func() {
+		l := newTestLogs(t)
+
+		Convey("Records the declared content length", func() {
+			req := newTestRequest("POST", "/books/_search", `{"query":{}}`)
+			req.Header.Set("Content-Length", "12")
+			rec := recordTestResponse(t, l, req, http.StatusOK, `{}`)
+			So(rec.Request.ContentLength, ShouldEqual, 12)
+			So(rec.Request.Chunked, ShouldBeFalse)
+		})
+		Convey("Records the chunked transfer encoding", func() {
+			req := newTestRequest("POST", "/books/_search", `{"query":{}}`)
+			req.ContentLength = -1
+			req.TransferEncoding = []string{"chunked"}
+			rec := recordTestResponse(t, l, req, http.StatusOK, `{}`)
+			So(rec.Request.ContentLength, ShouldEqual, -1)
+			So(rec.Request.Chunked, ShouldBeTrue)
+		})
+	})
+}
+
+func TestMetadataOnlyIndices(t *testing.T) {
+	Convey("Record only the metadata of sensitive indices", t, func() {
+		l := newTestLogs(t)
+		var err error
+		l.metadataOnlyIndices, err = parseIndexPatterns("customers-*, users")
+		So(err, ShouldBeNil)
+
+		Convey("Omits the bodies of a request to a listed index", func() {
+			req := newTestRequest("POST", "/customers-eu/_search", `{"query":{"term":{"email":"jane@example.com"}}}`)
+			req = req.WithContext(index.NewContext(req.Context(), []string{"customers-eu"}))
+			rec := recordTestResponse(t, l, req, http.StatusOK, `{"took":3,"hits":{}}`)
+			So(rec.Request.Method, ShouldEqual, "POST")
+			So(rec.Request.URI, ShouldEqual, "/customers-eu/_search")
+			So(rec.Response.Code, ShouldEqual, http.StatusOK)
+			So(*rec.Response.Took, ShouldEqual, 3)
+			So(rec.Request.Body, ShouldBeEmpty)
+			So(rec.Request.BodyHash, ShouldBeEmpty)
+			So(rec.Request.ComplexityScore, ShouldBeNil)
+			So(rec.Response.Body, ShouldBeEmpty)
+		})
+		Convey("Omits the bodies of a bulk request to a listed index", func() {
+			body := "{\"delete\":{\"_index\":\"books\",\"_id\":\"1\"}}\n{\"index\":{\"_index\":\"users\"}}\n{\"email\":\"jane@example.com\"}\n"
+			rec := recordTestResponse(t, l, newTestRequest("POST", "/_bulk", body), http.StatusOK,
+				`{"took":3,"errors":true,"items":[{"index":{"status":400,"error":{"type":"mapper_parsing_exception","reason":"jane@example.com"}}}]}`)
+			So(rec.Request.Body, ShouldBeEmpty)
+			So(rec.Response.BulkErrors, ShouldBeNil)
+		})
+		Convey("Omits the bodies of a msearch request to a listed index", func() {
+			body := "{\"index\":[\"books\",\"customers-eu\"]}\n{\"query\":{}}\n"
+			rec := recordTestResponse(t, l, newTestRequest("POST", "/_msearch", body), http.StatusOK, `{"took":3}`)
+			So(rec.Request.Body, ShouldBeEmpty)
+		})
+		Convey("Omits the bodies of a mget request to a listed index", func() {
+			body := `{"docs":[{"_index":"users","_id":"1"}]}`
+			rec := recordTestResponse(t, l, newTestRequest("POST", "/_mget", body), http.StatusOK, `{"docs":[]}`)
+			So(rec.Request.Body, ShouldBeEmpty)
+			So(rec.Response.Body, ShouldBeEmpty)
+		})
+		Convey("Omits the bodies of a pattern that may expand onto a listed index", func() {
+			req := newTestRequest("POST", "/cust*/_search", `{"query":{}}`)
+			req = req.WithContext(index.NewContext(req.Context(), []string{"cust*"}))
+			rec := recordTestResponse(t, l, req, http.StatusOK, `{"took":3}`)
+			So(rec.Request.Body, ShouldBeEmpty)
+		})
+		Convey("Records the bodies of a request to other indices", func() {
+			rec := recordTestResponse(t, l, newTestRequest("POST", "/books/_search", `{"query":{}}`), http.StatusOK, `{"took":3}`)
+			So(rec.Request.Body, ShouldEqual, `{"query":{}}`)
+			So(rec.Response.Body, ShouldEqual, `{"took":3}`)
+		})
+		Convey("Rejects an invalid pattern", func() {
+			_, err := parseIndexPatterns("users,[")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestRecoverPanic(t *testing.T) {
+	Convey("Recover from a panicking handler", t, func() {
+		l := newTestLogs(t)
+		l.synchronous = true
+		l.recordStackTrace = true
+
+		w := httptest.NewRecorder()
+		l.recorder(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"partial":`))
+			panic("unexpected state")
+		})(w, newTestRequest("POST", "/books/_search", `{}`))
+
+		So(w.Code, ShouldEqual, http.StatusInternalServerError)
+		So(w.Body.String(), ShouldNotContainSubstring, "partial")
+		So(w.Body.String(), ShouldNotContainSubstring, "goroutine")
+
+		records := readTestRecords(t, l)
+		So(len(records), ShouldEqual, 1)
+		So(records[0].Response.Code, ShouldEqual, http.StatusInternalServerError)
+		So(records[0].Response.StackTrace, ShouldStartWith, "unexpected state\n")
+		So(records[0].Response.StackTrace, ShouldContainSubstring, "TestRecoverPanic")
+		So(len(records[0].Response.StackTrace), ShouldBeLessThanOrEqualTo, maxStackTraceSize+len("unexpected state\n"))
+	})
+
+	Convey("Leave the panics to the server", t, func() {
+		l := newTestLogs(t)
+		l.synchronous = true
+		serve := func(err interface{}) func() {
+			return func() {
+				l.recorder(func(w http.ResponseWriter, r *http.Request) {
+					panic(err)
+				})(httptest.NewRecorder(), newTestRequest("POST", "/books/_search", `{}`))
+			}
+		}
+
+		Convey("The panics aren't recovered unless the stack traces are recorded", func() {
+			So(serve("unexpected state"), ShouldPanicWith, "unexpected state")
+		})
+		Convey("An aborted handler isn't recovered", func() {
+			l.recordStackTrace = true
+			So(serve(http.ErrAbortHandler), ShouldPanicWith, http.ErrAbortHandler)
+		})
+	})
+}
+
+func TestRecordQueueTime(t *testing.T) {
+	Convey("Record the queue time", t, func() {
+		l := newTestLogs(t)
+		l.synchronous = true
+		l.recordQueueTime = true
+		serve := func(waited time.Duration) record {
+			l.recorder(func(w http.ResponseWriter, r *http.Request) {
+				// a limiter stamps the time the request waited in it
+				if waited > 0 {
+					queuetime.Add(r.Context(), waited)
+				}
+				w.WriteHeader(http.StatusOK)
+			})(httptest.NewRecorder(), newTestRequest("POST", "/books/_search", `{}`))
+			records := readTestRecords(t, l)
+			return records[len(records)-1]
+		}
+
+		Convey("Queue time is recorded when the request waits", func() {
+			So(serve(40*time.Millisecond).Request.QueueTimeMs, ShouldEqual, 40)
+		})
+		Convey("Queue time is zero when the request doesn't wait", func() {
+			So(serve(0).Request.QueueTimeMs, ShouldEqual, 0)
+		})
+	})
+}
+
+func TestRecordTiming(t *testing.T) {
+	Convey("Record the timing of the phases", t, func() {
+		l := newTestLogs(t)
+		l.synchronous = true
+		l.recordTiming = true
+		phase := func(ctx context.Context, p timing.Phase, d time.Duration) {
+			start := time.Now()
+			time.Sleep(d)
+			timing.Add(ctx, p, time.Since(start))
+		}
+		l.recorder(func(w http.ResponseWriter, r *http.Request) {
+			phase(r.Context(), timing.Auth, 20*time.Millisecond)
+			phase(r.Context(), timing.Validate, 10*time.Millisecond)
+			phase(r.Context(), timing.Upstream, 30*time.Millisecond)
+			w.WriteHeader(http.StatusOK)
+		})(httptest.NewRecorder(), newTestRequest("POST", "/books/_search", `{}`))
+
+		records := readTestRecords(t, l)
+		So(len(records), ShouldEqual, 1)
+		rec := records[0].Timing
+		So(rec, ShouldNotBeNil)
+		So(rec.AuthMs, ShouldBeGreaterThanOrEqualTo, 20)
+		So(rec.ValidateMs, ShouldBeGreaterThanOrEqualTo, 10)
+		So(rec.UpstreamMs, ShouldBeGreaterThanOrEqualTo, 30)
+		So(rec.AuthMs+rec.ValidateMs+rec.UpstreamMs, ShouldAlmostEqual, rec.TotalMs, 5)
+		So(records[0].AuthCacheHit, ShouldBeNil)
+	})
+
+	Convey("Record the credential lookup", t, func() {
+		l := newTestLogs(t)
+		l.synchronous = true
+		l.recordTiming = true
+		l.recorder(func(w http.ResponseWriter, r *http.Request) {
+			timing.Add(r.Context(), timing.Auth, 20*time.Millisecond)
+			timing.Add(r.Context(), timing.AuthLookup, 15*time.Millisecond)
+			timing.SetAuthCacheHit(r.Context(), false)
+			w.WriteHeader(http.StatusOK)
+		})(httptest.NewRecorder(), newTestRequest("POST", "/books/_search", `{}`))
+
+		records := readTestRecords(t, l)
+		So(len(records), ShouldEqual, 1)
+		So(records[0].Timing.AuthLookupMs, ShouldEqual, 15)
+		So(records[0].AuthCacheHit, ShouldNotBeNil)
+		So(*records[0].AuthCacheHit, ShouldBeFalse)
+	})
+
+	Convey("Timing isn't recorded unless enabled", t, func() {
+		l := newTestLogs(t)
+		So(recordTestResponse(t, l, newTestRequest("POST", "/books/_search", `{}`), http.StatusOK, `{}`).Timing, ShouldBeNil)
+	})
+}
+
+func TestSampling(t *testing.T) {
+	Convey("Sample the fast requests", t, func() {
+		l := newTestLogs(t)
+		l.sampling = true
+		l.sampleRate = 0
+		l.slowThreshold = 100 * time.Millisecond
+		record := func(latency time.Duration) int {
+			w := httptest.NewRecorder()
+			w.WriteHeader(http.StatusOK)
+			l.recordResponse(w, newTestRequest("POST", "/books/_search", `{}`), nil, latency, "")
+			return len(readTestRecords(t, l))
+		}
+
+		Convey("Slow requests are always recorded", func() {
+			So(record(100*time.Millisecond), ShouldEqual, 1)
+			So(record(time.Second), ShouldEqual, 2)
+		})
+		Convey("Fast requests are subject to sampling", func() {
+			So(record(10*time.Millisecond), ShouldEqual, 0)
+			l.sampleRate = 1
+			So(record(10*time.Millisecond), ShouldEqual, 1)
+		})
+		Convey("Partial sample rate records a fraction of the fast requests", func() {
+			l.sampleRate = 0.5
+			recorded := 0
+			for i := 0; i < 1000; i++ {
+				if l.sampled(time.Millisecond) {
+					recorded++
+				}
+			}
+			So(recorded, ShouldBeBetween, 350, 650)
+		})
+	})
+}
+
+func TestRecordAuthDecisions(t *testing.T) {
+	Convey("Record the authorization decisions", t, func() {
+		l := newTestLogs(t)
+		l.synchronous = true
+		l.recordDecisions = true
+		serve := func(indices ...string) (*httptest.ResponseRecorder, record) {
+			req := newTestRequest("POST", "/books/_search", `{}`)
+			p := &permission.Permission{Categories: []category.Category{category.Search}, Indices: indices}
+			ctx := credential.NewContext(req.Context(), credential.Permission)
+			ctx = permission.NewContext(ctx, p)
+			w := httptest.NewRecorder()
+			l.recorder(validate.Category()(validate.Indices()(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})))(w, req.WithContext(ctx))
+			records := readTestRecords(t, l)
+			return w, records[len(records)-1]
+		}
+
+		Convey("Allowed request records the passing checks", func() {
+			w, rec := serve("books")
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(rec.AuthDecisions, ShouldResemble, []decision.Decision{
+				{Check: "category", Allowed: true},
+				{Check: "indices", Allowed: true},
+			})
+		})
+		Convey("Denied request records the denial reason", func() {
+			w, rec := serve("movies")
+			So(w.Code, ShouldEqual, http.StatusUnauthorized)
+			So(len(rec.AuthDecisions), ShouldEqual, 2)
+			So(rec.AuthDecisions[1].Check, ShouldEqual, "indices")
+			So(rec.AuthDecisions[1].Allowed, ShouldBeFalse)
+			So(rec.AuthDecisions[1].Reason, ShouldEqual, "credentials cannot access [books] index/indices")
+		})
+	})
+}
+
+func TestRecordCategoryCode(t *testing.T) {
+	Convey("Record the numeric code of the category", t, func() {
+		Convey("The codes are stable", func() {
+			So(category.Docs.Code(), ShouldEqual, 0)
+			So(category.Search.Code(), ShouldEqual, 1)
+			So(category.ReactiveSearch.Code(), ShouldEqual, 15)
+			So(category.Cache.Code(), ShouldEqual, 21)
+		})
+		Convey("The code and name of every category are recorded consistently", func() {
+			l := newTestLogs(t)
+			for c := category.Docs; c <= category.Cache; c++ {
+				if c == category.ReactiveSearch {
+					// the reactivesearch requests are recorded from their context
+					continue
+				}
+				req := newTestRequest("POST", "/books/_search", `{}`)
+				reqCategory := c
+				req = req.WithContext(category.NewContext(req.Context(), &reqCategory))
+				rec := recordTestResponse(t, l, req, http.StatusOK, `{}`)
+				So(rec.Category, ShouldEqual, c)
+				So(rec.CategoryCode, ShouldEqual, c.Code())
+				So(rec.CategoryCode, ShouldEqual, int(c))
+			}
+		})
+	})
+}
+
+func TestRecordCategoryFallback(t *testing.T) {
+	Convey("Record whether the category fell back to a default", t, func() {
+		l := newTestLogs(t)
+		l.recordCategoryFallback = true
+
+		Convey("Clearly classified request records a confident category", func() {
+			req := newTestRequest("POST", "/books/_search", `{}`)
+			req = req.WithContext(category.NewFallbackContext(req.Context(), false))
+			rec := recordTestResponse(t, l, req, http.StatusOK, `{}`)
+			So(rec.CategoryFallback, ShouldNotBeNil)
+			So(*rec.CategoryFallback, ShouldBeFalse)
+		})
+		Convey("Ambiguous request records a fallback category", func() {
+			req := newTestRequest("POST", "/books/_unknown", `{}`)
+			req = req.WithContext(category.NewFallbackContext(req.Context(), true))
+			rec := recordTestResponse(t, l, req, http.StatusOK, `{}`)
+			So(rec.CategoryFallback, ShouldNotBeNil)
+			So(*rec.CategoryFallback, ShouldBeTrue)
+		})
+		Convey("Disabled recording omits the indicator", func() {
+			l.recordCategoryFallback = false
+			rec := recordTestResponse(t, l, newTestRequest("POST", "/books/_search", `{}`), http.StatusOK, `{}`)
+			So(rec.CategoryFallback, ShouldBeNil)
+		})
+	})
+}
+
+func TestRecordAuthMethod(t *testing.T) {
+	Convey("Record the auth method of the requests", t, func() {
+		l := newTestLogs(t)
+		l.synchronous = true
+		serve := func(method authmethod.Method) record {
+			w := httptest.NewRecorder()
+			l.recorder(func(w http.ResponseWriter, r *http.Request) {
+				if method != "" {
+					authmethod.Set(r.Context(), method)
+				}
+				w.WriteHeader(http.StatusOK)
+			})(w, newTestRequest("POST", "/books/_search", `{}`))
+			records := readTestRecords(t, l)
+			return records[len(records)-1]
+		}
+
+		Convey("Basic auth request records basic", func() {
+			So(serve(authmethod.Basic).AuthMethod, ShouldEqual, authmethod.Basic)
+		})
+		Convey("Token request records jwt", func() {
+			So(serve(authmethod.JWT).AuthMethod, ShouldEqual, authmethod.JWT)
+		})
+		Convey("Unauthenticated request omits the method", func() {
+			So(serve("").AuthMethod, ShouldBeEmpty)
+		})
+	})
+}
+
+func TestShardsTouched(t *testing.T) {
+	Convey("Record the shards touched", t, func() {
+		l := newTestLogs(t)
+
+		Convey("Shard count is parsed for a search response", func() {
+			rec := recordTestResponse(t, l, newTestRequest("POST", "/books/_search", `{}`), http.StatusOK,
+				`{"took":2,"_shards":{"total":5,"successful":5,"skipped":0,"failed":0},"hits":{"hits":[]}}`)
+			So(*rec.Response.ShardsTouched, ShouldEqual, 5)
+		})
+		Convey("Shard counts of a msearch response are summed", func() {
+			rec := recordTestResponse(t, l, newTestRequest("POST", "/_msearch", ``), http.StatusOK,
+				`{"took":2,"responses":[{"_shards":{"total":3}},{"_shards":{"total":2}}]}`)
+			So(*rec.Response.ShardsTouched, ShouldEqual, 5)
+		})
+		Convey("Shard count is absent for a non-search request", func() {
+			req := newTestRequest("POST", "/books/_doc", `{}`)
+			reqCategory := category.Docs
+			req = req.WithContext(category.NewContext(req.Context(), &reqCategory))
+			rec := recordTestResponse(t, l, req, http.StatusCreated,
+				`{"_index":"books","result":"created","_shards":{"total":2,"successful":1,"failed":0}}`)
+			So(rec.Response.ShardsTouched, ShouldBeNil)
+		})
+	})
+}
+
+func TestTags(t *testing.T) {
+	Convey("Static tags", t, func() {
+		Convey("Configured tags appear on the records", func() {
+			l := newTestLogs(t)
+			var err error
+			l.tags, err = parseTags("env:prod, region:us")
+			So(err, ShouldBeNil)
+			rec := recordTestResponse(t, l, newTestRequest("POST", "/books/_search", `{}`), http.StatusOK, `{}`)
+			So(rec.Tags, ShouldResemble, map[string]string{"env": "prod", "region": "us"})
+		})
+
+		Convey("Records have no tags by default", func() {
+			rec := recordTestResponse(t, newTestLogs(t), newTestRequest("POST", "/books/_search", `{}`), http.StatusOK, `{}`)
+			So(rec.Tags, ShouldBeNil)
+		})
+
+		Convey("Malformed tags are rejected", func() {
+			for _, value := range []string{"env", "env:", ":prod", "env:prod,env:dev"} {
+				_, err := parseTags(value)
+				So(err, ShouldNotBeNil)
+			}
+		})
+
+		Convey("Malformed tags fail the plugin initialization", func() {
+			os.Setenv(envTags, "env")
+			defer os.Unsetenv(envTags)
+			l := newTestLogs(t)
+			err := l.InitFunc()
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, envTags)
+		})
+	})
+}
+
+func gzipTestBody(t *testing.T, body []byte) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(body); err != nil {
+		t.Fatalf("unable to compress body: %v", err)
+	}
+	gz.Close()
+	return buf.Bytes()
+}
+
+func TestDecompressBodies(t *testing.T) {
+	Convey("Decompress the recorded bodies", t, func() {
+		l := newTestLogs(t)
+		l.decompressBodies = true
+
+		Convey("Compressed bodies are recorded decompressed along with their ratio", func() {
+			body := []byte(strings.Repeat(`{"query":{"match_all":{}}}`, 10))
+			req := newTestRequest("POST", "/books/_search", string(gzipTestBody(t, body)))
+			req.Header.Set("Content-Encoding", "gzip")
+			rec := recordTestResponse(t, l, req, http.StatusOK, `{}`)
+			So(rec.Request.Body, ShouldEqual, string(body))
+			So(rec.Request.CompressionRatio, ShouldBeGreaterThan, 1)
+			So(rec.Flags, ShouldBeNil)
+		})
+		Convey("A high ratio body is flagged and not recorded", func() {
+			req := newTestRequest("POST", "/books/_search", string(gzipTestBody(t, make([]byte, 2*defaultMaxBodyBytes))))
+			req.Header.Set("Content-Encoding", "gzip")
+			rec := recordTestResponse(t, l, req, http.StatusOK, `{}`)
+			So(rec.Flags, ShouldNotBeNil)
+			So(rec.Flags.PossibleZipBomb, ShouldBeTrue)
+			So(rec.Request.Body, ShouldBeEmpty)
+			So(rec.Request.CompressionRatio, ShouldBeGreaterThan, defaultMaxCompressionRatio)
+		})
+		Convey("Uncompressed bodies are recorded as is", func() {
+			rec := recordTestResponse(t, l, newTestRequest("POST", "/books/_search", `{}`), http.StatusOK, `{}`)
+			So(rec.Request.Body, ShouldEqual, `{}`)
+			So(rec.Request.CompressionRatio, ShouldEqual, 0)
+		})
+	})
+
+	Convey("Abort the decompression", t, func() {
+		compressed := gzipTestBody(t, make([]byte, 10*defaultMaxBodyBytes))
+
+		Convey("once the ratio exceeds the maximum", func() {
+			result, err := decompressBody(compressed, "gzip", defaultMaxBodyBytes, 10)
+			So(err, ShouldBeNil)
+			So(result.possibleBomb, ShouldBeTrue)
+			So(result.body, ShouldBeNil)
+			// aborted within a chunk of crossing the ratio
+			So(result.ratio*float64(len(compressed)), ShouldBeLessThanOrEqualTo, 10*float64(len(compressed))+decompressionChunkSize)
+		})
+		Convey("at the cap", func() {
+			result, err := decompressBody(compressed, "gzip", defaultMaxBodyBytes, 1e9)
+			So(err, ShouldBeNil)
+			So(result.possibleBomb, ShouldBeFalse)
+			So(len(result.body), ShouldEqual, defaultMaxBodyBytes)
+		})
+	})
+}
+
+func TestMaxBodyBytes(t *testing.T) {
+	Convey("Truncate the recorded bodies to the configured size", t, func() {
+		l := newTestLogs(t)
+		body := strings.Repeat("a", 2*defaultMaxBodyBytes)
+
+		Convey("The bodies are truncated to the default size", func() {
+			rec := recordTestResponse(t, l, newTestRequest("POST", "/books/_search", body), http.StatusOK, body)
+			So(len(rec.Request.Body), ShouldEqual, defaultMaxBodyBytes)
+			So(len(rec.Response.Body), ShouldEqual, defaultMaxBodyBytes)
+		})
+		Convey("The bodies are truncated to the configured size", func() {
+			l.maxBodyBytes = 4096
+			rec := recordTestResponse(t, l, newTestRequest("POST", "/books/_search", body), http.StatusOK, body)
+			So(len(rec.Request.Body), ShouldEqual, 4096)
+			So(len(rec.Response.Body), ShouldEqual, 4096)
+		})
+		Convey("The bodies are recorded in full if the size is 0", func() {
+			l.maxBodyBytes = 0
+			rec := recordTestResponse(t, l, newTestRequest("POST", "/books/_search", body), http.StatusOK, body)
+			So(len(rec.Request.Body), ShouldEqual, len(body))
+			So(len(rec.Response.Body), ShouldEqual, len(body))
+		})
+	})
+}
+
+func TestRecordStatus(t *testing.T) {
+	Convey("Record the requests by their status code", t, func() {
+		l := newTestLogs(t)
+		var err error
+		l.recordStatus, err = parseRecordStatus("errors")
+		So(err, ShouldBeNil)
+
+		Convey("A successful request is dropped in errors mode", func() {
+			l.recordResponse(httptest.NewRecorder(), newTestRequest("POST", "/books/_search", `{}`), nil, time.Millisecond, "")
+			So(readTestRecords(t, l), ShouldBeEmpty)
+		})
+		Convey("A failed request is kept in errors mode", func() {
+			rec := recordTestResponse(t, l, newTestRequest("POST", "/books/_search", `{}`), http.StatusServiceUnavailable, `{"error":"unavailable"}`)
+			So(rec.Response.Code, ShouldEqual, http.StatusServiceUnavailable)
+			So(rec.Response.Body, ShouldEqual, `{"error":"unavailable"}`)
+		})
+		Convey("The error body of a reactivesearch request is kept", func() {
+			req := httptest.NewRequest("POST", "/books/_reactivesearch.v3", nil)
+			reqCategory := category.ReactiveSearch
+			ctx := category.NewContext(req.Context(), &reqCategory)
+			ctx = index.NewContext(ctx, []string{"books"})
+			ctx = request.NewContext(ctx, map[string]interface{}{"query": []interface{}{}})
+			rec := recordTestResponse(t, l, req.WithContext(ctx), http.StatusInternalServerError, `{"error":{"message":"failed"}}`)
+			So(rec.Response.Body, ShouldEqual, `{"error":{"message":"failed"}}`)
+		})
+		Convey("Every request is recorded in all mode", func() {
+			l.recordStatus, err = parseRecordStatus("all")
+			So(err, ShouldBeNil)
+			So(l.recordStatus, ShouldBeNil)
+			So(recordTestResponse(t, l, newTestRequest("POST", "/books/_search", `{}`), http.StatusOK, `{}`).Response.Code, ShouldEqual, http.StatusOK)
+		})
+		Convey("The requests are recorded by status ranges", func() {
+			l.recordStatus, err = parseRecordStatus("404,500-599")
+			So(err, ShouldBeNil)
+			l.recordResponse(httptest.NewRecorder(), newTestRequest("POST", "/books/_search", `{}`), nil, time.Millisecond, "")
+			So(readTestRecords(t, l), ShouldBeEmpty)
+			So(recordTestResponse(t, l, newTestRequest("POST", "/books/_search", `{}`), http.StatusNotFound, `{}`).Response.Code, ShouldEqual, http.StatusNotFound)
+		})
+		Convey("An invalid value is rejected", func() {
+			_, err := parseRecordStatus("failures")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,69 @@
+package logs
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+)
+
+// indexNormalization rewrites the index names matching its pattern, e.g. strips the
+// date suffix of `logs-2024.01.01` so that the dated indices are grouped as `logs-*`.
+type indexNormalization struct {
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+// parseIndexNormalizations parses a JSON array of the normalization rules, applied in
+// order, e.g. `[{"pattern": "-\\d{4}\\.\\d{2}\\.\\d{2}$", "replacement": "-*"}]`. The
+// replacement can refer to the submatches of the pattern, i.e. `${1}`.
+func parseIndexNormalizations(value string) ([]indexNormalization, error) {
+	if value == "" {
+		return nil, nil
+	}
+	var raw []struct {
+		Pattern     string `json:"pattern"`
+		Replacement string `json:"replacement"`
+	}
+	if err := json.Unmarshal([]byte(value), &raw); err != nil {
+		return nil, err
+	}
+	rules := make([]indexNormalization, 0, len(raw))
+	for _, r := range raw {
+		if r.Pattern == "" {
+			return nil, fmt.Errorf("pattern of a normalization rule can't be empty")
+		}
+		pattern, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %s: %v", r.Pattern, err)
+		}
+		rules = append(rules, indexNormalization{pattern: pattern, replacement: r.Replacement})
+	}
+	return rules, nil
+}
+
+// normalizeIndices returns the normalized index names, the ones normalized to the same
+// name are recorded once. The indices are returned as is if none of them is rewritten.
+func normalizeIndices(rules []indexNormalization, indices []string) ([]string, bool) {
+	if len(rules) == 0 {
+		return indices, false
+	}
+	var normalized []string
+	seen := make(map[string]bool)
+	changed := false
+	for _, indexName := range indices {
+		name := indexName
+		for _, rule := range rules {
+			name = rule.pattern.ReplaceAllString(name, rule.replacement)
+		}
+		changed = changed || name != indexName
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		normalized = append(normalized, name)
+	}
+	if !changed {
+		return indices, false
+	}
+	return normalized, true
+}
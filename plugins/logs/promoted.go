@@ -0,0 +1,47 @@
+package logs
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// parsePromotedHeaders parses a comma separated list of the request headers recorded as
+// dedicated fields, e.g. `X-Client-Version,X-App-Name`.
+func parsePromotedHeaders(value string) ([]string, error) {
+	var names []string
+	for _, token := range strings.Split(value, ",") {
+		token = strings.TrimSpace(token)
+		if token == "" {
+			continue
+		}
+		if strings.ContainsAny(token, " \t:") {
+			return nil, fmt.Errorf("invalid header name: %s", token)
+		}
+		names = append(names, http.CanonicalHeaderKey(token))
+	}
+	return names, nil
+}
+
+// promotedField returns the record field of a promoted header, e.g. `x_client_version`.
+func promotedField(name string) string {
+	return strings.ReplaceAll(strings.ToLower(name), "-", "_")
+}
+
+// promoteHeaders moves the promoted headers out of the recorded headers into their
+// dedicated fields, nil if none of them is set.
+func promoteHeaders(headers map[string][]string, names []string) map[string]string {
+	var fields map[string]string
+	for _, name := range names {
+		values, ok := headers[name]
+		if !ok {
+			continue
+		}
+		if fields == nil {
+			fields = make(map[string]string)
+		}
+		fields[promotedField(name)] = strings.Join(values, ",")
+		delete(headers, name)
+	}
+	return fields
+}
@@ -0,0 +1,45 @@
+package logs
+
+import (
+	"net/http"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestPromotedHeaders(t *testing.T) {
+	Convey("Record the promoted headers as dedicated fields", t, func() {
+		l := newTestLogs(t)
+		var err error
+		l.promotedHeaders, err = parsePromotedHeaders("x-client-version, X-App-Name")
+		So(err, ShouldBeNil)
+		So(l.promotedHeaders, ShouldResemble, []string{"X-Client-Version", "X-App-Name"})
+
+		req := newTestRequest("POST", "/books/_search", `{}`)
+		req.Header.Set("X-Client-Version", "3.1.0")
+		req.Header.Set("X-App-Name", "storefront")
+		req.Header.Set("X-Request-Source", "web")
+		rec := recordTestResponse(t, l, req, http.StatusOK, `{}`)
+
+		Convey("The configured headers are promoted", func() {
+			So(rec.Request.PromotedHeaders, ShouldResemble, map[string]string{
+				"x_client_version": "3.1.0",
+				"x_app_name":       "storefront",
+			})
+			So(rec.Request.Headers, ShouldNotContainKey, "X-Client-Version")
+			So(rec.Request.Headers, ShouldNotContainKey, "X-App-Name")
+		})
+		Convey("The other headers stay in the headers", func() {
+			So(rec.Request.Headers["X-Request-Source"], ShouldResemble, []string{"web"})
+		})
+		Convey("Nothing is promoted if the headers aren't set", func() {
+			rec := recordTestResponse(t, l, newTestRequest("POST", "/books/_search", `{}`), http.StatusOK, `{}`)
+			So(rec.Request.PromotedHeaders, ShouldBeNil)
+		})
+	})
+
+	Convey("Parse the promoted headers", t, func() {
+		_, err := parsePromotedHeaders("X-App-Name,X App")
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,64 @@
+package logs
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/appbaseio/reactivesearch-api/util"
+)
+
+const envRedactHeaders = "LOGS_REDACT_HEADERS"
+
+// defaultRedactedHeaders carry credentials, their values are never recorded.
+var defaultRedactedHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+
+// defaultRedacted returns the default redacted headers along with the gateway header,
+// if configured, since its value is a shared secret.
+func defaultRedacted() []string {
+	names := append([]string{}, defaultRedactedHeaders...)
+	if header := strings.TrimSpace(os.Getenv(util.GatewayHeaderEnvName)); header != "" {
+		names = append(names, http.CanonicalHeaderKey(header))
+	}
+	return names
+}
+
+// parseRedactHeaders parses a comma separated list of the headers redacted along with
+// the default ones, e.g. `X-Session-Token,Proxy-Authorization`.
+func parseRedactHeaders(value string) ([]string, error) {
+	names := defaultRedacted()
+	for _, token := range strings.Split(value, ",") {
+		token = strings.TrimSpace(token)
+		if token == "" {
+			continue
+		}
+		if strings.ContainsAny(token, " \t:") {
+			return nil, fmt.Errorf("invalid header name: %s", token)
+		}
+		names = append(names, http.CanonicalHeaderKey(token))
+	}
+	return names, nil
+}
+
+// redactHeaders returns a copy of the headers with the values of the redacted ones
+// replaced, the names are matched case-insensitively.
+func redactHeaders(headers map[string][]string, names []string) map[string][]string {
+	if headers == nil {
+		return nil
+	}
+	if names == nil {
+		names = defaultRedacted()
+	}
+	redacted := make(map[string][]string, len(headers))
+	for key, values := range headers {
+		redacted[key] = values
+		for _, name := range names {
+			if strings.EqualFold(key, name) {
+				redacted[key] = []string{maskedValue}
+				break
+			}
+		}
+	}
+	return redacted
+}
@@ -0,0 +1,75 @@
+package logs
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/appbaseio/reactivesearch-api/util"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRedactHeaders(t *testing.T) {
+	Convey("Redact the credentials of the recorded headers", t, func() {
+		l := newTestLogs(t)
+		req := newTestRequest("POST", "/books/_search", `{}`)
+		req.Header.Set("Authorization", "Basic Zm9vOmJhcg==")
+		req.Header.Set("Cookie", "session=abc")
+		req.Header["x-api-key"] = []string{"secret"}
+		req.Header.Set("X-Session-Token", "token")
+		req.Header.Set("X-Request-Source", "web")
+
+		recordHeaders := func() record {
+			dump, err := httputil.DumpRequest(req, true)
+			So(err, ShouldBeNil)
+			w := httptest.NewRecorder()
+			w.Header().Set("Set-Cookie", "session=def")
+			w.Header().Set("X-Session-Token", "token")
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			w.WriteString(`{}`)
+			l.recordResponse(w, req, dump, time.Millisecond, "")
+			return readTestRecords(t, l)[0]
+		}
+
+		Convey("The default headers are redacted on both sides", func() {
+			rec := recordHeaders()
+			So(rec.Request.Headers["Authorization"], ShouldResemble, []string{maskedValue})
+			So(rec.Request.Headers["Cookie"], ShouldResemble, []string{maskedValue})
+			So(rec.Request.Headers["x-api-key"], ShouldResemble, []string{maskedValue})
+			So(rec.Response.Headers["Set-Cookie"], ShouldResemble, []string{maskedValue})
+			So(rec.Request.Headers["X-Session-Token"], ShouldResemble, []string{"token"})
+			So(rec.Request.Headers["X-Request-Source"], ShouldResemble, []string{"web"})
+			So(rec.Response.Headers["Content-Type"], ShouldResemble, []string{"application/json"})
+			// the request itself is left as is
+			So(req.Header.Get("Authorization"), ShouldEqual, "Basic Zm9vOmJhcg==")
+		})
+		Convey("The configured headers are redacted along with the default ones", func() {
+			var err error
+			l.redactedHeaders, err = parseRedactHeaders("x-session-token")
+			So(err, ShouldBeNil)
+			rec := recordHeaders()
+			So(rec.Request.Headers["X-Session-Token"], ShouldResemble, []string{maskedValue})
+			So(rec.Response.Headers["X-Session-Token"], ShouldResemble, []string{maskedValue})
+			So(rec.Request.Headers["Authorization"], ShouldResemble, []string{maskedValue})
+		})
+		Convey("The gateway header is redacted if configured", func() {
+			os.Setenv(util.GatewayHeaderEnvName, "x-gateway-token")
+			defer os.Unsetenv(util.GatewayHeaderEnvName)
+			var err error
+			l.redactedHeaders, err = parseRedactHeaders("")
+			So(err, ShouldBeNil)
+			req.Header.Set("X-Gateway-Token", "secret")
+			rec := recordHeaders()
+			So(rec.Request.Headers["X-Gateway-Token"], ShouldResemble, []string{maskedValue})
+		})
+	})
+
+	Convey("Parse the redacted headers", t, func() {
+		_, err := parseRedactHeaders("X-Session-Token,X Token")
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,169 @@
+package logs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/appbaseio/reactivesearch-api/model/category"
+	"github.com/appbaseio/reactivesearch-api/util"
+)
+
+// defaultRetainIndices is the number of the latest indices of an alias kept on rollover.
+const defaultRetainIndices = 2
+
+// retention defines when the index of a logs alias is rolled over, and how many of
+// its latest indices are kept.
+type retention struct {
+	MaxAge  string `json:"max_age"`
+	MaxDocs int64  `json:"max_docs"`
+	MaxSize string `json:"max_size"`
+	Retain  int    `json:"retain"`
+}
+
+// defaultRetention returns the retention of the aliases without a configured one.
+func defaultRetention() retention {
+	if util.IsProductionPlan() {
+		return retention{MaxAge: "30d", MaxDocs: 1000000, MaxSize: "10gb", Retain: defaultRetainIndices}
+	}
+	return retention{MaxAge: "7d", MaxDocs: 10000, MaxSize: "1gb", Retain: defaultRetainIndices}
+}
+
+var (
+	rolloverAgePattern  = regexp.MustCompile(`^[0-9]+(d|h|m|s|ms|micros|nanos)$`)
+	rolloverSizePattern = regexp.MustCompile(`(?i)^[0-9]+(b|kb|mb|gb|tb|pb)$`)
+)
+
+// retentionFromEnv returns the rollover conditions and the number of the kept indices
+// set in the environment. The unset ones are left zero, so that they default to the ones
+// of the current plan at each rollover, as the plan may change after the startup.
+func retentionFromEnv() (retention, error) {
+	var r retention
+	if value := os.Getenv(envRetainIndices); value != "" {
+		// at least the write index is kept
+		retain, err := strconv.Atoi(value)
+		if err != nil || retain < 1 {
+			return r, fmt.Errorf("invalid value for %s: %s", envRetainIndices, value)
+		}
+		r.Retain = retain
+	}
+	if value := os.Getenv(envRolloverMaxAge); value != "" {
+		if !rolloverAgePattern.MatchString(value) {
+			return r, fmt.Errorf("invalid value for %s: %s", envRolloverMaxAge, value)
+		}
+		r.MaxAge = value
+	}
+	if value := os.Getenv(envRolloverMaxDocs); value != "" {
+		maxDocs, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || maxDocs <= 0 {
+			return r, fmt.Errorf("invalid value for %s: %s", envRolloverMaxDocs, value)
+		}
+		r.MaxDocs = maxDocs
+	}
+	if value := os.Getenv(envRolloverMaxSize); value != "" {
+		if !rolloverSizePattern.MatchString(value) {
+			return r, fmt.Errorf("invalid value for %s: %s", envRolloverMaxSize, value)
+		}
+		r.MaxSize = value
+	}
+	return r, nil
+}
+
+// withDefaults returns the retention with each of its unset values set to the default one.
+func (r retention) withDefaults(defaults retention) retention {
+	if r.Retain == 0 {
+		r.Retain = defaults.Retain
+	}
+	if r.MaxAge == "" {
+		r.MaxAge = defaults.MaxAge
+	}
+	if r.MaxDocs == 0 {
+		r.MaxDocs = defaults.MaxDocs
+	}
+	if r.MaxSize == "" {
+		r.MaxSize = defaults.MaxSize
+	}
+	return r
+}
+
+// inherit returns the retention of a category with its unset number of the kept indices
+// set to the default one, and its rollover conditions set to the default ones if none
+// of them is set.
+func (r retention) inherit(defaults retention) retention {
+	if r.Retain == 0 {
+		r.Retain = defaults.Retain
+	}
+	if r.MaxAge == "" && r.MaxDocs == 0 && r.MaxSize == "" {
+		r.MaxAge, r.MaxDocs, r.MaxSize = defaults.MaxAge, defaults.MaxDocs, defaults.MaxSize
+	}
+	return r
+}
+
+// conditions returns the rollover conditions of the retention.
+func (r retention) conditions() map[string]interface{} {
+	conditions := make(map[string]interface{})
+	if r.MaxAge != "" {
+		conditions["max_age"] = r.MaxAge
+	}
+	if r.MaxDocs > 0 {
+		conditions["max_docs"] = r.MaxDocs
+	}
+	if r.MaxSize != "" {
+		conditions["max_size"] = r.MaxSize
+	}
+	return conditions
+}
+
+// parseCategoryRetention parses a JSON object of category to retention, e.g.
+// `{"search": {"max_age": "1d", "retain": 7}}`. The unset values of a category are
+// inherited from the defaults at each rollover.
+func parseCategoryRetention(value string) (map[category.Category]retention, error) {
+	if value == "" {
+		return nil, nil
+	}
+	var raw map[string]retention
+	if err := json.Unmarshal([]byte(value), &raw); err != nil {
+		return nil, err
+	}
+	categoryRetention := make(map[category.Category]retention)
+	for name, r := range raw {
+		var c category.Category
+		if err := json.Unmarshal([]byte(`"`+name+`"`), &c); err != nil {
+			return nil, fmt.Errorf("invalid category: %s", name)
+		}
+		if r.Retain < 0 {
+			return nil, fmt.Errorf("retain of category %s must be a positive number", name)
+		}
+		if r.MaxAge != "" && !rolloverAgePattern.MatchString(r.MaxAge) {
+			return nil, fmt.Errorf("invalid max_age of category %s: %s", name, r.MaxAge)
+		}
+		if r.MaxDocs < 0 {
+			return nil, fmt.Errorf("max_docs of category %s must be a positive number", name)
+		}
+		if r.MaxSize != "" && !rolloverSizePattern.MatchString(r.MaxSize) {
+			return nil, fmt.Errorf("invalid max_size of category %s: %s", name, r.MaxSize)
+		}
+		categoryRetention[c] = r
+	}
+	return categoryRetention, nil
+}
+
+// staleIndices returns the rolled over indices of the alias, i.e. `${alias}-000001`,
+// except for the latest retain ones.
+func staleIndices(alias string, indices []string, retain int) []string {
+	r := regexp.MustCompile("^" + regexp.QuoteMeta(alias) + "-[0-9]+$")
+	var rolloverIndices []string
+	for _, index := range indices {
+		if r.MatchString(index) {
+			rolloverIndices = append(rolloverIndices, index)
+		}
+	}
+	if len(rolloverIndices) <= retain {
+		return nil
+	}
+	sort.Strings(rolloverIndices)
+	return rolloverIndices[:len(rolloverIndices)-retain]
+}
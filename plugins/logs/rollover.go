@@ -0,0 +1,184 @@
+package logs
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/appbaseio/reactivesearch-api/middleware"
+	"github.com/appbaseio/reactivesearch-api/model/index"
+	"github.com/appbaseio/reactivesearch-api/model/op"
+	"github.com/appbaseio/reactivesearch-api/model/queuetime"
+	"github.com/appbaseio/reactivesearch-api/util"
+)
+
+const (
+	// rolloverPolicyReject rejects the writes to a logs alias being rolled over with a 503
+	rolloverPolicyReject = "reject"
+	// rolloverPolicyBuffer holds the writes to a logs alias being rolled over until it's done
+	rolloverPolicyBuffer = "buffer"
+	// defaultRolloverGrace is how long the writes are still held off once a rollover is done
+	defaultRolloverGrace = time.Second
+	// maxRolloverWindow bounds a window in case the rollover never ends, and the time
+	// the buffered writes wait for
+	maxRolloverWindow = 30 * time.Second
+)
+
+// rolloverWindows holds the logs aliases being rolled over, during which their writes
+// are handled as per the configured policy.
+type rolloverWindows struct {
+	mu     sync.Mutex
+	policy string
+	grace  time.Duration
+	// closing is the time the window of each alias closes at
+	closing map[string]time.Time
+	now     func() time.Time
+}
+
+var rollovers = &rolloverWindows{
+	grace:   defaultRolloverGrace,
+	closing: make(map[string]time.Time),
+	now:     time.Now,
+}
+
+// configure sets the policy of the writes during the rollover windows.
+func (rw *rolloverWindows) configure(policy string, grace time.Duration) {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	rw.policy = policy
+	rw.grace = grace
+}
+
+// begin opens the window of the alias, it's closed by end.
+func (rw *rolloverWindows) begin(alias string) {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	if rw.policy == "" {
+		return
+	}
+	log.Println(logTag, ": holding off the writes to", alias, "while it's rolled over")
+	rw.closing[alias] = rw.now().Add(maxRolloverWindow)
+}
+
+// end closes the window of the alias after the grace period.
+func (rw *rolloverWindows) end(alias string) {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	if _, ok := rw.closing[alias]; ok {
+		rw.closing[alias] = rw.now().Add(rw.grace)
+	}
+}
+
+// remaining returns the time left until the window of the alias of the index closes,
+// zero if it isn't open.
+func (rw *rolloverWindows) remaining(indexName string) time.Duration {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	for alias, closing := range rw.closing {
+		if indexName != alias && !isRolloverIndex(alias, indexName) {
+			continue
+		}
+		if left := closing.Sub(rw.now()); left > 0 {
+			return left
+		}
+		delete(rw.closing, alias)
+	}
+	return 0
+}
+
+// isRolloverIndex returns true for the rolled over indices of the alias, i.e. `${alias}-000001`.
+func isRolloverIndex(alias, indexName string) bool {
+	suffix := strings.TrimPrefix(indexName, alias+"-")
+	if suffix == indexName || suffix == "" {
+		return false
+	}
+	_, err := strconv.Atoi(suffix)
+	return err == nil
+}
+
+func (rw *rolloverWindows) currentPolicy() string {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	return rw.policy
+}
+
+// wait blocks until the window of the alias of the index closes.
+func (rw *rolloverWindows) wait(indexName string) {
+	for left := rw.remaining(indexName); left > 0; left = rw.remaining(indexName) {
+		time.Sleep(minDuration(left, 100*time.Millisecond))
+	}
+}
+
+func minDuration(a, b time.Duration) time.Duration {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// rolloverPolicyFromEnv returns the policy of the writes during the rollover windows
+// and their grace period.
+func rolloverPolicyFromEnv() (string, time.Duration, error) {
+	policy := os.Getenv(envRolloverPolicy)
+	switch policy {
+	case "", rolloverPolicyReject, rolloverPolicyBuffer:
+	default:
+		return "", 0, fmt.Errorf("invalid value for %s: %s", envRolloverPolicy, policy)
+	}
+	grace := defaultRolloverGrace
+	if value := os.Getenv(envRolloverGrace); value != "" {
+		var err error
+		grace, err = time.ParseDuration(value)
+		if err != nil || grace < 0 || grace > maxRolloverWindow {
+			return "", 0, fmt.Errorf("invalid value for %s: %s", envRolloverGrace, value)
+		}
+	}
+	return policy, grace, nil
+}
+
+// RolloverWrites middleware handles the writes to the logs aliases being rolled over as
+// per LOGS_ROLLOVER_WRITE_POLICY, i.e. rejects them with a 503 or holds them until the
+// rollover is done, so that they don't fail while the write index is swapped.
+func RolloverWrites() middleware.Middleware {
+	return Instance().rolloverWrites
+}
+
+func (l *Logs) rolloverWrites(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		reqOp, err := op.FromContext(ctx)
+		if err != nil || *reqOp != op.Write {
+			h(w, req)
+			return
+		}
+		reqIndices, err := index.FromContext(ctx)
+		if err != nil {
+			h(w, req)
+			return
+		}
+		for _, indexName := range reqIndices {
+			left := rollovers.remaining(indexName)
+			if left == 0 {
+				continue
+			}
+			if rollovers.currentPolicy() == rolloverPolicyBuffer {
+				start := time.Now()
+				rollovers.wait(indexName)
+				queuetime.Add(ctx, time.Since(start))
+				continue
+			}
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(left.Seconds()))))
+			msg := fmt.Sprintf("index %s is being rolled over, retry the write shortly", indexName)
+			util.WriteBackError(w, msg, http.StatusServiceUnavailable)
+			return
+		}
+		h(w, req)
+	}
+}
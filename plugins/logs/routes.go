@@ -37,5 +37,26 @@ func (l *Logs) routes() []plugins.Route {
 			HandlerFunc: middleware(l.getSearchLogs()),
 			Description: "Returns the search request logs for the cluster",
 		},
+		{
+			Name:        "Stream logs",
+			Methods:     []string{http.MethodGet},
+			Path:        "/_logs/stream",
+			HandlerFunc: middleware(l.streamLogs()),
+			Description: "Streams the logs as they're recorded as server-sent events",
+		},
+		{
+			Name:        "Get logs indexing breaker",
+			Methods:     []string{http.MethodGet},
+			Path:        "/_logs/_breaker",
+			HandlerFunc: middleware(l.getBreaker()),
+			Description: "Returns the state of the breaker around the indexing of the logs",
+		},
+		{
+			Name:        "Get dropped logs",
+			Methods:     []string{http.MethodGet},
+			Path:        "/_logs/_dropped",
+			HandlerFunc: middleware(l.getDroppedRecords()),
+			Description: "Returns the numbers of the log records dropped because a buffer was full",
+		},
 	}
 }
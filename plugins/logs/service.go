@@ -6,4 +6,7 @@ type logsService interface {
 	getRawLogs(ctx context.Context, logsFilter logsFilter) ([]byte, error)
 	indexRecord(ctx context.Context, r record)
 	rolloverIndexJob(alias string)
+	aliases() []string
+	breakerStatus() *breakerStatus
+	close() error
 }
@@ -0,0 +1,133 @@
+package logs
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/appbaseio/reactivesearch-api/util"
+)
+
+const (
+	defaultStatsdPrefix = "arc."
+	// maxSizeBuckets bounds the buckets of the size histograms, each one is a metric series
+	maxSizeBuckets = 20
+)
+
+// statsd emits the request metrics to a StatsD server over UDP. Tags are
+// sent in the DogStatsD format, i.e. "|#key:value,key:value".
+type statsd struct {
+	conn   net.Conn
+	prefix string
+	tags   []string
+}
+
+func newStatsd(address, prefix string, tags []string) (*statsd, error) {
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	return &statsd{conn: conn, prefix: prefix, tags: tags}, nil
+}
+
+// parseStatsdTags parses a comma separated list of key:value tags.
+func parseStatsdTags(value string) []string {
+	var tags []string
+	for _, tag := range strings.Split(value, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// parseSizeBuckets parses a comma separated list of the ascending upper bounds, in
+// bytes, of the buckets of the size histograms, e.g. "1024,65536,1048576".
+func parseSizeBuckets(value string) ([]int64, error) {
+	var buckets []int64
+	for _, token := range strings.Split(value, ",") {
+		token = strings.TrimSpace(token)
+		if token == "" {
+			continue
+		}
+		bound, err := strconv.ParseInt(token, 10, 64)
+		if err != nil || bound <= 0 {
+			return nil, fmt.Errorf("invalid bucket: %s", token)
+		}
+		if len(buckets) > 0 && bound <= buckets[len(buckets)-1] {
+			return nil, fmt.Errorf("buckets must be ascending: %s", token)
+		}
+		buckets = append(buckets, bound)
+	}
+	if len(buckets) > maxSizeBuckets {
+		return nil, fmt.Errorf("at most %d buckets are allowed", maxSizeBuckets)
+	}
+	return buckets, nil
+}
+
+// sizeBucket returns the upper bound of the bucket the size falls in, "inf" beyond the last one.
+func sizeBucket(buckets []int64, size int) string {
+	for _, bound := range buckets {
+		if int64(size) <= bound {
+			return strconv.FormatInt(bound, 10)
+		}
+	}
+	return "inf"
+}
+
+func (s *statsd) count(name string, value int64, tags ...string) {
+	s.send(name, fmt.Sprintf("%d|c", value), tags)
+}
+
+func (s *statsd) timing(name string, d time.Duration, tags ...string) {
+	s.send(name, fmt.Sprintf("%d|ms", d.Milliseconds()), tags)
+}
+
+func (s *statsd) gauge(name string, value int64, tags ...string) {
+	s.send(name, fmt.Sprintf("%d|g", value), tags)
+}
+
+func (s *statsd) send(name, value string, tags []string) {
+	line := s.prefix + name + ":" + value
+	tags = append(append([]string{}, s.tags...), tags...)
+	if len(tags) > 0 {
+		line += "|#" + strings.Join(tags, ",")
+	}
+	// metrics are best effort, a lost packet must not affect the request
+	if _, err := s.conn.Write([]byte(line)); err != nil {
+		log.Errorln(logTag, ": unable to emit statsd metric:", err)
+	}
+}
+
+// emitMetrics emits the request count, latency and error count of a record, along with
+// the number of the in-flight requests.
+func (s *statsd) emitMetrics(rec record, latency time.Duration) {
+	tags := metricTags(rec)
+	s.count("requests", 1, tags...)
+	s.timing("latency", latency, tags...)
+	if rec.Response.Code >= 400 {
+		s.count("errors", 1, tags...)
+	}
+	s.gauge("in_flight", util.InFlightRequests())
+}
+
+// emitSizes emits the request and response body sizes into the size histograms, i.e.
+// counts them in the bucket they fall in, which is tagged by its upper bound, e.g. "le:1024".
+func (s *statsd) emitSizes(rec record, buckets []int64, requestSize, responseSize int) {
+	tags := metricTags(rec)
+	s.count("request_size", 1, append(tags, "le:"+sizeBucket(buckets, requestSize))...)
+	s.count("response_size", 1, append(tags, "le:"+sizeBucket(buckets, responseSize))...)
+}
+
+func metricTags(rec record) []string {
+	return []string{
+		"category:" + rec.Category.String(),
+		"method:" + strings.ToLower(rec.Request.Method),
+		fmt.Sprintf("status:%d", rec.Response.Code),
+	}
+}
@@ -0,0 +1,97 @@
+package logs
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// listenTestStatsd starts a fake statsd server and returns its address along with
+// a function that reads the next n metric lines.
+func listenTestStatsd(t *testing.T) (string, func(n int) []string) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	read := func(n int) []string {
+		var lines []string
+		buf := make([]byte, 1024)
+		for len(lines) < n {
+			conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			size, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				t.Fatalf("expected %d metrics, received %d: %v", n, len(lines), err)
+			}
+			lines = append(lines, string(buf[:size]))
+		}
+		return lines
+	}
+	return conn.LocalAddr().String(), read
+}
+
+func TestStatsd(t *testing.T) {
+	Convey("Emit metrics to statsd", t, func() {
+		address, read := listenTestStatsd(t)
+		l := newTestLogs(t)
+		var err error
+		l.statsd, err = newStatsd(address, "search.", parseStatsdTags("env:test, region:eu"))
+		So(err, ShouldBeNil)
+
+		Convey("Emits the request count and latency", func() {
+			recordTestResponse(t, l, newTestRequest("POST", "/books/_search", `{}`), http.StatusOK, `{"took":1}`)
+			So(read(2), ShouldResemble, []string{
+				"search.requests:1|c|#env:test,region:eu,category:search,method:post,status:200",
+				"search.latency:1|ms|#env:test,region:eu,category:search,method:post,status:200",
+			})
+		})
+		Convey("Emits the error count for failed requests", func() {
+			recordTestResponse(t, l, newTestRequest("GET", "/books/_search", ``), http.StatusNotFound, `{}`)
+			So(read(3)[2], ShouldEqual, "search.errors:1|c|#env:test,region:eu,category:search,method:get,status:404")
+		})
+		Convey("Emits the in-flight requests gauge", func() {
+			recordTestResponse(t, l, newTestRequest("POST", "/books/_search", `{}`), http.StatusOK, `{"took":1}`)
+			So(read(3)[2], ShouldEqual, "search.in_flight:0|g|#env:test,region:eu")
+		})
+		Convey("Emits the body sizes into the size histograms", func() {
+			l.sizeBuckets, err = parseSizeBuckets("16, 1024")
+			So(err, ShouldBeNil)
+			recordTestResponse(t, l, newTestRequest("POST", "/books/_search", `{}`), http.StatusOK, strings.Repeat("a", 100))
+			recordTestResponse(t, l, newTestRequest("POST", "/books/_search", strings.Repeat(" ", 2000)), http.StatusOK, `{}`)
+			lines := read(10)
+			So(lines[3:5], ShouldResemble, []string{
+				"search.request_size:1|c|#env:test,region:eu,category:search,method:post,status:200,le:16",
+				"search.response_size:1|c|#env:test,region:eu,category:search,method:post,status:200,le:1024",
+			})
+			So(lines[8:10], ShouldResemble, []string{
+				"search.request_size:1|c|#env:test,region:eu,category:search,method:post,status:200,le:inf",
+				"search.response_size:1|c|#env:test,region:eu,category:search,method:post,status:200,le:16",
+			})
+		})
+	})
+}
+
+func TestParseSizeBuckets(t *testing.T) {
+	Convey("Parse the buckets of the size histograms", t, func() {
+		buckets, err := parseSizeBuckets("1024, 65536,1048576")
+		So(err, ShouldBeNil)
+		So(buckets, ShouldResemble, []int64{1024, 65536, 1048576})
+		So(sizeBucket(buckets, 1024), ShouldEqual, "1024")
+		So(sizeBucket(buckets, 1025), ShouldEqual, "65536")
+		So(sizeBucket(buckets, 2000000), ShouldEqual, "inf")
+
+		var tooMany []string
+		for i := 1; i <= maxSizeBuckets+1; i++ {
+			tooMany = append(tooMany, strconv.Itoa(i))
+		}
+		for _, value := range []string{"1024,512", "0", "1kb", strings.Join(tooMany, ",")} {
+			_, err := parseSizeBuckets(value)
+			So(err, ShouldNotBeNil)
+		}
+	})
+}
@@ -0,0 +1,96 @@
+package logs
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/appbaseio/reactivesearch-api/util"
+)
+
+const (
+	// streamBufferSize is the number of records a subscriber can fall behind by, the
+	// records beyond it are dropped for the subscriber rather than holding off the others
+	streamBufferSize = 100
+	// streamKeepAlive is how often a comment is sent to the idle subscribers so that
+	// the proxies in between don't close the stream
+	streamKeepAlive = 15 * time.Second
+)
+
+// logStreams fans out the written records to the subscribers of the logs stream.
+type logStreams struct {
+	mu          sync.RWMutex
+	subscribers map[chan record]struct{}
+}
+
+func (s *logStreams) subscribe() chan record {
+	ch := make(chan record, streamBufferSize)
+	s.mu.Lock()
+	if s.subscribers == nil {
+		s.subscribers = make(map[chan record]struct{})
+	}
+	s.subscribers[ch] = struct{}{}
+	s.mu.Unlock()
+	return ch
+}
+
+func (s *logStreams) unsubscribe(ch chan record) {
+	s.mu.Lock()
+	delete(s.subscribers, ch)
+	s.mu.Unlock()
+}
+
+// publish sends the record to the subscribers without blocking on the slow ones.
+func (s *logStreams) publish(rec record) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for ch := range s.subscribers {
+		select {
+		case ch <- rec:
+		default:
+		}
+	}
+}
+
+// streamLogs streams the records as they're written as server-sent events, until the
+// client disconnects.
+func (l *Logs) streamLogs() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			util.WriteBackError(w, "streaming isn't supported", http.StatusInternalServerError)
+			return
+		}
+		ch := l.streams.subscribe()
+		defer l.streams.unsubscribe(ch)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		keepAlive := time.NewTicker(streamKeepAlive)
+		defer keepAlive.Stop()
+		for {
+			select {
+			case <-req.Context().Done():
+				return
+			case <-keepAlive.C:
+				fmt.Fprint(w, ": keep-alive\n\n")
+			case rec := <-ch:
+				raw, err := json.Marshal(rec)
+				if err != nil {
+					log.Errorln(logTag, "error encountered while marshalling record :", err)
+					continue
+				}
+				fmt.Fprintf(w, "event: record\ndata: %s\n\n", raw)
+			}
+			flusher.Flush()
+		}
+	}
+}
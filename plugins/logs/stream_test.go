@@ -0,0 +1,64 @@
+package logs
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestStreamLogs(t *testing.T) {
+	Convey("Stream the records as server-sent events", t, func() {
+		l := newTestLogs(t)
+		server := httptest.NewServer(l.streamLogs())
+		defer server.Close()
+
+		resp, err := http.Get(server.URL)
+		So(err, ShouldBeNil)
+		So(resp.StatusCode, ShouldEqual, http.StatusOK)
+		So(resp.Header.Get("Content-Type"), ShouldEqual, "text/event-stream")
+
+		recordTestResponse(t, l, newTestRequest(http.MethodPost, "/books/_search", `{"query":{"match_all":{}}}`), http.StatusOK, `{"hits":{}}`)
+
+		events := make(chan string, 1)
+		go func() {
+			scanner := bufio.NewScanner(resp.Body)
+			for scanner.Scan() {
+				if line := scanner.Text(); strings.HasPrefix(line, "data: ") {
+					events <- strings.TrimPrefix(line, "data: ")
+					return
+				}
+			}
+		}()
+		var data string
+		select {
+		case data = <-events:
+		case <-time.After(5 * time.Second):
+			t.Fatal("no event was streamed")
+		}
+		var rec record
+		So(json.Unmarshal([]byte(data), &rec), ShouldBeNil)
+		So(rec.Indices, ShouldResemble, []string{"books"})
+		So(rec.Response.Code, ShouldEqual, http.StatusOK)
+
+		Convey("The subscriber is removed once the client disconnects", func() {
+			resp.Body.Close()
+			deadline := time.Now().Add(5 * time.Second)
+			for time.Now().Before(deadline) {
+				l.streams.mu.RLock()
+				n := len(l.streams.subscribers)
+				l.streams.mu.RUnlock()
+				if n == 0 {
+					break
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			So(l.streams.subscribers, ShouldBeEmpty)
+		})
+	})
+}
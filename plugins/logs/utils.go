@@ -1,8 +1,235 @@
 package logs
 
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/buger/jsonparser"
+
+	"github.com/appbaseio/reactivesearch-api/middleware/classify"
+	"github.com/appbaseio/reactivesearch-api/util"
+)
+
+// statusRange is an inclusive range of HTTP status codes.
+type statusRange struct {
+	from int
+	to   int
+}
+
+// parseStatusRanges parses a comma separated list of status codes and ranges,
+// e.g. "2xx,404,500-503".
+func parseStatusRanges(value string) ([]statusRange, error) {
+	var ranges []statusRange
+	for _, token := range strings.Split(value, ",") {
+		token = strings.ToLower(strings.TrimSpace(token))
+		if token == "" {
+			continue
+		}
+		var r statusRange
+		var err error
+		switch {
+		case len(token) == 3 && strings.HasSuffix(token, "xx"):
+			var class int
+			class, err = strconv.Atoi(token[:1])
+			r = statusRange{class * 100, class*100 + 99}
+		case strings.Contains(token, "-"):
+			bounds := strings.SplitN(token, "-", 2)
+			r.from, err = strconv.Atoi(strings.TrimSpace(bounds[0]))
+			if err == nil {
+				r.to, err = strconv.Atoi(strings.TrimSpace(bounds[1]))
+			}
+		default:
+			r.from, err = strconv.Atoi(token)
+			r.to = r.from
+		}
+		if err != nil || r.from < 100 || r.to > 599 || r.from > r.to {
+			return nil, fmt.Errorf("invalid status range: %s", token)
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, nil
+}
+
+// parseRecordStatus parses the status codes of the recorded requests, `all` to record
+// every request, `errors` for the 4xx and 5xx ones, or a list of status ranges. It
+// returns nil if every request is recorded.
+func parseRecordStatus(value string) ([]statusRange, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "all":
+		return nil, nil
+	case "errors":
+		return []statusRange{{400, 599}}, nil
+	}
+	ranges, err := parseStatusRanges(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(ranges) == 0 {
+		return nil, fmt.Errorf("no status range in: %s", value)
+	}
+	return ranges, nil
+}
+
+// matchesStatus returns true if the status code falls in any of the ranges.
+func matchesStatus(ranges []statusRange, code int) bool {
+	for _, r := range ranges {
+		if code >= r.from && code <= r.to {
+			return true
+		}
+	}
+	return false
+}
+
+// parseIndexPatterns parses a comma separated list of index names and glob patterns,
+// e.g. "users,customers-*".
+func parseIndexPatterns(value string) ([]string, error) {
+	var patterns []string
+	for _, pattern := range strings.Split(value, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid index pattern: %s", pattern)
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, nil
+}
+
+// matchesIndex returns true if any of the indices matches any of the patterns.
+func matchesIndex(patterns, indices []string) bool {
+	for _, index := range indices {
+		for _, pattern := range patterns {
+			if matched, _ := path.Match(pattern, index); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// targetsIndex reports whether the targets of a request may reach an index matching the
+// patterns, the aliases through the index they point at, and `_all` and the wildcard
+// expressions if they may expand onto a matching index.
+func targetsIndex(patterns, targets []string) bool {
+	if len(patterns) == 0 {
+		return false
+	}
+	for _, target := range targets {
+		names := []string{target}
+		if indexName := classify.GetAliasIndex(target); indexName != "" {
+			names = append(names, indexName)
+		}
+		if matchesIndex(patterns, names) || target == "_all" {
+			return true
+		}
+		if strings.Contains(target, "*") {
+			for _, pattern := range patterns {
+				if util.PatternsOverlap(target, pattern) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// bodyIndices returns the indices targeted in the body of a request, i.e. by the actions
+// of a bulk body, the headers of a msearch body and the docs of a mget body.
+func bodyIndices(r *http.Request, body []byte) []string {
+	var indices []string
+	urlPath := strings.TrimSuffix(r.URL.Path, "/")
+	switch {
+	case strings.HasSuffix(urlPath, "/_bulk"):
+		expectSource := false
+		for _, line := range bytes.Split(body, []byte("\n")) {
+			if len(bytes.TrimSpace(line)) == 0 {
+				continue
+			}
+			if expectSource {
+				expectSource = false
+				continue
+			}
+			// each action is keyed by its name, e.g. {"index": {"_index": "books"}}
+			jsonparser.ObjectEach(line, func(action []byte, meta []byte, dataType jsonparser.ValueType, offset int) error {
+				if indexName, err := jsonparser.GetString(meta, "_index"); err == nil {
+					indices = append(indices, indexName)
+				}
+				expectSource = string(action) != "delete"
+				return nil
+			})
+		}
+	case strings.HasSuffix(urlPath, "/_msearch"):
+		isHeader := true
+		for _, line := range bytes.Split(body, []byte("\n")) {
+			if len(bytes.TrimSpace(line)) == 0 {
+				continue
+			}
+			if isHeader {
+				value, dataType, _, err := jsonparser.Get(line, "index")
+				if err == nil && dataType == jsonparser.String {
+					indices = append(indices, strings.Split(string(value), ",")...)
+				} else if err == nil && dataType == jsonparser.Array {
+					jsonparser.ArrayEach(value, func(item []byte, dataType jsonparser.ValueType, offset int, err error) {
+						indices = append(indices, string(item))
+					})
+				}
+			}
+			isHeader = !isHeader
+		}
+	case strings.HasSuffix(urlPath, "/_mget"):
+		jsonparser.ArrayEach(body, func(doc []byte, dataType jsonparser.ValueType, offset int, err error) {
+			if indexName, err := jsonparser.GetString(doc, "_index"); err == nil {
+				indices = append(indices, indexName)
+			}
+		}, "docs")
+	}
+	return indices
+}
+
+// parseTags parses a comma separated list of key:value tags, e.g. "env:prod,region:us".
+func parseTags(value string) (map[string]string, error) {
+	var tags map[string]string
+	for _, token := range strings.Split(value, ",") {
+		token = strings.TrimSpace(token)
+		if token == "" {
+			continue
+		}
+		kv := strings.SplitN(token, ":", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" || strings.TrimSpace(kv[1]) == "" {
+			return nil, fmt.Errorf("invalid tag: %s", token)
+		}
+		key := strings.TrimSpace(kv[0])
+		if tags == nil {
+			tags = make(map[string]string)
+		}
+		if _, ok := tags[key]; ok {
+			return nil, fmt.Errorf("duplicate tag: %s", key)
+		}
+		tags[key] = strings.TrimSpace(kv[1])
+	}
+	return tags, nil
+}
+
 // LogsMappings mappings for .logs indices
 const LogsMappings = `{
    "dynamic":false,
+   "dynamic_templates":[
+      {
+         "promoted_headers":{
+            "path_match":"request.promoted_headers.*",
+            "mapping":{
+               "type":"keyword",
+               "ignore_above":256
+            }
+         }
+      }
+   ],
    "properties":{
       "@timestamp":{
          "type":"date"
@@ -16,6 +243,9 @@ const LogsMappings = `{
             }
          }
       },
+      "category_code":{
+         "type":"integer"
+      },
       "indices":{
          "type":"text",
          "fields":{
@@ -25,8 +255,75 @@ const LogsMappings = `{
             }
          }
       },
+      "raw_indices":{
+         "type":"keyword",
+         "ignore_above":256
+      },
+      "tags":{
+         "type":"object",
+         "dynamic":true
+      },
+      "category_fallback":{
+         "type":"boolean"
+      },
+      "auth_method":{
+         "type":"keyword"
+      },
+      "timing":{
+         "properties":{
+            "auth_ms":{
+               "type":"float"
+            },
+            "validate_ms":{
+               "type":"float"
+            },
+            "upstream_ms":{
+               "type":"float"
+            },
+            "total_ms":{
+               "type":"float"
+            },
+            "auth_lookup_ms":{
+               "type":"float"
+            }
+         }
+      },
+      "auth_cache_hit":{
+         "type":"boolean"
+      },
+      "flags":{
+         "properties":{
+            "possible_zip_bomb":{
+               "type":"boolean"
+            }
+         }
+      },
       "request":{
          "properties":{
+            "body_hash":{
+               "type":"keyword"
+            },
+            "api_version":{
+               "type":"keyword"
+            },
+            "promoted_headers":{
+               "type":"object",
+               "dynamic":true
+            },
+            "complexity_score":{
+               "type":"float"
+            },
+            "rs_components":{
+               "properties":{
+                  "count":{
+                     "type":"integer"
+                  },
+                  "types":{
+                     "type":"object",
+                     "dynamic":true
+                  }
+               }
+            },
             "body":{
                "type":"text",
                "fields":{
@@ -296,6 +593,41 @@ const LogsMappings = `{
       },
       "response":{
          "properties":{
+            "resolved_index":{
+               "type":"keyword"
+            },
+            "bulk_errors":{
+               "properties":{
+                  "succeeded":{
+                     "type":"long"
+                  },
+                  "failed":{
+                     "type":"long"
+                  },
+                  "reasons":{
+                     "type":"keyword"
+                  }
+               }
+            },
+            "cache":{
+               "properties":{
+                  "cache_control":{
+                     "type":"keyword"
+                  },
+                  "max_age":{
+                     "type":"long"
+                  },
+                  "etag":{
+                     "type":"keyword"
+                  },
+                  "age":{
+                     "type":"long"
+                  },
+                  "cacheable":{
+                     "type":"boolean"
+                  }
+               }
+            },
             "Headers":{
                "properties":{
                   "Access-Control-Allow-Credentials":{
@@ -458,6 +790,9 @@ const LogsMappings = `{
             },
             "took":{
                "type":"long"
+            },
+            "shards_touched":{
+               "type":"long"
             }
          }
       },
@@ -0,0 +1,236 @@
+package logs
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const envWALPath = "LOGS_WAL_PATH"
+
+var (
+	// walMaxPending is the number of records the log holds until they're indexed, the
+	// oldest ones are dropped beyond it so that a failing cluster doesn't grow it unbounded
+	walMaxPending = 10000
+	// walCompactEntries is the number of entries written to the log after which it's
+	// rewritten with only its pending records
+	walCompactEntries = 10000
+)
+
+// walEntry is a line of the write-ahead log, either a record pending to be indexed or
+// the acknowledgement of an indexed one.
+type walEntry struct {
+	Seq    uint64  `json:"seq,omitempty"`
+	Record *record `json:"record,omitempty"`
+	Ack    uint64  `json:"ack,omitempty"`
+}
+
+// wal is an append-only file the records are durably written to before they're indexed,
+// so that the records that weren't indexed, e.g. because of a crash, are replayed on
+// startup. The file is truncated once all its records are indexed, and compacted once
+// it holds many more entries than pending records.
+type wal struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	seq     uint64
+	pending map[uint64]record
+	// oldest is lower than or equal to the sequence numbers of the pending records
+	oldest uint64
+	// entries is the number of the entries of the file
+	entries int
+}
+
+// openWAL opens the write-ahead log at the path, and returns along with it the entries of
+// the records that weren't acknowledged, ordered as they were written.
+func openWAL(path string) (*wal, []walEntry, error) {
+	w := &wal{path: path, pending: make(map[uint64]record)}
+	records := make(map[uint64]walEntry)
+	if f, err := os.Open(path); err == nil {
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		for scanner.Scan() {
+			w.entries++
+			var entry walEntry
+			if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+				// the last line is torn if the write of its record was interrupted
+				log.Warnln(logTag, ": skipping an unreadable entry of the write-ahead log :", err)
+				continue
+			}
+			if entry.Ack != 0 {
+				delete(records, entry.Ack)
+				continue
+			}
+			if entry.Record != nil {
+				records[entry.Seq] = entry
+			}
+			if entry.Seq > w.seq {
+				w.seq = entry.Seq
+			}
+		}
+		f.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, nil, fmt.Errorf("error reading the write-ahead log %s: %v", path, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, nil, fmt.Errorf("error opening the write-ahead log %s: %v", path, err)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error opening the write-ahead log %s: %v", path, err)
+	}
+	w.file = file
+
+	entries := make([]walEntry, 0, len(records))
+	for seq, entry := range records {
+		w.pending[seq] = *entry.Record
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Seq < entries[j].Seq })
+	if len(entries) > 0 {
+		w.oldest = entries[0].Seq
+	}
+	return w, entries, nil
+}
+
+// append durably writes the record to the log and returns its sequence number. The
+// oldest pending record is dropped if the log holds too many of them.
+func (w *wal) append(rec record) (uint64, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	seq := w.seq + 1
+	if err := w.write(walEntry{Seq: seq, Record: &rec}); err != nil {
+		return 0, err
+	}
+	if err := w.file.Sync(); err != nil {
+		return 0, err
+	}
+	w.seq = seq
+	w.pending[seq] = rec
+	if len(w.pending) > walMaxPending {
+		w.dropOldest()
+	}
+	w.compact()
+	return seq, nil
+}
+
+// dropOldest acknowledges the oldest pending record without indexing it.
+func (w *wal) dropOldest() {
+	for ; w.oldest <= w.seq; w.oldest++ {
+		if _, ok := w.pending[w.oldest]; ok {
+			break
+		}
+	}
+	delete(w.pending, w.oldest)
+	log.Warnln(logTag, ": dropping the log record", w.oldest, "of the write-ahead log, more than", walMaxPending, "records are pending")
+	if err := w.write(walEntry{Ack: w.oldest}); err != nil {
+		log.Errorln(logTag, ": error acknowledging a record of the write-ahead log :", err)
+	}
+}
+
+// ack acknowledges the indexing of the record, the log is truncated if none of its
+// records is pending anymore.
+func (w *wal) ack(seq uint64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.pending, seq)
+	if len(w.pending) == 0 {
+		if err := w.file.Truncate(0); err == nil {
+			w.entries = 0
+			return
+		}
+	}
+	if err := w.write(walEntry{Ack: seq}); err != nil {
+		log.Errorln(logTag, ": error acknowledging a record of the write-ahead log :", err)
+	}
+	w.compact()
+}
+
+// compact rewrites the log with only its pending records once it holds at least
+// walCompactEntries entries and twice as many as the pending records.
+func (w *wal) compact() {
+	if w.entries < walCompactEntries || w.entries < 2*len(w.pending) {
+		return
+	}
+	seqs := make([]uint64, 0, len(w.pending))
+	for seq := range w.pending {
+		seqs = append(seqs, seq)
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+
+	tmpPath := w.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		log.Errorln(logTag, ": error compacting the write-ahead log :", err)
+		return
+	}
+	var lines bytes.Buffer
+	for _, seq := range seqs {
+		rec := w.pending[seq]
+		line, err := json.Marshal(walEntry{Seq: seq, Record: &rec})
+		if err != nil {
+			continue
+		}
+		lines.Write(append(line, '\n'))
+	}
+	if _, err = tmp.Write(lines.Bytes()); err == nil {
+		err = tmp.Sync()
+	}
+	tmp.Close()
+	if err == nil {
+		err = os.Rename(tmpPath, w.path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		log.Errorln(logTag, ": error compacting the write-ahead log :", err)
+		return
+	}
+	file, err := os.OpenFile(w.path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		log.Errorln(logTag, ": error reopening the write-ahead log :", err)
+		return
+	}
+	w.file.Close()
+	w.file = file
+	w.entries = len(seqs)
+}
+
+func (w *wal) write(entry walEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if _, err = w.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	w.entries++
+	return nil
+}
+
+func (w *wal) close() error {
+	return w.file.Close()
+}
+
+// replayWAL indexes the records of the write-ahead log that weren't acknowledged, the ones
+// failing again stay in the log until the next startup. It's run in the background so
+// that a large log doesn't hold the startup.
+func (es *elasticsearch) replayWAL(ctx context.Context, entries []walEntry) {
+	if len(entries) == 0 {
+		return
+	}
+	log.Println(logTag, ": replaying", len(entries), "log records of the write-ahead log")
+	for _, entry := range entries {
+		alias := es.recordAlias(ctx, *entry.Record)
+		if es.indexPrimaryRecord(ctx, alias, *entry.Record) == nil {
+			es.wal.ack(entry.Seq)
+		}
+	}
+}
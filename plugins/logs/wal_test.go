@@ -0,0 +1,135 @@
+package logs
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestWriteAheadLog(t *testing.T) {
+	Convey("Log the records ahead of indexing them", t, func() {
+		path := filepath.Join(t.TempDir(), "logs.wal")
+		w, entries, err := openWAL(path)
+		So(err, ShouldBeNil)
+		So(entries, ShouldBeEmpty)
+		rec := record{Indices: []string{"books"}, Timestamp: time.Now()}
+
+		Convey("A record is removed once it's indexed", func() {
+			server := useTestES(t, "")
+			es := &elasticsearch{indexName: ".logs", wal: w}
+			es.indexRecord(context.Background(), rec)
+			So(server.indexed, ShouldResemble, []string{".logs"})
+			So(w.pending, ShouldBeEmpty)
+			info, err := os.Stat(path)
+			So(err, ShouldBeNil)
+			So(info.Size(), ShouldEqual, 0)
+			So(w.close(), ShouldBeNil)
+		})
+
+		Convey("A record that wasn't indexed survives a crash", func() {
+			server := useTestES(t, ".logs")
+			es := &elasticsearch{indexName: ".logs", wal: w}
+			es.indexRecord(context.Background(), rec)
+			es.indexRecord(context.Background(), record{Indices: []string{"authors"}, Timestamp: time.Now()})
+			So(server.indexed, ShouldBeEmpty)
+			// crash without acknowledging the records
+			So(w.close(), ShouldBeNil)
+
+			server.reset("")
+			replayed, entries, err := openWAL(path)
+			So(err, ShouldBeNil)
+			So(len(entries), ShouldEqual, 2)
+			So(entries[0].Record.Indices, ShouldResemble, []string{"books"})
+			So(entries[1].Record.Indices, ShouldResemble, []string{"authors"})
+
+			es = &elasticsearch{indexName: ".logs", wal: replayed}
+			es.replayWAL(context.Background(), entries)
+			So(server.indexed, ShouldResemble, []string{".logs", ".logs"})
+			So(replayed.pending, ShouldBeEmpty)
+			So(replayed.close(), ShouldBeNil)
+
+			// nothing is left to replay once the records are indexed
+			_, entries, err = openWAL(path)
+			So(err, ShouldBeNil)
+			So(entries, ShouldBeEmpty)
+		})
+
+		Convey("A recorded request is logged until it's indexed", func() {
+			server := useTestES(t, ".logs")
+			l := newTestLogs(t)
+			l.es = &elasticsearch{indexName: ".logs", wal: w}
+			recordTestResponse(t, l, newTestRequest("POST", "/books/_search", `{}`), http.StatusOK, `{"took":1}`)
+			So(server.indexed, ShouldBeEmpty)
+			So(len(w.pending), ShouldEqual, 1)
+			So(w.close(), ShouldBeNil)
+
+			reopened, entries, err := openWAL(path)
+			So(err, ShouldBeNil)
+			So(len(entries), ShouldEqual, 1)
+			So(entries[0].Record.Indices, ShouldResemble, []string{"books"})
+			So(reopened.close(), ShouldBeNil)
+		})
+
+		Convey("The acknowledged records aren't replayed", func() {
+			server := useTestES(t, ".logs")
+			es := &elasticsearch{indexName: ".logs", wal: w}
+			es.indexRecord(context.Background(), rec)
+			server.reset("")
+			es.indexRecord(context.Background(), record{Indices: []string{"authors"}, Timestamp: time.Now()})
+			So(w.close(), ShouldBeNil)
+
+			reopened, entries, err := openWAL(path)
+			So(err, ShouldBeNil)
+			So(len(entries), ShouldEqual, 1)
+			So(entries[0].Record.Indices, ShouldResemble, []string{"books"})
+			// the sequence continues after the logged records
+			So(reopened.seq, ShouldEqual, 2)
+			So(reopened.close(), ShouldBeNil)
+		})
+
+		Convey("The oldest records are dropped beyond the pending limit", func() {
+			walMaxPending = 2
+			Reset(func() { walMaxPending = 10000 })
+			useTestES(t, ".logs")
+			es := &elasticsearch{indexName: ".logs", wal: w}
+			for _, indexName := range []string{"books", "authors", "genres"} {
+				es.indexRecord(context.Background(), record{Indices: []string{indexName}, Timestamp: time.Now()})
+			}
+			So(len(w.pending), ShouldEqual, 2)
+			So(w.close(), ShouldBeNil)
+
+			reopened, entries, err := openWAL(path)
+			So(err, ShouldBeNil)
+			So(len(entries), ShouldEqual, 2)
+			So(entries[0].Record.Indices, ShouldResemble, []string{"authors"})
+			So(entries[1].Record.Indices, ShouldResemble, []string{"genres"})
+			So(reopened.close(), ShouldBeNil)
+		})
+
+		Convey("The log is compacted to its pending records", func() {
+			walCompactEntries = 4
+			Reset(func() { walCompactEntries = 10000 })
+			server := useTestES(t, ".logs")
+			es := &elasticsearch{indexName: ".logs", wal: w}
+			es.indexRecord(context.Background(), rec)
+			server.reset("")
+			for i := 0; i < 3; i++ {
+				es.indexRecord(context.Background(), record{Indices: []string{"authors"}, Timestamp: time.Now()})
+			}
+			So(w.entries, ShouldBeLessThan, 4)
+			So(w.close(), ShouldBeNil)
+
+			reopened, entries, err := openWAL(path)
+			So(err, ShouldBeNil)
+			So(len(entries), ShouldEqual, 1)
+			So(entries[0].Record.Indices, ShouldResemble, []string{"books"})
+			So(reopened.entries, ShouldBeLessThan, 4)
+			So(reopened.close(), ShouldBeNil)
+		})
+	})
+}
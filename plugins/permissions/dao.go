@@ -88,12 +88,15 @@ func (es *elasticsearch) getRawPermission(ctx context.Context, username string)
 }
 
 func (es *elasticsearch) postPermission(ctx context.Context, p permission.Permission) (bool, error) {
-	_, err := util.GetClient7().Index().
-		Refresh("wait_for").
-		Index(es.indexName).
-		Id(p.Username).
-		BodyJson(p).
-		Do(ctx)
+	err := util.RetryWrite(ctx, func() error {
+		_, err := util.GetClient7().Index().
+			Refresh("wait_for").
+			Index(es.indexName).
+			Id(p.Username).
+			BodyJson(p).
+			Do(ctx)
+		return err
+	})
 	if err != nil {
 		return false, err
 	}
@@ -102,12 +105,18 @@ func (es *elasticsearch) postPermission(ctx context.Context, p permission.Permis
 }
 
 func (es *elasticsearch) patchPermission(ctx context.Context, username string, patch map[string]interface{}) ([]byte, error) {
-	switch util.GetVersion() {
-	case 6:
-		return es.patchPermissionEs6(ctx, username, patch)
-	default:
-		return es.patchPermissionEs7(ctx, username, patch)
-	}
+	var raw []byte
+	err := util.RetryWrite(ctx, func() error {
+		var err error
+		switch util.GetVersion() {
+		case 6:
+			raw, err = es.patchPermissionEs6(ctx, username, patch)
+		default:
+			raw, err = es.patchPermissionEs7(ctx, username, patch)
+		}
+		return err
+	})
+	return raw, err
 }
 
 func (es *elasticsearch) deletePermission(ctx context.Context, username string) (bool, error) {
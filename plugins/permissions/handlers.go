@@ -14,6 +14,8 @@ import (
 	"github.com/appbaseio/reactivesearch-api/model/user"
 	"github.com/appbaseio/reactivesearch-api/plugins/auth"
 	"github.com/appbaseio/reactivesearch-api/util"
+	"github.com/appbaseio/reactivesearch-api/util/audit"
+	"github.com/appbaseio/reactivesearch-api/util/iplookup"
 	"github.com/gorilla/mux"
 )
 
@@ -95,6 +97,39 @@ func (p *permissions) postPermission(opts ...permission.Options) http.HandlerFun
 		if permissionBody.Indices != nil {
 			permissionOptions = append(permissionOptions, permission.SetIndices(permissionBody.Indices))
 		}
+		if permissionBody.AllowScripts != nil {
+			permissionOptions = append(permissionOptions, permission.SetAllowScripts(*permissionBody.AllowScripts))
+		}
+		if permissionBody.AllowedOrigins != nil {
+			permissionOptions = append(permissionOptions, permission.SetAllowedOrigins(permissionBody.AllowedOrigins))
+		}
+		if permissionBody.AllowedSortFields != nil {
+			permissionOptions = append(permissionOptions, permission.SetAllowedSortFields(permissionBody.AllowedSortFields))
+		}
+		if permissionBody.DeniedQueryClauses != nil {
+			permissionOptions = append(permissionOptions, permission.SetDeniedQueryClauses(permissionBody.DeniedQueryClauses))
+		}
+		if permissionBody.StablePreference != nil {
+			permissionOptions = append(permissionOptions, permission.SetStablePreference(*permissionBody.StablePreference))
+		}
+		if permissionBody.ForceSourceFiltering != nil {
+			permissionOptions = append(permissionOptions, permission.SetForceSourceFiltering(*permissionBody.ForceSourceFiltering))
+		}
+		if permissionBody.MaxBulkActions != nil {
+			permissionOptions = append(permissionOptions, permission.SetMaxBulkActions(*permissionBody.MaxBulkActions))
+		}
+		if permissionBody.TerminateAfter != nil {
+			permissionOptions = append(permissionOptions, permission.SetTerminateAfter(*permissionBody.TerminateAfter))
+		}
+		if permissionBody.DailyQuota != nil {
+			permissionOptions = append(permissionOptions, permission.SetDailyQuota(*permissionBody.DailyQuota))
+		}
+		if permissionBody.MaxPaginationDepth != nil {
+			permissionOptions = append(permissionOptions, permission.SetMaxPaginationDepth(*permissionBody.MaxPaginationDepth))
+		}
+		if permissionBody.Aggregations != nil {
+			permissionOptions = append(permissionOptions, permission.SetAggregations(permissionBody.Aggregations))
+		}
 		if permissionBody.Limits != nil {
 			permissionOptions = append(permissionOptions, permission.SetLimits(permissionBody.Limits, *reqUser.IsAdmin))
 		}
@@ -144,6 +179,10 @@ func (p *permissions) postPermission(opts ...permission.Options) http.HandlerFun
 
 		ok, err := p.es.postPermission(req.Context(), *newPermission)
 		if ok && err == nil {
+			auditChange(req, audit.PermissionCreated, newPermission.Username)
+			if newPermission.Role != "" {
+				auth.RemoveRolePermissionFromCache(newPermission.Role)
+			}
 			util.WriteBackRaw(w, rawPermission, http.StatusOK)
 			return
 		}
@@ -246,6 +285,10 @@ func (p *permissions) patchPermission() http.HandlerFunc {
 
 		_, err2 := p.es.patchPermission(req.Context(), username, patch)
 		if err2 == nil {
+			auditChange(req, audit.PermissionUpdated, username)
+			if roleExistsInPatch && obj.Role != "" {
+				auth.RemoveRolePermissionFromCache(obj.Role)
+			}
 			// Only update local state when proxy API has not been called
 			// If proxy API would get called then it would automatically update the
 			// state for all machines
@@ -304,6 +347,7 @@ func (p *permissions) deletePermission() http.HandlerFunc {
 
 		ok, err := p.es.deletePermission(req.Context(), username)
 		if ok && err == nil {
+			auditChange(req, audit.PermissionDeleted, username)
 			// Only update local state when proxy API has not been called
 			// If proxy API would get called then it would automatically update the
 			// state for all machines
@@ -439,3 +483,12 @@ func (p *permissions) role() http.HandlerFunc {
 		}
 	}
 }
+
+// auditChange emits an audit event for a change of the permission made by the request user.
+func auditChange(req *http.Request, eventType, username string) {
+	e := audit.Event{Type: eventType, Username: username, ClientIP: iplookup.FromRequest(req)}
+	if reqUser, err := user.FromContext(req.Context()); err == nil {
+		e.Actor = reqUser.Username
+	}
+	audit.Emit(e)
+}
@@ -1,6 +1,7 @@
 package querytranslate
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -14,6 +15,7 @@ import (
 	"github.com/appbaseio/reactivesearch-api/model/category"
 	"github.com/appbaseio/reactivesearch-api/model/op"
 	"github.com/appbaseio/reactivesearch-api/model/permission"
+	"github.com/appbaseio/reactivesearch-api/model/request"
 	"github.com/appbaseio/reactivesearch-api/plugins/auth"
 	"github.com/appbaseio/reactivesearch-api/plugins/logs"
 	"github.com/appbaseio/reactivesearch-api/util"
@@ -40,9 +42,13 @@ func list() []middleware.Middleware {
 		saveRequestToCtx, // middleware to save the request body in context
 		logs.Recorder(),
 		auth.BasicAuth(),
+		auth.Webhook(),
 		ratelimiter.Limit(),
+		ratelimiter.DailyQuota(),
+		ratelimiter.IndexRate(),
 		validate.Sources(),
 		validate.Referers(),
+		validate.Origins(),
 		validate.Indices(),
 		validate.Category(),
 		validate.Operation(),
@@ -84,7 +90,10 @@ func classifyOp(h http.HandlerFunc) http.HandlerFunc {
 func saveRequestToCtx(h http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		var body RSQuery
-		err := json.NewDecoder(req.Body).Decode(&body)
+		raw, err := ioutil.ReadAll(req.Body)
+		if err == nil {
+			err = json.NewDecoder(bytes.NewReader(raw)).Decode(&body)
+		}
 		if err != nil {
 			log.Errorln(logTag, ":", err)
 			util.WriteBackError(w, fmt.Sprintf("Can't parse request body: %v", err), http.StatusBadRequest)
@@ -93,6 +102,11 @@ func saveRequestToCtx(h http.HandlerFunc) http.HandlerFunc {
 		// Set request body as nil to avoid memory issues (storage duplication)
 		req.Body = nil
 		ctx := NewContext(req.Context(), body)
+		// the raw body keeps the numbers that don't fit a float64, e.g. the 64-bit ids,
+		// it's only kept for the logs recording the bodies with their numbers as sent
+		if logs.Instance().PreservesNumbers() {
+			ctx = request.NewRawBodyContext(ctx, raw)
+		}
 		req = req.WithContext(ctx)
 		h(w, req)
 	}
@@ -146,6 +160,10 @@ func queryTranslate(h http.HandlerFunc) http.HandlerFunc {
 			util.WriteBackError(w, err.Error(), http.StatusBadRequest)
 			return
 		}
+		// Make the translated query available to the logs recorder
+		if esQuery, err := request.ESQueryFromContext(req.Context()); err == nil {
+			esQuery.Query = msearchQuery
+		}
 		// Update the request body to the parsed query
 		req.Body = ioutil.NopCloser(strings.NewReader(msearchQuery))
 		h(w, req)
@@ -0,0 +1,26 @@
+package querytranslate
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/appbaseio/reactivesearch-api/model/request"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSaveRequestToCtx(t *testing.T) {
+	Convey("The raw body isn't kept unless the logs preserve the numbers", t, func() {
+		req := httptest.NewRequest(http.MethodPost, "/_reactivesearch", strings.NewReader(`{"query":[{"id":"search"}]}`))
+		var raw []byte
+		var saved bool
+		saveRequestToCtx(func(w http.ResponseWriter, r *http.Request) {
+			_, err := FromContext(r.Context())
+			saved = err == nil
+			raw = request.RawBodyFromContext(r.Context())
+		})(httptest.NewRecorder(), req)
+		So(saved, ShouldBeTrue)
+		So(raw, ShouldBeNil)
+	})
+}
@@ -7,7 +7,9 @@ import (
 	"math"
 	"reflect"
 	"strings"
+	"time"
 
+	"github.com/appbaseio/reactivesearch-api/model/timing"
 	"github.com/appbaseio/reactivesearch-api/util"
 	es7 "github.com/olivere/elastic/v7"
 	log "github.com/sirupsen/logrus"
@@ -590,7 +592,9 @@ func makeESRequest(ctx context.Context, url, method string, reqBody []byte) (*es
 		Path:   url,
 		Body:   string(reqBody),
 	}
+	start := time.Now()
 	response, err := esClient.PerformRequest(ctx, requestOptions)
+	timing.Add(ctx, timing.Upstream, time.Since(start))
 	if err != nil {
 		log.Errorln("Error while making request: ", err)
 		return response, err
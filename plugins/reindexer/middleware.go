@@ -30,6 +30,7 @@ func list() []middleware.Middleware {
 		validate.Indices(),
 		validate.Operation(),
 		validate.Category(),
+		validate.Maintenance(),
 	}
 }
 
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 
+	es7 "github.com/olivere/elastic/v7"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/appbaseio/reactivesearch-api/model/user"
@@ -64,7 +65,7 @@ func initPlugin(indexName, mapping string) (*elasticsearch, error) {
 
 func (es *elasticsearch) hashPasswords() error {
 	// get all users
-	rawUsers, err := es.getRawUsers(context.Background())
+	rawUsers, err := es.GetRawUsers(context.Background())
 	if err != nil {
 		return err
 	}
@@ -90,7 +91,7 @@ func (es *elasticsearch) hashPasswords() error {
 		}
 
 		// patch the user
-		_, err = es.patchUser(context.Background(), user.Username, map[string]interface{}{
+		_, err = es.PatchUser(context.Background(), user.Username, map[string]interface{}{
 			"password":           string(hashedPassword),
 			"password_hash_type": "bcrypt",
 		})
@@ -110,7 +111,18 @@ func (es *elasticsearch) postMasterUser() error {
 	// master user. ReactiveSearch shouldn't be initialized without a root user.
 	username, password := os.Getenv("USERNAME"), os.Getenv("PASSWORD")
 	if username == "" {
-		username, password = "foo", "bar"
+		username, password = util.DefaultMasterUsername, util.DefaultMasterPassword
+		log.Warnln(logTag, ": USERNAME isn't set, creating the master user with the default credentials")
+	}
+
+	if os.Getenv(envRotateMasterPass) == "true" {
+		rotated, err := rotateMasterPassword(context.Background(), es, username, password)
+		if err != nil {
+			return fmt.Errorf("%s: error while rotating the master user password: %v", logTag, err)
+		}
+		if rotated {
+			return nil
+		}
 	}
 
 	// hash the password
@@ -127,14 +139,14 @@ func (es *elasticsearch) postMasterUser() error {
 
 	admin.PasswordHashType = "bcrypt"
 
-	if created, err := es.postUser(context.Background(), *admin); !created || err != nil {
+	if created, err := es.PostUser(context.Background(), *admin); !created || err != nil {
 		return fmt.Errorf("%s: error while creating a master user: %v", logTag, err)
 	}
 	return nil
 }
 
-func (es *elasticsearch) getUser(ctx context.Context, username string) (*user.User, error) {
-	raw, err := es.getRawUser(ctx, username)
+func (es *elasticsearch) GetUser(ctx context.Context, username string) (*user.User, error) {
+	raw, err := es.GetRawUser(ctx, username)
 	if err != nil {
 		return nil, err
 	}
@@ -148,16 +160,16 @@ func (es *elasticsearch) getUser(ctx context.Context, username string) (*user.Us
 	return &u, nil
 }
 
-func (es *elasticsearch) getRawUsers(ctx context.Context) ([]byte, error) {
+func (es *elasticsearch) GetRawUsers(ctx context.Context, fields ...string) ([]byte, error) {
 	switch util.GetVersion() {
 	case 6:
-		return es.getRawUsersEs6(ctx)
+		return es.getRawUsersEs6(ctx, fields)
 	default:
-		return es.getRawUsersEs7(ctx)
+		return es.getRawUsersEs7(ctx, fields)
 	}
 }
 
-func (es *elasticsearch) getRawUser(ctx context.Context, username string) ([]byte, error) {
+func (es *elasticsearch) GetRawUser(ctx context.Context, username string) ([]byte, error) {
 	switch util.GetVersion() {
 	case 6:
 		return es.getRawUserEs6(ctx, username)
@@ -166,13 +178,16 @@ func (es *elasticsearch) getRawUser(ctx context.Context, username string) ([]byt
 	}
 }
 
-func (es *elasticsearch) postUser(ctx context.Context, u user.User) (bool, error) {
-	_, err := util.GetClient7().Index().
-		Refresh("wait_for").
-		Index(es.indexName).
-		Id(u.Username).
-		BodyJson(u).
-		Do(ctx)
+func (es *elasticsearch) PostUser(ctx context.Context, u user.User) (bool, error) {
+	err := util.RetryWrite(ctx, func() error {
+		_, err := util.GetClient7().Index().
+			Refresh("wait_for").
+			Index(es.indexName).
+			Id(u.Username).
+			BodyJson(u).
+			Do(ctx)
+		return err
+	})
 	if err != nil {
 		return false, err
 	}
@@ -180,16 +195,43 @@ func (es *elasticsearch) postUser(ctx context.Context, u user.User) (bool, error
 	return true, nil
 }
 
-func (es *elasticsearch) patchUser(ctx context.Context, username string, patch map[string]interface{}) ([]byte, error) {
-	switch util.GetVersion() {
-	case 6:
-		return es.patchUserEs6(ctx, username, patch)
-	default:
-		return es.patchUserEs7(ctx, username, patch)
+func (es *elasticsearch) CreateUser(ctx context.Context, u user.User) (bool, error) {
+	err := util.RetryWrite(ctx, func() error {
+		_, err := util.GetClient7().Index().
+			Refresh("wait_for").
+			Index(es.indexName).
+			Id(u.Username).
+			OpType("create").
+			BodyJson(u).
+			Do(ctx)
+		return err
+	})
+	if es7.IsConflict(err) {
+		return false, errUserExists
+	}
+	if err != nil {
+		return false, err
 	}
+
+	return true, nil
+}
+
+func (es *elasticsearch) PatchUser(ctx context.Context, username string, patch map[string]interface{}) ([]byte, error) {
+	var raw []byte
+	err := util.RetryWrite(ctx, func() error {
+		var err error
+		switch util.GetVersion() {
+		case 6:
+			raw, err = es.patchUserEs6(ctx, username, patch)
+		default:
+			raw, err = es.patchUserEs7(ctx, username, patch)
+		}
+		return err
+	})
+	return raw, err
 }
 
-func (es *elasticsearch) deleteUser(ctx context.Context, username string) (bool, error) {
+func (es *elasticsearch) DeleteUser(ctx context.Context, username string) (bool, error) {
 	_, err := util.GetClient7().Delete().
 		Refresh("wait_for").
 		Index(es.indexName).
@@ -5,13 +5,17 @@ import (
 	"encoding/json"
 
 	"github.com/appbaseio/reactivesearch-api/util"
+	es6 "gopkg.in/olivere/elastic.v6"
 )
 
-func (es *elasticsearch) getRawUsersEs6(ctx context.Context) ([]byte, error) {
-	response, err := util.GetClient6().Search().
+func (es *elasticsearch) getRawUsersEs6(ctx context.Context, fields []string) ([]byte, error) {
+	search := util.GetClient6().Search().
 		Index(es.indexName).
-		Size(1000).
-		Do(ctx)
+		Size(1000)
+	if len(fields) > 0 {
+		search = search.FetchSourceContext(es6.NewFetchSourceContext(true).Include(fields...))
+	}
+	response, err := search.Do(ctx)
 
 	if err != nil {
 		return nil, err
@@ -51,6 +55,9 @@ func (es *elasticsearch) getRawUserEs6(ctx context.Context, username string) ([]
 		Id(username).
 		FetchSource(true).
 		Do(ctx)
+	if es6.IsNotFound(err) {
+		return nil, errUserNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -5,13 +5,17 @@ import (
 	"encoding/json"
 
 	"github.com/appbaseio/reactivesearch-api/util"
+	es7 "github.com/olivere/elastic/v7"
 )
 
-func (es *elasticsearch) getRawUsersEs7(ctx context.Context) ([]byte, error) {
-	response, err := util.GetClient7().Search().
+func (es *elasticsearch) getRawUsersEs7(ctx context.Context, fields []string) ([]byte, error) {
+	search := util.GetClient7().Search().
 		Index(es.indexName).
-		Size(1000).
-		Do(ctx)
+		Size(1000)
+	if len(fields) > 0 {
+		search = search.FetchSourceContext(es7.NewFetchSourceContext(true).Include(fields...))
+	}
+	response, err := search.Do(ctx)
 
 	if err != nil {
 		return nil, err
@@ -49,6 +53,9 @@ func (es *elasticsearch) getRawUserEs7(ctx context.Context, username string) ([]
 		Id(username).
 		FetchSource(true).
 		Do(ctx)
+	if es7.IsNotFound(err) {
+		return nil, errUserNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
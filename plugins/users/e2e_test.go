@@ -146,6 +146,7 @@ func TestUser(t *testing.T) {
 
 			delete(parsedResponse, "password")
 			delete(parsedResponse, "created_at")
+			delete(parsedResponse, "password_changed_at")
 
 			mockMap := util.StructToMap(createUserResponse)
 
@@ -167,6 +168,7 @@ func TestUser(t *testing.T) {
 
 			delete(parsedResponse, "password")
 			delete(parsedResponse, "created_at")
+			delete(parsedResponse, "password_changed_at")
 
 			mockMap := util.StructToMap(createUserResponse)
 
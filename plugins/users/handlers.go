@@ -19,6 +19,7 @@ func (u *Users) getUser() http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		ctx := req.Context()
 		username, _, _ := req.BasicAuth()
+		username = util.NormalizeUsername(username)
 
 		// check the request context
 		if reqUser, err := user.FromContext(ctx); err == nil {
@@ -34,7 +35,7 @@ func (u *Users) getUser() http.HandlerFunc {
 		}
 
 		// fetch the user from elasticsearch
-		rawUser, err := u.es.getRawUser(req.Context(), username)
+		rawUser, err := u.es.GetRawUser(req.Context(), username)
 		if err != nil {
 			msg := fmt.Sprintf(`user with "username"="%s" not found`, username)
 			log.Errorln(logTag, ":", msg, ":", err)
@@ -54,8 +55,9 @@ func (u *Users) getUserWithUsername() http.HandlerFunc {
 			util.WriteBackError(w, `can't get a user without a "username"`, http.StatusBadRequest)
 			return
 		}
+		username = util.NormalizeUsername(username)
 
-		rawUser, err := u.es.getRawUser(req.Context(), username)
+		rawUser, err := u.es.GetRawUser(req.Context(), username)
 		if err != nil {
 			msg := fmt.Sprintf(`user with "username"="%s" not found`, username)
 			log.Errorln(logTag, ":", msg, ":", err)
@@ -68,108 +70,151 @@ func (u *Users) getUserWithUsername() http.HandlerFunc {
 
 func (u *Users) postUser() http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
-		body, err := ioutil.ReadAll(req.Body)
-		if err != nil {
-			const msg = "can't read request body"
-			log.Errorln(logTag, ":", msg, ":", err)
-			util.WriteBackError(w, msg, http.StatusBadRequest)
-			return
-		}
+		u.storeUser(w, req, "", false)
+	}
+}
 
-		var userBody user.User
-		err = json.Unmarshal(body, &userBody)
-		if err != nil {
-			msg := "can't parse request body"
-			log.Errorln(logTag, ":", msg, ":", err)
-			util.WriteBackError(w, msg, http.StatusBadRequest)
+func (u *Users) putUserWithUsername() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		username, ok := mux.Vars(req)["username"]
+		if !ok {
+			util.WriteBackError(w, `can't put user without a "username"`, http.StatusBadRequest)
 			return
 		}
+		u.storeUser(w, req, username, true)
+	}
+}
 
-		opts := []user.Options{
-			user.SetEmail(userBody.Email),
-		}
-		if userBody.IsAdmin != nil {
-			opts = append(opts, user.SetIsAdmin(*userBody.IsAdmin))
-		}
-		if userBody.AllowedActions != nil {
-			opts = append(opts, user.SetAllowedActions(*userBody.AllowedActions))
-		}
-		if userBody.ACLs != nil {
-			opts = append(opts, user.SetACLs(userBody.ACLs))
-		}
-		if userBody.Indices != nil {
-			opts = append(opts, user.SetIndices(userBody.Indices))
-		}
-		if userBody.Username == "" {
-			util.WriteBackError(w, `can't create a user without a "username"`, http.StatusBadRequest)
+// storeUser creates the user of the request body, or replaces the user with the
+// username if replace is set. A user isn't created over an existing one, which is
+// rejected with a 409 instead.
+func (u *Users) storeUser(w http.ResponseWriter, req *http.Request, username string, replace bool) {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		const msg = "can't read request body"
+		log.Errorln(logTag, ":", msg, ":", err)
+		util.WriteBackError(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	var userBody user.User
+	err = json.Unmarshal(body, &userBody)
+	if err != nil {
+		msg := "can't parse request body"
+		log.Errorln(logTag, ":", msg, ":", err)
+		util.WriteBackError(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	opts := []user.Options{
+		user.SetEmail(userBody.Email),
+	}
+	if userBody.IsAdmin != nil {
+		opts = append(opts, user.SetIsAdmin(*userBody.IsAdmin))
+	}
+	if userBody.AllowedActions != nil {
+		opts = append(opts, user.SetAllowedActions(*userBody.AllowedActions))
+	}
+	if userBody.ACLs != nil {
+		opts = append(opts, user.SetACLs(userBody.ACLs))
+	}
+	if userBody.Indices != nil {
+		opts = append(opts, user.SetIndices(userBody.Indices))
+	}
+	if username != "" {
+		if userBody.Username != "" && util.NormalizeUsername(userBody.Username) != util.NormalizeUsername(username) {
+			util.WriteBackError(w, `"username" of the body doesn't match the user's`, http.StatusBadRequest)
 			return
 		}
-		if userBody.Password == "" {
-			util.WriteBackError(w, `user "password" shouldn't be empty`, http.StatusBadRequest)
+		userBody.Username = username
+	}
+	if userBody.Username == "" {
+		util.WriteBackError(w, `can't create a user without a "username"`, http.StatusBadRequest)
+		return
+	}
+	userBody.Username = util.NormalizeUsername(userBody.Username)
+	if userBody.Password == "" {
+		util.WriteBackError(w, `user "password" shouldn't be empty`, http.StatusBadRequest)
+		return
+	}
+	// If user is not an admin then at least one action must present
+	if userBody.IsAdmin == nil || !*userBody.IsAdmin {
+		if userBody.AllowedActions == nil || len(*userBody.AllowedActions) == 0 {
+			util.WriteBackError(w, `user "allowed_actions" shouldn't be empty for non-admin users`, http.StatusBadRequest)
 			return
 		}
-		// If user is not an admin then at least one action must present
-		if userBody.IsAdmin == nil || !*userBody.IsAdmin {
-			if userBody.AllowedActions == nil || len(*userBody.AllowedActions) == 0 {
-				util.WriteBackError(w, `user "allowed_actions" shouldn't be empty for non-admin users`, http.StatusBadRequest)
-				return
-			}
-		}
+	}
 
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(userBody.Password), bcrypt.DefaultCost)
-		if err != nil {
-			msg := fmt.Sprintf("an error occurred while hashing password: %v", userBody.Password)
-			log.Errorln(logTag, ":", msg, ":", err)
-			util.WriteBackError(w, msg, http.StatusInternalServerError)
-			return
-		}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(userBody.Password), bcrypt.DefaultCost)
+	if err != nil {
+		msg := fmt.Sprintf("an error occurred while hashing password: %v", userBody.Password)
+		log.Errorln(logTag, ":", msg, ":", err)
+		util.WriteBackError(w, msg, http.StatusInternalServerError)
+		return
+	}
 
-		var newUser *user.User
-		if userBody.IsAdmin != nil && *userBody.IsAdmin {
-			newUser, err = user.NewAdmin(userBody.Username, string(hashedPassword), opts...)
-		} else {
-			newUser, err = user.New(userBody.Username, string(hashedPassword), opts...)
-		}
+	var newUser *user.User
+	if userBody.IsAdmin != nil && *userBody.IsAdmin {
+		newUser, err = user.NewAdmin(userBody.Username, string(hashedPassword), opts...)
+	} else {
+		newUser, err = user.New(userBody.Username, string(hashedPassword), opts...)
+	}
 
-		if err != nil {
-			msg := fmt.Sprintf("an error occurred while creating user: %v", err)
-			log.Errorln(logTag, ":", msg, ":", err)
-			util.WriteBackError(w, msg, http.StatusBadRequest)
-			return
-		}
+	if err != nil {
+		msg := fmt.Sprintf("an error occurred while creating user: %v", err)
+		log.Errorln(logTag, ":", msg, ":", err)
+		util.WriteBackError(w, msg, http.StatusBadRequest)
+		return
+	}
 
-		newUser.PasswordHashType = "bcrypt"
+	newUser.PasswordHashType = "bcrypt"
 
-		rawUser, err := json.Marshal(*newUser)
-		if err != nil {
-			msg := fmt.Sprintf(`an error occurred while creating a user with "username"="%s"`, userBody.Username)
-			log.Errorln(logTag, ":", msg, ":", err)
-			util.WriteBackError(w, msg, http.StatusInternalServerError)
+	rawUser, err := json.Marshal(*newUser)
+	if err != nil {
+		msg := fmt.Sprintf(`an error occurred while creating a user with "username"="%s"`, userBody.Username)
+		log.Errorln(logTag, ":", msg, ":", err)
+		util.WriteBackError(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	var ok bool
+	if replace {
+		ok, err = u.es.PostUser(req.Context(), *newUser)
+		// the cached credential of the replaced user is stale
+		auth.ClearLocalUser(newUser.Username)
+	} else {
+		ok, err = u.es.CreateUser(req.Context(), *newUser)
+		if err == errUserExists {
+			msg := fmt.Sprintf(`user with "username"="%s" already exists`, userBody.Username)
+			util.WriteBackError(w, msg, http.StatusConflict)
 			return
 		}
-
-		ok, err := u.es.postUser(req.Context(), *newUser)
-		if ok && err == nil {
-			// Subscribe to down time alerts
-			if newUser.HasAction(user.DowntimeAlerts) {
-				err := subscribeToDowntimeAlert(newUser.Email)
-				if err != nil {
-					log.Errorln(logTag, err.Error())
-				}
+	}
+	if ok && err == nil {
+		// Subscribe to down time alerts
+		if newUser.HasAction(user.DowntimeAlerts) {
+			err := subscribeToDowntimeAlert(newUser.Email)
+			if err != nil {
+				log.Errorln(logTag, err.Error())
 			}
-			util.WriteBackRaw(w, rawUser, http.StatusCreated)
-			return
 		}
-
-		msg := fmt.Sprintf(`an error occurred while creating a user with "username"="%s": %v`, userBody.Username, err)
-		log.Println(logTag, ":", msg)
-		util.WriteBackError(w, msg, http.StatusInternalServerError)
+		code := http.StatusCreated
+		if replace {
+			code = http.StatusOK
+		}
+		util.WriteBackRaw(w, rawUser, code)
+		return
 	}
+
+	msg := fmt.Sprintf(`an error occurred while creating a user with "username"="%s": %v`, userBody.Username, err)
+	log.Println(logTag, ":", msg)
+	util.WriteBackError(w, msg, http.StatusInternalServerError)
 }
 
 func (u *Users) patchUser() http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		username, _, _ := req.BasicAuth()
+		username = util.NormalizeUsername(username)
 
 		// To decide whether to just update the local state
 		isLocal := req.URL.Query().Get("local")
@@ -215,7 +260,7 @@ func (u *Users) patchUser() http.HandlerFunc {
 			}
 			patch["password"] = string(hashedPassword)
 		}
-		_, err2 := u.es.patchUser(req.Context(), username, patch)
+		_, err2 := u.es.PatchUser(req.Context(), username, patch)
 		if err2 == nil {
 			// Only update local state when proxy API has not been called
 			// If proxy API would get called then it would automatically update the
@@ -290,6 +335,7 @@ func (u *Users) patchUserWithUsername() http.HandlerFunc {
 			util.WriteBackError(w, `can't patch user without a "username"`, http.StatusBadRequest)
 			return
 		}
+		username = util.NormalizeUsername(username)
 		// To decide whether to just update the local state
 		isLocal := req.URL.Query().Get("local")
 		if isLocal == "true" {
@@ -335,7 +381,7 @@ func (u *Users) patchUserWithUsername() http.HandlerFunc {
 			patch["password"] = string(hashedPassword)
 		}
 
-		_, err2 := u.es.patchUser(req.Context(), username, patch)
+		_, err2 := u.es.PatchUser(req.Context(), username, patch)
 		if err2 == nil {
 			// Only update local state when proxy API has not been called
 			// If proxy API would get called then it would automatically update the
@@ -405,6 +451,7 @@ func (u *Users) patchUserWithUsername() http.HandlerFunc {
 func (u *Users) deleteUser() http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		username, _, _ := req.BasicAuth()
+		username = util.NormalizeUsername(username)
 
 		// To decide whether to just update the local state
 		isLocal := req.URL.Query().Get("local")
@@ -416,14 +463,14 @@ func (u *Users) deleteUser() http.HandlerFunc {
 			return
 		}
 
-		userDetails, err := u.es.getUser(req.Context(), username)
+		userDetails, err := u.es.GetUser(req.Context(), username)
 		if err != nil {
 			msg := fmt.Sprintf(`user with "username"="%s" not found`, username)
 			log.Errorln(logTag, ":", msg, ":", err)
 			util.WriteBackError(w, msg, http.StatusNotFound)
 			return
 		}
-		ok, err := u.es.deleteUser(req.Context(), username)
+		ok, err := u.es.DeleteUser(req.Context(), username)
 		if ok && err == nil {
 			// Only update local state when proxy API has not been called
 			// If proxy API would get called then it would automatically update the
@@ -484,6 +531,7 @@ func (u *Users) deleteUserWithUsername() http.HandlerFunc {
 			util.WriteBackError(w, `can't delete a user without a "username"`, http.StatusBadRequest)
 			return
 		}
+		username = util.NormalizeUsername(username)
 		// To decide whether to just update the local state
 		isLocal := req.URL.Query().Get("local")
 		if isLocal == "true" {
@@ -493,14 +541,14 @@ func (u *Users) deleteUserWithUsername() http.HandlerFunc {
 			util.WriteBackMessage(w, msg, http.StatusOK)
 			return
 		}
-		userDetails, err2 := u.es.getUser(req.Context(), username)
+		userDetails, err2 := u.es.GetUser(req.Context(), username)
 		if err2 != nil {
 			msg := fmt.Sprintf(`user with "username"="%s" not found`, username)
 			log.Errorln(logTag, ":", msg, ":", err2)
 			util.WriteBackError(w, msg, http.StatusNotFound)
 			return
 		}
-		ok, err := u.es.deleteUser(req.Context(), username)
+		ok, err := u.es.DeleteUser(req.Context(), username)
 		if ok && err == nil {
 			// Only update local state when proxy API has not been called
 			// If proxy API would get called then it would automatically update the
@@ -554,7 +602,23 @@ func (u *Users) deleteUserWithUsername() http.HandlerFunc {
 
 func (u *Users) getAllUsers() http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
-		raw, err := u.es.getRawUsers(req.Context())
+		fields := listedUserFields
+		if req.URL.Query().Get("privileged") == "true" {
+			reqUser, err := user.FromContext(req.Context())
+			if err != nil {
+				log.Errorln(logTag, ":", err)
+				util.WriteBackError(w, "an error occurred while fetching users", http.StatusInternalServerError)
+				return
+			}
+			if reqUser.IsAdmin == nil || !*reqUser.IsAdmin {
+				msg := fmt.Sprintf(`user with "username"="%s" can't list the privileged user fields`, reqUser.Username)
+				util.WriteBackError(w, msg, http.StatusForbidden)
+				return
+			}
+			fields = append(append([]string{}, listedUserFields...), u.privilegedFields...)
+		}
+
+		raw, err := u.es.GetRawUsers(req.Context(), fields...)
 		if err != nil {
 			msg := `an error occurred while fetching users`
 			log.Errorln(logTag, ":", err)
@@ -0,0 +1,209 @@
+package users
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/appbaseio/reactivesearch-api/model/user"
+	"github.com/appbaseio/reactivesearch-api/util"
+	"github.com/gorilla/mux"
+	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func serveUsers(h http.HandlerFunc, method, target, body string, vars map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if vars != nil {
+		req = mux.SetURLVars(req, vars)
+	}
+	w := httptest.NewRecorder()
+	h(w, req)
+	return w
+}
+
+func TestUserHandlers(t *testing.T) {
+	Convey("User handlers with an in-memory store", t, func() {
+		store := newMemoryStore()
+		u := &Users{es: store}
+		ctx := context.Background()
+
+		Convey("Post user stores a user with a hashed password", func() {
+			w := serveUsers(u.postUser(), http.MethodPost, "/_user",
+				`{"username":"john","password":"appleseed","allowed_actions":["develop"]}`, nil)
+			So(w.Code, ShouldEqual, http.StatusCreated)
+
+			stored, err := store.GetUser(ctx, "john")
+			So(err, ShouldBeNil)
+			So(stored.PasswordHashType, ShouldEqual, "bcrypt")
+			So(bcrypt.CompareHashAndPassword([]byte(stored.Password), []byte("appleseed")), ShouldBeNil)
+		})
+
+		Convey("Post user rejects a non-admin user without actions", func() {
+			w := serveUsers(u.postUser(), http.MethodPost, "/_user",
+				`{"username":"john","password":"appleseed"}`, nil)
+			So(w.Code, ShouldEqual, http.StatusBadRequest)
+			_, err := store.GetUser(ctx, "john")
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("With an existing user", func() {
+			john, err := user.New("john", "hash", user.SetEmail("john@appleseed.com"),
+				user.SetAllowedActions([]user.UserAction{user.Develop}))
+			So(err, ShouldBeNil)
+			store.PostUser(ctx, *john)
+
+			Convey("Get user returns the stored user", func() {
+				w := serveUsers(u.getUserWithUsername(), http.MethodGet, "/_user/john", "", map[string]string{"username": "john"})
+				So(w.Code, ShouldEqual, http.StatusOK)
+				var got user.User
+				So(json.Unmarshal(w.Body.Bytes(), &got), ShouldBeNil)
+				So(got.Email, ShouldEqual, "john@appleseed.com")
+			})
+
+			Convey("Get user returns 404 for an unknown user", func() {
+				w := serveUsers(u.getUserWithUsername(), http.MethodGet, "/_user/jane", "", map[string]string{"username": "jane"})
+				So(w.Code, ShouldEqual, http.StatusNotFound)
+			})
+
+			Convey("Get all users lists the stored users", func() {
+				w := serveUsers(u.getAllUsers(), http.MethodGet, "/_users", "", nil)
+				So(w.Code, ShouldEqual, http.StatusOK)
+				var got []user.User
+				So(json.Unmarshal(w.Body.Bytes(), &got), ShouldBeNil)
+				So(len(got), ShouldEqual, 1)
+			})
+
+			Convey("Patch user updates the stored user", func() {
+				w := serveUsers(u.patchUserWithUsername(), http.MethodPatch, "/_user/john",
+					`{"email":"john@example.com"}`, map[string]string{"username": "john"})
+				So(w.Code, ShouldEqual, http.StatusOK)
+				stored, err := store.GetUser(ctx, "john")
+				So(err, ShouldBeNil)
+				So(stored.Email, ShouldEqual, "john@example.com")
+			})
+
+			Convey("Post user rejects a duplicate username", func() {
+				w := serveUsers(u.postUser(), http.MethodPost, "/_user",
+					`{"username":"john","password":"appleseed","allowed_actions":["develop"],"email":"jane@example.com"}`, nil)
+				So(w.Code, ShouldEqual, http.StatusConflict)
+				stored, err := store.GetUser(ctx, "john")
+				So(err, ShouldBeNil)
+				So(stored.Email, ShouldEqual, "john@appleseed.com")
+			})
+
+			Convey("Put user replaces the stored user", func() {
+				w := serveUsers(u.putUserWithUsername(), http.MethodPut, "/_user/john",
+					`{"password":"appleseed","allowed_actions":["develop"],"email":"john@example.com"}`, map[string]string{"username": "john"})
+				So(w.Code, ShouldEqual, http.StatusOK)
+				stored, err := store.GetUser(ctx, "john")
+				So(err, ShouldBeNil)
+				So(stored.Email, ShouldEqual, "john@example.com")
+				So(bcrypt.CompareHashAndPassword([]byte(stored.Password), []byte("appleseed")), ShouldBeNil)
+			})
+
+			Convey("Put user rejects a mismatching username", func() {
+				w := serveUsers(u.putUserWithUsername(), http.MethodPut, "/_user/john",
+					`{"username":"jane","password":"appleseed","allowed_actions":["develop"]}`, map[string]string{"username": "john"})
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+			})
+
+			Convey("Delete user removes the stored user", func() {
+				w := serveUsers(u.deleteUserWithUsername(), http.MethodDelete, "/_user/john", "", map[string]string{"username": "john"})
+				So(w.Code, ShouldEqual, http.StatusOK)
+				_, err := store.GetUser(ctx, "john")
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
+
+func TestCaseInsensitiveUsernames(t *testing.T) {
+	Convey("Case-insensitive usernames", t, func() {
+		util.SetCaseInsensitiveUsernames(true)
+		Reset(func() { util.SetCaseInsensitiveUsernames(false) })
+		store := newMemoryStore()
+		u := &Users{es: store}
+		ctx := context.Background()
+
+		Convey("Post user stores the lowercase username", func() {
+			w := serveUsers(u.postUser(), http.MethodPost, "/_user",
+				`{"username":"John","password":"appleseed","allowed_actions":["develop"]}`, nil)
+			So(w.Code, ShouldEqual, http.StatusCreated)
+			_, err := store.GetUser(ctx, "john")
+			So(err, ShouldBeNil)
+
+			Convey("Case variants resolve the same user", func() {
+				for _, username := range []string{"john", "John", "JOHN"} {
+					w := serveUsers(u.getUserWithUsername(), http.MethodGet, "/_user/"+username, "", map[string]string{"username": username})
+					So(w.Code, ShouldEqual, http.StatusOK)
+					var got user.User
+					So(json.Unmarshal(w.Body.Bytes(), &got), ShouldBeNil)
+					So(got.Username, ShouldEqual, "john")
+				}
+			})
+		})
+
+		Convey("Existing mixed-case usernames are migrated", func() {
+			for _, username := range []string{"Jane", "Mark", "mark"} {
+				existing, err := user.New(username, "hash", user.SetAllowedActions([]user.UserAction{user.Develop}))
+				So(err, ShouldBeNil)
+				store.PostUser(ctx, *existing)
+			}
+			So(normalizeUsernames(ctx, store), ShouldBeNil)
+
+			_, err := store.GetUser(ctx, "jane")
+			So(err, ShouldBeNil)
+			_, err = store.GetUser(ctx, "Jane")
+			So(err, ShouldNotBeNil)
+			// conflicting usernames are left for a manual fix
+			_, err = store.GetUser(ctx, "Mark")
+			So(err, ShouldBeNil)
+			_, err = store.GetUser(ctx, "mark")
+			So(err, ShouldBeNil)
+		})
+	})
+}
+
+func TestListUsersProjection(t *testing.T) {
+	Convey("Users listing projection", t, func() {
+		store := newMemoryStore()
+		u := &Users{es: store, privilegedFields: []string{"password_hash_type"}}
+		john, err := user.New("john", "hash", user.SetAllowedActions([]user.UserAction{user.Develop}))
+		So(err, ShouldBeNil)
+		store.PostUser(context.Background(), *john)
+
+		list := func(target string, isAdmin bool) (*httptest.ResponseRecorder, []map[string]interface{}) {
+			req := httptest.NewRequest(http.MethodGet, target, nil)
+			req = req.WithContext(user.NewContext(req.Context(), &user.User{Username: "admin", IsAdmin: &isAdmin}))
+			w := httptest.NewRecorder()
+			u.getAllUsers()(w, req)
+			var got []map[string]interface{}
+			json.Unmarshal(w.Body.Bytes(), &got)
+			return w, got
+		}
+
+		Convey("Default listing excludes the password hash", func() {
+			w, got := list("/_users", true)
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(got[0]["username"], ShouldEqual, "john")
+			So(got[0], ShouldContainKey, "created_at")
+			So(got[0], ShouldContainKey, "password_changed_at")
+			So(got[0], ShouldNotContainKey, "password")
+			So(got[0], ShouldNotContainKey, "password_hash_type")
+		})
+		Convey("Privileged listing includes the configured fields", func() {
+			w, got := list("/_users?privileged=true", true)
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(got[0], ShouldContainKey, "password_hash_type")
+			So(got[0], ShouldNotContainKey, "password")
+		})
+		Convey("Privileged listing requires an admin", func() {
+			w, _ := list("/_users?privileged=true", false)
+			So(w.Code, ShouldEqual, http.StatusForbidden)
+		})
+	})
+}
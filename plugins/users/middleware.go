@@ -34,6 +34,7 @@ func list() []middleware.Middleware {
 		auth.BasicAuth(),
 		validate.Operation(),
 		validate.Category(),
+		validate.Maintenance(),
 	}
 }
 
@@ -0,0 +1,47 @@
+package users
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/appbaseio/reactivesearch-api/model/category"
+	"github.com/appbaseio/reactivesearch-api/model/user"
+	"github.com/appbaseio/reactivesearch-api/plugins/auth"
+	"github.com/appbaseio/reactivesearch-api/util"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMaintenanceMode(t *testing.T) {
+	Convey("The user routes in maintenance mode", t, func() {
+		isAdmin := true
+		auth.SaveCredentialToCache("maintainer", &user.User{
+			Username:   "maintainer",
+			IsAdmin:    &isAdmin,
+			Categories: []category.Category{category.User},
+		})
+		auth.SavePassword("maintainer", "secret")
+		util.SetMaintenanceMode(true)
+		Reset(func() {
+			util.SetMaintenanceMode(false)
+			auth.RemoveCredentialFromCache("maintainer")
+		})
+		serve := func(method string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(method, "/_user", nil)
+			req.SetBasicAuth("maintainer", "secret")
+			w := httptest.NewRecorder()
+			(&chain{}).Wrap(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})(w, req)
+			return w
+		}
+
+		Convey("The writes are rejected", func() {
+			So(serve(http.MethodPost).Code, ShouldEqual, http.StatusServiceUnavailable)
+			So(serve(http.MethodDelete).Code, ShouldEqual, http.StatusServiceUnavailable)
+		})
+		Convey("The reads are served", func() {
+			So(serve(http.MethodGet).Code, ShouldEqual, http.StatusOK)
+		})
+	})
+}
@@ -37,6 +37,13 @@ func (u *Users) routes() []plugins.Route {
 			HandlerFunc: middleware(hasUserAccess(u.postUser())),
 			Description: "Creates a new user",
 		},
+		{
+			Name:        "Put user with {username}",
+			Methods:     []string{http.MethodPut},
+			Path:        "/_user/{username}",
+			HandlerFunc: middleware(hasUserAccess(u.putUserWithUsername())),
+			Description: "Creates or replaces the user with {username}",
+		},
 		{
 			Name:        "Patch user",
 			Methods:     []string{http.MethodPatch},
@@ -2,15 +2,31 @@ package users
 
 import (
 	"context"
+	"errors"
 
 	"github.com/appbaseio/reactivesearch-api/model/user"
 )
 
-type userService interface {
-	getRawUsers(ctx context.Context) ([]byte, error)
-	getUser(ctx context.Context, username string) (*user.User, error)
-	getRawUser(ctx context.Context, username string) ([]byte, error)
-	postUser(ctx context.Context, u user.User) (bool, error)
-	patchUser(ctx context.Context, username string, patch map[string]interface{}) ([]byte, error)
-	deleteUser(ctx context.Context, username string) (bool, error)
+// errUserExists is returned when creating a user whose username is taken.
+var errUserExists = errors.New("user already exists")
+
+// errUserNotFound is returned when getting a user that doesn't exist.
+var errUserNotFound = errors.New("user not found")
+
+// UserStore abstracts the storage of users. The handlers only depend on this
+// interface, which is implemented by the elasticsearch dao.
+type UserStore interface {
+	// GetRawUsers returns the users with only the fields included, or the full
+	// documents if no fields are given.
+	GetRawUsers(ctx context.Context, fields ...string) ([]byte, error)
+	// GetUser and GetRawUser return errUserNotFound if the user doesn't exist.
+	GetUser(ctx context.Context, username string) (*user.User, error)
+	GetRawUser(ctx context.Context, username string) ([]byte, error)
+	// PostUser stores the user, replacing the user with the same username if any.
+	PostUser(ctx context.Context, u user.User) (bool, error)
+	// CreateUser stores the user unless a user with the same username exists, in
+	// which case errUserExists is returned.
+	CreateUser(ctx context.Context, u user.User) (bool, error)
+	PatchUser(ctx context.Context, username string, patch map[string]interface{}) ([]byte, error)
+	DeleteUser(ctx context.Context, username string) (bool, error)
 }
@@ -0,0 +1,117 @@
+package users
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/appbaseio/reactivesearch-api/model/user"
+)
+
+// memoryStore is an in-memory UserStore used to test the handlers without elasticsearch.
+type memoryStore struct {
+	mu    sync.RWMutex
+	users map[string]user.User
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{users: make(map[string]user.User)}
+}
+
+// GetRawUsers includes only the given top level fields, like the elasticsearch source filtering.
+func (m *memoryStore) GetRawUsers(ctx context.Context, fields ...string) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	usernames := make([]string, 0, len(m.users))
+	for username := range m.users {
+		usernames = append(usernames, username)
+	}
+	sort.Strings(usernames)
+	users := make([]map[string]interface{}, 0, len(usernames))
+	for _, username := range usernames {
+		raw, err := json.Marshal(m.users[username])
+		if err != nil {
+			return nil, err
+		}
+		var doc map[string]interface{}
+		if err := json.Unmarshal(raw, &doc); err != nil {
+			return nil, err
+		}
+		if len(fields) > 0 {
+			projected := make(map[string]interface{})
+			for _, field := range fields {
+				if value, ok := doc[field]; ok {
+					projected[field] = value
+				}
+			}
+			doc = projected
+		}
+		users = append(users, doc)
+	}
+	return json.Marshal(users)
+}
+
+func (m *memoryStore) GetUser(ctx context.Context, username string) (*user.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	u, ok := m.users[username]
+	if !ok {
+		return nil, errUserNotFound
+	}
+	return &u, nil
+}
+
+func (m *memoryStore) GetRawUser(ctx context.Context, username string) ([]byte, error) {
+	u, err := m.GetUser(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(u)
+}
+
+func (m *memoryStore) PostUser(ctx context.Context, u user.User) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.users[u.Username] = u
+	return true, nil
+}
+
+func (m *memoryStore) CreateUser(ctx context.Context, u user.User) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.users[u.Username]; ok {
+		return false, errUserExists
+	}
+	m.users[u.Username] = u
+	return true, nil
+}
+
+func (m *memoryStore) PatchUser(ctx context.Context, username string, patch map[string]interface{}) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.users[username]
+	if !ok {
+		return nil, errUserNotFound
+	}
+	raw, err := json.Marshal(patch)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, &u); err != nil {
+		return nil, err
+	}
+	m.users[username] = u
+	return raw, nil
+}
+
+func (m *memoryStore) DeleteUser(ctx context.Context, username string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.users[username]; !ok {
+		return false, fmt.Errorf("user %s not found", username)
+	}
+	delete(m.users, username)
+	return true, nil
+}
@@ -1,11 +1,15 @@
 package users
 
 import (
+	"context"
+	"fmt"
 	"os"
+	"strings"
 	"sync"
 
 	"github.com/appbaseio/reactivesearch-api/middleware"
 	"github.com/appbaseio/reactivesearch-api/plugins"
+	"github.com/appbaseio/reactivesearch-api/util"
 )
 
 const (
@@ -14,17 +18,27 @@ const (
 	typeName            = "_doc"
 	envEsURL            = "ES_CLUSTER_URL"
 	defaultUsersEsIndex = ".users"
+	envCaseInsensitive  = "CASE_INSENSITIVE_USERNAMES"
+	envPrivilegedFields = "USERS_PRIVILEGED_FIELDS"
+	envRotateMasterPass = "ROTATE_MASTER_PASSWORD"
 	settings            = `{ "settings" : { %s "index.number_of_shards" : 1, "index.number_of_replicas" : %d } }`
 )
 
 var (
 	singleton *Users
 	once      sync.Once
+	// listedUserFields are the user fields returned by the users listing, the
+	// password hash is only returned in the privileged listing
+	listedUserFields = []string{"username", "is_admin", "categories", "allowed_actions", "acls", "email", "indices", "created_at", "password_changed_at"}
+	// defaultPrivilegedFields are the sensitive user fields added to the privileged listing
+	defaultPrivilegedFields = []string{"password", "password_hash_type"}
 )
 
 // Users plugin deals with user management.
 type Users struct {
-	es userService
+	es UserStore
+	// privilegedFields are the sensitive user fields added to the privileged listing
+	privilegedFields []string
 }
 
 // Use only this function to fetch the instance of user from within
@@ -55,6 +69,23 @@ func (u *Users) InitFunc() error {
 		return err
 	}
 
+	u.privilegedFields = defaultPrivilegedFields
+	if value := os.Getenv(envPrivilegedFields); value != "" {
+		u.privilegedFields = nil
+		for _, field := range strings.Split(value, ",") {
+			if field = strings.TrimSpace(field); field != "" {
+				u.privilegedFields = append(u.privilegedFields, field)
+			}
+		}
+	}
+
+	if os.Getenv(envCaseInsensitive) == "true" {
+		util.SetCaseInsensitiveUsernames(true)
+		if err := normalizeUsernames(context.Background(), u.es); err != nil {
+			return fmt.Errorf("error while normalizing the usernames: %v", err)
+		}
+	}
+
 	return nil
 }
 
@@ -2,14 +2,18 @@ package users
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/appbaseio/reactivesearch-api/model/user"
 	"github.com/appbaseio/reactivesearch-api/util"
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func subscribeToDowntimeAlert(email string) error {
@@ -70,3 +74,68 @@ func HasAction(actions []user.UserAction, action user.UserAction) bool {
 	}
 	return false
 }
+
+// normalizeUsernames migrates the users with mixed-case usernames to lowercase usernames.
+// Users whose lowercase username is already taken are left untouched since they can't be
+// merged automatically, they must be renamed or deleted manually.
+func normalizeUsernames(ctx context.Context, store UserStore) error {
+	raw, err := store.GetRawUsers(ctx)
+	if err != nil {
+		return err
+	}
+	var users []user.User
+	if err := json.Unmarshal(raw, &users); err != nil {
+		return err
+	}
+	taken := make(map[string]bool)
+	for _, u := range users {
+		taken[u.Username] = true
+	}
+	for _, u := range users {
+		username := strings.ToLower(u.Username)
+		if username == u.Username {
+			continue
+		}
+		if taken[username] {
+			log.Errorln(logTag, ": can't normalize the username", u.Username, "since", username, "already exists")
+			continue
+		}
+		oldUsername := u.Username
+		u.Username = username
+		if _, err := store.PostUser(ctx, u); err != nil {
+			return err
+		}
+		if _, err := store.DeleteUser(ctx, oldUsername); err != nil {
+			return err
+		}
+		taken[username] = true
+		log.Println(logTag, ": normalized the username", oldUsername, "to", username)
+	}
+	return nil
+}
+
+// rotateMasterPassword patches only the password of an existing master user, leaving
+// the rest of its fields untouched. It returns false if the master user doesn't exist,
+// and an error if its existence can't be checked so that it isn't recreated.
+func rotateMasterPassword(ctx context.Context, store UserStore, username, password string) (bool, error) {
+	if _, err := store.GetUser(ctx, username); err == errUserNotFound {
+		log.Println(logTag, ": master user", username, "not found, creating it")
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return false, err
+	}
+	_, err = store.PatchUser(ctx, username, map[string]interface{}{
+		"password":            string(hashedPassword),
+		"password_hash_type":  "bcrypt",
+		"password_changed_at": time.Now().Format(time.RFC3339),
+	})
+	if err != nil {
+		return false, err
+	}
+	log.Println(logTag, ": rotated the password of master user", username)
+	return true, nil
+}
@@ -0,0 +1,66 @@
+package users
+
+import (
+	"context"
+	"testing"
+
+	"github.com/appbaseio/reactivesearch-api/model/user"
+	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestRotateMasterPassword(t *testing.T) {
+	Convey("Rotate the master user password", t, func() {
+		ctx := context.Background()
+		store := newMemoryStore()
+
+		Convey("Only the password of the existing master user is updated", func() {
+			master, err := user.NewAdmin("foo", "old-hash", user.SetEmail("ops@appbase.io"))
+			So(err, ShouldBeNil)
+			master.PasswordHashType = "bcrypt"
+			master.CreatedAt = "2021-01-01T00:00:00Z"
+			store.PostUser(ctx, *master)
+
+			rotated, err := rotateMasterPassword(ctx, store, "foo", "new-secret")
+			So(err, ShouldBeNil)
+			So(rotated, ShouldBeTrue)
+
+			stored, err := store.GetUser(ctx, "foo")
+			So(err, ShouldBeNil)
+			So(bcrypt.CompareHashAndPassword([]byte(stored.Password), []byte("new-secret")), ShouldBeNil)
+			So(stored.PasswordHashType, ShouldEqual, "bcrypt")
+			So(stored.Email, ShouldEqual, "ops@appbase.io")
+			So(stored.CreatedAt, ShouldEqual, "2021-01-01T00:00:00Z")
+			So(*stored.IsAdmin, ShouldBeTrue)
+			So(stored.ACLs, ShouldResemble, master.ACLs)
+		})
+
+		Convey("Missing master user isn't rotated", func() {
+			rotated, err := rotateMasterPassword(ctx, store, "foo", "new-secret")
+			So(err, ShouldBeNil)
+			So(rotated, ShouldBeFalse)
+		})
+
+		Convey("Master user isn't recreated when it can't be looked up", func() {
+			master, err := user.NewAdmin("foo", "old-hash", user.SetEmail("ops@appbase.io"))
+			So(err, ShouldBeNil)
+			store.PostUser(ctx, *master)
+
+			rotated, err := rotateMasterPassword(ctx, unavailableStore{store}, "foo", "new-secret")
+			So(err, ShouldNotBeNil)
+			So(rotated, ShouldBeFalse)
+			stored, err := store.GetUser(ctx, "foo")
+			So(err, ShouldBeNil)
+			So(stored.Password, ShouldEqual, "old-hash")
+		})
+	})
+}
+
+// unavailableStore fails the lookups as elasticsearch does when it times out.
+type unavailableStore struct {
+	*memoryStore
+}
+
+func (s unavailableStore) GetUser(ctx context.Context, username string) (*user.User, error) {
+	return nil, context.DeadlineExceeded
+}
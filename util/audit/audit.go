@@ -0,0 +1,134 @@
+package audit
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	logTag = "[audit]"
+	// bufferSize is the number of events queued for the sink before the new ones are dropped
+	bufferSize = 1000
+	// defaultRedialInterval is how often an unreachable sink is redialed
+	defaultRedialInterval = 30 * time.Second
+)
+
+// Types of the audit events.
+const (
+	AuthFailure            = "auth_failure"
+	AuthFailuresSuppressed = "auth_failures_suppressed"
+	PermissionCreated      = "permission_created"
+	PermissionUpdated      = "permission_updated"
+	PermissionDeleted      = "permission_deleted"
+)
+
+// Event is a security relevant event, e.g. a failed authentication.
+type Event struct {
+	Type string `json:"type"`
+	// Username is the subject of the event, i.e. the username the request authenticated
+	// with or the permission that changed
+	Username string `json:"username,omitempty"`
+	// Actor is the user that made the change
+	Actor    string `json:"actor,omitempty"`
+	ClientIP string `json:"client_ip,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+	// Count is the number of the events an aggregate event stands for
+	Count     int       `json:"count,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Sink writes the audit events as JSON messages in the background. The events are
+// dropped while the writer can't be dialed, so that an unreachable sink never affects
+// the requests.
+type Sink struct {
+	dial           func() (io.Writer, error)
+	redialInterval time.Duration
+	events         chan Event
+	done           chan struct{}
+}
+
+// NewSink returns a sink writing to the writers returned by dial, e.g. a syslog connection.
+func NewSink(dial func() (io.Writer, error)) *Sink {
+	s := &Sink{
+		dial:           dial,
+		redialInterval: defaultRedialInterval,
+		events:         make(chan Event, bufferSize),
+		done:           make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// Emit queues the event without blocking.
+func (s *Sink) Emit(e Event) {
+	select {
+	case s.events <- e:
+	default:
+		log.Warnln(logTag, ": audit sink is backed up, dropping", e.Type, "event")
+	}
+}
+
+// Close writes the queued events and stops the sink, no events must be emitted after.
+func (s *Sink) Close() {
+	close(s.events)
+	<-s.done
+}
+
+func (s *Sink) run() {
+	defer close(s.done)
+	var w io.Writer
+	var lastDial time.Time
+	for e := range s.events {
+		if w == nil && time.Since(lastDial) >= s.redialInterval {
+			lastDial = time.Now()
+			var err error
+			if w, err = s.dial(); err != nil {
+				log.Warnln(logTag, ": audit sink is unreachable, dropping the events until it's redialed:", err)
+				w = nil
+			}
+		}
+		if w == nil {
+			continue
+		}
+		msg, err := json.Marshal(e)
+		if err != nil {
+			log.Errorln(logTag, ": unable to marshal", e.Type, "event:", err)
+			continue
+		}
+		if _, err := w.Write(msg); err != nil {
+			log.Warnln(logTag, ": unable to write", e.Type, "event:", err)
+		}
+	}
+}
+
+var defaultSink *Sink
+
+// SetSink sets the sink the events are emitted to, a nil sink disables the events.
+func SetSink(s *Sink) {
+	defaultSink = s
+}
+
+// Emit emits the event to the configured sink, if any. The auth failures beyond the
+// configured rate are counted rather than emitted, so that a brute-force attack doesn't
+// flood the sink, and their count is emitted once the rate allows it again.
+func Emit(e Event) {
+	if defaultSink == nil {
+		return
+	}
+	if e.Timestamp.IsZero() {
+		e.Timestamp = time.Now()
+	}
+	if e.Type == AuthFailure {
+		allowed, suppressed := authFailures.allow()
+		if suppressed > 0 {
+			defaultSink.Emit(Event{Type: AuthFailuresSuppressed, Count: suppressed, Timestamp: e.Timestamp})
+		}
+		if !allowed {
+			return
+		}
+	}
+	defaultSink.Emit(e)
+}
@@ -0,0 +1,158 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/syslog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// fakeSyslog records the messages written to it.
+type fakeSyslog struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (f *fakeSyslog) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.messages = append(f.messages, string(p))
+	return len(p), nil
+}
+
+func TestSink(t *testing.T) {
+	Convey("Audit sink", t, func() {
+		fake := &fakeSyslog{}
+
+		Convey("Writes an auth failure event", func() {
+			s := NewSink(func() (io.Writer, error) { return fake, nil })
+			at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+			s.Emit(Event{Type: AuthFailure, Username: "foo", ClientIP: "10.0.0.1", Reason: "invalid password", Timestamp: at})
+			s.Close()
+
+			So(fake.messages, ShouldHaveLength, 1)
+			So(fake.messages[0], ShouldEqual, `{"type":"auth_failure","username":"foo","client_ip":"10.0.0.1","reason":"invalid password","timestamp":"2024-01-02T03:04:05Z"}`)
+		})
+		Convey("Drops the events while unreachable and recovers once redialed", func() {
+			var dials int
+			s := NewSink(func() (io.Writer, error) {
+				dials++
+				if dials == 1 {
+					return nil, errors.New("connection refused")
+				}
+				return fake, nil
+			})
+			s.redialInterval = 0
+			s.Emit(Event{Type: AuthFailure, Username: "foo"})
+			s.Emit(Event{Type: AuthFailure, Username: "bar"})
+			s.Close()
+
+			So(dials, ShouldEqual, 2)
+			So(fake.messages, ShouldHaveLength, 1)
+			So(fake.messages[0], ShouldContainSubstring, `"username":"bar"`)
+		})
+		Convey("Emitting never blocks on an unreachable sink", func() {
+			s := NewSink(func() (io.Writer, error) {
+				time.Sleep(time.Second)
+				return nil, errors.New("timeout")
+			})
+			start := time.Now()
+			for i := 0; i < 2*bufferSize; i++ {
+				s.Emit(Event{Type: AuthFailure})
+			}
+			So(time.Since(start), ShouldBeLessThan, 500*time.Millisecond)
+		})
+		Convey("Emit is a no-op without a sink", func() {
+			SetSink(nil)
+			So(func() { Emit(Event{Type: AuthFailure}) }, ShouldNotPanic)
+		})
+		Convey("Events are valid JSON", func() {
+			var buf bytes.Buffer
+			s := NewSink(func() (io.Writer, error) { return &buf, nil })
+			s.Emit(Event{Type: PermissionDeleted, Username: "abc", Actor: `a"b`})
+			s.Close()
+			var e Event
+			So(json.Unmarshal(buf.Bytes(), &e), ShouldBeNil)
+			So(e.Actor, ShouldEqual, `a"b`)
+		})
+	})
+}
+
+func TestAuthFailureRate(t *testing.T) {
+	Convey("Rate limit the auth failure events", t, func() {
+		fake := &fakeSyslog{}
+		s := NewSink(func() (io.Writer, error) { return fake, nil })
+		SetSink(s)
+		now := time.Now()
+		authFailures.configure(2)
+		authFailures.now = func() time.Time { return now }
+		Reset(func() {
+			SetSink(nil)
+			authFailures.configure(defaultAuthFailureRate)
+			authFailures.now = time.Now
+		})
+		events := func() []Event {
+			s.Close()
+			var events []Event
+			for _, msg := range fake.messages {
+				var e Event
+				So(json.Unmarshal([]byte(msg), &e), ShouldBeNil)
+				events = append(events, e)
+			}
+			return events
+		}
+
+		Convey("The failures beyond the rate are suppressed and counted", func() {
+			for i := 0; i < 5; i++ {
+				Emit(Event{Type: AuthFailure, Username: "foo"})
+			}
+			now = now.Add(time.Minute)
+			Emit(Event{Type: AuthFailure, Username: "bar"})
+			got := events()
+			So(got, ShouldHaveLength, 4)
+			So(got[0].Username, ShouldEqual, "foo")
+			So(got[0].Timestamp.IsZero(), ShouldBeFalse)
+			So(got[2].Type, ShouldEqual, AuthFailuresSuppressed)
+			So(got[2].Count, ShouldEqual, 3)
+			So(got[3].Username, ShouldEqual, "bar")
+		})
+		Convey("The other events aren't rate limited", func() {
+			for i := 0; i < 5; i++ {
+				Emit(Event{Type: PermissionUpdated, Username: "foo"})
+			}
+			So(events(), ShouldHaveLength, 5)
+		})
+		Convey("A zero rate emits every failure", func() {
+			authFailures.configure(0)
+			for i := 0; i < 5; i++ {
+				Emit(Event{Type: AuthFailure, Username: "foo"})
+			}
+			So(events(), ShouldHaveLength, 5)
+		})
+	})
+}
+
+func TestParsePriority(t *testing.T) {
+	Convey("Parse the syslog priority", t, func() {
+		p, err := parsePriority("", "")
+		So(err, ShouldBeNil)
+		So(p, ShouldEqual, syslog.LOG_AUTH|syslog.LOG_WARNING)
+
+		p, err = parsePriority("LOCAL3", "err")
+		So(err, ShouldBeNil)
+		So(p, ShouldEqual, syslog.LOG_LOCAL3|syslog.LOG_ERR)
+
+		_, err = parsePriority("nope", "err")
+		So(err, ShouldNotBeNil)
+		So(strings.Contains(err.Error(), envSyslogFacility), ShouldBeTrue)
+		_, err = parsePriority("auth", "loud")
+		So(err, ShouldNotBeNil)
+	})
+}
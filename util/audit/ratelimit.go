@@ -0,0 +1,78 @@
+package audit
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	envAuthFailureRate = "AUDIT_AUTH_FAILURE_RATE"
+	// defaultAuthFailureRate is the number of the auth failures emitted per minute
+	defaultAuthFailureRate = 100
+)
+
+// authFailures rate limits the auth failure events.
+var authFailures = newEventLimiter(defaultAuthFailureRate, time.Minute)
+
+// eventLimiter allows a limited number of events per fixed window and counts the
+// events suppressed beyond it. A zero limit allows every event.
+type eventLimiter struct {
+	mu     sync.Mutex
+	limit  int
+	window time.Duration
+	// start is the time the current window started at
+	start      time.Time
+	allowed    int
+	suppressed int
+	now        func() time.Time
+}
+
+func newEventLimiter(limit int, window time.Duration) *eventLimiter {
+	return &eventLimiter{limit: limit, window: window, now: time.Now}
+}
+
+// allow returns true if an event can be emitted. Once a window is over, the number of
+// the events suppressed during it is returned along with the first event of a new one.
+func (l *eventLimiter) allow() (bool, int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.limit == 0 {
+		return true, 0
+	}
+	var suppressed int
+	if now := l.now(); now.Sub(l.start) >= l.window {
+		suppressed = l.suppressed
+		l.start, l.allowed, l.suppressed = now, 0, 0
+	}
+	if l.allowed >= l.limit {
+		l.suppressed++
+		return false, suppressed
+	}
+	l.allowed++
+	return true, suppressed
+}
+
+// configure resets the limiter with the given limit.
+func (l *eventLimiter) configure(limit int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limit = limit
+	l.start, l.allowed, l.suppressed = time.Time{}, 0, 0
+}
+
+// initAuthFailureRate configures the rate of the auth failure events from the env.
+func initAuthFailureRate() error {
+	value := os.Getenv(envAuthFailureRate)
+	if value == "" {
+		return nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 0 {
+		return fmt.Errorf("invalid value for %s: %s", envAuthFailureRate, value)
+	}
+	authFailures.configure(limit)
+	return nil
+}
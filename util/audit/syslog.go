@@ -0,0 +1,91 @@
+package audit
+
+import (
+	"fmt"
+	"io"
+	"log/syslog"
+	"os"
+	"strings"
+)
+
+const (
+	envSyslog         = "AUDIT_SYSLOG"
+	envSyslogNetwork  = "AUDIT_SYSLOG_NETWORK"
+	envSyslogAddress  = "AUDIT_SYSLOG_ADDRESS"
+	envSyslogFacility = "AUDIT_SYSLOG_FACILITY"
+	envSyslogSeverity = "AUDIT_SYSLOG_SEVERITY"
+	syslogTag         = "arc"
+)
+
+var facilities = map[string]syslog.Priority{
+	"kern":     syslog.LOG_KERN,
+	"user":     syslog.LOG_USER,
+	"daemon":   syslog.LOG_DAEMON,
+	"auth":     syslog.LOG_AUTH,
+	"syslog":   syslog.LOG_SYSLOG,
+	"authpriv": syslog.LOG_AUTHPRIV,
+	"local0":   syslog.LOG_LOCAL0,
+	"local1":   syslog.LOG_LOCAL1,
+	"local2":   syslog.LOG_LOCAL2,
+	"local3":   syslog.LOG_LOCAL3,
+	"local4":   syslog.LOG_LOCAL4,
+	"local5":   syslog.LOG_LOCAL5,
+	"local6":   syslog.LOG_LOCAL6,
+	"local7":   syslog.LOG_LOCAL7,
+}
+
+var severities = map[string]syslog.Priority{
+	"emerg":   syslog.LOG_EMERG,
+	"alert":   syslog.LOG_ALERT,
+	"crit":    syslog.LOG_CRIT,
+	"err":     syslog.LOG_ERR,
+	"warning": syslog.LOG_WARNING,
+	"notice":  syslog.LOG_NOTICE,
+	"info":    syslog.LOG_INFO,
+	"debug":   syslog.LOG_DEBUG,
+}
+
+// parsePriority returns the syslog priority of the facility and severity names, they
+// default to auth and warning.
+func parsePriority(facility, severity string) (syslog.Priority, error) {
+	if facility == "" {
+		facility = "auth"
+	}
+	if severity == "" {
+		severity = "warning"
+	}
+	f, ok := facilities[strings.ToLower(facility)]
+	if !ok {
+		return 0, fmt.Errorf("invalid value for %s: %s", envSyslogFacility, facility)
+	}
+	s, ok := severities[strings.ToLower(severity)]
+	if !ok {
+		return 0, fmt.Errorf("invalid value for %s: %s", envSyslogSeverity, severity)
+	}
+	return f | s, nil
+}
+
+// InitSyslog sets a syslog sink for the audit events if AUDIT_SYSLOG is set. The local
+// syslog daemon is used unless AUDIT_SYSLOG_NETWORK and AUDIT_SYSLOG_ADDRESS are set,
+// e.g. to "udp" and "siem:514".
+func InitSyslog() error {
+	if os.Getenv(envSyslog) != "true" {
+		return nil
+	}
+	priority, err := parsePriority(os.Getenv(envSyslogFacility), os.Getenv(envSyslogSeverity))
+	if err != nil {
+		return err
+	}
+	if err := initAuthFailureRate(); err != nil {
+		return err
+	}
+	network, address := os.Getenv(envSyslogNetwork), os.Getenv(envSyslogAddress)
+	SetSink(NewSink(func() (io.Writer, error) {
+		w, err := syslog.Dial(network, address, priority, syslogTag)
+		if err != nil {
+			return nil, err
+		}
+		return w, nil
+	}))
+	return nil
+}
@@ -0,0 +1,59 @@
+package util
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// StrictConfigEnvName enables the validation of the required configuration on startup.
+const StrictConfigEnvName = "ARC_STRICT_CONFIG"
+
+// DefaultMasterUsername and DefaultMasterPassword are the credentials of the master
+// user created when USERNAME isn't set.
+const (
+	DefaultMasterUsername = "foo"
+	DefaultMasterPassword = "bar"
+)
+
+// ValidateConfig validates the required configuration read with getenv if the strict
+// mode is enabled, in which case the insecure defaults aren't allowed. The returned
+// error lists all the missing and invalid variables. In the non strict mode the
+// variables fall back to their defaults and nil is returned.
+func ValidateConfig(getenv func(string) string) error {
+	if getenv(StrictConfigEnvName) != "true" {
+		return nil
+	}
+	var problems []string
+	if esURL := getenv("ES_CLUSTER_URL"); esURL == "" {
+		problems = append(problems, "ES_CLUSTER_URL is missing")
+	} else if u, err := url.Parse(esURL); err != nil || u.Scheme == "" || u.Host == "" {
+		problems = append(problems, "ES_CLUSTER_URL must be an absolute url")
+	}
+	username, password := getenv("USERNAME"), getenv("PASSWORD")
+	if username == "" {
+		problems = append(problems, "USERNAME is missing")
+	}
+	if password == "" {
+		problems = append(problems, "PASSWORD is missing")
+	}
+	if username == DefaultMasterUsername && password == DefaultMasterPassword {
+		problems = append(problems, "USERNAME and PASSWORD must not be the default master credentials")
+	}
+	if getenv(GatewayHeaderEnvName) != "" && getenv(GatewayHeaderValueEnvName) == "" {
+		problems = append(problems, GatewayHeaderValueEnvName+" is missing, it's required along with "+GatewayHeaderEnvName)
+	}
+	if _, err := ParseAllowedMethods(getenv(AllowedMethodsEnvName)); err != nil {
+		problems = append(problems, fmt.Sprintf("invalid value for %s: %v", AllowedMethodsEnvName, err))
+	}
+	if _, err := ParseMaxQueryString(getenv(MaxQueryStringEnvName)); err != nil {
+		problems = append(problems, fmt.Sprintf("invalid value for %s: %v", MaxQueryStringEnvName, err))
+	}
+	if _, err := ParseResponseHeaders(getenv(ResponseHeadersEnvName)); err != nil {
+		problems = append(problems, fmt.Sprintf("invalid value for %s: %v", ResponseHeadersEnvName, err))
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration in strict mode:\n- %s", strings.Join(problems, "\n- "))
+	}
+	return nil
+}
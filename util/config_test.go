@@ -0,0 +1,47 @@
+package util
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestValidateConfig(t *testing.T) {
+	Convey("Validate the configuration", t, func() {
+		env := map[string]string{
+			"ES_CLUSTER_URL": "http://localhost:9200",
+			"USERNAME":       "admin",
+			"PASSWORD":       "s3cret",
+		}
+		getenv := func(name string) string { return env[name] }
+
+		Convey("Non strict mode preserves the defaults", func() {
+			delete(env, "ES_CLUSTER_URL")
+			delete(env, "USERNAME")
+			delete(env, "PASSWORD")
+			So(ValidateConfig(getenv), ShouldBeNil)
+		})
+		Convey("Strict mode accepts a complete configuration", func() {
+			env[StrictConfigEnvName] = "true"
+			So(ValidateConfig(getenv), ShouldBeNil)
+		})
+		Convey("Strict mode rejects a missing es url", func() {
+			env[StrictConfigEnvName] = "true"
+			delete(env, "ES_CLUSTER_URL")
+			err := ValidateConfig(getenv)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "ES_CLUSTER_URL is missing")
+		})
+		Convey("Strict mode lists all the invalid variables", func() {
+			env[StrictConfigEnvName] = "true"
+			env["ES_CLUSTER_URL"] = "localhost"
+			env["USERNAME"], env["PASSWORD"] = DefaultMasterUsername, DefaultMasterPassword
+			env[MaxQueryStringEnvName] = "-1"
+			err := ValidateConfig(getenv)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "ES_CLUSTER_URL must be an absolute url")
+			So(err.Error(), ShouldContainSubstring, "default master credentials")
+			So(err.Error(), ShouldContainSubstring, MaxQueryStringEnvName)
+		})
+	})
+}
@@ -47,7 +47,11 @@ func GetESURL() string {
 	if esURL == "" {
 		log.Fatal("Error encountered: ", fmt.Errorf("ES_CLUSTER_URL must be set in the environment variables"))
 	}
+	return escapeURLCredentials(esURL)
+}
 
+// escapeURLCredentials escapes the username and password of the elasticsearch url.
+func escapeURLCredentials(esURL string) string {
 	if strings.Contains(esURL, "@") {
 		splitIndex := strings.LastIndex(esURL, "@")
 		protocolWithCredentials := strings.Split(esURL[0:splitIndex], "://")
@@ -143,23 +147,28 @@ func initClient6() {
 func initClient7() {
 	var err error
 	// Initialize the ES v7 client
+	client7, err = NewClient7(GetESURL())
+	if err != nil {
+		log.Fatal("Error encountered: ", fmt.Errorf("error while initializing elastic v7 client: %v", err))
+	}
+}
 
+// NewClient7 returns an es v7 client of the cluster at the url, configured like the
+// client of ES_CLUSTER_URL. The options are applied on top of that configuration.
+func NewClient7(esURL string, options ...es7.ClientOptionFunc) (*es7.Client, error) {
 	loggerT := log.New()
 	wrappedLoggerDebug := &WrapKitLoggerDebug{*loggerT}
 	wrappedLoggerError := &WrapKitLoggerError{*loggerT}
 
-	client7, err = es7.NewClient(
-		es7.SetURL(GetESURL()),
+	return es7.NewClient(append([]es7.ClientOptionFunc{
+		es7.SetURL(escapeURLCredentials(esURL)),
 		es7.SetRetrier(NewRetrier()),
 		es7.SetSniff(isSniffingEnabled()),
 		es7.SetHttpClient(HTTPClient()),
 		es7.SetErrorLog(wrappedLoggerError),
 		es7.SetInfoLog(wrappedLoggerDebug),
 		es7.SetTraceLog(wrappedLoggerDebug),
-	)
-	if err != nil {
-		log.Fatal("Error encountered: ", fmt.Errorf("error while initializing elastic v7 client: %v", err))
-	}
+	}, options...)...)
 }
 
 // NewClient instantiates the ES v6 and v7 clients
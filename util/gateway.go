@@ -0,0 +1,33 @@
+package util
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+)
+
+// GatewayHeaderEnvName is the name of the header every request must carry, e.g.
+// X-Gateway-Token, so that arc is only reachable through the API gateway injecting it.
+const GatewayHeaderEnvName = "GATEWAY_HEADER"
+
+// GatewayHeaderValueEnvName is the expected value of the gateway header.
+const GatewayHeaderValueEnvName = "GATEWAY_HEADER_VALUE"
+
+// GatewayHeaderMiddleware returns a middleware that rejects the requests without the
+// header set to the expected value.
+func GatewayHeaderMiddleware(header, value string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got := r.Header.Get(header)
+			if got == "" {
+				WriteBackError(w, fmt.Sprintf("%s header is required", header), http.StatusForbidden)
+				return
+			}
+			if subtle.ConstantTimeCompare([]byte(got), []byte(value)) != 1 {
+				WriteBackError(w, fmt.Sprintf("invalid %s header", header), http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
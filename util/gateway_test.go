@@ -0,0 +1,38 @@
+package util
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGatewayHeaderMiddleware(t *testing.T) {
+	Convey("Gateway header", t, func() {
+		handler := GatewayHeaderMiddleware("X-Gateway-Token", "s3cret")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		serve := func(value string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodGet, "/books/_search", nil)
+			if value != "" {
+				req.Header.Set("X-Gateway-Token", value)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			return w
+		}
+
+		Convey("Matching header passes", func() {
+			So(serve("s3cret").Code, ShouldEqual, http.StatusOK)
+		})
+		Convey("Missing header is rejected", func() {
+			w := serve("")
+			So(w.Code, ShouldEqual, http.StatusForbidden)
+			So(w.Body.String(), ShouldContainSubstring, "X-Gateway-Token header is required")
+		})
+		Convey("Mismatched header is rejected", func() {
+			So(serve("guess").Code, ShouldEqual, http.StatusForbidden)
+		})
+	})
+}
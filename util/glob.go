@@ -0,0 +1,64 @@
+package util
+
+// globToken is a token of a glob pattern, either a literal character, any single
+// character (`?` or a character class) or any sequence of characters (`*`).
+type globToken struct {
+	char        rune
+	any, anySeq bool
+}
+
+// globTokens tokenizes a glob pattern in the syntax of path.Match. The character
+// classes are taken as any character, which can only widen the overlaps.
+func globTokens(pattern string) []globToken {
+	var tokens []globToken
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		switch runes[i] {
+		case '*':
+			tokens = append(tokens, globToken{anySeq: true})
+		case '?':
+			tokens = append(tokens, globToken{any: true})
+		case '[':
+			for i < len(runes) && runes[i] != ']' {
+				if runes[i] == '\\' {
+					i++
+				}
+				i++
+			}
+			tokens = append(tokens, globToken{any: true})
+		case '\\':
+			if i+1 < len(runes) {
+				i++
+			}
+			tokens = append(tokens, globToken{char: runes[i]})
+		default:
+			tokens = append(tokens, globToken{char: runes[i]})
+		}
+	}
+	return tokens
+}
+
+// PatternsOverlap reports whether some name matches both of the glob patterns.
+func PatternsOverlap(a, b string) bool {
+	x, y := globTokens(a), globTokens(b)
+	// overlap[i][j] tells whether x[i:] and y[j:] overlap, filled from the ends
+	overlap := make([][]bool, len(x)+1)
+	for i := range overlap {
+		overlap[i] = make([]bool, len(y)+1)
+	}
+	for i := len(x); i >= 0; i-- {
+		for j := len(y); j >= 0; j-- {
+			switch {
+			case i == len(x) && j == len(y):
+				overlap[i][j] = true
+			case i < len(x) && x[i].anySeq:
+				overlap[i][j] = overlap[i+1][j] || j < len(y) && overlap[i][j+1]
+			case j < len(y) && y[j].anySeq:
+				overlap[i][j] = overlap[i][j+1] || i < len(x) && overlap[i+1][j]
+			case i < len(x) && j < len(y):
+				overlap[i][j] = (x[i].any || y[j].any || x[i].char == y[j].char) && overlap[i+1][j+1]
+			}
+		}
+	}
+	return overlap[0][0]
+}
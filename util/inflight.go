@@ -0,0 +1,49 @@
+package util
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+)
+
+// MaxInFlightEnvName is the maximum number of requests arc serves at once.
+const MaxInFlightEnvName = "MAX_IN_FLIGHT_REQUESTS"
+
+// inFlight is the number of the requests being served.
+var inFlight int64
+
+// InFlightRequests returns the number of the requests being served.
+func InFlightRequests() int64 {
+	return atomic.LoadInt64(&inFlight)
+}
+
+// ParseMaxInFlight parses the maximum number of the in-flight requests, an empty value
+// is unlimited, i.e. zero.
+func ParseMaxInFlight(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	limit, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || limit < 0 {
+		return 0, fmt.Errorf("must be a positive number of requests: %s", value)
+	}
+	return limit, nil
+}
+
+// InFlightLimitMiddleware returns a middleware that counts the in-flight requests and
+// rejects the ones beyond limit with a 503, a zero limit only counts them.
+func InFlightLimitMiddleware(limit int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer atomic.AddInt64(&inFlight, -1)
+			if n := atomic.AddInt64(&inFlight, 1); limit > 0 && n > limit {
+				w.Header().Set("Retry-After", "1")
+				msg := fmt.Sprintf("limit of %d concurrent requests exceeded", limit)
+				WriteBackError(w, msg, http.StatusServiceUnavailable)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package util
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestInFlightLimitMiddleware(t *testing.T) {
+	Convey("In-flight requests limit", t, func() {
+		const limit = 3
+		release := make(chan struct{})
+		var started sync.WaitGroup
+		handler := InFlightLimitMiddleware(limit)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			started.Done()
+			<-release
+			w.WriteHeader(http.StatusOK)
+		}))
+		serve := func() *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/books/_search", nil))
+			return w
+		}
+
+		codes := make(chan int, limit)
+		started.Add(limit)
+		for i := 0; i < limit; i++ {
+			go func() { codes <- serve().Code }()
+		}
+		started.Wait()
+		So(InFlightRequests(), ShouldEqual, limit)
+
+		Convey("The request over the limit is rejected", func() {
+			w := serve()
+			So(w.Code, ShouldEqual, http.StatusServiceUnavailable)
+			So(w.Header().Get("Retry-After"), ShouldEqual, "1")
+			So(InFlightRequests(), ShouldEqual, limit)
+
+			close(release)
+			for i := 0; i < limit; i++ {
+				So(<-codes, ShouldEqual, http.StatusOK)
+			}
+			So(InFlightRequests(), ShouldEqual, 0)
+
+			Convey("Requests are served once the in-flight ones are done", func() {
+				started.Add(1)
+				So(serve().Code, ShouldEqual, http.StatusOK)
+			})
+		})
+	})
+}
+
+func TestParseMaxInFlight(t *testing.T) {
+	Convey("Parse the maximum in-flight requests", t, func() {
+		limit, err := ParseMaxInFlight("")
+		So(err, ShouldBeNil)
+		So(limit, ShouldEqual, 0)
+		limit, err = ParseMaxInFlight("100")
+		So(err, ShouldBeNil)
+		So(limit, ShouldEqual, 100)
+		_, err = ParseMaxInFlight("-1")
+		So(err, ShouldNotBeNil)
+	})
+}
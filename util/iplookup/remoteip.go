@@ -48,8 +48,12 @@ func isPrivateAddress(address string) (bool, error) {
 	return false, nil
 }
 
-// FromRequest identifies the remote ip from an http request
+// FromRequest identifies the remote ip from an http request. If trusted proxies are
+// configured the client ip is taken from the X-Forwarded-For chain by skipping them.
 func FromRequest(r *http.Request) string {
+	if trusted != nil {
+		return trusted.clientIP(r)
+	}
 	// Fetch header value
 	xRealIP := r.Header.Get("X-Real-Ip")
 	xForwardedFor := r.Header.Get("X-Forwarded-For")
@@ -81,3 +85,16 @@ func FromRequest(r *http.Request) string {
 	// If nothing succeed, return X-Real-IP
 	return xRealIP
 }
+
+// Anonymize masks the last octet of an IPv4 address and the last 80 bits of an
+// IPv6 address. Values that aren't valid IP addresses are returned as is.
+func Anonymize(address string) string {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return address
+	}
+	if ipv4 := ip.To4(); ipv4 != nil {
+		return ipv4.Mask(net.CIDRMask(24, 32)).String()
+	}
+	return ip.Mask(net.CIDRMask(48, 128)).String()
+}
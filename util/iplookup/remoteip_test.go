@@ -0,0 +1,21 @@
+package iplookup
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestAnonymize(t *testing.T) {
+	Convey("Anonymize", t, func() {
+		Convey("Masks the last octet of an IPv4 address", func() {
+			So(Anonymize("203.0.113.195"), ShouldEqual, "203.0.113.0")
+		})
+		Convey("Masks the last 80 bits of an IPv6 address", func() {
+			So(Anonymize("2001:db8:85a3:8d3:1319:8a2e:370:7348"), ShouldEqual, "2001:db8:85a3::")
+		})
+		Convey("Leaves invalid addresses untouched", func() {
+			So(Anonymize("unknown"), ShouldEqual, "unknown")
+		})
+	})
+}
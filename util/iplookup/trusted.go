@@ -0,0 +1,118 @@
+package iplookup
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	envTrustedProxyCount = "TRUSTED_PROXY_COUNT"
+	envTrustedProxyCIDRs = "TRUSTED_PROXY_CIDRS"
+)
+
+// trustedProxies are the proxies in front of arc, whose hops are skipped from the right
+// of the X-Forwarded-For chain to find the client ip.
+type trustedProxies struct {
+	// count is the number of the trusted hops, including the immediate peer
+	count int
+	cidrs []*net.IPNet
+}
+
+// trusted is nil unless the trusted proxies are configured, in which case the trusted
+// hops are skipped from the right of the X-Forwarded-For chain and the first untrusted
+// one is taken as the client ip.
+var trusted *trustedProxies
+
+// InitTrustedProxies configures the trusted proxies from the env.
+func InitTrustedProxies() error {
+	t, err := parseTrustedProxies(os.Getenv(envTrustedProxyCount), os.Getenv(envTrustedProxyCIDRs))
+	if err != nil {
+		return err
+	}
+	trusted = t
+	return nil
+}
+
+func parseTrustedProxies(count, cidrs string) (*trustedProxies, error) {
+	if count == "" && cidrs == "" {
+		return nil, nil
+	}
+	t := &trustedProxies{}
+	if count != "" {
+		var err error
+		t.count, err = strconv.Atoi(count)
+		if err != nil || t.count < 0 {
+			return nil, fmt.Errorf("invalid value for %s: %s", envTrustedProxyCount, count)
+		}
+	}
+	for _, value := range strings.Split(cidrs, ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		// a single address is trusted as is
+		if !strings.Contains(value, "/") {
+			if ip := net.ParseIP(value); ip != nil && ip.To4() != nil {
+				value += "/32"
+			} else {
+				value += "/128"
+			}
+		}
+		_, cidr, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %v", envTrustedProxyCIDRs, err)
+		}
+		t.cidrs = append(t.cidrs, cidr)
+	}
+	return t, nil
+}
+
+func (t *trustedProxies) isTrusted(address string) bool {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+	for _, cidr := range t.cidrs {
+		if cidr.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the client ip of the X-Forwarded-For chain followed by the immediate
+// peer: the count rightmost hops are skipped, then the hops from the trusted cidrs. The
+// hops left of the client ip can be spoofed by the client and are ignored. The leftmost
+// hop is returned if all of them are trusted.
+func (t *trustedProxies) clientIP(r *http.Request) string {
+	var chain []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		for _, address := range strings.Split(header, ",") {
+			if address = strings.TrimSpace(address); address != "" {
+				chain = append(chain, address)
+			}
+		}
+	}
+	chain = append(chain, remoteAddr(r))
+
+	i := len(chain) - 1
+	for skipped := 0; skipped < t.count && i > 0; skipped++ {
+		i--
+	}
+	for i > 0 && t.isTrusted(chain[i]) {
+		i--
+	}
+	return chain[i]
+}
+
+// remoteAddr returns the address of the immediate peer without the port.
+func remoteAddr(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
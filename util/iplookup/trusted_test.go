@@ -0,0 +1,67 @@
+package iplookup
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTrustedProxies(t *testing.T) {
+	Convey("Client ip behind trusted proxies", t, func() {
+		Reset(func() { trusted = nil })
+		clientIP := func(remoteAddr string, xff ...string) string {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = remoteAddr
+			for _, header := range xff {
+				req.Header.Add("X-Forwarded-For", header)
+			}
+			return FromRequest(req)
+		}
+		configure := func(count, cidrs string) {
+			var err error
+			trusted, err = parseTrustedProxies(count, cidrs)
+			So(err, ShouldBeNil)
+		}
+
+		Convey("Without trusted proxies the first global address is taken", func() {
+			So(clientIP("10.0.0.2:1234", "1.1.1.1, 203.0.113.7"), ShouldEqual, "1.1.1.1")
+		})
+		Convey("One trusted proxy", func() {
+			configure("1", "")
+			So(clientIP("10.0.0.2:1234", "203.0.113.7"), ShouldEqual, "203.0.113.7")
+			// the prepended address is spoofed by the client
+			So(clientIP("10.0.0.2:1234", "1.1.1.1, 203.0.113.7"), ShouldEqual, "203.0.113.7")
+		})
+		Convey("Two trusted proxies", func() {
+			configure("2", "")
+			So(clientIP("10.0.0.2:1234", "203.0.113.7, 10.0.0.1"), ShouldEqual, "203.0.113.7")
+			So(clientIP("10.0.0.2:1234", "1.1.1.1, 203.0.113.7, 10.0.0.1"), ShouldEqual, "203.0.113.7")
+			So(clientIP("10.0.0.2:1234", "1.1.1.1", "203.0.113.7, 10.0.0.1"), ShouldEqual, "203.0.113.7")
+		})
+		Convey("No trusted proxies take the immediate peer", func() {
+			configure("0", "")
+			So(clientIP("198.51.100.3:1234", "1.1.1.1"), ShouldEqual, "198.51.100.3")
+		})
+		Convey("Shorter chains than the trust depth take the leftmost hop", func() {
+			configure("3", "")
+			So(clientIP("10.0.0.2:1234", "203.0.113.7"), ShouldEqual, "203.0.113.7")
+			So(clientIP("198.51.100.3:1234"), ShouldEqual, "198.51.100.3")
+		})
+		Convey("Trusted cidrs are skipped", func() {
+			configure("", "10.0.0.0/8, 198.51.100.3")
+			So(clientIP("10.0.0.2:1234", "1.1.1.1, 203.0.113.7, 198.51.100.3, 10.0.0.1"), ShouldEqual, "203.0.113.7")
+			So(clientIP("203.0.113.9:1234", "1.1.1.1"), ShouldEqual, "203.0.113.9")
+		})
+		Convey("Trusted cidrs are skipped after the trusted count", func() {
+			configure("1", "10.0.0.0/8")
+			So(clientIP("192.0.2.1:1234", "1.1.1.1, 203.0.113.7, 10.0.0.1"), ShouldEqual, "203.0.113.7")
+		})
+		Convey("Invalid values are rejected", func() {
+			_, err := parseTrustedProxies("-1", "")
+			So(err, ShouldNotBeNil)
+			_, err = parseTrustedProxies("", "10.0.0.0/33")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
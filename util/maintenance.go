@@ -0,0 +1,20 @@
+package util
+
+import "sync/atomic"
+
+// maintenanceMode is set to 1 when arc is in read-only maintenance mode
+var maintenanceMode int32
+
+// IsMaintenanceMode returns true if arc is in maintenance mode
+func IsMaintenanceMode() bool {
+	return atomic.LoadInt32(&maintenanceMode) == 1
+}
+
+// SetMaintenanceMode toggles the maintenance mode
+func SetMaintenanceMode(enabled bool) {
+	var val int32
+	if enabled {
+		val = 1
+	}
+	atomic.StoreInt32(&maintenanceMode, val)
+}
@@ -0,0 +1,60 @@
+package util
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// AllowedMethodsEnvName is the comma separated list of the HTTP methods the requests
+// can be made with.
+const AllowedMethodsEnvName = "ALLOWED_HTTP_METHODS"
+
+// DefaultAllowedMethods are the HTTP methods allowed unless configured otherwise.
+var DefaultAllowedMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+	http.MethodOptions,
+}
+
+// ParseAllowedMethods parses a comma separated list of HTTP methods, the default
+// methods are returned for an empty value.
+func ParseAllowedMethods(value string) ([]string, error) {
+	if strings.TrimSpace(value) == "" {
+		return DefaultAllowedMethods, nil
+	}
+	var methods []string
+	for _, method := range strings.Split(value, ",") {
+		method = strings.ToUpper(strings.TrimSpace(method))
+		if method == "" || strings.ContainsAny(method, " \t") {
+			return nil, fmt.Errorf("invalid HTTP method in %q", value)
+		}
+		methods = append(methods, method)
+	}
+	return methods, nil
+}
+
+// AllowedMethodsMiddleware returns a middleware that rejects the requests made with a
+// method outside of the allowed ones with a 405 and the Allow header. It can wrap the
+// whole router as well as individual routes.
+func AllowedMethodsMiddleware(methods ...string) func(http.Handler) http.Handler {
+	allowed := make(map[string]bool, len(methods))
+	for _, method := range methods {
+		allowed[method] = true
+	}
+	allow := strings.Join(methods, ", ")
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !allowed[r.Method] {
+				w.Header().Set("Allow", allow)
+				WriteBackError(w, fmt.Sprintf("method %s is not allowed", r.Method), http.StatusMethodNotAllowed)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package util
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestAllowedMethodsMiddleware(t *testing.T) {
+	Convey("Allowed methods", t, func() {
+		handler := AllowedMethodsMiddleware(DefaultAllowedMethods...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		serve := func(method string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(method, "/books/_search", nil))
+			return w
+		}
+
+		Convey("Allowed method passes", func() {
+			So(serve(http.MethodGet).Code, ShouldEqual, http.StatusOK)
+			So(serve(http.MethodPatch).Code, ShouldEqual, http.StatusOK)
+		})
+		Convey("TRACE is rejected with the Allow header", func() {
+			w := serve(http.MethodTrace)
+			So(w.Code, ShouldEqual, http.StatusMethodNotAllowed)
+			So(w.Header().Get("Allow"), ShouldEqual, "GET, HEAD, POST, PUT, PATCH, DELETE, OPTIONS")
+		})
+	})
+}
+
+func TestParseAllowedMethods(t *testing.T) {
+	Convey("Parse the allowed methods", t, func() {
+		methods, err := ParseAllowedMethods("")
+		So(err, ShouldBeNil)
+		So(methods, ShouldResemble, DefaultAllowedMethods)
+
+		methods, err = ParseAllowedMethods("get, post")
+		So(err, ShouldBeNil)
+		So(methods, ShouldResemble, []string{"GET", "POST"})
+
+		_, err = ParseAllowedMethods("GET,,POST")
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,41 @@
+package util
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// MaxQueryStringEnvName is the maximum size in bytes of the raw query string of a request.
+const MaxQueryStringEnvName = "MAX_QUERY_STRING_BYTES"
+
+// DefaultMaxQueryString is the maximum size of the query strings unless configured otherwise.
+const DefaultMaxQueryString = 16 * 1024
+
+// ParseMaxQueryString parses the maximum size of the query strings, the default size is
+// returned for an empty value.
+func ParseMaxQueryString(value string) (int, error) {
+	if value == "" {
+		return DefaultMaxQueryString, nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit <= 0 {
+		return 0, fmt.Errorf("must be a positive number of bytes: %s", value)
+	}
+	return limit, nil
+}
+
+// QueryStringLimitMiddleware returns a middleware that rejects the requests whose raw
+// query string is larger than limit bytes with a 414.
+func QueryStringLimitMiddleware(limit int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if size := len(r.URL.RawQuery); size > limit {
+				msg := fmt.Sprintf("query string of %d bytes exceeds the limit of %d bytes", size, limit)
+				WriteBackError(w, msg, http.StatusRequestURITooLong)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
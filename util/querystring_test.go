@@ -0,0 +1,47 @@
+package util
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestQueryStringLimitMiddleware(t *testing.T) {
+	Convey("Query string limit", t, func() {
+		handler := QueryStringLimitMiddleware(32)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		serve := func(query string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/books/_search?"+query, nil))
+			return w
+		}
+
+		Convey("Query under the limit passes", func() {
+			So(serve("q=title:foo&size=10").Code, ShouldEqual, http.StatusOK)
+		})
+		Convey("Query over the limit is rejected with 414", func() {
+			w := serve("q=" + strings.Repeat("a", 64))
+			So(w.Code, ShouldEqual, http.StatusRequestURITooLong)
+			So(w.Body.String(), ShouldContainSubstring, "66 bytes")
+		})
+	})
+}
+
+func TestParseMaxQueryString(t *testing.T) {
+	Convey("Parse the query string limit", t, func() {
+		limit, err := ParseMaxQueryString("")
+		So(err, ShouldBeNil)
+		So(limit, ShouldEqual, DefaultMaxQueryString)
+
+		limit, err = ParseMaxQueryString("1024")
+		So(err, ShouldBeNil)
+		So(limit, ShouldEqual, 1024)
+
+		_, err = ParseMaxQueryString("-1")
+		So(err, ShouldNotBeNil)
+	})
+}
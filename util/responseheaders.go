@@ -0,0 +1,88 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"strings"
+)
+
+// ResponseHeadersEnvName is a JSON object of the static headers set on every response.
+const ResponseHeadersEnvName = "RESPONSE_HEADERS"
+
+// ResponseHeadersOverrideEnvName makes the static headers replace the ones set by the handlers.
+const ResponseHeadersOverrideEnvName = "RESPONSE_HEADERS_OVERRIDE"
+
+// ParseResponseHeaders parses a JSON object of header name to value, e.g.
+// `{"X-Content-Type-Options": "nosniff"}`, nil is returned for an empty value.
+func ParseResponseHeaders(value string) (http.Header, error) {
+	if value == "" {
+		return nil, nil
+	}
+	var raw map[string]string
+	if err := json.Unmarshal([]byte(value), &raw); err != nil {
+		return nil, fmt.Errorf("must be a JSON object of header name to value: %v", err)
+	}
+	headers := make(http.Header)
+	for name, v := range raw {
+		if name == "" || strings.ContainsAny(name, " \t\r\n:") {
+			return nil, fmt.Errorf("invalid header name: %q", name)
+		}
+		if strings.ContainsAny(v, "\r\n") {
+			return nil, fmt.Errorf("invalid value of header %s", name)
+		}
+		headers.Set(textproto.CanonicalMIMEHeaderKey(name), v)
+	}
+	return headers, nil
+}
+
+// ResponseHeadersMiddleware returns a middleware that sets the headers on the responses
+// once the handler writes them. The headers set by the handler are kept unless override
+// is true.
+func ResponseHeadersMiddleware(headers http.Header, override bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(&responseHeadersWriter{ResponseWriter: w, headers: headers, override: override}, r)
+		})
+	}
+}
+
+// responseHeadersWriter sets the static headers right before the response header is written.
+type responseHeadersWriter struct {
+	http.ResponseWriter
+	headers     http.Header
+	override    bool
+	wroteHeader bool
+}
+
+func (w *responseHeadersWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		h := w.ResponseWriter.Header()
+		for name, values := range w.headers {
+			if _, ok := h[name]; ok && !w.override {
+				continue
+			}
+			h[name] = values
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseHeadersWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush lets the streamed responses through the middleware.
+func (w *responseHeadersWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,53 @@
+package util
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestResponseHeadersMiddleware(t *testing.T) {
+	Convey("Static response headers", t, func() {
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Write([]byte(`{"hits":{}}`))
+		}))
+		defer upstream.Close()
+		target, _ := url.Parse(upstream.URL)
+		proxy := httputil.NewSingleHostReverseProxy(target)
+
+		headers, err := ParseResponseHeaders(`{"strict-transport-security":"max-age=63072000","Cache-Control":"max-age=60"}`)
+		So(err, ShouldBeNil)
+		serve := func(override bool) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			ResponseHeadersMiddleware(headers, override)(proxy).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/books/_search", nil))
+			return w
+		}
+
+		Convey("The configured headers are set on the proxied responses", func() {
+			w := serve(false)
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(w.Header().Get("Strict-Transport-Security"), ShouldEqual, "max-age=63072000")
+			So(w.Body.String(), ShouldEqual, `{"hits":{}}`)
+		})
+		Convey("The headers set by the handler are kept", func() {
+			So(serve(false).Header().Get("Cache-Control"), ShouldEqual, "no-cache")
+		})
+		Convey("The headers set by the handler are replaced if configured to", func() {
+			So(serve(true).Header().Get("Cache-Control"), ShouldEqual, "max-age=60")
+		})
+	})
+
+	Convey("Invalid response headers", t, func() {
+		_, err := ParseResponseHeaders(`["nosniff"]`)
+		So(err, ShouldNotBeNil)
+		_, err = ParseResponseHeaders(`{"X Frame": "DENY"}`)
+		So(err, ShouldNotBeNil)
+		_, err = ParseResponseHeaders(`{"X-Frame-Options": "DENY\r\nSet-Cookie: a=b"}`)
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -3,11 +3,16 @@ package util
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
+	"os"
+	"strconv"
 	"syscall"
 	"time"
 
 	es7 "github.com/olivere/elastic/v7"
+	log "github.com/sirupsen/logrus"
+	es6 "gopkg.in/olivere/elastic.v6"
 )
 
 // Retrier is a custom Retry implementation.
@@ -38,3 +43,64 @@ func (r *Retrier) Retry(ctx context.Context, retry int, req *http.Request, resp
 	wait, stop := r.backoff.Next(retry)
 	return wait, stop, nil
 }
+
+const (
+	envWriteRetries     = "ES_WRITE_RETRIES"
+	defaultWriteRetries = 3
+)
+
+var (
+	// writeRetries is the number of times a failed write is retried
+	writeRetries = defaultWriteRetries
+	// writeBackoff decides how long a failed write waits before it's retried
+	writeBackoff es7.Backoff = es7.NewExponentialBackoff(100*time.Millisecond, 2*time.Second)
+)
+
+// InitWriteRetries reads the number of times a failed write is retried from ES_WRITE_RETRIES.
+func InitWriteRetries() error {
+	value := os.Getenv(envWriteRetries)
+	if value == "" {
+		return nil
+	}
+	retries, err := strconv.Atoi(value)
+	if err != nil || retries < 0 {
+		return fmt.Errorf("invalid value for %s: %s", envWriteRetries, value)
+	}
+	writeRetries = retries
+	return nil
+}
+
+// isRetryableWrite returns true if a write failed since elasticsearch was briefly
+// unable to handle it, i.e. with a 429 or a 503.
+func isRetryableWrite(err error) bool {
+	for _, code := range []int{http.StatusTooManyRequests, http.StatusServiceUnavailable} {
+		if es7.IsStatusCode(err, code) || es6.IsStatusCode(err, code) {
+			return true
+		}
+	}
+	return false
+}
+
+// RetryWrite calls write until it succeeds, fails with an error that isn't retryable,
+// or the retries configured with ES_WRITE_RETRIES are exhausted. The writes rejected by
+// elasticsearch with a 429 or a 503 are retried with an exponential backoff, unlike the
+// client's retrier which only retries the failed connections.
+func RetryWrite(ctx context.Context, write func() error) error {
+	retries := writeRetries
+	for retry := 0; ; retry++ {
+		err := write()
+		if err == nil || !isRetryableWrite(err) || retry >= retries {
+			return err
+		}
+		wait, ok := writeBackoff.Next(retry)
+		if !ok {
+			return err
+		}
+		log.Warnln("retrying the write in", wait, "after it failed:", err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	es7 "github.com/olivere/elastic/v7"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRetryWrite(t *testing.T) {
+	Convey("Retry the writes rejected by elasticsearch", t, func() {
+		backoff := writeBackoff
+		writeBackoff = es7.NewConstantBackoff(time.Millisecond)
+		Reset(func() { writeBackoff = backoff })
+
+		// the fake elasticsearch fails the writes with the given codes, in order
+		var attempts int32
+		serve := func(codes ...int) error {
+			atomic.StoreInt32(&attempts, 0)
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempt := atomic.AddInt32(&attempts, 1)
+				w.Header().Set("Content-Type", "application/json")
+				if int(attempt) <= len(codes) {
+					w.WriteHeader(codes[attempt-1])
+					fmt.Fprintf(w, `{"error":{"type":"rejected"},"status":%d}`, codes[attempt-1])
+					return
+				}
+				w.Write([]byte(`{"_index":"users","_id":"foo","result":"created"}`))
+			}))
+			defer ts.Close()
+			client, err := es7.NewSimpleClient(es7.SetURL(ts.URL))
+			So(err, ShouldBeNil)
+			return RetryWrite(context.Background(), func() error {
+				_, err := client.Index().Index("users").Id("foo").BodyJson(map[string]string{"username": "foo"}).Do(context.Background())
+				return err
+			})
+		}
+
+		Convey("A write succeeding on the second attempt after a 503 succeeds", func() {
+			So(serve(http.StatusServiceUnavailable), ShouldBeNil)
+			So(atomic.LoadInt32(&attempts), ShouldEqual, 2)
+		})
+		Convey("A write rejected with a 429 is retried", func() {
+			So(serve(http.StatusTooManyRequests, http.StatusTooManyRequests), ShouldBeNil)
+			So(atomic.LoadInt32(&attempts), ShouldEqual, 3)
+		})
+		Convey("A non-retryable error fails immediately", func() {
+			err := serve(http.StatusBadRequest)
+			So(es7.IsStatusCode(err, http.StatusBadRequest), ShouldBeTrue)
+			So(atomic.LoadInt32(&attempts), ShouldEqual, 1)
+		})
+		Convey("The write fails once the retries are exhausted", func() {
+			retries := writeRetries
+			codes := make([]int, retries+1)
+			for i := range codes {
+				codes[i] = http.StatusServiceUnavailable
+			}
+			err := serve(codes...)
+			So(es7.IsStatusCode(err, http.StatusServiceUnavailable), ShouldBeTrue)
+			So(atomic.LoadInt32(&attempts), ShouldEqual, retries+1)
+		})
+	})
+
+	Convey("Read the write retries", t, func() {
+		Reset(func() {
+			os.Unsetenv(envWriteRetries)
+			writeRetries = defaultWriteRetries
+		})
+
+		Convey("An invalid value is rejected", func() {
+			os.Setenv(envWriteRetries, "-1")
+			So(InitWriteRetries(), ShouldNotBeNil)
+			So(writeRetries, ShouldEqual, defaultWriteRetries)
+		})
+		Convey("A valid value is used", func() {
+			os.Setenv(envWriteRetries, "5")
+			So(InitWriteRetries(), ShouldBeNil)
+			So(writeRetries, ShouldEqual, 5)
+		})
+	})
+}
@@ -0,0 +1,32 @@
+package util
+
+import (
+	"strings"
+	"sync/atomic"
+)
+
+// caseInsensitiveUsernames is set to 1 when the usernames are matched case-insensitively
+var caseInsensitiveUsernames int32
+
+// IsCaseInsensitiveUsernames returns true if the usernames are matched case-insensitively
+func IsCaseInsensitiveUsernames() bool {
+	return atomic.LoadInt32(&caseInsensitiveUsernames) == 1
+}
+
+// SetCaseInsensitiveUsernames toggles the case-insensitive matching of the usernames
+func SetCaseInsensitiveUsernames(enabled bool) {
+	var val int32
+	if enabled {
+		val = 1
+	}
+	atomic.StoreInt32(&caseInsensitiveUsernames, val)
+}
+
+// NormalizeUsername returns the username in the form it is stored and looked up with,
+// i.e. lowercased if the usernames are matched case-insensitively.
+func NormalizeUsername(username string) string {
+	if IsCaseInsensitiveUsernames() {
+		return strings.ToLower(username)
+	}
+	return username
+}
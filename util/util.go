@@ -14,6 +14,7 @@ import (
 	"net/http"
 	"os"
 	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -211,22 +212,72 @@ var (
 // as well as establishing an end-to-end request timeout.
 func HTTPClient() *http.Client {
 	once.Do(func() {
-		var netTransport = &http.Transport{
-			DialContext: (&net.Dialer{
-				Timeout: 10 * time.Second,
-			}).DialContext,
-			TLSHandshakeTimeout: 10 * time.Second,
-			TLSClientConfig:     &tls.Config{InsecureSkipVerify: true},
+		transport, err := newHTTPTransport()
+		if err != nil {
+			// the env vars are validated at startup by InitHTTPClient
+			log.Errorln(err, ", using the default idle connection pool")
+			transport = defaultHTTPTransport()
 		}
 		var netClient = &http.Client{
 			Timeout:   time.Minute * 2,
-			Transport: netTransport,
+			Transport: transport,
 		}
 		client = netClient
 	})
 	return client
 }
 
+// InitHTTPClient validates the HTTP_MAX_IDLE_CONNS, HTTP_MAX_IDLE_CONNS_PER_HOST and
+// HTTP_IDLE_CONN_TIMEOUT env vars tuning the idle connection pool of HTTPClient.
+func InitHTTPClient() error {
+	_, err := newHTTPTransport()
+	return err
+}
+
+// defaultHTTPTransport returns the transport used by HTTPClient with the idle connection
+// pool of http.DefaultTransport, i.e. 100 connections kept for 90s.
+func defaultHTTPTransport() *http.Transport {
+	return &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 10 * time.Second,
+		}).DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+		TLSClientConfig:     &tls.Config{InsecureSkipVerify: true},
+		MaxIdleConns:        100,
+		IdleConnTimeout:     90 * time.Second,
+	}
+}
+
+// newHTTPTransport returns the transport used by HTTPClient, with the idle connection
+// pool tuned by the HTTP_MAX_IDLE_CONNS, HTTP_MAX_IDLE_CONNS_PER_HOST and
+// HTTP_IDLE_CONN_TIMEOUT (e.g. "90s") env vars, otherwise the defaults of
+// http.DefaultTransport apply.
+func newHTTPTransport() (*http.Transport, error) {
+	netTransport := defaultHTTPTransport()
+	if value := os.Getenv("HTTP_MAX_IDLE_CONNS"); value != "" {
+		maxIdleConns, err := strconv.Atoi(value)
+		if err != nil || maxIdleConns < 0 {
+			return nil, fmt.Errorf("invalid value for HTTP_MAX_IDLE_CONNS: %s", value)
+		}
+		netTransport.MaxIdleConns = maxIdleConns
+	}
+	if value := os.Getenv("HTTP_MAX_IDLE_CONNS_PER_HOST"); value != "" {
+		maxIdleConnsPerHost, err := strconv.Atoi(value)
+		if err != nil || maxIdleConnsPerHost < 0 {
+			return nil, fmt.Errorf("invalid value for HTTP_MAX_IDLE_CONNS_PER_HOST: %s", value)
+		}
+		netTransport.MaxIdleConnsPerHost = maxIdleConnsPerHost
+	}
+	if value := os.Getenv("HTTP_IDLE_CONN_TIMEOUT"); value != "" {
+		idleConnTimeout, err := time.ParseDuration(value)
+		if err != nil || idleConnTimeout < 0 {
+			return nil, fmt.Errorf("invalid value for HTTP_IDLE_CONN_TIMEOUT: %s", value)
+		}
+		netTransport.IdleConnTimeout = idleConnTimeout
+	}
+	return netTransport, nil
+}
+
 // IntervalForRange returns the interval in seconds for a given time range.
 // It expects the time arguments in RFC3339 format. The interval is calculated by:
 // I = (25 * D) seconds, where D = duration (in hours), I = interval.
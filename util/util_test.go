@@ -0,0 +1,42 @@
+package util
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestHTTPTransport(t *testing.T) {
+	Convey("HTTP transport", t, func() {
+		Convey("Uses the configured pool sizes", func() {
+			os.Setenv("HTTP_MAX_IDLE_CONNS", "500")
+			os.Setenv("HTTP_MAX_IDLE_CONNS_PER_HOST", "100")
+			os.Setenv("HTTP_IDLE_CONN_TIMEOUT", "45s")
+			defer os.Unsetenv("HTTP_MAX_IDLE_CONNS")
+			defer os.Unsetenv("HTTP_MAX_IDLE_CONNS_PER_HOST")
+			defer os.Unsetenv("HTTP_IDLE_CONN_TIMEOUT")
+
+			transport, err := newHTTPTransport()
+			So(err, ShouldBeNil)
+			So(transport.MaxIdleConns, ShouldEqual, 500)
+			So(transport.MaxIdleConnsPerHost, ShouldEqual, 100)
+			So(transport.IdleConnTimeout, ShouldEqual, 45*time.Second)
+		})
+		Convey("Uses the net/http defaults", func() {
+			transport, err := newHTTPTransport()
+			So(err, ShouldBeNil)
+			So(transport.MaxIdleConns, ShouldEqual, 100)
+			So(transport.IdleConnTimeout, ShouldEqual, 90*time.Second)
+		})
+		Convey("Rejects invalid values", func() {
+			for _, env := range []string{"HTTP_MAX_IDLE_CONNS", "HTTP_MAX_IDLE_CONNS_PER_HOST", "HTTP_IDLE_CONN_TIMEOUT"} {
+				os.Setenv(env, "-1")
+				So(InitHTTPClient(), ShouldNotBeNil)
+				os.Unsetenv(env)
+			}
+			So(InitHTTPClient(), ShouldBeNil)
+		})
+	})
+}